@@ -6,11 +6,25 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/clef/go-sdk/clef"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/propagation"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 const defaultBaseURL = "http://localhost:3000"
@@ -19,6 +33,24 @@ type ConduitClient struct {
 	BaseURL string
 	Token   string
 	HTTP    *http.Client
+
+	propagator   propagation.TextMapPropagator
+	protobuf     bool
+	grpc         *clef.GRPCClient
+	reconnect    *reconnectPolicy
+	interceptors []Interceptor
+}
+
+// ClientOption configures a ConduitClient at construction time.
+type ClientOption func(*ConduitClient)
+
+// WithPropagator injects trace context from each call's context into the
+// outgoing request headers, e.g. propagation.TraceContext{} for W3C
+// traceparent headers.
+func WithPropagator(prop propagation.TextMapPropagator) ClientOption {
+	return func(c *ConduitClient) {
+		c.propagator = prop
+	}
 }
 
 type User struct {
@@ -30,10 +62,15 @@ type User struct {
 }
 
 type Article struct {
-	Slug        string `json:"slug"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Body        string `json:"body"`
+	Slug        string   `json:"slug"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Body        string   `json:"body"`
+	TagList     []string `json:"tagList,omitempty"`
+	// Favorited and FavoritesCount reflect the current user's view and
+	// are updated by Favorite and Unfavorite.
+	Favorited      bool `json:"favorited"`
+	FavoritesCount int  `json:"favoritesCount"`
 }
 
 type UserResponse struct {
@@ -44,70 +81,595 @@ type ArticleResponse struct {
 	Article Article `json:"article"`
 }
 
+// FavoriteArticle is the reply to Favorite: the article with Favorited
+// set and the updated FavoritesCount.
+type FavoriteArticle = ArticleResponse
+
+// UnfavoriteArticle is the reply to Unfavorite: the article with
+// Favorited cleared and the updated FavoritesCount.
+type UnfavoriteArticle = ArticleResponse
+
+type ArticleListResponse struct {
+	Articles      []Article `json:"articles"`
+	ArticlesCount int       `json:"articlesCount"`
+}
+
+type TagsResponse struct {
+	Tags []string `json:"tags"`
+}
+
 type HealthResponse struct {
 	Status   string `json:"status"`
 	Concepts int    `json:"concepts"`
 	Syncs    int    `json:"syncs"`
+	// Healthy is reported by plain Clef servers (GET /health), which
+	// have no status field.
+	Healthy bool `json:"healthy,omitempty"`
+	// Latency is the client-measured round-trip time of the check.
+	Latency time.Duration `json:"-"`
+}
+
+// WithDebugLogger writes every request and response to w: method, URL,
+// headers, and bodies, plus the response status. The Authorization token
+// is masked.
+func WithDebugLogger(w io.Writer) ClientOption {
+	return func(c *ConduitClient) {
+		c.wrapTransport(func(next http.RoundTripper) http.RoundTripper {
+			return &debugTransport{next: next, w: w}
+		})
+	}
+}
+
+// WithRequestHook calls fn with each outgoing request just before it is
+// sent.
+func WithRequestHook(fn func(req *http.Request)) ClientOption {
+	return func(c *ConduitClient) {
+		c.wrapTransport(func(next http.RoundTripper) http.RoundTripper {
+			return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				fn(req)
+				return next.RoundTrip(req)
+			})
+		})
+	}
+}
+
+// WithResponseHook calls fn with each response before the client reads
+// its body.
+func WithResponseHook(fn func(resp *http.Response)) ClientOption {
+	return func(c *ConduitClient) {
+		c.wrapTransport(func(next http.RoundTripper) http.RoundTripper {
+			return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				resp, err := next.RoundTrip(req)
+				if err == nil {
+					fn(resp)
+				}
+				return resp, err
+			})
+		})
+	}
+}
+
+// Interceptor wraps each HTTP call the client makes. It may modify req,
+// call next zero or more times, and inspect or replace the response.
+type Interceptor func(req *http.Request, next func(*http.Request) (*http.Response, error)) (*http.Response, error)
+
+// WithInterceptors runs interceptors around every HTTP call, outermost
+// first: the first interceptor sees the request before the second, and
+// the response after it.
+func WithInterceptors(interceptors ...Interceptor) ClientOption {
+	return func(c *ConduitClient) {
+		c.interceptors = append(c.interceptors, interceptors...)
+	}
+}
+
+// LoggingInterceptor writes one line per call to w with the method, path,
+// status or error, and duration.
+func LoggingInterceptor(w io.Writer) Interceptor {
+	return func(req *http.Request, next func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+		start := time.Now()
+		resp, err := next(req)
+		d := time.Since(start).Round(time.Microsecond)
+		if err != nil {
+			fmt.Fprintf(w, "%s %s error: %v (%s)\n", req.Method, req.URL.Path, err, d)
+		} else {
+			fmt.Fprintf(w, "%s %s %d (%s)\n", req.Method, req.URL.Path, resp.StatusCode, d)
+		}
+		return resp, err
+	}
+}
+
+// TimingInterceptor reports the duration of every call to record, failed
+// calls included.
+func TimingInterceptor(record func(method, path string, d time.Duration)) Interceptor {
+	return func(req *http.Request, next func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+		start := time.Now()
+		resp, err := next(req)
+		record(req.Method, req.URL.Path, time.Since(start))
+		return resp, err
+	}
+}
+
+// do sends req through the interceptor chain.
+func (c *ConduitClient) do(req *http.Request) (*http.Response, error) {
+	next := c.HTTP.Do
+	for i := len(c.interceptors) - 1; i >= 0; i-- {
+		interceptor, inner := c.interceptors[i], next
+		next = func(req *http.Request) (*http.Response, error) {
+			return interceptor(req, inner)
+		}
+	}
+	return next(req)
+}
+
+func (c *ConduitClient) wrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	next := c.HTTP.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	c.HTTP.Transport = wrap(next)
+}
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
 }
 
-func NewClient(baseURL string) *ConduitClient {
+type debugTransport struct {
+	next http.RoundTripper
+	w    io.Writer
+}
+
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	fmt.Fprintf(t.w, "--> %s %s\n", req.Method, req.URL)
+	writeHeaders(t.w, req.Header)
+	if req.Body != nil && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err == nil {
+			data, _ := io.ReadAll(body)
+			writeBody(t.w, req.Header, data)
+		}
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		fmt.Fprintf(t.w, "<-- error: %v\n", err)
+		return nil, err
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+
+	fmt.Fprintf(t.w, "<-- %s\n", resp.Status)
+	writeHeaders(t.w, resp.Header)
+	writeBody(t.w, resp.Header, data)
+	return resp, nil
+}
+
+func writeHeaders(w io.Writer, h http.Header) {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range h[name] {
+			if name == "Authorization" {
+				v = maskAuthorization(v)
+			}
+			fmt.Fprintf(w, "    %s: %s\n", name, v)
+		}
+	}
+}
+
+func writeBody(w io.Writer, h http.Header, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	if h.Get("Content-Type") == protobufContentType {
+		fmt.Fprintf(w, "    <%d bytes protobuf>\n", len(data))
+		return
+	}
+	fmt.Fprintf(w, "    %s\n", bytes.TrimSpace(data))
+}
+
+// maskAuthorization keeps the scheme ("Token", "Bearer") and hides the
+// credential.
+func maskAuthorization(v string) string {
+	if scheme, _, ok := strings.Cut(v, " "); ok {
+		return scheme + " ***"
+	}
+	return "***"
+}
+
+func NewClient(baseURL string, opts ...ClientOption) *ConduitClient {
 	if baseURL == "" {
 		baseURL = defaultBaseURL
 	}
-	return &ConduitClient{
+	c := &ConduitClient{
 		BaseURL: baseURL,
 		HTTP:    &http.Client{},
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
-func (c *ConduitClient) request(method, path string, body interface{}) ([]byte, error) {
-	var bodyReader io.Reader
+// NewProtobufConduitClient creates a client that sends and accepts
+// application/protobuf bodies (google.protobuf.Struct messages) instead of
+// JSON. Responses are converted back to JSON internally, so every method
+// behaves exactly as with NewClient.
+func NewProtobufConduitClient(baseURL string) *ConduitClient {
+	c := NewClient(baseURL)
+	c.protobuf = true
+	return c
+}
+
+// webConcept is the concept that turns API requests into syncs
+// (Web/request → ... → Web/respond) on the Clef side.
+const webConcept = "urn:clef/Web"
+
+// NewGRPCConduitClient creates a client that reaches the Conduit API over
+// the Clef gRPC transport at addr instead of HTTP. Each API call becomes a
+// Web/request invocation with the method, path, body and token as input;
+// the completion carries the response status and body. opts must include
+// transport credentials.
+func NewGRPCConduitClient(addr string, opts ...grpc.DialOption) (*ConduitClient, error) {
+	gc, err := clef.DialGRPC(addr, opts...)
+	if err != nil {
+		return nil, err
+	}
+	c := NewClient(addr)
+	c.grpc = gc
+	return c, nil
+}
+
+func (c *ConduitClient) request(ctx context.Context, method, path string, body interface{}) ([]byte, error) {
+	if c.grpc != nil {
+		return c.requestGRPC(ctx, method, path, body)
+	}
+
+	var encoded []byte
 	if body != nil {
-		jsonBody, err := json.Marshal(body)
-		if err != nil {
+		var err error
+		if encoded, err = c.encodeBody(body); err != nil {
 			return nil, err
 		}
-		bodyReader = bytes.NewReader(jsonBody)
 	}
 
-	req, err := http.NewRequest(method, c.BaseURL+path, bodyReader)
+	resp, data, err := c.send(ctx, method, path, encoded)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(data))
+	}
+
+	if c.protobuf && resp.Header.Get("Content-Type") == protobufContentType {
+		return protobufToJSON(data)
+	}
+	return data, nil
+}
+
+// send performs one HTTP call with the client's headers and returns the
+// response with its body already read. With WithAutoReconnect, a
+// connection failure waits for the server to come back and retries once.
+func (c *ConduitClient) send(ctx context.Context, method, path string, body []byte) (*http.Response, []byte, error) {
+	resp, data, err := c.sendOnce(ctx, method, path, body)
+	if err == nil || c.reconnect == nil || !isConnectionError(ctx, err) {
+		return resp, data, err
+	}
+	if perr := c.awaitServer(ctx); perr != nil {
+		return nil, nil, fmt.Errorf("%w (reconnect failed: %v)", err, perr)
+	}
+	return c.sendOnce(ctx, method, path, body)
+}
+
+func (c *ConduitClient) sendOnce(ctx context.Context, method, path string, body []byte) (*http.Response, []byte, error) {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, bodyReader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if c.protobuf {
+		req.Header.Set("Content-Type", protobufContentType)
+		req.Header.Set("Accept", protobufContentType)
+	} else {
+		req.Header.Set("Content-Type", "application/json")
+	}
 	if c.Token != "" {
 		req.Header.Set("Authorization", "Token "+c.Token)
 	}
+	if c.propagator != nil {
+		c.propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+	}
 
-	resp, err := c.HTTP.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer resp.Body.Close()
 
 	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, data, nil
+}
+
+// InvokeRaw sends inv straight to the Clef POST /invoke endpoint (or the
+// gRPC Invoke method) and returns the completion. An empty inv.ID is
+// filled with a new UUID, and an empty inv.Flow reuses that ID, so callers
+// that need idempotent retries can set the ID themselves and resend it.
+// Error variants are returned as completions, not errors; err is only set
+// when no completion could be read.
+func (c *ConduitClient) InvokeRaw(ctx context.Context, inv clef.ActionInvocation) (*clef.ActionCompletion, error) {
+	inv = withInvocationIDs(inv)
+	if c.grpc != nil {
+		completion, err := c.grpc.Invoke(ctx, inv)
+		if err != nil {
+			return nil, err
+		}
+		return &completion, nil
+	}
+
+	resp, data, err := c.sendInvocation(ctx, inv)
 	if err != nil {
 		return nil, err
 	}
 
+	unmarshal := json.Unmarshal
+	if c.protobuf {
+		unmarshal = clef.UnmarshalProto
+	}
+	var completion clef.ActionCompletion
+	if err := unmarshal(data, &completion); err != nil || completion.ID == "" {
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return &completion, nil
+}
+
+// InvokeRawBytes is InvokeRaw for actions that answer with a
+// clef.BinaryOutput, such as image or PDF renderers. It returns the
+// response body and its Content-Type as sent. An error status is
+// returned as an error. It is not available over gRPC.
+func (c *ConduitClient) InvokeRawBytes(ctx context.Context, inv clef.ActionInvocation) (contentType string, data []byte, err error) {
+	if c.grpc != nil {
+		return "", nil, errors.New("binary output is not supported over gRPC")
+	}
+	resp, data, err := c.sendInvocation(ctx, withInvocationIDs(inv))
+	if err != nil {
+		return "", nil, err
+	}
 	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(data))
+		return "", nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
 	}
+	return resp.Header.Get("Content-Type"), data, nil
+}
 
-	return data, nil
+// withInvocationIDs fills an empty inv.ID with a new UUID and an empty
+// inv.Flow with the ID.
+func withInvocationIDs(inv clef.ActionInvocation) clef.ActionInvocation {
+	if inv.ID == "" {
+		inv.ID = uuid.NewString()
+	}
+	if inv.Flow == "" {
+		inv.Flow = inv.ID
+	}
+	return inv
+}
+
+// sendInvocation posts inv to /invoke in the client's encoding.
+func (c *ConduitClient) sendInvocation(ctx context.Context, inv clef.ActionInvocation) (*http.Response, []byte, error) {
+	marshal := json.Marshal
+	if c.protobuf {
+		marshal = clef.MarshalProto
+	}
+	body, err := marshal(&inv)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.send(ctx, "POST", "/invoke", body)
+}
+
+func (c *ConduitClient) requestGRPC(ctx context.Context, method, path string, body interface{}) ([]byte, error) {
+	input := map[string]any{"method": method, "path": path}
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		var decoded any
+		if err := json.Unmarshal(raw, &decoded); err != nil {
+			return nil, err
+		}
+		input["body"] = decoded
+	}
+	if c.Token != "" {
+		input["token"] = c.Token
+	}
+
+	completion, err := c.grpc.Invoke(ctx, clef.ActionInvocation{
+		Concept: webConcept,
+		Action:  "request",
+		Input:   input,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if completion.Variant != "ok" {
+		return nil, fmt.Errorf("%s: %v", completion.Variant, completion.Output["message"])
+	}
+	respBody, err := json.Marshal(completion.Output["body"])
+	if err != nil {
+		return nil, err
+	}
+	if status, _ := completion.Output["status"].(float64); status >= 400 {
+		return nil, fmt.Errorf("HTTP %d: %s", int(status), respBody)
+	}
+	return respBody, nil
+}
+
+const protobufContentType = "application/protobuf"
+
+func (c *ConduitClient) encodeBody(body interface{}) ([]byte, error) {
+	jsonBody, err := json.Marshal(body)
+	if err != nil || !c.protobuf {
+		return jsonBody, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(jsonBody, &m); err != nil {
+		return nil, err
+	}
+	st, err := structpb.NewStruct(m)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(st)
+}
+
+func protobufToJSON(data []byte) ([]byte, error) {
+	var st structpb.Struct
+	if err := proto.Unmarshal(data, &st); err != nil {
+		return nil, err
+	}
+	return json.Marshal(st.AsMap())
 }
 
-func (c *ConduitClient) Health() (*HealthResponse, error) {
-	data, err := c.request("GET", "/api/health", nil)
+func (c *ConduitClient) Health(ctx context.Context) (*HealthResponse, error) {
+	start := time.Now()
+	data, err := c.request(ctx, "GET", "/api/health", nil)
 	if err != nil {
 		return nil, err
 	}
-	var resp HealthResponse
+	resp := HealthResponse{Latency: time.Since(start)}
 	return &resp, json.Unmarshal(data, &resp)
 }
 
-func (c *ConduitClient) Register(username, email, password string) (*UserResponse, error) {
+// fleetTimeout bounds each node's health check in CheckFleet.
+const fleetTimeout = 5 * time.Second
+
+// CheckFleet checks the health of every node in urls concurrently and
+// returns each node's response keyed by URL. Nodes are asked for
+// GET /api/health, falling back to the Clef GET /health when that is not
+// found. A node that does not answer within five seconds, or answers
+// with something other than a health report, maps to nil.
+func CheckFleet(ctx context.Context, urls []string) map[string]*HealthResponse {
+	results := make([]*HealthResponse, len(urls))
+	var g errgroup.Group
+	for i, url := range urls {
+		g.Go(func() error {
+			ctx, cancel := context.WithTimeout(ctx, fleetTimeout)
+			defer cancel()
+			results[i], _ = NewClient(url).probeHealth(ctx)
+			return nil
+		})
+	}
+	g.Wait()
+
+	fleet := make(map[string]*HealthResponse, len(urls))
+	for i, url := range urls {
+		fleet[url] = results[i]
+	}
+	return fleet
+}
+
+// probeHealth is Health with the /health fallback used by CheckFleet and
+// Ping. Unlike Health it keeps the report from nodes that answer 503,
+// since an unhealthy node is still reachable. It never reconnects.
+func (c *ConduitClient) probeHealth(ctx context.Context) (*HealthResponse, error) {
+	start := time.Now()
+	resp, data, err := c.sendOnce(ctx, "GET", "/api/health", nil)
+	if err == nil && resp.StatusCode == http.StatusNotFound {
+		resp, data, err = c.sendOnce(ctx, "GET", "/health", nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	health := HealthResponse{Latency: time.Since(start)}
+	if err := json.Unmarshal(data, &health); err != nil {
+		return nil, fmt.Errorf("HTTP %d: not a health report: %w", resp.StatusCode, err)
+	}
+	if health.Status == "" {
+		health.Status = "ok"
+		if !health.Healthy {
+			health.Status = "unhealthy"
+		}
+	}
+	return &health, nil
+}
+
+// Ping checks that the server is reachable and healthy, using
+// GET /api/health or, on plain Clef servers, GET /health.
+func (c *ConduitClient) Ping(ctx context.Context) error {
+	health, err := c.probeHealth(ctx)
+	if err != nil {
+		return err
+	}
+	if health.Status != "ok" {
+		return fmt.Errorf("server is %s", health.Status)
+	}
+	return nil
+}
+
+// reconnectPolicy is set by WithAutoReconnect.
+type reconnectPolicy struct {
+	maxAttempts int
+	backoff     time.Duration
+}
+
+// WithAutoReconnect makes HTTP requests that fail to reach the server
+// (connection refused or reset, not an HTTP error status) wait for it to
+// come back: the client pings up to maxAttempts times, sleeping backoff
+// before the first ping and doubling it after each failure, then retries
+// the original request once. gRPC clients reconnect on their own and
+// ignore this option.
+func WithAutoReconnect(maxAttempts int, backoff time.Duration) ClientOption {
+	maxAttempts = max(maxAttempts, 1)
+	return func(c *ConduitClient) {
+		c.reconnect = &reconnectPolicy{maxAttempts: maxAttempts, backoff: backoff}
+	}
+}
+
+// awaitServer pings until the server is healthy or the attempts run out.
+func (c *ConduitClient) awaitServer(ctx context.Context) error {
+	delay := c.reconnect.backoff
+	var err error
+	for attempt := 0; attempt < c.reconnect.maxAttempts; attempt++ {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if err = c.Ping(ctx); err == nil {
+			return nil
+		}
+		delay *= 2
+	}
+	return fmt.Errorf("server not healthy after %d attempts: %w", c.reconnect.maxAttempts, err)
+}
+
+// isConnectionError reports whether err means the server could not be
+// reached, as opposed to the caller's context ending.
+func isConnectionError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func (c *ConduitClient) Register(ctx context.Context, username, email, password string) (*UserResponse, error) {
 	body := map[string]interface{}{
 		"user": map[string]string{
 			"username": username,
@@ -115,7 +677,7 @@ func (c *ConduitClient) Register(username, email, password string) (*UserRespons
 			"password": password,
 		},
 	}
-	data, err := c.request("POST", "/api/users", body)
+	data, err := c.request(ctx, "POST", "/api/users", body)
 	if err != nil {
 		return nil, err
 	}
@@ -127,14 +689,36 @@ func (c *ConduitClient) Register(username, email, password string) (*UserRespons
 	return &resp, nil
 }
 
-func (c *ConduitClient) Login(email, password string) (*UserResponse, error) {
+// Logout ends the session with DELETE /api/users/login and clears the
+// token. The token is cleared even if the server call fails, so the
+// client never keeps using a session the caller meant to end.
+func (c *ConduitClient) Logout(ctx context.Context) error {
+	_, err := c.request(ctx, "DELETE", "/api/users/login", nil)
+	c.Token = ""
+	return err
+}
+
+// IsAuthenticated reports whether the client holds a token.
+func (c *ConduitClient) IsAuthenticated() bool {
+	return c.Token != ""
+}
+
+// WithToken returns a shallow copy of the client that sends token, for
+// acting as several users at once. The original client is unchanged.
+func (c *ConduitClient) WithToken(token string) *ConduitClient {
+	clone := *c
+	clone.Token = token
+	return &clone
+}
+
+func (c *ConduitClient) Login(ctx context.Context, email, password string) (*UserResponse, error) {
 	body := map[string]interface{}{
 		"user": map[string]string{
 			"email":    email,
 			"password": password,
 		},
 	}
-	data, err := c.request("POST", "/api/users/login", body)
+	data, err := c.request(ctx, "POST", "/api/users/login", body)
 	if err != nil {
 		return nil, err
 	}
@@ -146,15 +730,20 @@ func (c *ConduitClient) Login(email, password string) (*UserResponse, error) {
 	return &resp, nil
 }
 
-func (c *ConduitClient) CreateArticle(title, description, body string) (*ArticleResponse, error) {
-	reqBody := map[string]interface{}{
-		"article": map[string]string{
-			"title":       title,
-			"description": description,
-			"body":        body,
-		},
+func (c *ConduitClient) CreateArticle(ctx context.Context, title, description, body string, tags ...string) (*ArticleResponse, error) {
+	return c.CreateArticleWithTags(ctx, title, description, body, tags)
+}
+
+func (c *ConduitClient) CreateArticleWithTags(ctx context.Context, title, description, body string, tags []string) (*ArticleResponse, error) {
+	article := map[string]interface{}{
+		"title":       title,
+		"description": description,
+		"body":        body,
+	}
+	if len(tags) > 0 {
+		article["tagList"] = tags
 	}
-	data, err := c.request("POST", "/api/articles", reqBody)
+	data, err := c.request(ctx, "POST", "/api/articles", map[string]interface{}{"article": article})
 	if err != nil {
 		return nil, err
 	}
@@ -162,34 +751,108 @@ func (c *ConduitClient) CreateArticle(title, description, body string) (*Article
 	return &resp, json.Unmarshal(data, &resp)
 }
 
-func (c *ConduitClient) Follow(username string) error {
-	_, err := c.request("POST", "/api/profiles/"+username+"/follow", nil)
+// Feed returns a page of articles by users the current user follows.
+func (c *ConduitClient) Feed(ctx context.Context, limit, offset int) (*ArticleListResponse, error) {
+	data, err := c.request(ctx, "GET", fmt.Sprintf("/api/articles/feed?limit=%d&offset=%d", limit, offset), nil)
+	if err != nil {
+		return nil, err
+	}
+	var resp ArticleListResponse
+	return &resp, json.Unmarshal(data, &resp)
+}
+
+// FeedCursor pages through the feed, tracking the offset for the caller.
+type FeedCursor struct {
+	client   *ConduitClient
+	pageSize int
+	offset   int
+	done     bool
+}
+
+// NewFeedCursor returns a cursor over client's feed that fetches
+// pageSize articles per call to Next.
+func NewFeedCursor(client *ConduitClient, pageSize int) *FeedCursor {
+	return &FeedCursor{client: client, pageSize: pageSize}
+}
+
+// Next fetches the next page and reports whether more may follow. The
+// feed is exhausted after a short page, or once the offset reaches the
+// server's articlesCount; further calls then return (nil, false, nil).
+// On error the offset is unchanged, so Next can be retried.
+func (f *FeedCursor) Next(ctx context.Context) (*ArticleListResponse, bool, error) {
+	if f.done {
+		return nil, false, nil
+	}
+	page, err := f.client.Feed(ctx, f.pageSize, f.offset)
+	if err != nil {
+		return nil, true, err
+	}
+	f.offset += len(page.Articles)
+	if len(page.Articles) < f.pageSize || (page.ArticlesCount > 0 && f.offset >= page.ArticlesCount) {
+		f.done = true
+	}
+	return page, !f.done, nil
+}
+
+func (c *ConduitClient) GetTags(ctx context.Context) (*TagsResponse, error) {
+	data, err := c.request(ctx, "GET", "/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	var resp TagsResponse
+	return &resp, json.Unmarshal(data, &resp)
+}
+
+func (c *ConduitClient) Follow(ctx context.Context, username string) error {
+	_, err := c.request(ctx, "POST", "/api/profiles/"+username+"/follow", nil)
 	return err
 }
 
-func (c *ConduitClient) Favorite(slug string) error {
-	_, err := c.request("POST", "/api/articles/"+slug+"/favorite", nil)
+func (c *ConduitClient) Unfollow(ctx context.Context, username string) error {
+	_, err := c.request(ctx, "DELETE", "/api/profiles/"+username+"/follow", nil)
 	return err
 }
 
+// Favorite marks the article as a favorite of the current user and
+// returns it with the updated favorites count.
+func (c *ConduitClient) Favorite(ctx context.Context, slug string) (*FavoriteArticle, error) {
+	return c.favorite(ctx, "POST", slug)
+}
+
+// Unfavorite removes the article from the current user's favorites and
+// returns it with the updated favorites count.
+func (c *ConduitClient) Unfavorite(ctx context.Context, slug string) (*UnfavoriteArticle, error) {
+	return c.favorite(ctx, "DELETE", slug)
+}
+
+func (c *ConduitClient) favorite(ctx context.Context, method, slug string) (*ArticleResponse, error) {
+	data, err := c.request(ctx, method, "/api/articles/"+slug+"/favorite", nil)
+	if err != nil {
+		return nil, err
+	}
+	var resp ArticleResponse
+	return &resp, json.Unmarshal(data, &resp)
+}
+
 func main() {
 	baseURL := os.Getenv("CONDUIT_URL")
-	client := NewClient(baseURL)
+	client := NewClient(baseURL, WithPropagator(propagation.TraceContext{}))
+	ctx := context.Background()
 
 	fmt.Println("Conduit Go SDK Client")
 	fmt.Println("=====================")
 
 	// Health check
-	health, err := client.Health()
+	health, err := client.Health(ctx)
 	if err != nil {
 		fmt.Printf("Server unreachable: %v\n", err)
 		os.Exit(1)
 	}
-	fmt.Printf("Server: %s | Concepts: %d | Syncs: %d\n\n", health.Status, health.Concepts, health.Syncs)
+	fmt.Printf("Server: %s | Concepts: %d | Syncs: %d | Latency: %s\n\n", health.Status, health.Concepts, health.Syncs, health.Latency)
 
 	// Register
 	fmt.Println("1. Registering user...")
-	user, err := client.Register("go-user", "go@conduit.io", "password123")
+	user, err := client.Register(ctx, "go-user", "go@conduit.io", "password123")
 	if err != nil {
 		fmt.Printf("   Failed: %v\n", err)
 		os.Exit(1)
@@ -198,7 +861,7 @@ func main() {
 
 	// Login
 	fmt.Println("2. Logging in...")
-	login, err := client.Login("go@conduit.io", "password123")
+	login, err := client.Login(ctx, "go@conduit.io", "password123")
 	if err != nil {
 		fmt.Printf("   Failed: %v\n", err)
 		os.Exit(1)
@@ -208,9 +871,11 @@ func main() {
 	// Create article
 	fmt.Println("3. Creating article...")
 	article, err := client.CreateArticle(
+		ctx,
 		"Clef from Go",
 		"Using the Go SDK to interact with Clef",
 		"This article was created by the Go SDK client...",
+		"go", "clef",
 	)
 	if err != nil {
 		fmt.Printf("   Failed: %v\n", err)
@@ -220,11 +885,26 @@ func main() {
 
 	// Follow
 	fmt.Println("4. Following user...")
-	if err := client.Follow("other-user"); err != nil {
+	if err := client.Follow(ctx, "other-user"); err != nil {
 		fmt.Printf("   Failed: %v\n", err)
 	} else {
 		fmt.Println("   Followed!")
 	}
 
+	// Favorite and unfavorite
+	if article != nil {
+		fmt.Println("5. Favoriting article...")
+		if fav, err := client.Favorite(ctx, article.Article.Slug); err != nil {
+			fmt.Printf("   Failed: %v\n", err)
+		} else {
+			fmt.Printf("   Favorited (%d favorites)\n", fav.Article.FavoritesCount)
+		}
+		if unfav, err := client.Unfavorite(ctx, article.Article.Slug); err != nil {
+			fmt.Printf("   Failed: %v\n", err)
+		} else {
+			fmt.Printf("   Unfavorited (%d favorites)\n", unfav.Article.FavoritesCount)
+		}
+	}
+
 	fmt.Println("\nGo SDK journey complete!")
 }
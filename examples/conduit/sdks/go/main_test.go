@@ -0,0 +1,743 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/clef/go-sdk/clef"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestPropagatorInjectsTraceparent(t *testing.T) {
+	var traceparent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.Write([]byte(`{"status":"ok","concepts":1,"syncs":1}`))
+	}))
+	defer srv.Close()
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer tp.Shutdown(context.Background())
+
+	ctx, span := tp.Tracer("test").Start(context.Background(), "browser-call")
+	client := NewClient(srv.URL, WithPropagator(propagation.TraceContext{}))
+	if _, err := client.Health(ctx); err != nil {
+		t.Fatal(err)
+	}
+	span.End()
+
+	if traceparent == "" {
+		t.Fatal("expected traceparent header on outgoing request")
+	}
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 exported span, got %d", len(spans))
+	}
+	traceID := spans[0].SpanContext.TraceID().String()
+	if !strings.Contains(traceparent, traceID) {
+		t.Errorf("traceparent %q does not carry trace id %s", traceparent, traceID)
+	}
+}
+
+func TestNoPropagatorOmitsTraceparent(t *testing.T) {
+	var traceparent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	if _, err := NewClient(srv.URL).Health(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if traceparent != "" {
+		t.Errorf("expected no traceparent header, got %q", traceparent)
+	}
+}
+
+func TestProtobufClientRoundTrip(t *testing.T) {
+	var gotUser map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != protobufContentType {
+			t.Errorf("expected protobuf request, got %q", r.Header.Get("Content-Type"))
+		}
+		data, _ := io.ReadAll(r.Body)
+		var st structpb.Struct
+		if err := proto.Unmarshal(data, &st); err != nil {
+			t.Fatalf("request body is not a Struct: %v", err)
+		}
+		gotUser, _ = st.AsMap()["user"].(map[string]interface{})
+
+		resp, _ := structpb.NewStruct(map[string]interface{}{
+			"user": map[string]interface{}{"username": "gopher", "email": "g@x.io", "token": "tok-123"},
+		})
+		out, _ := proto.Marshal(resp)
+		w.Header().Set("Content-Type", protobufContentType)
+		w.Write(out)
+	}))
+	defer srv.Close()
+
+	client := NewProtobufConduitClient(srv.URL)
+	resp, err := client.Login(context.Background(), "g@x.io", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotUser["email"] != "g@x.io" {
+		t.Errorf("server saw user %v", gotUser)
+	}
+	if resp.User.Username != "gopher" || client.Token != "tok-123" {
+		t.Errorf("unexpected response %+v", resp.User)
+	}
+}
+
+func TestCreateArticleWithTagsSendsTagList(t *testing.T) {
+	var sent struct {
+		Article struct {
+			Title   string   `json:"title"`
+			TagList []string `json:"tagList"`
+		} `json:"article"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&sent); err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(`{"article":{"slug":"hello","title":"Hello","tagList":["go","clef"]}}`))
+	}))
+	defer srv.Close()
+
+	resp, err := NewClient(srv.URL).CreateArticleWithTags(context.Background(), "Hello", "d", "b", []string{"go", "clef"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sent.Article.TagList) != 2 || sent.Article.TagList[0] != "go" || sent.Article.TagList[1] != "clef" {
+		t.Errorf("expected tagList [go clef] in body, got %v", sent.Article.TagList)
+	}
+	if len(resp.Article.TagList) != 2 {
+		t.Errorf("expected tags in response, got %v", resp.Article.TagList)
+	}
+}
+
+func TestCreateArticleVariadicTags(t *testing.T) {
+	var raw map[string]map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&raw)
+		w.Write([]byte(`{"article":{}}`))
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL)
+	if _, err := client.CreateArticle(context.Background(), "T", "d", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := raw["article"]["tagList"]; ok {
+		t.Error("expected no tagList when no tags are given")
+	}
+	if _, err := client.CreateArticle(context.Background(), "T", "d", "b", "go"); err != nil {
+		t.Fatal(err)
+	}
+	if tags, _ := raw["article"]["tagList"].([]interface{}); len(tags) != 1 || tags[0] != "go" {
+		t.Errorf("expected tagList [go], got %v", raw["article"]["tagList"])
+	}
+}
+
+func TestGetTags(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/api/tags" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.Write([]byte(`{"tags":["go","clef","sync"]}`))
+	}))
+	defer srv.Close()
+
+	resp, err := NewClient(srv.URL).GetTags(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Tags) != 3 || resp.Tags[2] != "sync" {
+		t.Errorf("unexpected tags %v", resp.Tags)
+	}
+}
+
+func TestFeedCursor(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/articles/feed" || r.URL.Query().Get("limit") != "2" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		switch r.URL.Query().Get("offset") {
+		case "0":
+			w.Write([]byte(`{"articles":[{"slug":"a"},{"slug":"b"}],"articlesCount":3}`))
+		case "2":
+			w.Write([]byte(`{"articles":[{"slug":"c"}],"articlesCount":3}`))
+		default:
+			t.Errorf("unexpected offset %s", r.URL.Query().Get("offset"))
+		}
+	}))
+	defer srv.Close()
+
+	cursor := NewFeedCursor(NewClient(srv.URL), 2)
+	ctx := context.Background()
+	page, more, err := cursor.Next(ctx)
+	if err != nil || !more || len(page.Articles) != 2 || page.Articles[0].Slug != "a" {
+		t.Fatalf("first page = %+v, %v, %v", page, more, err)
+	}
+	page, more, err = cursor.Next(ctx)
+	if err != nil || more || len(page.Articles) != 1 || page.Articles[0].Slug != "c" {
+		t.Fatalf("second page = %+v, %v, %v", page, more, err)
+	}
+	page, more, err = cursor.Next(ctx)
+	if err != nil || more || page != nil {
+		t.Fatalf("third call = %+v, %v, %v", page, more, err)
+	}
+}
+
+func TestFavoriteAndUnfavorite(t *testing.T) {
+	var methods []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/articles/hello/favorite" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		methods = append(methods, r.Method)
+		if r.Method == "POST" {
+			w.Write([]byte(`{"article":{"slug":"hello","favorited":true,"favoritesCount":3}}`))
+		} else {
+			w.Write([]byte(`{"article":{"slug":"hello","favorited":false,"favoritesCount":2}}`))
+		}
+	}))
+	defer srv.Close()
+	client := NewClient(srv.URL)
+
+	fav, err := client.Favorite(context.Background(), "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fav.Article.Favorited || fav.Article.FavoritesCount != 3 {
+		t.Errorf("unexpected favorite response %+v", fav.Article)
+	}
+	unfav, err := client.Unfavorite(context.Background(), "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unfav.Article.Favorited || unfav.Article.FavoritesCount != 2 {
+		t.Errorf("unexpected unfavorite response %+v", unfav.Article)
+	}
+	if len(methods) != 2 || methods[0] != "POST" || methods[1] != "DELETE" {
+		t.Errorf("expected POST then DELETE, got %v", methods)
+	}
+}
+
+func TestUnfollowUsesDelete(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "DELETE" || r.URL.Path != "/api/profiles/jake/follow" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.Write([]byte(`{"profile":{"username":"jake","following":false}}`))
+	}))
+	defer srv.Close()
+
+	if err := NewClient(srv.URL).Unfollow(context.Background(), "jake"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInterceptorsRunInOrder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-First") != "1" {
+			t.Error("server did not see header added by first interceptor")
+		}
+		w.Write([]byte(`{"tags":[]}`))
+	}))
+	defer srv.Close()
+
+	var calls []string
+	first := func(req *http.Request, next func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+		calls = append(calls, "first")
+		req.Header.Set("X-First", "1")
+		resp, err := next(req)
+		calls = append(calls, "first done")
+		return resp, err
+	}
+	second := func(req *http.Request, next func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+		calls = append(calls, "second saw X-First="+req.Header.Get("X-First"))
+		resp, err := next(req)
+		calls = append(calls, "second done")
+		return resp, err
+	}
+
+	client := NewClient(srv.URL, WithInterceptors(first, second))
+	if _, err := client.GetTags(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"first", "second saw X-First=1", "second done", "first done"}
+	if strings.Join(calls, ",") != strings.Join(want, ",") {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestLoggingAndTimingInterceptors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"tags":[]}`))
+	}))
+	defer srv.Close()
+
+	var log bytes.Buffer
+	var timed []string
+	client := NewClient(srv.URL, WithInterceptors(
+		LoggingInterceptor(&log),
+		TimingInterceptor(func(method, path string, d time.Duration) {
+			timed = append(timed, method+" "+path)
+		}),
+	))
+	if _, err := client.GetTags(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(log.String(), "GET /api/tags 200 (") {
+		t.Errorf("log = %q", log.String())
+	}
+	if len(timed) != 1 || timed[0] != "GET /api/tags" {
+		t.Errorf("timed = %v", timed)
+	}
+}
+
+func TestDebugLoggerMasksToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"tags":["go"]}`))
+	}))
+	defer srv.Close()
+
+	var log bytes.Buffer
+	client := NewClient(srv.URL, WithDebugLogger(&log))
+	client.Token = "secret-jwt"
+	if _, err := client.CreateArticle(context.Background(), "Title", "d", "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	out := log.String()
+	for _, want := range []string{
+		"--> POST " + srv.URL + "/api/articles",
+		"Authorization: Token ***",
+		`"title":"Title"`,
+		"<-- 200 OK",
+		`{"tags":["go"]}`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected log to contain %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "secret-jwt") {
+		t.Errorf("token leaked into debug log:\n%s", out)
+	}
+}
+
+func TestRequestAndResponseHooks(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"tags":[]}`))
+	}))
+	defer srv.Close()
+
+	var sent string
+	var status int
+	client := NewClient(srv.URL,
+		WithRequestHook(func(req *http.Request) { sent = req.Method + " " + req.URL.Path }),
+		WithResponseHook(func(resp *http.Response) { status = resp.StatusCode }),
+	)
+	if _, err := client.GetTags(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if sent != "GET /api/tags" {
+		t.Errorf("expected request hook to see GET /api/tags, got %q", sent)
+	}
+	if status != http.StatusCreated {
+		t.Errorf("expected response hook to see 201, got %d", status)
+	}
+}
+
+// webHandler plays the Clef Web concept for gRPC client tests.
+type webHandler struct{}
+
+func (webHandler) Handle(action string, input map[string]any, storage clef.Storage) map[string]any {
+	switch input["path"] {
+	case "/api/tags":
+		return map[string]any{"variant": "ok", "status": 200, "body": map[string]any{"tags": []any{"go", "grpc"}}}
+	case "/api/articles":
+		if input["token"] != "jwt" {
+			return map[string]any{"variant": "ok", "status": 401, "body": map[string]any{"errors": "unauthorized"}}
+		}
+		article := input["body"].(map[string]any)["article"].(map[string]any)
+		article["slug"] = "hello"
+		return map[string]any{"variant": "ok", "status": 201, "body": map[string]any{"article": article}}
+	}
+	return map[string]any{"variant": "ok", "status": 404, "body": map[string]any{}}
+}
+
+func newGRPCTestClient(t *testing.T) *ConduitClient {
+	t.Helper()
+	clef.Register(webConcept, webHandler{}, nil)
+	lis := bufconn.Listen(1 << 20)
+	gs := clef.NewGRPCServer()
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+
+	client, err := NewGRPCConduitClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestGRPCClientRoundTrip(t *testing.T) {
+	client := newGRPCTestClient(t)
+
+	tags, err := client.GetTags(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags.Tags) != 2 || tags.Tags[1] != "grpc" {
+		t.Errorf("unexpected tags %v", tags.Tags)
+	}
+
+	if _, err := client.CreateArticle(context.Background(), "T", "d", "b"); err == nil || !strings.Contains(err.Error(), "HTTP 401") {
+		t.Errorf("expected 401 without token, got %v", err)
+	}
+	client.Token = "jwt"
+	article, err := client.CreateArticle(context.Background(), "Over gRPC", "d", "b", "go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if article.Article.Slug != "hello" || article.Article.Title != "Over gRPC" || len(article.Article.TagList) != 1 {
+		t.Errorf("unexpected article %+v", article.Article)
+	}
+}
+
+func TestInvokeRawGeneratesUUIDv4(t *testing.T) {
+	clef.Register(webConcept, webHandler{}, nil)
+	srv := httptest.NewServer(clef.NewHandler())
+	defer srv.Close()
+
+	completion, err := NewClient(srv.URL).InvokeRaw(context.Background(), clef.ActionInvocation{
+		Concept: webConcept,
+		Action:  "request",
+		Input:   map[string]any{"method": "GET", "path": "/api/tags"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := uuid.Parse(completion.ID)
+	if err != nil || id.Version() != 4 {
+		t.Fatalf("expected a UUID v4 completion id, got %q (%v)", completion.ID, err)
+	}
+	if completion.Flow != completion.ID {
+		t.Errorf("expected flow to reuse id %s, got %s", completion.ID, completion.Flow)
+	}
+	if completion.Variant != "ok" || completion.Concept != webConcept || completion.Action != "request" || completion.Timestamp == "" {
+		t.Errorf("completion not fully parsed: %+v", completion)
+	}
+	if completion.Input["path"] != "/api/tags" || completion.Output["status"] != float64(200) {
+		t.Errorf("unexpected input/output %v %v", completion.Input, completion.Output)
+	}
+}
+
+func TestInvokeRawKeepsCallerIDAndErrorVariants(t *testing.T) {
+	srv := httptest.NewServer(clef.NewHandler())
+	defer srv.Close()
+
+	inv := clef.ActionInvocation{ID: "retry-1", Flow: "flow-1", Concept: "urn:clef/Missing", Action: "noop"}
+	completion, err := NewClient(srv.URL).InvokeRaw(context.Background(), inv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if completion.ID != "retry-1" || completion.Flow != "flow-1" {
+		t.Errorf("expected caller ids to be kept, got %s/%s", completion.ID, completion.Flow)
+	}
+	if completion.Variant != "error" || completion.Output["code"] != "not_found" {
+		t.Errorf("expected not_found error completion, got %+v", completion)
+	}
+}
+
+func TestInvokeRawProtobuf(t *testing.T) {
+	clef.Register(webConcept, webHandler{}, nil)
+	srv := httptest.NewServer(clef.NewHandler())
+	defer srv.Close()
+
+	completion, err := NewProtobufConduitClient(srv.URL).InvokeRaw(context.Background(), clef.ActionInvocation{
+		Concept: webConcept,
+		Action:  "request",
+		Input:   map[string]any{"path": "/api/tags"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := uuid.Parse(completion.ID); err != nil || completion.Variant != "ok" {
+		t.Errorf("unexpected completion %+v", completion)
+	}
+}
+
+func TestHealthReportsLatency(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		w.Write([]byte(`{"status":"ok","concepts":3,"syncs":2}`))
+	}))
+	defer srv.Close()
+
+	health, err := NewClient(srv.URL).Health(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if health.Concepts != 3 || health.Latency < 10*time.Millisecond {
+		t.Errorf("unexpected health %+v", health)
+	}
+}
+
+func TestCheckFleet(t *testing.T) {
+	// Each Conduit node waits until every Conduit node has been reached,
+	// so the check only completes if the requests run concurrently.
+	var arrived sync.WaitGroup
+	arrived.Add(2)
+	conduit := func(concepts int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			arrived.Done()
+			arrived.Wait()
+			fmt.Fprintf(w, `{"status":"ok","concepts":%d,"syncs":1}`, concepts)
+		}))
+	}
+	a, b := conduit(1), conduit(2)
+	defer a.Close()
+	defer b.Close()
+
+	plain := httptest.NewServer(clef.NewHandler())
+	defer plain.Close()
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	fleet := CheckFleet(context.Background(), []string{a.URL, b.URL, plain.URL, down.URL})
+	if len(fleet) != 4 {
+		t.Fatalf("expected 4 entries, got %v", fleet)
+	}
+	if fleet[a.URL] == nil || fleet[a.URL].Concepts != 1 || fleet[b.URL] == nil || fleet[b.URL].Concepts != 2 {
+		t.Errorf("conduit nodes mapped incorrectly: %+v %+v", fleet[a.URL], fleet[b.URL])
+	}
+	if h := fleet[plain.URL]; h == nil || !h.Healthy || h.Status != "ok" || h.Latency <= 0 {
+		t.Errorf("expected /health fallback for plain Clef node, got %+v", h)
+	}
+	if h, ok := fleet[down.URL]; !ok || h != nil {
+		t.Errorf("expected nil for unreachable node, got %+v", h)
+	}
+}
+
+func TestPing(t *testing.T) {
+	healthy := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			http.NotFound(w, r)
+			return
+		}
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		fmt.Fprintf(w, `{"healthy":%t}`, healthy)
+	}))
+	client := NewClient(srv.URL)
+
+	if err := client.Ping(context.Background()); err != nil {
+		t.Errorf("expected healthy server, got %v", err)
+	}
+	healthy = false
+	if err := client.Ping(context.Background()); err == nil || !strings.Contains(err.Error(), "unhealthy") {
+		t.Errorf("expected unhealthy error, got %v", err)
+	}
+	srv.Close()
+	if err := client.Ping(context.Background()); err == nil {
+		t.Error("expected error for unreachable server")
+	}
+}
+
+func TestAutoReconnectRetriesAfterServerRestart(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/health":
+			w.Write([]byte(`{"status":"ok"}`))
+		case "/api/tags":
+			w.Write([]byte(`{"tags":["back"]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	srv := httptest.NewServer(handler)
+	addr := srv.Listener.Addr().String()
+	client := NewClient(srv.URL, WithAutoReconnect(8, 10*time.Millisecond))
+	srv.Close()
+
+	// Bring the server back on the same address after the client's first
+	// attempt has failed.
+	restarted := make(chan *http.Server, 1)
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		lis, err := net.Listen("tcp", addr)
+		if err != nil {
+			restarted <- nil
+			return
+		}
+		s := &http.Server{Handler: handler}
+		go s.Serve(lis)
+		restarted <- s
+	}()
+
+	tags, err := client.GetTags(context.Background())
+	if s := <-restarted; s != nil {
+		defer s.Close()
+	} else {
+		t.Skip("could not rebind the test server address")
+	}
+	if err != nil {
+		t.Fatalf("expected transparent reconnect, got %v", err)
+	}
+	if len(tags.Tags) != 1 || tags.Tags[0] != "back" {
+		t.Errorf("unexpected tags %v", tags.Tags)
+	}
+}
+
+func TestAutoReconnectIgnoresHTTPErrors(t *testing.T) {
+	var calls, pings int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "health") {
+			pings++
+		} else {
+			calls++
+		}
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	_, err := NewClient(srv.URL, WithAutoReconnect(3, time.Millisecond)).GetTags(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "HTTP 404") {
+		t.Errorf("expected HTTP 404, got %v", err)
+	}
+	if calls != 1 || pings != 0 {
+		t.Errorf("expected one call and no pings, got %d calls and %d pings", calls, pings)
+	}
+}
+
+func TestAutoReconnectGivesUp(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	_, err := NewClient(srv.URL, WithAutoReconnect(2, time.Millisecond)).GetTags(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "not healthy after 2 attempts") {
+		t.Errorf("expected reconnect failure, got %v", err)
+	}
+}
+
+func TestInvokeRawBytes(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\nfake")
+	clef.Register("urn:test/Thumbnail", clef.HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage clef.Storage) map[string]any {
+		if input["missing"] == true {
+			return map[string]any{"variant": "error", "code": "not_found", "message": "no such image"}
+		}
+		return map[string]any{"variant": "ok", "_binary": clef.BinaryOutput{ContentType: "image/png", Data: png}}
+	}), nil)
+	srv := httptest.NewServer(clef.NewHandler())
+	defer srv.Close()
+	client := NewClient(srv.URL)
+
+	contentType, data, err := client.InvokeRawBytes(context.Background(), clef.ActionInvocation{Concept: "urn:test/Thumbnail", Action: "render"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "image/png" || !bytes.Equal(data, png) {
+		t.Errorf("unexpected binary response %q %q", contentType, data)
+	}
+
+	_, _, err = client.InvokeRawBytes(context.Background(), clef.ActionInvocation{Concept: "urn:test/Thumbnail", Action: "render", Input: map[string]any{"missing": true}})
+	if err == nil || !strings.Contains(err.Error(), "HTTP 404") {
+		t.Errorf("expected HTTP 404 error, got %v", err)
+	}
+}
+
+func TestLogoutClearsToken(t *testing.T) {
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path+" auth="+r.Header.Get("Authorization"))
+		w.Write([]byte(`{"tags":[]}`))
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL)
+	client.Token = "jwt"
+	if !client.IsAuthenticated() {
+		t.Fatal("expected client with token to be authenticated")
+	}
+	if err := client.Logout(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if client.IsAuthenticated() || client.Token != "" {
+		t.Error("expected Logout to clear the token")
+	}
+	if _, err := client.GetTags(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"DELETE /api/users/login auth=Token jwt", "GET /api/tags auth="}
+	if len(requests) != 2 || requests[0] != want[0] || requests[1] != want[1] {
+		t.Errorf("expected %v, got %v", want, requests)
+	}
+}
+
+func TestLogoutClearsTokenOnError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	client := NewClient(srv.URL)
+	client.Token = "jwt"
+	if err := client.Logout(context.Background()); err == nil {
+		t.Error("expected server error to be returned")
+	}
+	if client.IsAuthenticated() {
+		t.Error("expected token cleared even when the server call fails")
+	}
+}
+
+func TestWithTokenCopiesClient(t *testing.T) {
+	var auth []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Authorization"))
+		w.Write([]byte(`{"tags":[]}`))
+	}))
+	defer srv.Close()
+
+	alice := NewClient(srv.URL)
+	alice.Token = "alice"
+	bob := alice.WithToken("bob")
+
+	if alice.Token != "alice" || bob.Token != "bob" || bob.BaseURL != alice.BaseURL {
+		t.Fatalf("unexpected clients alice=%q bob=%q", alice.Token, bob.Token)
+	}
+	alice.GetTags(context.Background())
+	bob.GetTags(context.Background())
+	if len(auth) != 2 || auth[0] != "Token alice" || auth[1] != "Token bob" {
+		t.Errorf("unexpected Authorization headers %v", auth)
+	}
+}
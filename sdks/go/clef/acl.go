@@ -0,0 +1,61 @@
+package clef
+
+import "context"
+
+// ACLPolicy decides whether the caller described by claims may invoke
+// action on concept. Claims are whatever the authentication layer stored
+// with ContextWithClaims; they are nil for unauthenticated requests.
+type ACLPolicy func(ctx context.Context, concept, action string, claims map[string]any) bool
+
+// WithACL checks every invocation against acl after authentication.
+// Denied invocations get HTTP 403 and a "forbidden" error completion
+// without reaching the handler.
+func WithACL(acl ACLPolicy) ServeOption {
+	return func(c *ServerConfig) {
+		c.acl = acl
+	}
+}
+
+type claimsKey struct{}
+
+// ContextWithClaims attaches authenticated caller claims to ctx.
+// Authentication middleware in front of the transport calls this once the
+// caller's token has been verified.
+func ContextWithClaims(ctx context.Context, claims map[string]any) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// ClaimsFromContext returns the claims stored by ContextWithClaims, or nil.
+func ClaimsFromContext(ctx context.Context) map[string]any {
+	claims, _ := ctx.Value(claimsKey{}).(map[string]any)
+	return claims
+}
+
+// StaticACL allows an invocation when claims["role"] is one of the roles
+// listed for "concept:action", or for "concept:*" if the action has no
+// entry of its own. Anything not listed is denied.
+//
+// Example:
+//
+//	clef.StaticACL(map[string][]string{
+//	    "urn:app/Article:create": {"editor", "admin"},
+//	    "urn:app/Article:*":      {"admin"},
+//	})
+func StaticACL(rules map[string][]string) ACLPolicy {
+	return func(ctx context.Context, concept, action string, claims map[string]any) bool {
+		role, _ := claims["role"].(string)
+		if role == "" {
+			return false
+		}
+		allowed, ok := rules[concept+":"+action]
+		if !ok {
+			allowed = rules[concept+":*"]
+		}
+		for _, r := range allowed {
+			if r == role {
+				return true
+			}
+		}
+		return false
+	}
+}
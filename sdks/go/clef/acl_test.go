@@ -0,0 +1,80 @@
+package clef
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// withRole simulates an auth layer that has verified a token carrying role.
+func withRole(next http.Handler, role string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if role != "" {
+			r = r.WithContext(ContextWithClaims(r.Context(), map[string]any{"role": role}))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func invokeAs(t *testing.T, role string) (*httptest.ResponseRecorder, ActionCompletion) {
+	t.Helper()
+	h := NewHandler(WithACL(StaticACL(map[string][]string{
+		"urn:test/Echo:echo": {"editor", "admin"},
+		"urn:test/Echo:*":    {"admin"},
+	})))
+	req := httptest.NewRequest(http.MethodPost, "/invoke", strings.NewReader(`{"concept":"urn:test/Echo","action":"echo","input":{"message":"hi"}}`))
+	rec := httptest.NewRecorder()
+	withRole(h, role).ServeHTTP(rec, req)
+
+	var c ActionCompletion
+	if err := json.NewDecoder(rec.Body).Decode(&c); err != nil {
+		t.Fatal(err)
+	}
+	return rec, c
+}
+
+func TestACLAllowedRole(t *testing.T) {
+	resetRegistry()
+	Register("urn:test/Echo", &echoHandler{}, nil)
+
+	rec, c := invokeAs(t, "editor")
+	if rec.Code != http.StatusOK || c.Variant != "ok" {
+		t.Errorf("expected editor to be allowed, got %d %v", rec.Code, c.Output)
+	}
+}
+
+func TestACLDeniedRole(t *testing.T) {
+	resetRegistry()
+	Register("urn:test/Echo", &echoHandler{}, nil)
+
+	rec, c := invokeAs(t, "viewer")
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403, got %d", rec.Code)
+	}
+	if c.Variant != "error" || c.Output["message"] != "forbidden" {
+		t.Errorf("expected forbidden error, got %v", c.Output)
+	}
+}
+
+func TestACLMissingClaims(t *testing.T) {
+	resetRegistry()
+	Register("urn:test/Echo", &echoHandler{}, nil)
+
+	rec, _ := invokeAs(t, "")
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403 without claims, got %d", rec.Code)
+	}
+}
+
+func TestStaticACLWildcard(t *testing.T) {
+	acl := StaticACL(map[string][]string{"urn:test/Echo:*": {"admin"}})
+	if !acl(context.Background(), "urn:test/Echo", "purge", map[string]any{"role": "admin"}) {
+		t.Error("expected wildcard to allow admin")
+	}
+	if acl(context.Background(), "urn:test/Other", "purge", map[string]any{"role": "admin"}) {
+		t.Error("expected unlisted concept to be denied")
+	}
+}
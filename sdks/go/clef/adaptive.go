@@ -0,0 +1,141 @@
+package clef
+
+import (
+	"context"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DefaultAdaptiveMaxTimeout is the MaxTimeout of
+// AdaptiveTimeoutMiddleware when none is set.
+const DefaultAdaptiveMaxTimeout = 30 * time.Second
+
+// AdaptiveTimeoutOptions configures AdaptiveTimeoutMiddleware.
+type AdaptiveTimeoutOptions struct {
+	// MinTimeout and MaxTimeout bound the timeout. Until the first call
+	// completes, the timeout is MaxTimeout. Zero MaxTimeout means
+	// DefaultAdaptiveMaxTimeout.
+	MinTimeout time.Duration
+	MaxTimeout time.Duration
+	// TargetPercentile is the latency percentile the timeout follows, in
+	// (0, 1]. Zero means 0.99.
+	TargetPercentile float64
+	// Window is the number of recent durations kept. Zero means 100.
+	Window int
+}
+
+// AdaptiveTimeoutMiddleware times out calls that take more than twice
+// the TargetPercentile latency of the last Window calls of the same
+// action, clamped to [MinTimeout, MaxTimeout], returning {"variant":
+// "error", "code": "timeout"}. Each action keeps its own window, so a
+// slow action does not loosen the timeout of cheap ones. The handler's context is cancelled at the timeout; a
+// handler that finishes late still contributes its duration, so the
+// timeout grows again when a handler slows down for good.
+//
+// Example:
+//
+//	clef.MustRegister("urn:app/Search", clef.Chain(&SearchHandler{}, clef.AdaptiveTimeoutMiddleware(clef.AdaptiveTimeoutOptions{
+//	    MinTimeout: 50 * time.Millisecond,
+//	    MaxTimeout: 5 * time.Second,
+//	})), nil)
+func AdaptiveTimeoutMiddleware(opts AdaptiveTimeoutOptions) MiddlewareFunc {
+	var (
+		mu      sync.Mutex
+		actions = make(map[string]*adaptiveTimeout)
+	)
+	forAction := func(action string) *adaptiveTimeout {
+		mu.Lock()
+		defer mu.Unlock()
+		at, ok := actions[action]
+		if !ok {
+			at = newAdaptiveTimeout(opts)
+			actions[action] = at
+		}
+		return at
+	}
+	return func(next ConceptHandler) ConceptHandler {
+		return HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+			at := forAction(action)
+			timeout := at.timeout()
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			done := make(chan map[string]any, 1)
+			go func() {
+				start := time.Now()
+				output := callHandlerRecovered(ctx, next, action, input, storage)
+				at.record(time.Since(start))
+				done <- output
+			}()
+			select {
+			case output := <-done:
+				return output
+			case <-ctx.Done():
+				return map[string]any{
+					"variant": "error",
+					"code":    "timeout",
+					"message": "handler exceeded adaptive timeout of " + timeout.String(),
+				}
+			}
+		})
+	}
+}
+
+// adaptiveTimeout keeps a circular buffer of recent call durations of
+// one action.
+type adaptiveTimeout struct {
+	opts AdaptiveTimeoutOptions
+
+	mu        sync.Mutex
+	durations []time.Duration
+	next      int
+	count     int
+}
+
+func newAdaptiveTimeout(opts AdaptiveTimeoutOptions) *adaptiveTimeout {
+	if opts.TargetPercentile <= 0 || opts.TargetPercentile > 1 {
+		opts.TargetPercentile = 0.99
+	}
+	if opts.Window <= 0 {
+		opts.Window = 100
+	}
+	if opts.MaxTimeout <= 0 {
+		opts.MaxTimeout = DefaultAdaptiveMaxTimeout
+	}
+	return &adaptiveTimeout{opts: opts, durations: make([]time.Duration, opts.Window)}
+}
+
+func (a *adaptiveTimeout) record(d time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.durations[a.next] = d
+	a.next = (a.next + 1) % len(a.durations)
+	if a.count < len(a.durations) {
+		a.count++
+	}
+}
+
+// percentile returns the TargetPercentile duration by nearest rank, or
+// false before any call has completed.
+func (a *adaptiveTimeout) percentile() (time.Duration, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.count == 0 {
+		return 0, false
+	}
+	sorted := slices.Clone(a.durations[:a.count])
+	slices.Sort(sorted)
+	rank := int(math.Ceil(a.opts.TargetPercentile * float64(a.count)))
+	return sorted[max(rank, 1)-1], true
+}
+
+// timeout returns the timeout for the next call.
+func (a *adaptiveTimeout) timeout() time.Duration {
+	p, ok := a.percentile()
+	if !ok {
+		return a.opts.MaxTimeout
+	}
+	return min(a.opts.MaxTimeout, max(a.opts.MinTimeout, 2*p))
+}
@@ -0,0 +1,118 @@
+package clef
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveTimeoutPercentile(t *testing.T) {
+	at := newAdaptiveTimeout(AdaptiveTimeoutOptions{MinTimeout: time.Millisecond, MaxTimeout: time.Second})
+	if got := at.timeout(); got != time.Second {
+		t.Errorf("timeout with no samples = %v, want MaxTimeout", got)
+	}
+	for i := range 100 {
+		switch {
+		case i < 2:
+			at.record(100 * time.Millisecond)
+		case i < 50:
+			at.record(5 * time.Millisecond)
+		default:
+			at.record(10 * time.Millisecond)
+		}
+	}
+	if p, _ := at.percentile(); p != 100*time.Millisecond {
+		t.Errorf("P99 = %v, want 100ms", p)
+	}
+	if got := at.timeout(); got != 200*time.Millisecond {
+		t.Errorf("timeout = %v, want 2 × P99 = 200ms", got)
+	}
+
+	// Two more calls push the 100ms samples out of the window.
+	at.record(5 * time.Millisecond)
+	at.record(5 * time.Millisecond)
+	if got := at.timeout(); got != 20*time.Millisecond {
+		t.Errorf("timeout = %v, want 20ms once the slow calls leave the window", got)
+	}
+
+	clamped := newAdaptiveTimeout(AdaptiveTimeoutOptions{MinTimeout: 50 * time.Millisecond, MaxTimeout: 150 * time.Millisecond, Window: 10})
+	clamped.record(time.Millisecond)
+	if got := clamped.timeout(); got != 50*time.Millisecond {
+		t.Errorf("timeout = %v, want MinTimeout", got)
+	}
+	clamped.record(time.Second)
+	if got := clamped.timeout(); got != 150*time.Millisecond {
+		t.Errorf("timeout = %v, want MaxTimeout", got)
+	}
+}
+
+func TestAdaptiveTimeoutMiddleware(t *testing.T) {
+	sleeper := HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		select {
+		case <-time.After(time.Duration(input["ms"].(int)) * time.Millisecond):
+			return map[string]any{"variant": "ok"}
+		case <-ctx.Done():
+			return map[string]any{"variant": "error", "message": "cancelled"}
+		}
+	})
+	h := Chain(sleeper, AdaptiveTimeoutMiddleware(AdaptiveTimeoutOptions{
+		MinTimeout: time.Millisecond,
+		MaxTimeout: 2 * time.Second,
+		Window:     10,
+	}))
+	call := func(ms int) map[string]any {
+		return callHandler(context.Background(), h, "search", map[string]any{"ms": ms}, NewInMemoryStorage())
+	}
+
+	// The first call runs under MaxTimeout. With it in the window, P99
+	// is about 100ms and a 150ms call fits in the 200ms timeout.
+	for _, ms := range []int{100, 5, 5, 5, 10, 10, 10} {
+		if out := call(ms); out["variant"] != "ok" {
+			t.Fatalf("%dms call: %v", ms, out)
+		}
+	}
+	if out := call(150); out["variant"] != "ok" {
+		t.Errorf("150ms call with P99 ≈ 100ms: %v, want ok", out)
+	}
+
+	// Once only 5–10ms calls remain, the timeout is about 20ms.
+	for range 10 {
+		call(10)
+	}
+	if out := call(100); out["code"] != "timeout" {
+		t.Errorf("100ms call with P99 ≈ 10ms: %v, want a timeout", out)
+	}
+}
+
+func TestAdaptiveTimeoutPerAction(t *testing.T) {
+	sleeper := HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		select {
+		case <-time.After(time.Duration(input["ms"].(int)) * time.Millisecond):
+			return map[string]any{"variant": "ok"}
+		case <-ctx.Done():
+			return map[string]any{"variant": "error", "message": "cancelled"}
+		}
+	})
+	// MaxTimeout is left at zero, which must not time out every call.
+	h := Chain(sleeper, AdaptiveTimeoutMiddleware(AdaptiveTimeoutOptions{MinTimeout: time.Millisecond, Window: 5}))
+	call := func(action string, ms int) map[string]any {
+		return callHandler(context.Background(), h, action, map[string]any{"ms": ms}, NewInMemoryStorage())
+	}
+
+	for range 5 {
+		if out := call("get", 1); out["variant"] != "ok" {
+			t.Fatalf("get: %v", out)
+		}
+	}
+	// A slow action of its own, the first of which runs under the
+	// default MaxTimeout.
+	if out := call("report", 100); out["variant"] != "ok" {
+		t.Fatalf("report: %v", out)
+	}
+	if out := call("report", 100); out["variant"] != "ok" {
+		t.Errorf("report within 2 × its own P99: %v", out)
+	}
+	if out := call("get", 100); out["code"] != "timeout" {
+		t.Errorf("slow get after fast gets: %v, want a timeout", out)
+	}
+}
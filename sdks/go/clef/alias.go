@@ -0,0 +1,86 @@
+package clef
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// RegisterAlias forwards invocations of oldAction on the concept at uri
+// to newAction on the same handler, for renaming an action without
+// breaking callers. Completions of forwarded calls carry the old name in
+// AliasedFrom. The concept must already be registered, and registering
+// it again drops its aliases. Aliases do not chain: newAction must not
+// itself be an alias.
+//
+// Example:
+//
+//	clef.MustRegister("urn:app/User", &UserHandler{}, nil)
+//	clef.RegisterAlias("urn:app/User", "signup", "register")
+func RegisterAlias(uri, oldAction, newAction string) error {
+	entry, ok := registry[uri]
+	if !ok {
+		return fmt.Errorf("clef: unknown concept: %s", uri)
+	}
+	if oldAction == newAction {
+		return fmt.Errorf("clef: action %q cannot alias itself", oldAction)
+	}
+	if _, ok := entry.aliases[newAction]; ok {
+		return fmt.Errorf("clef: %q is itself an alias", newAction)
+	}
+	for old, target := range entry.aliases {
+		if target == oldAction {
+			return fmt.Errorf("clef: %q is the target of alias %q", oldAction, old)
+		}
+	}
+
+	aliases := make(map[string]string, len(entry.aliases)+1)
+	for k, v := range entry.aliases {
+		aliases[k] = v
+	}
+	aliases[oldAction] = newAction
+	entry.aliases = aliases
+	registry[uri] = entry
+	return nil
+}
+
+type aliasKey struct{}
+
+// AliasedFromContext returns the deprecated action name the caller used
+// when the current invocation was forwarded by an alias.
+func AliasedFromContext(ctx context.Context) (string, bool) {
+	old, ok := ctx.Value(aliasKey{}).(string)
+	return old, ok
+}
+
+// resolveAlias rewrites inv to the action its alias points to, returning
+// the old name, or "" when inv.Action is not an alias.
+func (e registryEntry) resolveAlias(ctx context.Context, inv ActionInvocation) (context.Context, ActionInvocation, string) {
+	target, ok := e.aliases[inv.Action]
+	if !ok {
+		return ctx, inv, ""
+	}
+	old := inv.Action
+	inv.Action = target
+	return context.WithValue(ctx, aliasKey{}, old), inv, old
+}
+
+// DeprecationWarningMiddleware logs a warning each time a handler is
+// reached through an alias registered with RegisterAlias, naming the old
+// and new action so callers still using the old name can be found.
+func DeprecationWarningMiddleware(logger *slog.Logger) MiddlewareFunc {
+	return func(next ConceptHandler) ConceptHandler {
+		return HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+			if old, ok := AliasedFromContext(ctx); ok {
+				inv, _ := InvocationFromContext(ctx)
+				logger.WarnContext(ctx, "deprecated action invoked",
+					"concept", inv.Concept,
+					"action", old,
+					"replacement", action,
+					"flow", inv.Flow,
+				)
+			}
+			return callHandler(ctx, next, action, input, storage)
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package clef
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRegisterAliasForwardsToNewAction(t *testing.T) {
+	resetRegistry()
+	var reached []string
+	Register("urn:test/Echo", HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		reached = append(reached, action)
+		return map[string]any{"variant": "ok"}
+	}), nil)
+	if err := RegisterAlias("urn:test/Echo", "say", "echo"); err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler()
+
+	var completions []ActionCompletion
+	for _, action := range []string{"say", "echo"} {
+		rec := doRequest(h, "POST", "/invoke", `{"concept":"urn:test/Echo","action":"`+action+`"}`)
+		var c ActionCompletion
+		if err := json.Unmarshal(rec.Body.Bytes(), &c); err != nil {
+			t.Fatal(err)
+		}
+		completions = append(completions, c)
+	}
+
+	if len(reached) != 2 || reached[0] != "echo" || reached[1] != "echo" {
+		t.Errorf("expected both calls to reach echo, got %v", reached)
+	}
+	if completions[0].AliasedFrom != "say" || completions[0].Action != "echo" {
+		t.Errorf("expected aliased completion for old name, got %+v", completions[0])
+	}
+	if completions[1].AliasedFrom != "" {
+		t.Errorf("expected no alias for new name, got %q", completions[1].AliasedFrom)
+	}
+}
+
+func TestRegisterAliasErrors(t *testing.T) {
+	resetRegistry()
+	if err := RegisterAlias("urn:test/Missing", "a", "b"); err == nil {
+		t.Error("expected error for unknown concept")
+	}
+	Register("urn:test/Echo", &echoHandler{}, nil)
+	if err := RegisterAlias("urn:test/Echo", "echo", "echo"); err == nil {
+		t.Error("expected error for self alias")
+	}
+	if err := RegisterAlias("urn:test/Echo", "say", "echo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterAlias("urn:test/Echo", "speak", "say"); err == nil {
+		t.Error("expected error when aliasing to an alias")
+	}
+	if err := RegisterAlias("urn:test/Echo", "echo", "shout"); err == nil {
+		t.Error("expected error when aliasing an alias target")
+	}
+}
+
+func TestDeprecationWarningMiddleware(t *testing.T) {
+	resetRegistry()
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	Register("urn:test/Echo", Chain(&echoHandler{}, DeprecationWarningMiddleware(logger)), nil)
+	if err := RegisterAlias("urn:test/Echo", "say", "echo"); err != nil {
+		t.Fatal(err)
+	}
+
+	invokeRecorder(t, `{"concept":"urn:test/Echo","action":"echo","input":{"message":"hi"}}`)
+	if logs.Len() != 0 {
+		t.Errorf("expected no warning for new name, got %q", logs.String())
+	}
+	invokeRecorder(t, `{"concept":"urn:test/Echo","action":"say","input":{"message":"hi"}}`)
+	if out := logs.String(); !strings.Contains(out, "level=WARN") || !strings.Contains(out, "action=say") || !strings.Contains(out, "replacement=echo") {
+		t.Errorf("expected deprecation warning, got %q", out)
+	}
+}
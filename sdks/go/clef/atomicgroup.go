@@ -0,0 +1,123 @@
+package clef
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// AtomicGroup runs invocations of several concepts concurrently and
+// commits their storage writes only if every one succeeds. Each handler
+// writes to its own StorageTx; when all return the "ok" variant every
+// transaction is committed, otherwise all are rolled back. Commits happen
+// one concept at a time after all handlers finish, so the group is
+// all-or-nothing against handler failures, not against a storage that
+// fails midway through committing.
+//
+// Handlers are called directly, in the flow of the invocation in ctx or a
+// new one. Unlike InvokeLocal, this bypasses the transport: per-concept
+// timeouts, caller quotas, preconditions and invariants do not apply to
+// group members. Transactions a member defers with TransactionMiddleware
+// are committed into the group's transaction for that member, so they
+// follow the group's outcome.
+//
+// Example:
+//
+//	outputs, err := clef.NewAtomicGroup().
+//	    Add("urn:app/Account", "debit", map[string]any{"id": "a1", "amount": 10}).
+//	    Add("urn:app/Account", "credit", map[string]any{"id": "a2", "amount": 10}).
+//	    Add("urn:app/Ledger", "record", map[string]any{"from": "a1", "to": "a2", "amount": 10}).
+//	    Execute(ctx)
+type AtomicGroup struct {
+	calls []LocalCall
+}
+
+// NewAtomicGroup returns an empty group.
+func NewAtomicGroup() *AtomicGroup {
+	return &AtomicGroup{}
+}
+
+// Add appends an invocation to the group.
+func (g *AtomicGroup) Add(concept, action string, input map[string]any) *AtomicGroup {
+	g.calls = append(g.calls, LocalCall{URI: concept, Action: action, Input: input})
+	return g
+}
+
+// AtomicGroupError reports the invocations that made an AtomicGroup roll
+// back.
+type AtomicGroupError struct {
+	// Failed holds the indices of the invocations that did not return
+	// the "ok" variant, in order.
+	Failed []int
+}
+
+func (e *AtomicGroupError) Error() string {
+	return fmt.Sprintf("clef: atomic group rolled back: %d invocation(s) failed: %v", len(e.Failed), e.Failed)
+}
+
+// Execute runs the group and returns the outputs in the order the
+// invocations were added. A handler that panics has an error output with
+// code "panic". If any invocation fails, nothing is committed and the
+// returned *AtomicGroupError lists the failures. An unknown concept fails
+// the group before any handler runs.
+func (g *AtomicGroup) Execute(ctx context.Context) ([]map[string]any, error) {
+	entries := make([]registryEntry, len(g.calls))
+	for i, call := range g.calls {
+		entry, ok := registry[call.URI]
+		if !ok {
+			return nil, fmt.Errorf("clef: atomic group: unknown concept: %s", call.URI)
+		}
+		entries[i] = entry
+	}
+	flow := uuid.NewString()
+	if parent, ok := InvocationFromContext(ctx); ok && parent.Flow != "" {
+		flow = parent.Flow
+	}
+
+	outputs := make([]map[string]any, len(g.calls))
+	txs := make([]*StorageTx, len(g.calls))
+	commits := make([]*pendingCommits, len(g.calls))
+	var wg sync.WaitGroup
+	for i, call := range g.calls {
+		txs[i] = BeginTx(bindStorage(ctx, entries[i].storage))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			inv := ActionInvocation{ID: uuid.NewString(), Concept: call.URI, Action: call.Action, Input: call.Input, Flow: flow}
+			callCtx, inv, _ := entries[i].resolveAlias(ctx, inv)
+			// Each member defers its own transactions and reports its
+			// own storage errors, not those of the caller's invocation.
+			callCtx = context.WithValue(callCtx, storageErrorsKey{}, &storageErrors{})
+			callCtx, commits[i] = withPendingCommits(callCtx)
+			outputs[i] = runAtomic(ContextWithInvocation(callCtx, inv), entries[i].handler, inv, txs[i])
+		}()
+	}
+	wg.Wait()
+
+	var failed []int
+	for i, output := range outputs {
+		if variant, _ := output["variant"].(string); variant != "ok" && variant != "" {
+			failed = append(failed, i)
+		}
+	}
+	for _, c := range commits {
+		c.finish(failed == nil)
+	}
+	if failed != nil {
+		for _, tx := range txs {
+			tx.Rollback()
+		}
+		return outputs, &AtomicGroupError{Failed: failed}
+	}
+	for _, tx := range txs {
+		tx.Commit()
+	}
+	return outputs, nil
+}
+
+// runAtomic calls h against tx, turning a panic into an error output.
+func runAtomic(ctx context.Context, h ConceptHandler, inv ActionInvocation, tx *StorageTx) map[string]any {
+	return callHandlerRecovered(ctx, h, inv.Action, inv.Input, tx)
+}
@@ -0,0 +1,117 @@
+package clef
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// writerHandler stores its input under "records" and fails when asked.
+func writerHandler(fail, panics bool) ConceptHandler {
+	return HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		storage.Put("records", input["id"].(string), input)
+		if panics {
+			panic("ledger unavailable")
+		}
+		if fail {
+			return map[string]any{"variant": "error", "message": "insufficient funds"}
+		}
+		return map[string]any{"variant": "ok", "id": input["id"]}
+	})
+}
+
+func TestAtomicGroupRollsBack(t *testing.T) {
+	for _, panics := range []bool{false, true} {
+		resetRegistry()
+		order, inventory, payment := NewInMemoryStorage(), NewInMemoryStorage(), NewInMemoryStorage()
+		Register("urn:test/Order", writerHandler(false, false), order)
+		Register("urn:test/Inventory", writerHandler(false, false), inventory)
+		Register("urn:test/Payment", writerHandler(!panics, panics), payment)
+
+		outputs, err := NewAtomicGroup().
+			Add("urn:test/Order", "place", map[string]any{"id": "o1"}).
+			Add("urn:test/Inventory", "debit", map[string]any{"id": "i1"}).
+			Add("urn:test/Payment", "charge", map[string]any{"id": "p1"}).
+			Execute(context.Background())
+		var groupErr *AtomicGroupError
+		if !errors.As(err, &groupErr) || len(groupErr.Failed) != 1 || groupErr.Failed[0] != 2 {
+			t.Fatalf("panics=%v: err = %v, want the third invocation failed", panics, err)
+		}
+		if len(outputs) != 3 || outputs[0]["variant"] != "ok" || outputs[2]["variant"] != "error" {
+			t.Errorf("panics=%v: outputs = %v", panics, outputs)
+		}
+		for name, s := range map[string]Storage{"order": order, "inventory": inventory, "payment": payment} {
+			if records := s.Find("records", nil); len(records) != 0 {
+				t.Errorf("panics=%v: %s kept writes %v", panics, name, records)
+			}
+		}
+	}
+}
+
+func TestAtomicGroupCommits(t *testing.T) {
+	resetRegistry()
+	order, inventory := NewInMemoryStorage(), NewInMemoryStorage()
+	Register("urn:test/Order", writerHandler(false, false), order)
+	Register("urn:test/Inventory", writerHandler(false, false), inventory)
+
+	outputs, err := NewAtomicGroup().
+		Add("urn:test/Order", "place", map[string]any{"id": "o1"}).
+		Add("urn:test/Inventory", "debit", map[string]any{"id": "i1"}).
+		Add("urn:test/Inventory", "debit", map[string]any{"id": "i2"}).
+		Execute(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outputs) != 3 || outputs[2]["id"] != "i2" {
+		t.Errorf("outputs = %v", outputs)
+	}
+	if _, ok := order.Get("records", "o1"); !ok {
+		t.Error("order write not committed")
+	}
+	if n := len(inventory.Find("records", nil)); n != 2 {
+		t.Errorf("inventory has %d records, want 2", n)
+	}
+}
+
+func TestAtomicGroupUnknownConcept(t *testing.T) {
+	resetRegistry()
+	order := NewInMemoryStorage()
+	Register("urn:test/Order", writerHandler(false, false), order)
+	_, err := NewAtomicGroup().
+		Add("urn:test/Order", "place", map[string]any{"id": "o1"}).
+		Add("urn:test/Missing", "x", nil).
+		Execute(context.Background())
+	if err == nil {
+		t.Fatal("expected an error for an unknown concept")
+	}
+	if _, ok := order.Get("records", "o1"); ok {
+		t.Error("handler ran despite the unknown concept")
+	}
+}
+
+func TestAtomicGroupInsideHandlerCommitsTransactionalMembers(t *testing.T) {
+	for _, fail := range []bool{false, true} {
+		resetRegistry()
+		order, payment := NewInMemoryStorage(), NewInMemoryStorage()
+		Register("urn:test/Order", Chain(writerHandler(false, false), TransactionMiddleware()), order)
+		Register("urn:test/Payment", writerHandler(fail, false), payment)
+		Register("urn:test/Checkout", HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+			_, err := NewAtomicGroup().
+				Add("urn:test/Order", "place", map[string]any{"id": "o1"}).
+				Add("urn:test/Payment", "charge", map[string]any{"id": "p1"}).
+				Execute(ctx)
+			if err != nil {
+				return map[string]any{"variant": "error", "message": err.Error()}
+			}
+			return map[string]any{"variant": "ok"}
+		}), NewInMemoryStorage())
+
+		c := InvokeLocal(context.Background(), ActionInvocation{Concept: "urn:test/Checkout", Action: "run"})
+		if want := map[bool]string{false: "ok", true: "error"}[fail]; c.Variant != want {
+			t.Fatalf("fail=%v: variant = %q, want %q: %v", fail, c.Variant, want, c.Output)
+		}
+		if _, ok := order.Get("records", "o1"); ok == fail {
+			t.Errorf("fail=%v: order write present = %v", fail, ok)
+		}
+	}
+}
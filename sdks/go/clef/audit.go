@@ -0,0 +1,296 @@
+package clef
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// AuditRecord describes one mutation made through an AuditStorage.
+type AuditRecord struct {
+	Timestamp     time.Time      `json:"timestamp"`
+	Operation     string         `json:"operation"`
+	Relation      string         `json:"relation"`
+	Key           string         `json:"key"`
+	OldValue      map[string]any `json:"oldValue,omitempty"`
+	NewValue      map[string]any `json:"newValue,omitempty"`
+	CallerAction  string         `json:"callerAction,omitempty"`
+	CallerConcept string         `json:"callerConcept,omitempty"`
+}
+
+// AuditSink receives audit records in the order mutations happen.
+type AuditSink interface {
+	Append(record AuditRecord) error
+}
+
+// AuditStorage is a Storage decorator that reports every Put, Delete
+// and patch (including bulk variants and MergeRelation) to an AuditSink
+// with the previous and new value and the invoking concept and action.
+// Reads pass straight through. Records are appended before the mutation
+// is applied, so no mutation goes unrecorded: when the sink fails, the
+// error is reported with ReportStorageError, the mutation is not made
+// and the writes that follow in the same handler call are dropped, as
+// with QuotaStorage. A bulk write whose sink fails partway leaves records
+// for entries that were not written.
+type AuditStorage struct {
+	Storage
+	sink AuditSink
+	ctx  context.Context
+	// mu orders read-modify-append sequences so records match the order
+	// in which writes were applied.
+	mu *sync.Mutex
+}
+
+// NewAuditStorage wraps inner so its mutations are appended to sink.
+func NewAuditStorage(inner Storage, sink AuditSink) *AuditStorage {
+	return &AuditStorage{Storage: inner, sink: sink, ctx: context.Background(), mu: &sync.Mutex{}}
+}
+
+// WithContext implements ContextualStorage.
+func (s *AuditStorage) WithContext(ctx context.Context) Storage {
+	return &AuditStorage{Storage: bindStorage(ctx, s.Storage), sink: s.sink, ctx: ctx, mu: s.mu}
+}
+
+// Relations implements Enumerable when the inner storage does.
+func (s *AuditStorage) Relations() []string {
+	if enum, ok := s.Storage.(Enumerable); ok {
+		return enum.Relations()
+	}
+	return nil
+}
+
+// Keys implements Enumerable when the inner storage does.
+func (s *AuditStorage) Keys(relation string) []string {
+	if enum, ok := s.Storage.(Enumerable); ok {
+		return enum.Keys(relation)
+	}
+	return nil
+}
+
+func (s *AuditStorage) Put(relation, key string, value map[string]any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, _ := s.Storage.Get(relation, key)
+	if s.rejected(s.append("put", relation, key, old, value)) {
+		return
+	}
+	s.Storage.Put(relation, key, value)
+}
+
+func (s *AuditStorage) Delete(relation, key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.Storage.Get(relation, key)
+	if !ok {
+		return false
+	}
+	if s.rejected(s.append("delete", relation, key, old, nil)) {
+		return false
+	}
+	return s.Storage.Delete(relation, key)
+}
+
+func (s *AuditStorage) BulkPut(relation string, entries map[string]map[string]any) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, value := range entries {
+		old, _ := s.Storage.Get(relation, key)
+		if s.rejected(s.append("put", relation, key, old, value)) {
+			return 0
+		}
+	}
+	return s.Storage.BulkPut(relation, entries)
+}
+
+func (s *AuditStorage) BulkDelete(relation string, keys []string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		if old, ok := s.Storage.Get(relation, key); ok {
+			if s.rejected(s.append("delete", relation, key, old, nil)) {
+				return 0
+			}
+		}
+	}
+	return s.Storage.BulkDelete(relation, keys)
+}
+
+// CompareAndSwap records a "put" only when the swap happens.
+func (s *AuditStorage) CompareAndSwap(relation, key string, expected, replacement map[string]any, compareFields []string) (bool, map[string]any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, live := s.Storage.Get(relation, key)
+	if !casMatches(old, live, expected, compareFields) || s.rejected(s.append("put", relation, key, old, replacement)) {
+		return false, old
+	}
+	return s.Storage.CompareAndSwap(relation, key, expected, replacement, compareFields)
+}
+
+// MergeRelation implements Merger. An existing entry merged with
+// MergePatch is recorded as a "patch" whose NewValue is the merged entry;
+// every other write is a "put".
+func (s *AuditStorage) MergeRelation(relation string, entries map[string]map[string]any, strategy MergeStrategy) (int, error) {
+	if err := strategy.validate(); err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, value := range entries {
+		prev, existed := s.Storage.Get(relation, key)
+		op := "put"
+		if existed {
+			merged, written := strategy.merge(prev, value)
+			if !written {
+				continue
+			}
+			if strategy == MergePatch {
+				op = "patch"
+			}
+			value = merged
+		}
+		if s.rejected(s.append(op, relation, key, prev, value)) {
+			return 0, nil
+		}
+	}
+	return MergeRelation(s.Storage, relation, entries, strategy)
+}
+
+// rejected reports err unless nil, and whether the write must be dropped:
+// because of err, or because an earlier storage error was reported in
+// the same handler call.
+func (s *AuditStorage) rejected(err error) bool {
+	if err != nil {
+		ReportStorageError(s.ctx, err)
+		return true
+	}
+	return storageFailed(s.ctx)
+}
+
+func (s *AuditStorage) append(op, relation, key string, old, value map[string]any) error {
+	inv, _ := InvocationFromContext(s.ctx)
+	err := s.sink.Append(AuditRecord{
+		Timestamp:     time.Now().UTC(),
+		Operation:     op,
+		Relation:      relation,
+		Key:           key,
+		OldValue:      old,
+		NewValue:      value,
+		CallerAction:  inv.Action,
+		CallerConcept: inv.Concept,
+	})
+	if err != nil {
+		return fmt.Errorf("clef: audit %s %s/%s: %w", op, relation, key, err)
+	}
+	return nil
+}
+
+// fileAuditSink writes records as JSON lines, each carrying a hash that
+// chains it to the line before.
+type fileAuditSink struct {
+	mu   sync.Mutex
+	w    io.Writer
+	prev string
+}
+
+// auditLine is one line of a FileAuditSink log.
+type auditLine struct {
+	AuditRecord
+	// Hash is the hex SHA-256 of the previous line's hash followed by
+	// this record's JSON encoding.
+	Hash string `json:"hash"`
+}
+
+// FileAuditSink writes each record to w as a line of JSON. Every line
+// includes a hash chained to the previous line, so VerifyAuditLog can
+// detect edited, removed, or reordered records.
+func FileAuditSink(w io.Writer) AuditSink {
+	return &fileAuditSink{w: w}
+}
+
+func (s *fileAuditSink) Append(record AuditRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash, err := chainHash(s.prev, record)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(auditLine{AuditRecord: record, Hash: hash})
+	if err != nil {
+		return err
+	}
+	if _, err := s.w.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	s.prev = hash
+	return nil
+}
+
+// chainHash hashes prev followed by the canonical JSON encoding of
+// record: the encoding of record as VerifyAuditLog decodes it, with
+// numbers kept as written and object keys sorted. Hashing record's own
+// encoding would make a line fail verification whenever decoding
+// changes a value, such as an int64 beyond float64 precision or a
+// struct whose fields are not in key order.
+func chainHash(prev string, record AuditRecord) (string, error) {
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var canonical any
+	if err := dec.Decode(&canonical); err != nil {
+		return "", err
+	}
+	if raw, err = json.Marshal(canonical); err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(append([]byte(prev), raw...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// VerifyAuditLog reads a log written by FileAuditSink and returns the
+// records, or an error naming the first line whose hash does not match.
+// Numbers in the returned records are json.Numbers, so large integers
+// keep their exact value.
+func VerifyAuditLog(r io.Reader) ([]AuditRecord, error) {
+	var records []AuditRecord
+	prev := ""
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 16<<20)
+	for n := 1; sc.Scan(); n++ {
+		var line auditLine
+		dec := json.NewDecoder(bytes.NewReader(sc.Bytes()))
+		dec.UseNumber()
+		if err := dec.Decode(&line); err != nil {
+			return records, fmt.Errorf("audit log line %d: %w", n, err)
+		}
+		want, err := chainHash(prev, line.AuditRecord)
+		if err != nil {
+			return records, err
+		}
+		if line.Hash != want {
+			return records, fmt.Errorf("audit log line %d: hash mismatch", n)
+		}
+		records = append(records, line.AuditRecord)
+		prev = line.Hash
+	}
+	return records, sc.Err()
+}
+
+type nopAuditSink struct{}
+
+func (nopAuditSink) Append(AuditRecord) error { return nil }
+
+// NopAuditSink discards every record.
+func NopAuditSink() AuditSink {
+	return nopAuditSink{}
+}
@@ -0,0 +1,173 @@
+package clef
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// sliceSink collects records in memory.
+type sliceSink struct {
+	records []AuditRecord
+}
+
+func (s *sliceSink) Append(r AuditRecord) error {
+	s.records = append(s.records, r)
+	return nil
+}
+
+func TestAuditStorageRecordsMutationsInOrder(t *testing.T) {
+	sink := &sliceSink{}
+	storage := NewAuditStorage(NewInMemoryStorage(), sink)
+	ctx := ContextWithInvocation(context.Background(), ActionInvocation{Concept: "urn:test/Ledger", Action: "post"})
+	s := storage.WithContext(ctx)
+
+	s.Put("accounts", "a1", map[string]any{"balance": 10})
+	s.Put("accounts", "a1", map[string]any{"balance": 25})
+	s.Delete("accounts", "a1")
+	s.Delete("accounts", "a1")
+	s.Get("accounts", "a1")
+
+	want := []struct {
+		op       string
+		old, new any
+	}{
+		{"put", nil, 10},
+		{"put", 10, 25},
+		{"delete", 25, nil},
+	}
+	if len(sink.records) != len(want) {
+		t.Fatalf("expected %d records, got %d: %+v", len(want), len(sink.records), sink.records)
+	}
+	for i, w := range want {
+		r := sink.records[i]
+		if r.Operation != w.op || r.Relation != "accounts" || r.Key != "a1" {
+			t.Errorf("record %d: unexpected %+v", i, r)
+		}
+		if got := r.OldValue["balance"]; got != w.old {
+			t.Errorf("record %d: expected old balance %v, got %v", i, w.old, got)
+		}
+		if got := r.NewValue["balance"]; got != w.new {
+			t.Errorf("record %d: expected new balance %v, got %v", i, w.new, got)
+		}
+		if r.CallerConcept != "urn:test/Ledger" || r.CallerAction != "post" {
+			t.Errorf("record %d: expected caller urn:test/Ledger/post, got %s/%s", i, r.CallerConcept, r.CallerAction)
+		}
+		if i > 0 && r.Timestamp.Before(sink.records[i-1].Timestamp) {
+			t.Errorf("record %d out of order", i)
+		}
+	}
+}
+
+func TestAuditStorageThroughTransport(t *testing.T) {
+	resetRegistry()
+	sink := &sliceSink{}
+	Register("urn:test/Profile", profileHandler{}, NewAuditStorage(NewInMemoryStorage(), sink))
+
+	invokeRecorder(t, `{"concept":"urn:test/Profile","action":"update","input":{"id":"alice"}}`)
+	if len(sink.records) != 1 || sink.records[0].CallerAction != "update" || sink.records[0].CallerConcept != "urn:test/Profile" {
+		t.Errorf("expected caller from invocation context, got %+v", sink.records)
+	}
+}
+
+// failingSink rejects every record.
+type failingSink struct{}
+
+func (failingSink) Append(AuditRecord) error {
+	return errors.New("audit log unavailable")
+}
+
+func TestAuditStorageFailsWritesItCannotRecord(t *testing.T) {
+	resetRegistry()
+	inner := NewInMemoryStorage()
+	Register("urn:test/Profile", profileHandler{}, NewAuditStorage(inner, failingSink{}))
+
+	c := InvokeLocal(context.Background(), ActionInvocation{Concept: "urn:test/Profile", Action: "update", Input: map[string]any{"id": "alice"}})
+	if c.Variant != "error" || c.Output["code"] != "storage_error" {
+		t.Errorf("completion = %s %v, want a storage error", c.Variant, c.Output)
+	}
+	if keys := inner.Keys("profiles"); len(keys) != 0 {
+		t.Errorf("unrecorded writes applied: %v", keys)
+	}
+}
+
+func TestFileAuditSinkIsTamperEvident(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewAuditStorage(NewInMemoryStorage(), FileAuditSink(&buf))
+	s.Put("accounts", "a1", map[string]any{"balance": 10})
+	s.Put("accounts", "a1", map[string]any{"balance": 20})
+	s.BulkDelete("accounts", []string{"a1"})
+
+	records, err := VerifyAuditLog(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[2].Operation != "delete" {
+		t.Fatalf("unexpected records %+v", records)
+	}
+
+	tampered := strings.Replace(buf.String(), `"balance":20`, `"balance":99`, 1)
+	if _, err := VerifyAuditLog(strings.NewReader(tampered)); err == nil {
+		t.Error("expected edited log to fail verification")
+	}
+	lines := strings.SplitAfter(buf.String(), "\n")
+	if _, err := VerifyAuditLog(strings.NewReader(lines[0] + lines[2])); err == nil {
+		t.Error("expected log with a removed line to fail verification")
+	}
+}
+
+func TestVerifyAuditLogCanonicalizesValues(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewAuditStorage(NewInMemoryStorage(), FileAuditSink(&buf))
+	type account struct {
+		Owner string `json:"owner"`
+		Cents int64  `json:"cents"`
+	}
+	s.Put("accounts", "a1", map[string]any{"id": int64(9007199254740993), "account": account{"ada", 1}})
+
+	records, err := VerifyAuditLog(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := records[0].NewValue["id"]; got != json.Number("9007199254740993") {
+		t.Errorf("id = %v (%T), want the exact number", got, got)
+	}
+}
+
+func TestAuditStorageRecordsPatches(t *testing.T) {
+	sink := &sliceSink{}
+	s := NewAuditStorage(NewInMemoryStorage(), sink)
+	s.Put("accounts", "a1", map[string]any{"owner": "ada", "balance": 10})
+
+	n, err := MergeRelation(s, "accounts", map[string]map[string]any{
+		"a1": {"balance": 20},
+		"a2": {"owner": "bob"},
+	}, MergePatch)
+	if err != nil || n != 2 {
+		t.Fatalf("MergeRelation = %d, %v", n, err)
+	}
+	if len(sink.records) != 3 {
+		t.Fatalf("expected 3 records, got %+v", sink.records)
+	}
+	byKey := map[string]AuditRecord{}
+	for _, r := range sink.records[1:] {
+		byKey[r.Key] = r
+	}
+	if r := byKey["a1"]; r.Operation != "patch" || r.OldValue["balance"] != 10 || r.NewValue["balance"] != 20 || r.NewValue["owner"] != "ada" {
+		t.Errorf("patch record = %+v", r)
+	}
+	if r := byKey["a2"]; r.Operation != "put" || r.OldValue != nil {
+		t.Errorf("insert record = %+v", r)
+	}
+}
+
+func TestNopAuditSink(t *testing.T) {
+	s := NewAuditStorage(NewInMemoryStorage(), NopAuditSink())
+	s.Put("r", "k", map[string]any{"v": 1})
+	if v, ok := s.Get("r", "k"); !ok || v["v"] != 1 {
+		t.Errorf("expected write to pass through, got %v", v)
+	}
+}
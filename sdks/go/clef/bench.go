@@ -0,0 +1,110 @@
+package clef
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"math"
+	"net/http"
+	"runtime"
+	"sort"
+	"time"
+)
+
+// maxBenchIterations bounds a single bench request.
+const maxBenchIterations = 100000
+
+// WithBenchEndpoint enables POST /bench/{concept}/{action}, which runs a
+// handler repeatedly against its registered storage and reports timings.
+// Requests must carry "Authorization: Bearer <adminToken>". Benchmarks
+// write to the concept's real storage, so use inputs that are safe to
+// repeat.
+func WithBenchEndpoint(adminToken string) ServeOption {
+	return func(c *ServerConfig) {
+		c.benchToken = adminToken
+	}
+}
+
+// BenchRequest is the body of POST /bench/{concept}/{action}.
+type BenchRequest struct {
+	Iterations int            `json:"iterations"`
+	Input      map[string]any `json:"input"`
+}
+
+// BenchResult is the response of POST /bench/{concept}/{action}.
+type BenchResult struct {
+	TotalMs     float64 `json:"total_ms"`
+	MeanUs      float64 `json:"mean_us"`
+	P99Us       float64 `json:"p99_us"`
+	AllocsPerOp float64 `json:"allocs_per_op"`
+}
+
+// handleBench serves POST /bench/{concept}/{action}. {concept} is the
+// concept's slug as in /invoke/{concept}/{action}.
+func (s *server) handleBench(w http.ResponseWriter, r *http.Request) {
+	if s.config.benchToken == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorized(r, s.config.benchToken) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	slug := r.PathValue("concept")
+	inv := ActionInvocation{Concept: conceptForSlug(slug), Action: r.PathValue("action")}
+	if inv.Concept == "" {
+		http.Error(w, "unknown concept: "+slug, http.StatusNotFound)
+		return
+	}
+	var req BenchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Iterations < 1 || req.Iterations > maxBenchIterations {
+		http.Error(w, "iterations must be between 1 and 100000", http.StatusBadRequest)
+		return
+	}
+	inv.Input = req.Input
+
+	entry, _ := s.lookup(inv)
+	s.writeJSON(w, r, bench(r, entry, inv, req.Iterations))
+}
+
+// bench calls the handler n times, timing each call. Allocations are
+// counted process-wide, so concurrent traffic inflates allocs_per_op.
+func bench(r *http.Request, entry registryEntry, inv ActionInvocation, n int) BenchResult {
+	ctx := ContextWithInvocation(r.Context(), inv)
+	durations := make([]time.Duration, n)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	for i := range durations {
+		t := time.Now()
+		callHandler(ctx, entry.handler, inv.Action, inv.Input, entry.storage)
+		durations[i] = time.Since(t)
+	}
+	total := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	p99 := durations[int(math.Ceil(float64(n)*0.99))-1]
+	return BenchResult{
+		TotalMs:     float64(total) / float64(time.Millisecond),
+		MeanUs:      float64(total) / float64(n) / float64(time.Microsecond),
+		P99Us:       float64(p99) / float64(time.Microsecond),
+		AllocsPerOp: float64(after.Mallocs-before.Mallocs) / float64(n),
+	}
+}
+
+// authorized reports whether r carries "Authorization: Bearer <token>",
+// comparing in constant time.
+func authorized(r *http.Request, token string) bool {
+	want := "Bearer " + token
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(want)) == 1
+}
@@ -0,0 +1,64 @@
+package clef
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func benchRequest(h http.Handler, token, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestBenchEndpoint(t *testing.T) {
+	resetRegistry()
+	Register("urn:test/Echo", &echoHandler{}, nil)
+	h := NewHandler(WithBenchEndpoint("secret"))
+
+	rec := benchRequest(h, "secret", "/bench/test-Echo/echo", `{"iterations":10,"input":{"message":"hi"}}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var fields map[string]float64
+	if err := json.Unmarshal(rec.Body.Bytes(), &fields); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"total_ms", "mean_us", "p99_us", "allocs_per_op"} {
+		v, ok := fields[k]
+		if !ok || v < 0 {
+			t.Errorf("%s = %v (present %v)", k, v, ok)
+		}
+	}
+}
+
+func TestBenchEndpointRejects(t *testing.T) {
+	resetRegistry()
+	Register("urn:test/Echo", &echoHandler{}, nil)
+	body := `{"iterations":10,"input":{}}`
+
+	if rec := benchRequest(NewHandler(), "secret", "/bench/test-Echo/echo", body); rec.Code != http.StatusNotFound {
+		t.Errorf("disabled: status = %d", rec.Code)
+	}
+	h := NewHandler(WithBenchEndpoint("secret"))
+	for name, tc := range map[string]struct {
+		token, path, body string
+		want              int
+	}{
+		"no token":        {"", "/bench/test-Echo/echo", body, http.StatusUnauthorized},
+		"wrong token":     {"nope", "/bench/test-Echo/echo", body, http.StatusUnauthorized},
+		"unknown concept": {"secret", "/bench/test-Missing/echo", body, http.StatusNotFound},
+		"zero iterations": {"secret", "/bench/test-Echo/echo", `{"iterations":0}`, http.StatusBadRequest},
+	} {
+		if rec := benchRequest(h, tc.token, tc.path, tc.body); rec.Code != tc.want {
+			t.Errorf("%s: status = %d, want %d", name, rec.Code, tc.want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package clef
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// binaryOutputKey is the output field that carries a BinaryOutput.
+const binaryOutputKey = "_binary"
+
+// BinaryOutput lets a handler answer with raw bytes, such as an image or
+// a PDF, instead of a JSON completion. Put it in the result under
+// "_binary":
+//
+//	return map[string]any{
+//	    "variant": "ok",
+//	    "_binary": clef.BinaryOutput{ContentType: "image/png", Data: png},
+//	}
+//
+// POST /invoke then responds with Data as the body and ContentType as its
+// Content-Type; the variant is sent in the X-Clef-Variant header. Other
+// transports see it as an ordinary output field.
+type BinaryOutput struct {
+	ContentType string `json:"contentType"`
+	Data        []byte `json:"data"`
+}
+
+// binaryOutput returns the BinaryOutput in c's output, if any.
+func binaryOutput(c *ActionCompletion) (BinaryOutput, bool) {
+	switch b := c.Output[binaryOutputKey].(type) {
+	case BinaryOutput:
+		return b, true
+	case *BinaryOutput:
+		if b != nil {
+			return *b, true
+		}
+	}
+	return BinaryOutput{}, false
+}
+
+// writeBinary writes b as the raw response body for completion c.
+func writeBinary(w http.ResponseWriter, c *ActionCompletion, b BinaryOutput) {
+	contentType := b.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(b.Data)))
+	w.Header().Set("X-Clef-Variant", c.Variant)
+	w.WriteHeader(c.StatusCode())
+	w.Write(b.Data)
+}
@@ -0,0 +1,43 @@
+package clef
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+)
+
+var pngBlob = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestBinaryOutputWritesRawBytes(t *testing.T) {
+	resetRegistry()
+	Register("urn:test/Image", HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		return map[string]any{"variant": "ok", "_binary": BinaryOutput{ContentType: "image/png", Data: pngBlob}}
+	}), nil)
+
+	rec := doRequest(NewHandler(), http.MethodPost, "/invoke", `{"concept":"urn:test/Image","action":"resize","input":{}}`)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "image/png" {
+		t.Errorf("expected image/png, got %q", ct)
+	}
+	if v := rec.Header().Get("X-Clef-Variant"); v != "ok" {
+		t.Errorf("expected variant header ok, got %q", v)
+	}
+	if !bytes.Equal(rec.Body.Bytes(), pngBlob) {
+		t.Errorf("expected raw PNG body, got %q", rec.Body.Bytes())
+	}
+}
+
+func TestBinaryOutputPointerAndErrorStatus(t *testing.T) {
+	resetRegistry()
+	Register("urn:test/Image", HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		return map[string]any{"variant": "error", "code": "not_found", "_binary": &BinaryOutput{Data: []byte("missing")}}
+	}), nil)
+
+	rec := doRequest(NewHandler(), http.MethodPost, "/invoke", `{"concept":"urn:test/Image","action":"get","input":{}}`)
+	if rec.Code != http.StatusNotFound || rec.Header().Get("Content-Type") != "application/octet-stream" || rec.Body.String() != "missing" {
+		t.Errorf("unexpected response %d %q %q", rec.Code, rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+}
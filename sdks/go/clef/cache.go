@@ -0,0 +1,280 @@
+package clef
+
+import (
+	"container/list"
+	"context"
+	"maps"
+	"path"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+	"weak"
+)
+
+// DefaultCacheMaxSize bounds a CachingMiddleware cache when
+// CacheOptions.MaxSize is zero.
+const DefaultCacheMaxSize = 1000
+
+// CacheOptions configures CachingMiddleware.
+type CacheOptions struct {
+	// TTL is how long an output is served from the cache.
+	TTL time.Duration
+	// KeyFunc returns the cache key of an invocation, or "" to bypass the
+	// cache. The default is "<action>:<SHA-256 of the input's JSON>" for
+	// the actions in Actions and "" for all others. The default leaves out
+	// "_"-prefixed metadata fields (see InputEnricher).
+	KeyFunc func(action string, input map[string]any) string
+	// Actions lists the read-only actions the default KeyFunc caches.
+	// With neither KeyFunc nor Actions nothing is cached, since caching a
+	// mutation would skip its writes.
+	Actions []string
+	// MaxSize is the number of entries kept; the least recently used is
+	// evicted first. Zero means DefaultCacheMaxSize.
+	MaxSize int
+}
+
+// caches holds every live CachingMiddleware and ContentAddressedCache
+// cache, for InvalidateCache. The pointers are weak, so caches of
+// handlers that are no longer used are collected and dropped.
+var (
+	cachesMu sync.Mutex
+	caches   []weak.Pointer[lruCache]
+)
+
+// registerCache adds c to caches, dropping collected caches.
+func registerCache(c *lruCache) {
+	cachesMu.Lock()
+	defer cachesMu.Unlock()
+	caches = slices.DeleteFunc(caches, func(p weak.Pointer[lruCache]) bool { return p.Value() == nil })
+	caches = append(caches, weak.Make(c))
+}
+
+// CachingMiddleware serves repeated invocations from a cache of handler
+// outputs until TTL expires. Only outputs whose variant is not "error"
+// are cached. Callers get their own copy of a cached output. Entries are
+// kept per concept and tenant, so a middleware shared by several
+// concepts, or a concept serving several tenants, never returns one's
+// output to another.
+//
+// Example:
+//
+//	clef.MustRegister("urn:app/Article", clef.Chain(&ArticleHandler{}, clef.CachingMiddleware(clef.CacheOptions{
+//	    TTL: 30 * time.Second,
+//	    KeyFunc: func(action string, input map[string]any) string {
+//	        if action != "get" {
+//	            return ""
+//	        }
+//	        return "article:" + input["slug"].(string)
+//	    },
+//	})), nil)
+func CachingMiddleware(opts CacheOptions) MiddlewareFunc {
+	if opts.KeyFunc == nil {
+		actions := make(map[string]bool, len(opts.Actions))
+		for _, a := range opts.Actions {
+			actions[a] = true
+		}
+		opts.KeyFunc = func(action string, input map[string]any) string {
+			if !actions[action] {
+				return ""
+			}
+			hash, err := inputHash(input)
+			if err != nil {
+				return ""
+			}
+			return action + ":" + hash
+		}
+	}
+	keyFunc := opts.KeyFunc
+	return newCachingMiddleware(opts.TTL, opts.MaxSize, func(ctx context.Context, action string, input map[string]any) string {
+		return keyFunc(action, input)
+	})
+}
+
+// newCachingMiddleware returns a middleware caching outputs under the
+// keys key returns, for ttl, in an LRU cache of maxSize entries
+// (DefaultCacheMaxSize if maxSize is zero).
+func newCachingMiddleware(ttl time.Duration, maxSize int, key func(ctx context.Context, action string, input map[string]any) string) MiddlewareFunc {
+	if maxSize <= 0 {
+		maxSize = DefaultCacheMaxSize
+	}
+	return func(next ConceptHandler) ConceptHandler {
+		cache := newLRUCache(maxSize)
+		registerCache(cache)
+		return &cachingHandler{next: next, cache: cache, ttl: ttl, key: key}
+	}
+}
+
+// cachingHandler is the handler CachingMiddleware and
+// ContentAddressedCache return.
+type cachingHandler struct {
+	next   ConceptHandler
+	cache  *lruCache
+	ttl    time.Duration
+	key    func(ctx context.Context, action string, input map[string]any) string
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// CacheStats returns the number of invocations served from the cache and
+// the number that ran the handler. Invocations bypassing the cache are
+// not counted.
+func (c *cachingHandler) CacheStats() (hits, misses int64) {
+	return c.hits.Load(), c.misses.Load()
+}
+
+func (c *cachingHandler) Handle(action string, input map[string]any, storage Storage) map[string]any {
+	return c.HandleContext(context.Background(), action, input, storage)
+}
+
+func (c *cachingHandler) HandleContext(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+	key := c.key(ctx, action, input)
+	if key == "" {
+		return callHandler(ctx, c.next, action, input, storage)
+	}
+	scope := cacheScope(ctx)
+	if output, ok := c.cache.get(scope, key); ok {
+		c.hits.Add(1)
+		return maps.Clone(output)
+	}
+	c.misses.Add(1)
+	output := callHandler(ctx, c.next, action, input, storage)
+	if output["variant"] != "error" {
+		c.cache.put(scope, key, maps.Clone(output), c.ttl)
+	}
+	return output
+}
+
+// cacheScopeKey is the concept and tenant an entry was cached for.
+type cacheScopeKey struct {
+	concept string
+	tenant  string
+}
+
+// cacheScope returns the concept and tenant of the invocation in ctx.
+func cacheScope(ctx context.Context) cacheScopeKey {
+	inv, _ := InvocationFromContext(ctx)
+	tenant, _ := TenantFromContext(ctx)
+	return cacheScopeKey{concept: inv.Concept, tenant: tenant}
+}
+
+// InvalidateCache removes the entries of concept whose keys match
+// pattern, in the syntax of path.Match, from every CachingMiddleware
+// cache, for all tenants. The keys of ContentAddressedCache start with
+// "<action>:", so "<action>:*" drops an action's entries. Handlers
+// called outside the transport, without an invocation in their context,
+// cache under the concept "".
+//
+// Example:
+//
+//	clef.InvalidateCache("urn:app/Article", "article:*")
+func InvalidateCache(concept, pattern string) {
+	cachesMu.Lock()
+	defer cachesMu.Unlock()
+	for _, p := range caches {
+		if c := p.Value(); c != nil {
+			c.invalidate(concept, pattern)
+		}
+	}
+}
+
+// ContentAddressedCache serves repeated invocations with the same
+// action, input and caller claims from a cache for ttl, without any help
+// from the client. It is a CachingMiddleware whose key is the action and
+// the SHA-256 of the input and of ClaimsFromContext, so every action is
+// cached and callers with different claims, like different tenants and
+// concepts, never share an entry. Outputs with the "error" variant are
+// not cached. At most maxSize outputs are kept (DefaultCacheMaxSize if
+// maxSize is zero), evicting the least recently used.
+//
+// The handler it returns, like CachingMiddleware's, reports its hit and
+// miss counts through a CacheStats method:
+//
+//	cached := clef.ContentAddressedCache(time.Minute, 0)(&ArticleHandler{})
+//	hits, misses := cached.(interface{ CacheStats() (int64, int64) }).CacheStats()
+func ContentAddressedCache(ttl time.Duration, maxSize int) MiddlewareFunc {
+	return newCachingMiddleware(ttl, maxSize, func(ctx context.Context, action string, input map[string]any) string {
+		hash, err := inputHash(input)
+		if err != nil {
+			return ""
+		}
+		claims, err := jsonHash(ClaimsFromContext(ctx))
+		if err != nil {
+			return ""
+		}
+		return action + ":" + hash + ":" + claims
+	})
+}
+
+// lruCache is a size-bounded cache with per-entry expiry.
+type lruCache struct {
+	mu      sync.Mutex
+	maxSize int
+	order   *list.List // front is most recently used
+	entries map[cacheKey]*list.Element
+}
+
+type cacheKey struct {
+	scope cacheScopeKey
+	key   string
+}
+
+type cacheEntry struct {
+	key       cacheKey
+	output    map[string]any
+	expiresAt time.Time
+}
+
+func newLRUCache(maxSize int) *lruCache {
+	return &lruCache{maxSize: maxSize, order: list.New(), entries: make(map[cacheKey]*list.Element)}
+}
+
+func (c *lruCache) get(scope cacheScopeKey, k string) (map[string]any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := cacheKey{scope, k}
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*cacheEntry)
+	if time.Now().After(e.expiresAt) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return e.output, true
+}
+
+func (c *lruCache) put(scope cacheScopeKey, k string, output map[string]any, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := cacheKey{scope, k}
+	e := &cacheEntry{key: key, output: output, expiresAt: time.Now().Add(ttl)}
+	if el, ok := c.entries[key]; ok {
+		el.Value = e
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(e)
+	for c.order.Len() > c.maxSize {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+func (c *lruCache) invalidate(concept, pattern string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, el := range c.entries {
+		if key.scope.concept != concept {
+			continue
+		}
+		if ok, _ := path.Match(pattern, key.key); ok {
+			c.order.Remove(el)
+			delete(c.entries, key)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package clef
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCachingMiddleware(t *testing.T) {
+	calls := 0
+	inner := HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		calls++
+		return map[string]any{"variant": "ok", "calls": calls}
+	})
+	h := Chain(inner, CachingMiddleware(CacheOptions{TTL: 30 * time.Millisecond, Actions: []string{"get"}}))
+	s := NewInMemoryStorage()
+	input := map[string]any{"slug": "hello"}
+
+	first := h.Handle("get", input, s)
+	second := h.Handle("get", input, s)
+	if calls != 1 || second["calls"] != 1 {
+		t.Fatalf("handler called %d times, second output %v; want one call", calls, second)
+	}
+	second["calls"] = 99
+	if first["calls"] != 1 {
+		t.Error("callers must not share the cached map")
+	}
+	h.Handle("get", map[string]any{"slug": "other"}, s)
+	if calls != 2 {
+		t.Errorf("different input should miss the cache, calls = %d", calls)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	if out := h.Handle("get", input, s); calls != 3 || out["calls"] != 3 {
+		t.Errorf("after TTL: calls = %d, output %v; want a fresh call", calls, out)
+	}
+}
+
+func TestCachingMiddlewareInvalidateAndEvict(t *testing.T) {
+	calls := map[string]int{}
+	inner := HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		calls[input["id"].(string)]++
+		return map[string]any{"variant": "ok"}
+	})
+	h := Chain(inner, CachingMiddleware(CacheOptions{
+		TTL:     time.Hour,
+		MaxSize: 2,
+		KeyFunc: func(action string, input map[string]any) string {
+			return fmt.Sprintf("cachetest:%s:%s", action, input["id"])
+		},
+	}))
+	s := NewInMemoryStorage()
+	get := func(id string) { h.Handle("get", map[string]any{"id": id}, s) }
+
+	get("a")
+	get("b")
+	InvalidateCache("urn:test/Other", "cachetest:get:*")
+	get("a")
+	if calls["a"] != 1 {
+		t.Fatalf("invalidating another concept dropped a: calls = %v", calls)
+	}
+	InvalidateCache("", "cachetest:get:a")
+	get("a")
+	get("b")
+	if calls["a"] != 2 || calls["b"] != 1 {
+		t.Fatalf("after invalidating a: calls = %v", calls)
+	}
+
+	get("c") // evicts a, the least recently used
+	get("b")
+	get("a")
+	if calls["a"] != 3 || calls["b"] != 1 || calls["c"] != 1 {
+		t.Errorf("after eviction: calls = %v", calls)
+	}
+}
+
+func TestContentAddressedCache(t *testing.T) {
+	resetRegistry()
+	var calls atomic.Int64
+	handler := HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		calls.Add(1)
+		if input["slug"] == "missing" {
+			return map[string]any{"variant": "error", "message": "not found"}
+		}
+		return map[string]any{"variant": "ok", "slug": input["slug"]}
+	})
+	cached := ContentAddressedCache(time.Minute, 10)(handler)
+	Register("urn:test/Article", cached, nil)
+	Register("urn:test/Draft", cached, nil)
+
+	get := func(concept, slug string) ActionCompletion {
+		return InvokeLocal(context.Background(), ActionInvocation{Concept: concept, Action: "get", Input: map[string]any{"slug": slug}})
+	}
+	for range 100 {
+		if c := get("urn:test/Article", "a1"); c.Output["slug"] != "a1" {
+			t.Fatalf("output = %v", c.Output)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("handler called %d times, want 1", n)
+	}
+	stats := cached.(interface{ CacheStats() (int64, int64) })
+	if hits, misses := stats.CacheStats(); hits != 99 || misses != 1 {
+		t.Errorf("CacheStats = %d hits, %d misses; want 99, 1", hits, misses)
+	}
+
+	// The concept is part of the key.
+	get("urn:test/Draft", "a1")
+	if n := calls.Load(); n != 2 {
+		t.Errorf("same input to another concept: handler called %d times, want 2", n)
+	}
+	// Errors are not cached.
+	get("urn:test/Article", "missing")
+	get("urn:test/Article", "missing")
+	if n := calls.Load(); n != 4 {
+		t.Errorf("error outputs: handler called %d times, want 4", n)
+	}
+}
+
+func TestContentAddressedCacheKeyedByClaimsAndTenant(t *testing.T) {
+	calls := 0
+	cached := ContentAddressedCache(time.Minute, 0)(HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		calls++
+		return map[string]any{"variant": "ok", "user": ClaimsFromContext(ctx)["sub"]}
+	}))
+	get := func(ctx context.Context) map[string]any {
+		return callHandler(ctx, cached, "me", map[string]any{}, nil)
+	}
+	alice := ContextWithClaims(context.Background(), map[string]any{"sub": "alice"})
+	bob := ContextWithClaims(context.Background(), map[string]any{"sub": "bob"})
+
+	get(alice)
+	if out := get(bob); out["user"] != "bob" {
+		t.Fatalf("bob got %v", out)
+	}
+	if out := get(context.WithValue(alice, tenantKey{}, "acme")); out["user"] != "alice" || calls != 3 {
+		t.Fatalf("alice in another tenant got %v after %d calls, want 3", out, calls)
+	}
+	if get(alice); calls != 3 {
+		t.Errorf("repeated call ran the handler: %d calls", calls)
+	}
+}
+
+func TestCachingMiddlewareDefaultCachesOnlyDeclaredActions(t *testing.T) {
+	calls := 0
+	inner := HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		calls++
+		return map[string]any{"variant": "ok"}
+	})
+	s := NewInMemoryStorage()
+	input := map[string]any{"slug": "a"}
+
+	h := Chain(inner, CachingMiddleware(CacheOptions{TTL: time.Hour}))
+	h.Handle("get", input, s)
+	h.Handle("get", input, s)
+	if calls != 2 {
+		t.Fatalf("no actions declared: handler called %d times, want 2", calls)
+	}
+
+	calls = 0
+	h = Chain(inner, CachingMiddleware(CacheOptions{TTL: time.Hour, Actions: []string{"get"}}))
+	h.Handle("update", input, s)
+	h.Handle("update", input, s)
+	h.Handle("get", input, s)
+	h.Handle("get", input, s)
+	if calls != 3 {
+		t.Fatalf("handler called %d times, want 3 (two updates, one get)", calls)
+	}
+}
+
+func TestCachingMiddlewareScopedByConceptAndTenant(t *testing.T) {
+	resetRegistry()
+	var calls atomic.Int64
+	cached := Chain(HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		calls.Add(1)
+		return map[string]any{"variant": "ok"}
+	}), CachingMiddleware(CacheOptions{TTL: time.Hour, Actions: []string{"get"}}))
+	Register("urn:test/Article", cached, nil)
+	Register("urn:test/Draft", cached, nil)
+
+	get := func(concept, tenant string) {
+		InvokeLocal(context.Background(), ActionInvocation{Concept: concept, Action: "get", Input: map[string]any{"_tenant": tenant}})
+	}
+	get("urn:test/Article", "acme")
+	get("urn:test/Article", "acme")
+	get("urn:test/Draft", "acme")
+	get("urn:test/Article", "globex")
+	if n := calls.Load(); n != 3 {
+		t.Fatalf("handler called %d times, want 3", n)
+	}
+}
@@ -0,0 +1,141 @@
+package clef
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ChunkedAction is an optional interface for handlers whose results are
+// too large to buffer. Over HTTP, POST /invoke and
+// /invoke/{concept}/{action} call HandleChunked instead of Handle and
+// stream each chunk passed to flush as one line of newline-delimited
+// JSON, ending with {"variant": "done"}. flush returns an error once the
+// client has gone away, and ctx is cancelled with it, so the handler can
+// stop early. Other transports, and handlers wrapped by Chain, use
+// Handle.
+//
+// Example:
+//
+//	func (h *ExportHandler) HandleChunked(ctx context.Context, action string, input map[string]any, storage clef.Storage, flush func(map[string]any) error) {
+//	    for _, page := range pages(storage) {
+//	        if err := flush(map[string]any{"variant": "ok", "items": page}); err != nil {
+//	            return
+//	        }
+//	    }
+//	}
+type ChunkedAction interface {
+	HandleChunked(ctx context.Context, action string, input map[string]any, storage Storage, flush func(chunk map[string]any) error)
+}
+
+// doneChunk is the last chunk of every streamed response.
+var doneChunk = map[string]any{"variant": "done"}
+
+type chunkWriterKey struct{}
+
+// chunkWriter streams chunks to an HTTP response.
+type chunkWriter struct {
+	mu      sync.Mutex
+	w       http.ResponseWriter
+	started bool
+}
+
+func (cw *chunkWriter) write(chunk map[string]any) error {
+	line, err := json.Marshal(chunk)
+	if err != nil {
+		return err
+	}
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	if !cw.started {
+		cw.w.Header().Set("Content-Type", "application/x-ndjson")
+		cw.w.Header().Set("Transfer-Encoding", "chunked")
+		cw.w.WriteHeader(http.StatusOK)
+		cw.started = true
+	}
+	if _, err := cw.w.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return http.NewResponseController(cw.w).Flush()
+}
+
+func (cw *chunkWriter) hasStarted() bool {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	return cw.started
+}
+
+// serveInvocation dispatches inv and writes its response, streaming it
+// when the concept's handler is a ChunkedAction.
+func (s *server) serveInvocation(w http.ResponseWriter, r *http.Request, inv ActionInvocation) {
+	cw := &chunkWriter{w: w}
+	c := s.dispatchHTTP(r.WithContext(context.WithValue(r.Context(), chunkWriterKey{}, cw)), inv)
+	if cw.hasStarted() {
+		return
+	}
+	s.writeCompletion(w, r, &c)
+}
+
+// invokeChunked runs a ChunkedAction, streaming its chunks to cw, and
+// returns the completion for the transport's bookkeeping.
+func invokeChunked(ctx context.Context, entry registryEntry, h ChunkedAction, inv ActionInvocation, cw *chunkWriter) ActionCompletion {
+	if entry.options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, entry.options.Timeout)
+		defer cancel()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	var last map[string]any
+	flush := func(chunk map[string]any) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := cw.write(chunk); err != nil {
+			cancel()
+			return err
+		}
+		mu.Lock()
+		last = chunk
+		mu.Unlock()
+		return nil
+	}
+	storageErr := func() map[string]any {
+		ctx, errs := withStorageErrors(ctx)
+		h.HandleChunked(ctx, inv.Action, inv.Input, bindStorage(ctx, entry.storage), flush)
+		if err := errs.since(0); err != nil {
+			return storageErrorResult(err)
+		}
+		return nil
+	}()
+	if storageErr != nil {
+		flush(storageErr)
+	}
+
+	mu.Lock()
+	output := last
+	mu.Unlock()
+	if output["variant"] != "done" {
+		if err := flush(doneChunk); err == nil {
+			output = doneChunk
+		}
+	}
+	variant, _ := output["variant"].(string)
+	if ctx.Err() != nil {
+		variant = "error"
+	}
+	return ActionCompletion{
+		ID:        inv.ID,
+		Concept:   inv.Concept,
+		Action:    inv.Action,
+		Input:     inv.Input,
+		Variant:   variant,
+		Output:    output,
+		Flow:      inv.Flow,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+}
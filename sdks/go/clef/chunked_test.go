@@ -0,0 +1,127 @@
+package clef
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pagesHandler streams input["pages"] chunks, or pages until the client
+// goes away when pages is 0.
+type pagesHandler struct {
+	echoHandler
+	stopped chan error
+}
+
+func (h *pagesHandler) HandleChunked(ctx context.Context, action string, input map[string]any, storage Storage, flush func(map[string]any) error) {
+	pages, _ := input["pages"].(float64)
+	for i := 0; pages == 0 || i < int(pages); i++ {
+		if err := flush(map[string]any{"variant": "ok", "page": i}); err != nil {
+			<-ctx.Done()
+			h.stopped <- err
+			return
+		}
+		if pages == 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+func TestChunkedAction(t *testing.T) {
+	resetRegistry()
+	Register("urn:test/Export", &pagesHandler{}, nil)
+	srv := httptest.NewServer(NewHandler())
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/invoke", "application/json", strings.NewReader(`{"concept":"urn:test/Export","action":"export","input":{"pages":3}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got := resp.TransferEncoding; len(got) != 1 || got[0] != "chunked" {
+		t.Errorf("Transfer-Encoding = %v, want chunked", got)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	var chunks []map[string]any
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var chunk map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &chunk); err != nil {
+			t.Fatalf("chunk %q: %v", scanner.Text(), err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	if len(chunks) != 4 {
+		t.Fatalf("got %d chunks, want 3 and done: %v", len(chunks), chunks)
+	}
+	for i, chunk := range chunks[:3] {
+		if chunk["page"] != float64(i) {
+			t.Errorf("chunk %d = %v", i, chunk)
+		}
+	}
+	if chunks[3]["variant"] != "done" {
+		t.Errorf("last chunk = %v, want the done marker", chunks[3])
+	}
+}
+
+func TestChunkedActionClientDisconnect(t *testing.T) {
+	resetRegistry()
+	h := &pagesHandler{stopped: make(chan error, 1)}
+	Register("urn:test/Export", h, nil)
+	srv := httptest.NewServer(NewHandler())
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/invoke", "application/json", strings.NewReader(`{"concept":"urn:test/Export","action":"export","input":{"pages":0}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bufio.NewReader(resp.Body).ReadString('\n')
+	resp.Body.Close()
+
+	select {
+	case err := <-h.stopped:
+		if err == nil {
+			t.Error("flush returned nil after disconnect")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler kept streaming after the client disconnected")
+	}
+}
+
+func TestChunkedActionBufferedElsewhere(t *testing.T) {
+	resetRegistry()
+	Register("urn:test/Export", &pagesHandler{}, nil)
+	c := InvokeLocal(context.Background(), ActionInvocation{Concept: "urn:test/Export", Action: "echo", Input: map[string]any{"pages": 3}})
+	if c.Variant != "ok" {
+		t.Errorf("InvokeLocal completion = %+v, want Handle's output", c)
+	}
+}
+
+func TestChunkedActionNestedCallDoesNotStream(t *testing.T) {
+	resetRegistry()
+	Register("urn:test/Export", &pagesHandler{}, nil)
+	Register("urn:test/Report", HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		c := InvokeLocal(ctx, ActionInvocation{Concept: "urn:test/Export", Action: "echo", Input: map[string]any{"message": "nested", "pages": 2}})
+		return map[string]any{"variant": "ok", "nested": c.Output["message"]}
+	}), nil)
+
+	rec := doRequest(NewHandler(), http.MethodPost, "/invoke", `{"concept":"urn:test/Report","action":"build"}`)
+	if ct := rec.Header().Get("Content-Type"); ct == "application/x-ndjson" {
+		t.Fatalf("nested chunked call streamed into the outer response: %s", rec.Body.String())
+	}
+	var c ActionCompletion
+	if err := json.Unmarshal(rec.Body.Bytes(), &c); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	if c.Variant != "ok" || c.Output["nested"] != "nested" {
+		t.Errorf("completion = %+v", c)
+	}
+}
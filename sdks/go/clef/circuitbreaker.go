@@ -0,0 +1,139 @@
+package clef
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// CircuitBreakerOptions configures CircuitBreakerMiddleware.
+type CircuitBreakerOptions struct {
+	// Threshold is the number of consecutive error completions that
+	// opens the circuit.
+	Threshold int
+	// Timeout is how long the circuit stays open before a single trial
+	// call is let through.
+	Timeout time.Duration
+	// OnOpen, if set, is called each time a circuit opens.
+	OnOpen func(concept, action string)
+}
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// circuit tracks one concept/action pair.
+type circuit struct {
+	state    circuitState
+	failures int
+	openedAt time.Time
+}
+
+// circuitBreaker holds the circuits of one CircuitBreakerMiddleware.
+// Only circuits with failures or open are kept, so calls to actions that
+// succeed, or do not exist, do not grow the map.
+type circuitBreaker struct {
+	opts     CircuitBreakerOptions
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+// CircuitBreakerMiddleware stops calling a failing handler so its
+// failures do not cascade. Each concept/action pair has its own circuit:
+// after Threshold consecutive "error" completions it opens, and calls
+// return a "circuit_open" error immediately. Once Timeout has passed one
+// trial call is allowed (half-open); success closes the circuit, failure
+// opens it again. Time is read from ClockFromContext, so WithClock and
+// ClockMiddleware apply.
+func CircuitBreakerMiddleware(opts CircuitBreakerOptions) MiddlewareFunc {
+	b := &circuitBreaker{opts: opts, circuits: make(map[string]*circuit)}
+	return b.middleware
+}
+
+func (b *circuitBreaker) middleware(next ConceptHandler) ConceptHandler {
+	return HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		inv, _ := InvocationFromContext(ctx)
+		key := inv.Concept + "\x00" + action
+		clock := ClockFromContext(ctx)
+		if !b.allow(key, clock.Now()) {
+			return circuitOpenOutput()
+		}
+
+		// The handler panicking counts as a failure, so a half-open
+		// trial never keeps its slot; the panic goes on to
+		// RecoveryMiddleware or the transport.
+		completed := false
+		defer func() {
+			if !completed {
+				b.record(key, true, clock.Now(), inv.Concept, action)
+			}
+		}()
+		result := callHandler(ctx, next, action, input, storage)
+		completed = true
+		b.record(key, result["variant"] == "error", clock.Now(), inv.Concept, action)
+		return result
+	})
+}
+
+// allow reports whether a call may go ahead, moving an open circuit whose
+// timeout has passed to half-open for a trial call.
+func (b *circuitBreaker) allow(key string, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c, ok := b.circuits[key]
+	if !ok {
+		return true
+	}
+	switch c.state {
+	case circuitOpen:
+		if now.Sub(c.openedAt) < b.opts.Timeout {
+			return false
+		}
+		c.state = circuitHalfOpen
+	case circuitHalfOpen:
+		// A trial call is already in flight.
+		return false
+	}
+	return true
+}
+
+// record moves the circuit at key on after a call, calling OnOpen if the
+// call opened it. A success forgets the circuit.
+func (b *circuitBreaker) record(key string, failed bool, now time.Time, concept, action string) {
+	b.mu.Lock()
+	opened := false
+	c, ok := b.circuits[key]
+	switch {
+	case !failed:
+		delete(b.circuits, key)
+	case ok && c.state == circuitHalfOpen:
+		c.state = circuitOpen
+		c.openedAt = now
+		opened = true
+	default:
+		if !ok {
+			c = &circuit{}
+			b.circuits[key] = c
+		}
+		c.failures++
+		if c.failures >= b.opts.Threshold {
+			c.state = circuitOpen
+			c.openedAt = now
+			c.failures = 0
+			opened = true
+		}
+	}
+	b.mu.Unlock()
+
+	if opened && b.opts.OnOpen != nil {
+		b.opts.OnOpen(concept, action)
+	}
+}
+
+func circuitOpenOutput() map[string]any {
+	return map[string]any{"variant": "error", "code": "circuit_open", "message": "circuit breaker open"}
+}
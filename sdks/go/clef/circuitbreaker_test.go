@@ -0,0 +1,159 @@
+package clef
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// flakyHandler fails while failing is set and counts its calls.
+type flakyHandler struct {
+	failing bool
+	calls   int
+}
+
+func (h *flakyHandler) Handle(action string, input map[string]any, storage Storage) map[string]any {
+	h.calls++
+	if h.failing {
+		return map[string]any{"variant": "error", "message": "downstream unavailable"}
+	}
+	return map[string]any{"variant": "ok"}
+}
+
+func TestCircuitBreakerOpensAndRecovers(t *testing.T) {
+	inner := &flakyHandler{failing: true}
+	var opened []string
+	h := Chain(inner, CircuitBreakerMiddleware(CircuitBreakerOptions{
+		Threshold: 3,
+		Timeout:   50 * time.Millisecond,
+		OnOpen:    func(concept, action string) { opened = append(opened, concept+" "+action) },
+	}))
+	ctx := ContextWithInvocation(context.Background(), ActionInvocation{Concept: "urn:test/Flaky"})
+	call := func() map[string]any {
+		return callHandler(ctx, h, "fetch", nil, NewInMemoryStorage())
+	}
+
+	for i := 0; i < 3; i++ {
+		if out := call(); out["code"] == "circuit_open" {
+			t.Fatalf("circuit opened early on call %d", i)
+		}
+	}
+	if len(opened) != 1 || opened[0] != "urn:test/Flaky fetch" {
+		t.Fatalf("expected OnOpen once, got %v", opened)
+	}
+
+	out := call()
+	if out["code"] != "circuit_open" {
+		t.Fatalf("expected circuit_open, got %v", out)
+	}
+	if inner.calls != 3 {
+		t.Errorf("open circuit must not call handler, calls=%d", inner.calls)
+	}
+
+	// After the timeout a failing trial call reopens the circuit.
+	time.Sleep(60 * time.Millisecond)
+	if out := call(); out["code"] == "circuit_open" || inner.calls != 4 {
+		t.Fatalf("expected half-open trial call, got %v", out)
+	}
+	if out := call(); out["code"] != "circuit_open" {
+		t.Fatalf("expected failed trial to reopen circuit, got %v", out)
+	}
+	if len(opened) != 2 {
+		t.Errorf("expected OnOpen again, got %v", opened)
+	}
+
+	// A successful trial closes it.
+	inner.failing = false
+	time.Sleep(60 * time.Millisecond)
+	if out := call(); out["variant"] != "ok" {
+		t.Fatalf("expected recovery, got %v", out)
+	}
+	if out := call(); out["variant"] != "ok" {
+		t.Errorf("expected closed circuit, got %v", out)
+	}
+}
+
+func TestCircuitBreakerSuccessResetsFailures(t *testing.T) {
+	inner := &flakyHandler{}
+	h := Chain(inner, CircuitBreakerMiddleware(CircuitBreakerOptions{Threshold: 2, Timeout: time.Minute}))
+	s := NewInMemoryStorage()
+
+	for i := 0; i < 5; i++ {
+		inner.failing = i%2 == 0
+		if out := h.Handle("fetch", nil, s); out["code"] == "circuit_open" {
+			t.Fatalf("non-consecutive failures must not open the circuit (call %d)", i)
+		}
+	}
+}
+
+func TestCircuitBreakerIsPerAction(t *testing.T) {
+	inner := &flakyHandler{failing: true}
+	h := Chain(inner, CircuitBreakerMiddleware(CircuitBreakerOptions{Threshold: 1, Timeout: time.Minute}))
+	s := NewInMemoryStorage()
+
+	h.Handle("fetch", nil, s)
+	if out := h.Handle("fetch", nil, s); out["code"] != "circuit_open" {
+		t.Fatalf("expected fetch circuit open, got %v", out)
+	}
+	inner.failing = false
+	if out := h.Handle("store", nil, s); out["variant"] != "ok" {
+		t.Errorf("expected store to be unaffected, got %v", out)
+	}
+}
+
+func TestCircuitBreakerPanickingTrialReopens(t *testing.T) {
+	inner := &flakyHandler{failing: true}
+	panicking := false
+	h := Chain(HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		if panicking {
+			panic("boom")
+		}
+		return inner.Handle(action, input, storage)
+	}), CircuitBreakerMiddleware(CircuitBreakerOptions{Threshold: 1, Timeout: 10 * time.Millisecond}))
+	s := NewInMemoryStorage()
+
+	h.Handle("fetch", nil, s)
+	time.Sleep(20 * time.Millisecond)
+	panicking = true
+	func() {
+		defer func() { recover() }()
+		h.Handle("fetch", nil, s)
+	}()
+
+	// The panicking trial reopened the circuit instead of holding the
+	// half-open slot, so a trial is let through once Timeout passes again.
+	panicking, inner.failing = false, false
+	if out := h.Handle("fetch", nil, s); out["code"] != "circuit_open" {
+		t.Fatalf("after the panicking trial = %v, want circuit_open", out)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if out := h.Handle("fetch", nil, s); out["variant"] != "ok" {
+		t.Fatalf("next trial = %v, want ok", out)
+	}
+}
+
+func TestCircuitBreakerUsesClockAndForgetsHealthyCircuits(t *testing.T) {
+	inner := &flakyHandler{failing: true}
+	b := &circuitBreaker{opts: CircuitBreakerOptions{Threshold: 1, Timeout: time.Minute}, circuits: make(map[string]*circuit)}
+	clock := NewMockClock(time.Unix(0, 0))
+	h := Chain(inner, ClockMiddleware(clock), b.middleware)
+	ctx := ContextWithInvocation(context.Background(), ActionInvocation{Concept: "urn:test/Flaky"})
+
+	callHandler(ctx, h, "fetch", nil, NewInMemoryStorage())
+	if out := callHandler(ctx, h, "fetch", nil, NewInMemoryStorage()); out["code"] != "circuit_open" {
+		t.Fatalf("expected circuit_open, got %v", out)
+	}
+	clock.Advance(time.Minute)
+	inner.failing = false
+	if out := callHandler(ctx, h, "fetch", nil, NewInMemoryStorage()); out["variant"] != "ok" {
+		t.Fatalf("expected a trial call once the clock passed the timeout, got %v", out)
+	}
+
+	for i := range 100 {
+		callHandler(ctx, h, fmt.Sprintf("action%d", i), nil, NewInMemoryStorage())
+	}
+	if n := len(b.circuits); n != 0 {
+		t.Errorf("breaker keeps %d circuits after only successful calls", n)
+	}
+}
@@ -1,7 +1,16 @@
 package clef
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 // ============================================================
@@ -88,6 +97,176 @@ func TestStorageFindWithFilter(t *testing.T) {
 	}
 }
 
+func TestStorageFindListFilter(t *testing.T) {
+	s := NewInMemoryStorage()
+	s.Put("articles", "a1", map[string]any{"slug": "a1", "tagList": []any{"go", "copf"}})
+	s.Put("articles", "a2", map[string]any{"slug": "a2", "tagList": []string{"rust"}})
+
+	if got := s.Find("articles", map[string]any{"tagList": []any{"go"}}); len(got) != 1 || got[0]["slug"] != "a1" {
+		t.Errorf("expected a1 for tag go, got %v", got)
+	}
+	if got := s.Find("articles", map[string]any{"tagList": []any{"java"}}); len(got) != 0 {
+		t.Errorf("expected no articles for tag java, got %v", got)
+	}
+	if got := s.Find("articles", map[string]any{"tagList": []any{"java", "rust"}}); len(got) != 1 || got[0]["slug"] != "a2" {
+		t.Errorf("expected a2 for tags java or rust, got %v", got)
+	}
+	if got := s.Find("articles", map[string]any{"slug": []any{"a1", "a2", "a3"}}); len(got) != 2 {
+		t.Errorf("expected IN filter to match both slugs, got %v", got)
+	}
+	if got := s.Find("articles", map[string]any{"tagList": "go"}); len(got) != 0 {
+		t.Errorf("expected scalar filter not to match a list field, got %v", got)
+	}
+}
+
+// testRange checks Range bounds on keys "a" to "e", inserted out of
+// order.
+func testRange(t *testing.T, s Storage) {
+	t.Helper()
+	for _, k := range []string{"d", "b", "e", "a", "c"} {
+		s.Put("queue", k, map[string]any{"k": k})
+	}
+	keys := func(records []map[string]any) string {
+		var out string
+		for _, r := range records {
+			out += r["k"].(string)
+		}
+		return out
+	}
+	for _, tc := range []struct {
+		start, end string
+		inclusive  bool
+		want       string
+	}{
+		{"b", "d", true, "bcd"},
+		{"b", "d", false, "c"},
+		{"", "c", true, "abc"},
+		{"c", "", false, "de"},
+		{"", "", true, "abcde"},
+		{"bb", "cc", true, "c"},
+	} {
+		if got := keys(s.Range("queue", tc.start, tc.end, tc.inclusive)); got != tc.want {
+			t.Errorf("Range(%q, %q, %v) = %q, want %q", tc.start, tc.end, tc.inclusive, got, tc.want)
+		}
+	}
+}
+
+func TestStorageRange(t *testing.T) {
+	s := NewInMemoryStorage()
+	testRange(t, s)
+
+	s.Delete("queue", "c")
+	s.SoftDelete("queue", "b")
+	if got := s.Range("queue", "a", "e", true); len(got) != 3 {
+		t.Errorf("expected deleted keys to be skipped, got %v", got)
+	}
+}
+
+func TestStorageMergeRelation(t *testing.T) {
+	existing := map[string]map[string]any{
+		"a": {"name": "A", "n": 1},
+		"b": {"name": "B", "n": 1},
+		"c": {"name": "C", "n": 1},
+	}
+	incoming := map[string]map[string]any{
+		"b": {"n": 2},
+		"c": {"n": 2},
+		"d": {"name": "D", "n": 2},
+		"e": {"name": "E", "n": 2},
+		"f": {"name": "F", "n": 2},
+	}
+	for _, tc := range []struct {
+		strategy MergeStrategy
+		written  int
+		b        map[string]any
+	}{
+		{MergeReplace, 5, map[string]any{"n": 2}},
+		{MergeKeep, 3, map[string]any{"name": "B", "n": 1}},
+		{MergePatch, 5, map[string]any{"name": "B", "n": 2}},
+	} {
+		s := NewInMemoryStorage()
+		s.BulkPut("items", existing)
+		if n, err := s.MergeRelation("items", incoming, tc.strategy); err != nil || n != tc.written {
+			t.Errorf("%s: wrote %d entries (err %v), want %d", tc.strategy, n, err, tc.written)
+		}
+		if got := s.Find("items", nil); len(got) != 6 {
+			t.Errorf("%s: relation has %d entries, want 6", tc.strategy, len(got))
+		}
+		if got, _ := s.Get("items", "b"); !reflect.DeepEqual(got, tc.b) {
+			t.Errorf("%s: b = %v, want %v", tc.strategy, got, tc.b)
+		}
+		if got, _ := s.Get("items", "a"); got["n"] != 1 {
+			t.Errorf("%s: untouched entry a changed to %v", tc.strategy, got)
+		}
+		if got, _ := s.Get("items", "e"); got["name"] != "E" {
+			t.Errorf("%s: new entry e = %v", tc.strategy, got)
+		}
+	}
+
+	if _, err := NewInMemoryStorage().MergeRelation("items", incoming, "upsert"); err == nil {
+		t.Error("expected an error for an unknown strategy")
+	}
+}
+
+func TestMergeRelationThroughDecorator(t *testing.T) {
+	inner := NewInMemoryStorage()
+	var key [32]byte
+	s := EncryptedStorage(inner, key)
+	s.Put("items", "b", map[string]any{"name": "B", "n": 1})
+
+	n, err := MergeRelation(s, "items", map[string]map[string]any{"b": {"n": 2}, "d": {"name": "D"}}, MergePatch)
+	if err != nil || n != 2 {
+		t.Fatalf("MergeRelation = %d, %v", n, err)
+	}
+	if got, _ := s.Get("items", "b"); !reflect.DeepEqual(got, map[string]any{"name": "B", "n": float64(2)}) {
+		t.Errorf("b = %v, want the patched entry", got)
+	}
+	if _, err := MergeRelation(s, "items", nil, "upsert"); err == nil {
+		t.Error("expected an error for an unknown strategy")
+	}
+}
+
+func TestStorageSoftDeleteAndRestore(t *testing.T) {
+	s := NewInMemoryStorage()
+	s.Put("users", "alice", map[string]any{"name": "Alice"})
+	s.Put("users", "bob", map[string]any{"name": "Bob"})
+
+	if !s.SoftDelete("users", "alice") {
+		t.Fatal("expected soft delete to find alice")
+	}
+	if s.SoftDelete("users", "alice") {
+		t.Error("expected second soft delete to report false")
+	}
+	if _, ok := s.Get("users", "alice"); ok {
+		t.Error("expected Get to skip soft-deleted entry")
+	}
+	if got := s.Find("users", nil); len(got) != 1 || got[0]["name"] != "Bob" {
+		t.Errorf("expected Find to skip alice, got %v", got)
+	}
+	if got := s.FindSorted("users", nil, "name", true); len(got) != 1 {
+		t.Errorf("expected FindSorted to skip alice, got %v", got)
+	}
+	if keys := s.Keys("users"); len(keys) != 1 || keys[0] != "bob" {
+		t.Errorf("expected Keys to skip alice, got %v", keys)
+	}
+	if got := s.FindIncludeDeleted("users", map[string]any{"name": "Alice"}); len(got) != 1 {
+		t.Errorf("expected FindIncludeDeleted to return alice, got %v", got)
+	}
+
+	if !s.Restore("users", "alice") {
+		t.Fatal("expected restore to find alice")
+	}
+	if s.Restore("users", "alice") {
+		t.Error("expected restoring a live entry to report false")
+	}
+	if v, ok := s.Get("users", "alice"); !ok || v["name"] != "Alice" {
+		t.Errorf("expected alice restored with the same data, got %v", v)
+	}
+	if got := s.Find("users", nil); len(got) != 2 {
+		t.Errorf("expected alice back in Find, got %v", got)
+	}
+}
+
 func TestStorageFindEmpty(t *testing.T) {
 	s := NewInMemoryStorage()
 	results := s.Find("empty", nil)
@@ -110,6 +289,154 @@ func TestStorageIsolatesRelations(t *testing.T) {
 	}
 }
 
+func bulkEntries(n int) map[string]map[string]any {
+	entries := make(map[string]map[string]any, n)
+	for i := 0; i < n; i++ {
+		key := fmt.Sprintf("k%05d", i)
+		entries[key] = map[string]any{"id": key, "n": i}
+	}
+	return entries
+}
+
+func TestStorageBulkPutAndDelete(t *testing.T) {
+	s := NewInMemoryStorage()
+	s.Put("items", "k00000", map[string]any{"id": "old"})
+
+	if n := s.BulkPut("items", bulkEntries(100)); n != 100 {
+		t.Errorf("expected 100 writes, got %d", n)
+	}
+	if got := len(s.Find("items", nil)); got != 100 {
+		t.Errorf("expected 100 items, got %d", got)
+	}
+	if v, _ := s.Get("items", "k00000"); v["id"] != "k00000" {
+		t.Errorf("expected overwrite, got %v", v)
+	}
+
+	if n := s.BulkDelete("items", []string{"k00001", "k00002", "missing"}); n != 2 {
+		t.Errorf("expected 2 deletes, got %d", n)
+	}
+	if got := len(s.Find("items", nil)); got != 98 {
+		t.Errorf("expected 98 items, got %d", got)
+	}
+}
+
+func TestStorageBulkPutIsAtomic(t *testing.T) {
+	s := NewInMemoryStorage()
+	entries := bulkEntries(1000)
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if n := len(s.Find("items", nil)); n != 0 && n != len(entries) {
+				t.Errorf("observed partial batch of %d entries", n)
+				return
+			}
+		}
+	}()
+
+	s.BulkPut("items", entries)
+	close(stop)
+	wg.Wait()
+	if n := len(s.Find("items", nil)); n != len(entries) {
+		t.Errorf("expected all %d entries after BulkPut, got %d", len(entries), n)
+	}
+}
+
+func TestStorageCompareAndSwap(t *testing.T) {
+	s := NewInMemoryStorage()
+	if ok, _ := s.CompareAndSwap("counters", "c", map[string]any{"n": 0}, map[string]any{"n": 1}, []string{"n"}); ok {
+		t.Fatal("swapped a missing entry against a non-nil expected value")
+	}
+	if ok, cur := s.CompareAndSwap("counters", "c", nil, map[string]any{"n": 0, "owner": "a"}, nil); !ok || cur["n"] != 0 {
+		t.Fatalf("create = %v, %v", ok, cur)
+	}
+	if ok, cur := s.CompareAndSwap("counters", "c", nil, map[string]any{"n": 5}, nil); ok || cur["n"] != 0 {
+		t.Fatalf("create over existing = %v, %v", ok, cur)
+	}
+	// Only compareFields are compared.
+	if ok, _ := s.CompareAndSwap("counters", "c", map[string]any{"n": 0, "owner": "b"}, map[string]any{"n": 1}, []string{"n"}); !ok {
+		t.Fatal("expected swap when compared fields match")
+	}
+	if ok, cur := s.CompareAndSwap("counters", "c", map[string]any{"n": 0}, map[string]any{"n": 2}, []string{"n"}); ok || cur["n"] != 1 {
+		t.Fatalf("stale swap = %v, %v", ok, cur)
+	}
+}
+
+func TestStorageCompareAndSwapConcurrent(t *testing.T) {
+	testCompareAndSwapCounter(t, NewInMemoryStorage(), 100)
+}
+
+// testCompareAndSwapCounter has workers goroutines each increment a
+// counter once with a CAS retry loop, then checks that every value from 1
+// to workers was written exactly once.
+func testCompareAndSwapCounter(t *testing.T, s Storage, workers int) {
+	t.Helper()
+	s.CompareAndSwap("counters", "c", nil, map[string]any{"n": float64(0)}, nil)
+
+	written := make(chan float64, workers)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cur, _ := s.Get("counters", "c")
+			for {
+				n := cur["n"].(float64)
+				ok, now := s.CompareAndSwap("counters", "c", cur, map[string]any{"n": n + 1}, []string{"n"})
+				if ok {
+					written <- n + 1
+					return
+				}
+				cur = now
+			}
+		}()
+	}
+	wg.Wait()
+	close(written)
+
+	seen := make(map[float64]bool)
+	for n := range written {
+		if seen[n] {
+			t.Errorf("value %v written twice", n)
+		}
+		seen[n] = true
+	}
+	for n := 1; n <= workers; n++ {
+		if !seen[float64(n)] {
+			t.Errorf("value %d skipped", n)
+		}
+	}
+	if v, _ := s.Get("counters", "c"); v["n"] != float64(workers) {
+		t.Errorf("final counter = %v, want %d", v["n"], workers)
+	}
+}
+
+func BenchmarkStoragePut10k(b *testing.B) {
+	entries := bulkEntries(10000)
+	for i := 0; i < b.N; i++ {
+		s := NewInMemoryStorage()
+		for key, value := range entries {
+			s.Put("items", key, value)
+		}
+	}
+}
+
+func BenchmarkStorageBulkPut10k(b *testing.B) {
+	entries := bulkEntries(10000)
+	for i := 0; i < b.N; i++ {
+		s := NewInMemoryStorage()
+		s.BulkPut("items", entries)
+	}
+}
+
 // ============================================================
 // Handler Tests
 // ============================================================
@@ -200,3 +527,167 @@ func TestRegisterWithCustomStorage(t *testing.T) {
 		t.Error("expected custom storage to be used")
 	}
 }
+
+// ============================================================
+// Transport Tests
+// ============================================================
+
+// codeHandler returns the variant and code named in its input.
+type codeHandler struct{}
+
+func (codeHandler) Handle(action string, input map[string]any, storage Storage) map[string]any {
+	out := map[string]any{"variant": input["variant"]}
+	if code, ok := input["code"]; ok {
+		out["code"] = code
+	}
+	return out
+}
+
+func TestInvokeStatusCodes(t *testing.T) {
+	resetRegistry()
+	Register("urn:test/Code", codeHandler{}, nil)
+	h := NewHandler()
+
+	cases := []struct {
+		variant, code string
+		want          int
+	}{
+		{"ok", "", http.StatusOK},
+		{"notfound", "", http.StatusOK},
+		{"error", "not_found", http.StatusNotFound},
+		{"error", "unauthorized", http.StatusUnauthorized},
+		{"error", "forbidden", http.StatusForbidden},
+		{"error", "validation_failed", http.StatusUnprocessableEntity},
+		{"error", "conflict", http.StatusConflict},
+		{"error", "rate_limited", http.StatusTooManyRequests},
+		{"error", "boom", http.StatusInternalServerError},
+		{"error", "", http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		body := `{"concept":"urn:test/Code","action":"run","input":{"variant":"` + tc.variant + `"`
+		if tc.code != "" {
+			body += `,"code":"` + tc.code + `"`
+		}
+		body += `}}`
+		rec := doRequest(h, http.MethodPost, "/invoke", body)
+		if rec.Code != tc.want {
+			t.Errorf("variant %q code %q: expected %d, got %d", tc.variant, tc.code, tc.want, rec.Code)
+		}
+	}
+}
+
+func TestInvokeUnknownConceptIs404(t *testing.T) {
+	resetRegistry()
+	rec := doRequest(NewHandler(), http.MethodPost, "/invoke", `{"concept":"urn:test/Missing","action":"run"}`)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", rec.Code)
+	}
+}
+
+func TestPrettyPrint(t *testing.T) {
+	resetRegistry()
+	Register("urn:test/Echo", &echoHandler{}, nil)
+	body := `{"id":"1","flow":"f","concept":"urn:test/Echo","action":"echo","input":{"message":"hi"}}`
+
+	cases := []struct {
+		name string
+		h    http.Handler
+		path string
+	}{
+		{"config", NewHandler(WithPrettyPrint()), "/invoke"},
+		{"query param", NewHandler(), "/invoke?pretty=1"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := doRequest(tc.h, http.MethodPost, tc.path, body)
+			pretty := rec.Body.Bytes()
+			if !bytes.Contains(pretty, []byte("\n  ")) {
+				t.Fatalf("expected indented output, got %s", pretty)
+			}
+			if !json.Valid(pretty) {
+				t.Fatalf("expected valid JSON, got %s", pretty)
+			}
+
+			var compacted bytes.Buffer
+			if err := json.Compact(&compacted, pretty); err != nil {
+				t.Fatal(err)
+			}
+			var c ActionCompletion
+			json.Unmarshal(pretty, &c)
+			plain, _ := json.Marshal(c)
+			if compacted.String() != string(plain) {
+				t.Errorf("compacted output differs:\n%s\n%s", compacted.String(), plain)
+			}
+		})
+	}
+}
+
+func TestPrettyPrintFromEnv(t *testing.T) {
+	t.Setenv("COPF_PRETTY_PRINT", "1")
+	rec := doRequest(NewHandler(), http.MethodGet, "/health", "")
+	if !bytes.Contains(rec.Body.Bytes(), []byte("\n  ")) {
+		t.Errorf("expected indented output, got %s", rec.Body.String())
+	}
+}
+
+func TestCompactByDefault(t *testing.T) {
+	rec := doRequest(NewHandler(), http.MethodGet, "/health", "")
+	if bytes.Contains(rec.Body.Bytes(), []byte("\n  ")) {
+		t.Errorf("expected compact output, got %s", rec.Body.String())
+	}
+}
+
+// resetRegistry clears all registered concepts and their per-concept metadata.
+func resetRegistry() {
+	for k := range registry {
+		delete(registry, k)
+	}
+	for k := range consistencyRules {
+		delete(consistencyRules, k)
+	}
+	for k := range errorCodes {
+		delete(errorCodes, k)
+	}
+	jobsMu.Lock()
+	clear(jobs)
+	jobsSweptAt = time.Time{}
+	jobsMu.Unlock()
+	warmupsMu.Lock()
+	clear(warmups)
+	warmupsMu.Unlock()
+	clear(tenantRegistry)
+	namespaceIsolation.Store(false)
+	dependenciesMu.Lock()
+	clear(dependencies)
+	dependenciesMu.Unlock()
+	conceptMetricsMu.Lock()
+	clear(conceptMetrics)
+	conceptMetricsMu.Unlock()
+}
+
+// doRequest sends a request through h and returns the recorded response.
+func doRequest(h http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestQueryListFilter(t *testing.T) {
+	resetRegistry()
+	storage := NewInMemoryStorage()
+	storage.Put("articles", "a1", map[string]any{"slug": "a1", "tagList": []string{"go", "copf"}})
+	Register("urn:test/Article", &echoHandler{}, storage)
+	h := NewHandler()
+
+	for filter, want := range map[string]int{`["go"]`: 1, `["java"]`: 0} {
+		rec := doRequest(h, "POST", "/query", `{"concept":"urn:test/Article","relation":"articles","args":{"tagList":`+filter+`}}`)
+		var results []map[string]any
+		if err := json.Unmarshal(rec.Body.Bytes(), &results); err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != want {
+			t.Errorf("tagList %s: expected %d results, got %v", filter, want, results)
+		}
+	}
+}
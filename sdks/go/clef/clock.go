@@ -0,0 +1,131 @@
+package clef
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Clock tells the time. Handlers that read it through ClockFromContext
+// instead of calling time.Now can be tested with a MockClock.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// RealClock returns the system clock.
+func RealClock() Clock {
+	return realClock{}
+}
+
+// MockClock is a Clock that only moves when Advance is called.
+type MockClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []clockWaiter
+}
+
+type clockWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+// NewMockClock returns a MockClock reading initial.
+func NewMockClock(initial time.Time) *MockClock {
+	return &MockClock{now: initial}
+}
+
+// Now returns the mock time.
+func (c *MockClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After returns a channel that receives the mock time once Advance has
+// moved it d past the current time. A non-positive d fires at once.
+func (c *MockClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, clockWaiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the mock time forward by d, firing the channels of
+// After calls that are now due.
+func (c *MockClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = pending
+}
+
+type clockKey struct{}
+
+// ClockMiddleware makes clock the handler's clock: ClockFromContext
+// returns it, and a FlowStorage the handler binds to its context expires
+// entries by it. The server's flow storage (WithFlowStorage) always
+// follows the server's clock, see WithClock.
+//
+// Example:
+//
+//	clock := clef.NewMockClock(time.Now())
+//	clef.MustRegister("urn:app/Session", clef.Chain(&SessionHandler{}, clef.ClockMiddleware(clock)), nil)
+//	clock.Advance(2 * time.Hour)
+func ClockMiddleware(clock Clock) MiddlewareFunc {
+	return func(next ConceptHandler) ConceptHandler {
+		return HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+			return callHandler(context.WithValue(ctx, clockKey{}, clock), next, action, input, storage)
+		})
+	}
+}
+
+// WithClock sets the server's clock: ClockFromContext returns it in every
+// handler without a ClockMiddleware, and WithFlowStorage stamps, expires
+// and sweeps entries by it. The default is RealClock.
+//
+// Example:
+//
+//	clock := clef.NewMockClock(time.Now())
+//	h := clef.NewHandler(clef.WithFlowStorage(time.Minute), clef.WithClock(clock))
+//	clock.Advance(2 * time.Minute) // flow entries have expired
+func WithClock(clock Clock) ServeOption {
+	return func(c *ServerConfig) {
+		c.clock = clock
+	}
+}
+
+// clock returns the server's clock.
+func (s *server) clock() Clock {
+	if s.config.clock != nil {
+		return s.config.clock
+	}
+	return realClock{}
+}
+
+// ClockFromContext returns the clock set by ClockMiddleware or WithClock,
+// or RealClock.
+func ClockFromContext(ctx context.Context) Clock {
+	if clock, ok := ctx.Value(clockKey{}).(Clock); ok {
+		return clock
+	}
+	return realClock{}
+}
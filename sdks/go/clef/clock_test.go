@@ -0,0 +1,113 @@
+package clef
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestServerClockExpiresFlowStorage(t *testing.T) {
+	resetRegistry()
+	// A clock far in the past: a sweep by real time would drop every
+	// entry at once.
+	clock := NewMockClock(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+	var seen time.Time
+	Register("urn:test/Session", HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		seen = ClockFromContext(ctx).Now()
+		flow := FlowStorageFromContext(ctx)
+		if action == "open" {
+			flow.Put("sessions", "s1", map[string]any{"user": "ada"})
+			return map[string]any{"variant": "ok"}
+		}
+		if _, ok := flow.Get("sessions", "s1"); !ok {
+			return map[string]any{"variant": "expired"}
+		}
+		return map[string]any{"variant": "ok"}
+	}), nil)
+	s := newServer([]ServeOption{WithFlowStorage(time.Minute), WithClock(clock)})
+
+	invoke := func(action, flow string) string {
+		return s.dispatch(context.Background(), ActionInvocation{Concept: "urn:test/Session", Action: action, Flow: flow}).Variant
+	}
+
+	invoke("open", "f1")
+	if !seen.Equal(clock.Now()) {
+		t.Fatalf("handler saw %v, want the mock time %v", seen, clock.Now())
+	}
+	clock.Advance(30 * time.Second)
+	if got := invoke("check", "f1"); got != "ok" {
+		t.Fatalf("before the TTL: %s", got)
+	}
+	if len(s.flowStore.Keys("f1/flow/sessions")) != 1 {
+		t.Fatal("live entry swept")
+	}
+
+	// Past the TTL, the next invocation sweeps the store by the mock time.
+	clock.Advance(31 * time.Second)
+	invoke("check", "f2")
+	deadline := time.Now().Add(time.Second)
+	for len(s.flowStore.Keys("f1/flow/sessions")) != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if keys := s.flowStore.Keys("f1/flow/sessions"); len(keys) != 0 {
+		t.Fatalf("expired entries not swept: %v", keys)
+	}
+	if got := invoke("check", "f1"); got != "expired" {
+		t.Fatalf("after the TTL: %s", got)
+	}
+}
+
+func TestClockMiddlewareOverridesServerClock(t *testing.T) {
+	resetRegistry()
+	server, handler := NewMockClock(time.Unix(0, 0)), NewMockClock(time.Unix(1000, 0))
+	var seen time.Time
+	Register("urn:test/Now", Chain(HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		seen = ClockFromContext(ctx).Now()
+		return map[string]any{"variant": "ok"}
+	}), ClockMiddleware(handler)), nil)
+	newServer([]ServeOption{WithClock(server)}).dispatch(context.Background(), ActionInvocation{Concept: "urn:test/Now", Action: "now"})
+	if !seen.Equal(handler.Now()) {
+		t.Errorf("handler saw %v, want its own clock's %v", seen, handler.Now())
+	}
+}
+
+func TestSweepExpiredUsesGivenTime(t *testing.T) {
+	inner := NewInMemoryStorage()
+	clock := NewMockClock(time.Now())
+	ctx := context.WithValue(context.Background(), clockKey{}, Clock(clock))
+	fs := bindStorage(ctx, FlowStorageWithTTL("f1", inner, time.Hour))
+	fs.Put("state", "k", map[string]any{"v": 1})
+
+	sweepExpired(inner, clock.Now())
+	if _, ok := inner.Get("f1/flow/state", "k"); !ok {
+		t.Fatal("sweep removed a live entry")
+	}
+	clock.Advance(2 * time.Hour)
+	sweepExpired(inner, clock.Now())
+	if _, ok := inner.Get("f1/flow/state", "k"); ok {
+		t.Fatal("expected sweep to remove the expired entry")
+	}
+}
+
+func TestMockClockAfter(t *testing.T) {
+	clock := NewMockClock(time.Unix(0, 0))
+	ch := clock.After(time.Minute)
+	clock.Advance(59 * time.Second)
+	select {
+	case <-ch:
+		t.Fatal("fired early")
+	default:
+	}
+	clock.Advance(time.Second)
+	select {
+	case got := <-ch:
+		if !got.Equal(time.Unix(60, 0)) {
+			t.Fatalf("fired with %v", got)
+		}
+	default:
+		t.Fatal("did not fire")
+	}
+	if ClockFromContext(context.Background()).Now().IsZero() {
+		t.Fatal("default clock returned the zero time")
+	}
+}
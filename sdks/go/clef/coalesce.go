@@ -0,0 +1,82 @@
+package clef
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"maps"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// CacheableOutputKey is the output field a handler sets to true to mark a
+// result as safe to share between identical invocations, e.g. an
+// idempotent query. The transport moves it to
+// ActionCompletion.CacheableOutput.
+const CacheableOutputKey = "_cacheable"
+
+// RequestCoalescingMiddleware shares one handler call among concurrent
+// invocations with the same action and input (keyed by the action and
+// the SHA-256 of the input's JSON, per tenant). Only results whose
+// output sets CacheableOutputKey are shared; callers that joined a call
+// whose result is not cacheable run the handler themselves.
+//
+// Example:
+//
+//	func (h *ArticleHandler) list(input map[string]any, storage clef.Storage) map[string]any {
+//	    return map[string]any{"variant": "ok", "articles": ..., clef.CacheableOutputKey: true}
+//	}
+//
+//	clef.MustRegister("urn:app/Article", clef.Chain(&ArticleHandler{}, clef.RequestCoalescingMiddleware()), nil)
+func RequestCoalescingMiddleware() MiddlewareFunc {
+	return func(next ConceptHandler) ConceptHandler {
+		var group singleflight.Group
+		return HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+			key, err := coalescingKey(ctx, action, input)
+			if err != nil {
+				return callHandler(ctx, next, action, input, storage)
+			}
+			ran := false
+			v, _, _ := group.Do(key, func() (any, error) {
+				ran = true
+				return callHandler(ctx, next, action, input, storage), nil
+			})
+			result := v.(map[string]any)
+			if ran {
+				return result
+			}
+			if result[CacheableOutputKey] != true {
+				return callHandler(ctx, next, action, input, storage)
+			}
+			// Each caller gets its own copy of the shared result.
+			return maps.Clone(result)
+		})
+	}
+}
+
+func coalescingKey(ctx context.Context, action string, input map[string]any) (string, error) {
+	hash, err := inputHash(input)
+	if err != nil {
+		return "", err
+	}
+	tenant, _ := TenantFromContext(ctx)
+	return tenant + "\x00" + action + "\x00" + hash, nil
+}
+
+// inputHash returns the hex SHA-256 of input's JSON, which has sorted
+// keys and so is the same for equal inputs. Metadata fields, like those
+// of InputEnrichers, are left out.
+func inputHash(input map[string]any) (string, error) {
+	return jsonHash(withoutMetadata(input))
+}
+
+// jsonHash returns the hex SHA-256 of v's JSON.
+func jsonHash(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,75 @@
+package clef
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowQuery blocks until release is closed, counting its calls.
+type slowQuery struct {
+	calls     atomic.Int32
+	release   chan struct{}
+	cacheable bool
+}
+
+func (h *slowQuery) Handle(action string, input map[string]any, storage Storage) map[string]any {
+	h.calls.Add(1)
+	<-h.release
+	return map[string]any{"variant": "ok", "n": 1, CacheableOutputKey: h.cacheable}
+}
+
+func runConcurrently(t *testing.T, h *slowQuery, n int) []ActionCompletion {
+	t.Helper()
+	completions := make([]ActionCompletion, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			completions[i] = InvokeLocal(context.Background(), ActionInvocation{
+				Concept: "urn:test/Query", Action: "list", Input: map[string]any{"tag": "go"},
+			})
+		}()
+	}
+	// Give every caller time to join the in-flight call.
+	time.Sleep(50 * time.Millisecond)
+	close(h.release)
+	wg.Wait()
+	return completions
+}
+
+func TestRequestCoalescing(t *testing.T) {
+	resetRegistry()
+	h := &slowQuery{release: make(chan struct{}), cacheable: true}
+	Register("urn:test/Query", Chain(h, RequestCoalescingMiddleware()), nil)
+
+	for _, c := range runConcurrently(t, h, 50) {
+		if c.Variant != "ok" || !c.CacheableOutput || c.Output["n"] != 1 {
+			t.Fatalf("unexpected completion %+v", c)
+		}
+		if _, ok := c.Output[CacheableOutputKey]; ok {
+			t.Fatalf("%s leaked into output", CacheableOutputKey)
+		}
+	}
+	if n := h.calls.Load(); n != 1 {
+		t.Errorf("handler called %d times, want 1", n)
+	}
+}
+
+func TestRequestCoalescingSkipsUncacheable(t *testing.T) {
+	resetRegistry()
+	h := &slowQuery{release: make(chan struct{})}
+	Register("urn:test/Query", Chain(h, RequestCoalescingMiddleware()), nil)
+
+	for _, c := range runConcurrently(t, h, 10) {
+		if c.CacheableOutput {
+			t.Fatal("completion marked cacheable")
+		}
+	}
+	if n := h.calls.Load(); n != 10 {
+		t.Errorf("handler called %d times, want 10", n)
+	}
+}
@@ -0,0 +1,117 @@
+// Package codegen generates typed Encode and Decode helpers for structs
+// annotated with //copf:input or //copf:output, so handlers can work with
+// structs instead of map[string]any. It is driven by cmd/clefgen.
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"strings"
+	"text/template"
+)
+
+// Annotations that mark a struct type for generation.
+const (
+	InputAnnotation  = "//copf:input"
+	OutputAnnotation = "//copf:output"
+)
+
+// OutputName returns the file Generate's output for filename is written
+// to: foo.go becomes foo_copf.go.
+func OutputName(filename string) string {
+	return strings.TrimSuffix(filename, ".go") + "_copf.go"
+}
+
+// AnnotatedTypes returns the names of the struct types in a parsed file
+// whose doc comment carries an annotation, in source order.
+func AnnotatedTypes(file *ast.File) []string {
+	var names []string
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if _, isStruct := ts.Type.(*ast.StructType); !isStruct {
+				continue
+			}
+			doc := ts.Doc
+			if doc == nil && len(gen.Specs) == 1 {
+				doc = gen.Doc
+			}
+			if annotated(doc) {
+				names = append(names, ts.Name.Name)
+			}
+		}
+	}
+	return names
+}
+
+func annotated(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		text := strings.TrimSpace(c.Text)
+		if text == InputAnnotation || text == OutputAnnotation {
+			return true
+		}
+	}
+	return false
+}
+
+var helpers = template.Must(template.New("helpers").Parse(`// Code generated by clefgen from {{.Source}}. DO NOT EDIT.
+
+package {{.Package}}
+
+import "github.com/clef/go-sdk/clef"
+{{range .Types}}
+// Encode{{.}} converts v to the map form used by concept handlers.
+func Encode{{.}}(v {{.}}) map[string]any {
+	m, err := clef.Encode(v)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Decode{{.}} converts a handler map to {{.}}. Unknown keys are ignored.
+func Decode{{.}}(m map[string]any) ({{.}}, error) {
+	return clef.Decode[{{.}}](m)
+}
+{{end}}`))
+
+// Generate returns the gofmt-ed source of the helpers for the annotated
+// types in src, or nil if there are none. filename is used for error
+// positions and the generated header.
+func Generate(filename string, src []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	types := AnnotatedTypes(file)
+	if len(types) == 0 {
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+	err = helpers.Execute(&buf, map[string]any{
+		"Source":  filename,
+		"Package": file.Name.Name,
+		"Types":   types,
+	})
+	if err != nil {
+		return nil, err
+	}
+	out, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("codegen: formatting output for %s: %w", filename, err)
+	}
+	return out, nil
+}
@@ -0,0 +1,61 @@
+package codegen
+
+import (
+	"strings"
+	"testing"
+)
+
+const src = `package demo
+
+// In is an input.
+//
+//copf:input
+type In struct {
+	Name string ` + "`json:\"name\"`" + `
+}
+
+//copf:output
+type Out struct{ OK bool }
+
+// Plain is not annotated.
+type Plain struct{}
+
+//copf:input
+type NotAStruct string
+`
+
+func TestGenerate(t *testing.T) {
+	out, err := Generate("demo.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := string(out)
+	for _, want := range []string{
+		"// Code generated by clefgen from demo.go. DO NOT EDIT.",
+		"package demo",
+		"func EncodeIn(v In) map[string]any",
+		"func DecodeIn(m map[string]any) (In, error)",
+		"func EncodeOut(v Out) map[string]any",
+		"func DecodeOut(m map[string]any) (Out, error)",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("output missing %q:\n%s", want, code)
+		}
+	}
+	if strings.Contains(code, "Plain") || strings.Contains(code, "NotAStruct") {
+		t.Errorf("unannotated or non-struct type generated:\n%s", code)
+	}
+}
+
+func TestGenerateNoAnnotations(t *testing.T) {
+	out, err := Generate("plain.go", []byte("package demo\n\ntype Plain struct{}\n"))
+	if err != nil || out != nil {
+		t.Fatalf("Generate = %q, %v; want nil, nil", out, err)
+	}
+}
+
+func TestOutputName(t *testing.T) {
+	if got := OutputName("dir/types.go"); got != "dir/types_copf.go" {
+		t.Fatalf("OutputName = %q", got)
+	}
+}
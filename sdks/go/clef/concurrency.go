@@ -0,0 +1,78 @@
+package clef
+
+import (
+	"context"
+	"sync"
+)
+
+// ConcurrencyLimitMiddleware caps how many invocations of each action may
+// run at once. limits maps action names to their cap; the "*" entry, if
+// present, gives every other action its own cap of that size. Actions
+// with no entry are unlimited. An invocation over the cap is rejected at
+// once with an "overloaded" error rather than queued.
+func ConcurrencyLimitMiddleware(limits map[string]int) MiddlewareFunc {
+	l := newConcurrencyLimiter(limits)
+	return func(next ConceptHandler) ConceptHandler {
+		return HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+			release, ok := l.acquire(action)
+			if !ok {
+				return map[string]any{"variant": "error", "code": "overloaded", "message": "too many concurrent requests"}
+			}
+			defer release()
+			return callHandler(ctx, next, action, input, storage)
+		})
+	}
+}
+
+// concurrencyLimiter admits invocations for ConcurrencyLimitMiddleware.
+type concurrencyLimiter struct {
+	sems        map[string]chan struct{}
+	wildcard    int
+	hasWildcard bool
+
+	// running counts the invocations of each action under the wildcard
+	// cap. An action's entry is deleted when its last invocation
+	// finishes, so the map holds only actions that are running.
+	mu      sync.Mutex
+	running map[string]int
+}
+
+func newConcurrencyLimiter(limits map[string]int) *concurrencyLimiter {
+	l := &concurrencyLimiter{sems: make(map[string]chan struct{}, len(limits)), running: make(map[string]int)}
+	for action, n := range limits {
+		if action != "*" {
+			l.sems[action] = make(chan struct{}, n)
+		}
+	}
+	l.wildcard, l.hasWildcard = limits["*"]
+	return l
+}
+
+// acquire admits one invocation of action, reporting false if it is at
+// its cap. The caller must call release when the invocation finishes.
+func (l *concurrencyLimiter) acquire(action string) (release func(), ok bool) {
+	if sem, ok := l.sems[action]; ok {
+		select {
+		case sem <- struct{}{}:
+			return func() { <-sem }, true
+		default:
+			return nil, false
+		}
+	}
+	if !l.hasWildcard {
+		return func() {}, true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.running[action] >= l.wildcard {
+		return nil, false
+	}
+	l.running[action]++
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if l.running[action]--; l.running[action] == 0 {
+			delete(l.running, action)
+		}
+	}, true
+}
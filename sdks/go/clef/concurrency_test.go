@@ -0,0 +1,97 @@
+package clef
+
+import (
+	"context"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// callConcurrently calls action from n goroutines at once through a
+// handler that holds every admitted call until all n have either entered
+// it or been rejected, then reports how many got each outcome.
+func callConcurrently(limits map[string]int, action string, n int) (ok, overloaded int) {
+	var entered, rejected atomic.Int32
+	release := make(chan struct{})
+	inner := HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		entered.Add(1)
+		<-release
+		return map[string]any{"variant": "ok"}
+	})
+	h := Chain(inner, ConcurrencyLimitMiddleware(limits))
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out := h.Handle(action, nil, nil)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case out["variant"] == "ok":
+				ok++
+			case out["code"] == "overloaded":
+				overloaded++
+				rejected.Add(1)
+			}
+		}()
+	}
+	for int(entered.Load()+rejected.Load()) < n {
+		runtime.Gosched()
+	}
+	close(release)
+	wg.Wait()
+	return ok, overloaded
+}
+
+func TestConcurrencyLimitRejectsOverflow(t *testing.T) {
+	ok, overloaded := callConcurrently(map[string]int{"render": 5}, "render", 20)
+	if ok != 5 || overloaded != 15 {
+		t.Errorf("expected 5 admitted and 15 overloaded, got %d and %d", ok, overloaded)
+	}
+}
+
+func TestConcurrencyLimitWildcard(t *testing.T) {
+	ok, overloaded := callConcurrently(map[string]int{"*": 3}, "anything", 10)
+	if ok != 3 || overloaded != 7 {
+		t.Errorf("expected 3 admitted and 7 overloaded, got %d and %d", ok, overloaded)
+	}
+}
+
+func TestConcurrencyLimitUnlistedActionUnlimited(t *testing.T) {
+	ok, overloaded := callConcurrently(map[string]int{"render": 1}, "other", 10)
+	if ok != 10 || overloaded != 0 {
+		t.Errorf("expected all 10 admitted, got %d admitted and %d overloaded", ok, overloaded)
+	}
+}
+
+func TestConcurrencyLimitReleasesSlots(t *testing.T) {
+	h := Chain(&echoHandler{}, ConcurrencyLimitMiddleware(map[string]int{"echo": 1}))
+	for i := 0; i < 3; i++ {
+		if out := h.Handle("echo", map[string]any{"message": "hi"}, nil); out["variant"] != "ok" {
+			t.Fatalf("sequential call %d rejected: %v", i, out)
+		}
+	}
+}
+
+func TestConcurrencyLimiterForgetsIdleActions(t *testing.T) {
+	l := newConcurrencyLimiter(map[string]int{"*": 2})
+	first, _ := l.acquire("a")
+	second, _ := l.acquire("a")
+	if _, ok := l.acquire("a"); ok {
+		t.Fatal("third concurrent call admitted over a cap of 2")
+	}
+	first()
+	second()
+	for i := range 100 {
+		release, _ := l.acquire("action-" + strconv.Itoa(i))
+		release()
+	}
+	if n := len(l.running); n != 0 {
+		t.Errorf("limiter still tracks %d idle actions", n)
+	}
+}
@@ -0,0 +1,202 @@
+package clef
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// configDebounce coalesces the burst of events a single save produces
+// (truncate, write, chmod) into one reload.
+const configDebounce = 50 * time.Millisecond
+
+// LiveConfig holds configuration that can change without a restart. It is
+// created by ConfigWatcher and updated when the watched file changes or a
+// new config is pushed to POST /admin/config.
+type LiveConfig struct {
+	mu      sync.RWMutex
+	current map[string]any
+	// applyMu serializes reloads without blocking Current, so reload
+	// functions may read the old config.
+	applyMu sync.Mutex
+	reload  func(newConfig map[string]any) error
+
+	path    string
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+}
+
+// ConfigWatcher loads the JSON object in configPath, passes it to reload,
+// and then watches the file with fsnotify, calling reload again on every
+// change. If reload returns an error, or the file does not parse, the
+// previous config stays live and the error is logged. The initial load
+// must succeed. Pass the result to WithLiveConfig to expose it over HTTP,
+// and Close it to stop watching.
+//
+// Example:
+//
+//	cfg, err := clef.ConfigWatcher("config.json", func(c map[string]any) error {
+//	    limiter.SetLimit(c["rateLimit"])
+//	    return nil
+//	})
+func ConfigWatcher(configPath string, reload func(newConfig map[string]any) error) (*LiveConfig, error) {
+	lc := &LiveConfig{reload: reload, path: configPath, done: make(chan struct{})}
+	cfg, err := readConfigFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := lc.Apply(cfg); err != nil {
+		return nil, err
+	}
+
+	// Watch the directory rather than the file so editors that save by
+	// renaming a temporary file over the original are still seen.
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := w.Add(filepath.Dir(configPath)); err != nil {
+		w.Close()
+		return nil, err
+	}
+	lc.watcher = w
+	go lc.watch()
+	return lc, nil
+}
+
+// Current returns the live config. Callers must not modify it.
+func (lc *LiveConfig) Current() map[string]any {
+	lc.mu.RLock()
+	defer lc.mu.RUnlock()
+	return lc.current
+}
+
+// Apply passes newConfig to the reload function and, if it succeeds,
+// makes it the live config.
+func (lc *LiveConfig) Apply(newConfig map[string]any) error {
+	lc.applyMu.Lock()
+	defer lc.applyMu.Unlock()
+	if err := lc.reload(newConfig); err != nil {
+		return err
+	}
+	lc.mu.Lock()
+	lc.current = newConfig
+	lc.mu.Unlock()
+	return nil
+}
+
+// Close stops watching the config file.
+func (lc *LiveConfig) Close() error {
+	if lc.watcher == nil {
+		return nil
+	}
+	select {
+	case <-lc.done:
+		return nil
+	default:
+		close(lc.done)
+	}
+	return lc.watcher.Close()
+}
+
+func (lc *LiveConfig) watch() {
+	name := filepath.Clean(lc.path)
+	var timer *time.Timer
+	for {
+		select {
+		case <-lc.done:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case ev, ok := <-lc.watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(ev.Name) != name || !ev.Has(fsnotify.Write|fsnotify.Create) {
+				continue
+			}
+			if timer == nil {
+				timer = time.AfterFunc(configDebounce, lc.reloadFile)
+			} else {
+				timer.Reset(configDebounce)
+			}
+		case err, ok := <-lc.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("clef: watching %s: %v", lc.path, err)
+		}
+	}
+}
+
+func (lc *LiveConfig) reloadFile() {
+	cfg, err := readConfigFile(lc.path)
+	if err == nil {
+		err = lc.Apply(cfg)
+	}
+	if err != nil {
+		log.Printf("clef: config reload from %s failed, keeping previous config: %v", lc.path, err)
+	}
+}
+
+func readConfigFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg map[string]any
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// WithLiveConfig serves lc at /admin/config: GET returns the live config
+// and POST replaces it with the JSON object in the request body. A config
+// rejected by the reload function gets HTTP 422 and the old config stays.
+// Requests must carry "Authorization: Bearer <adminToken>"; with an empty
+// token the endpoint is not served.
+func WithLiveConfig(lc *LiveConfig, adminToken string) ServeOption {
+	return func(c *ServerConfig) {
+		c.liveConfig = lc
+		c.liveConfigToken = adminToken
+	}
+}
+
+func (s *server) handleAdminConfig(w http.ResponseWriter, r *http.Request) {
+	lc := s.config.liveConfig
+	if lc == nil || s.config.liveConfigToken == "" {
+		http.Error(w, "live config not enabled", http.StatusNotFound)
+		return
+	}
+	if !authorized(r, s.config.liveConfigToken) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.writeJSON(w, r, lc.Current())
+	case http.MethodPost:
+		var cfg map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := lc.Apply(cfg); err != nil {
+			s.writeJSONStatus(w, r, http.StatusUnprocessableEntity, map[string]any{"error": err.Error()})
+			return
+		}
+		s.writeJSON(w, r, lc.Current())
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,139 @@
+package clef
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, path, body string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// startConfigWatcher watches a config file and reports each successful
+// reload on the returned channel.
+func startConfigWatcher(t *testing.T) (string, *LiveConfig, <-chan map[string]any) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeConfig(t, path, `{"rateLimit":10}`)
+
+	reloads := make(chan map[string]any, 8)
+	lc, err := ConfigWatcher(path, func(c map[string]any) error {
+		if limit, _ := c["rateLimit"].(float64); limit < 0 {
+			return errors.New("rateLimit must not be negative")
+		}
+		reloads <- c
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lc.Close() })
+	<-reloads // initial load
+	return path, lc, reloads
+}
+
+func waitReload(t *testing.T, reloads <-chan map[string]any) map[string]any {
+	t.Helper()
+	select {
+	case c := <-reloads:
+		return c
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for reload")
+		return nil
+	}
+}
+
+func TestConfigWatcherReloadsOnChange(t *testing.T) {
+	path, lc, reloads := startConfigWatcher(t)
+
+	writeConfig(t, path, `{"rateLimit":20}`)
+	if c := waitReload(t, reloads); c["rateLimit"] != float64(20) {
+		t.Errorf("expected rateLimit 20, got %v", c["rateLimit"])
+	}
+	if lc.Current()["rateLimit"] != float64(20) {
+		t.Errorf("expected live config to update, got %v", lc.Current())
+	}
+}
+
+func TestConfigWatcherKeepsOldConfigOnError(t *testing.T) {
+	path, lc, reloads := startConfigWatcher(t)
+
+	writeConfig(t, path, `{"rateLimit":-1}`)
+	time.Sleep(4 * configDebounce)
+	writeConfig(t, path, `not json`)
+	time.Sleep(4 * configDebounce)
+	if lc.Current()["rateLimit"] != float64(10) {
+		t.Errorf("expected old config to stay live, got %v", lc.Current())
+	}
+
+	writeConfig(t, path, `{"rateLimit":30}`)
+	if c := waitReload(t, reloads); c["rateLimit"] != float64(30) {
+		t.Errorf("expected recovery to rateLimit 30, got %v", c["rateLimit"])
+	}
+}
+
+func TestConfigWatcherInitialLoadMustSucceed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeConfig(t, path, `{`)
+	if _, err := ConfigWatcher(path, func(map[string]any) error { return nil }); err == nil {
+		t.Error("expected parse error")
+	}
+}
+
+func TestAdminConfigEndpoint(t *testing.T) {
+	_, lc, reloads := startConfigWatcher(t)
+	h := NewHandler(WithLiveConfig(lc, "secret"))
+	authed := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := doRequest(h, http.MethodPost, "/admin/config", `{"rateLimit":50}`); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without the admin token, got %d", rec.Code)
+	}
+	rec := authed(http.MethodGet, "/admin/config", "")
+	var got map[string]any
+	json.Unmarshal(rec.Body.Bytes(), &got)
+	if got["rateLimit"] != float64(10) {
+		t.Errorf("expected live config, got %s", rec.Body.String())
+	}
+
+	rec = authed(http.MethodPost, "/admin/config", `{"rateLimit":50}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if c := waitReload(t, reloads); c["rateLimit"] != float64(50) {
+		t.Errorf("expected reload with pushed config, got %v", c)
+	}
+
+	rec = authed(http.MethodPost, "/admin/config", `{"rateLimit":-5}`)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for rejected config, got %d", rec.Code)
+	}
+	if lc.Current()["rateLimit"] != float64(50) {
+		t.Errorf("expected pushed config to stay live, got %v", lc.Current())
+	}
+}
+
+func TestAdminConfigDisabled(t *testing.T) {
+	if rec := doRequest(NewHandler(), http.MethodGet, "/admin/config", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without live config, got %d", rec.Code)
+	}
+	_, lc, _ := startConfigWatcher(t)
+	if rec := doRequest(NewHandler(WithLiveConfig(lc, "")), http.MethodGet, "/admin/config", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without an admin token, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,181 @@
+package clef
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// ConsistencyViolation describes a single stored entry that failed a
+// consistency rule.
+type ConsistencyViolation struct {
+	Concept  string `json:"concept"`
+	Relation string `json:"relation"`
+	Key      string `json:"key"`
+	Rule     string `json:"rule"`
+	Message  string `json:"message"`
+}
+
+// ConsistencyRule inspects one stored entry and returns a violation, or nil
+// if the entry is consistent. Rules receive the full storage so they can
+// follow references into other relations.
+type ConsistencyRule func(relation, key string, value map[string]any, storage Storage) *ConsistencyViolation
+
+// RegisterConsistencyRules attaches rules to a registered concept so they
+// run when POST /admin/check is called (see WithConsistencyCheck). Rules
+// accumulate across calls.
+func RegisterConsistencyRules(uri string, rules ...ConsistencyRule) {
+	consistencyRules[uri] = append(consistencyRules[uri], rules...)
+}
+
+// consistencyRules maps concept URIs to the rules checked by /admin/check.
+var consistencyRules = make(map[string][]ConsistencyRule)
+
+// ConsistencyCheck walks every entry in the storage of the concept
+// registered at uri and runs each rule against it. The storage must
+// implement Enumerable.
+func ConsistencyCheck(uri string, rules []ConsistencyRule) []ConsistencyViolation {
+	entry, ok := registry[uri]
+	if !ok {
+		return []ConsistencyViolation{{
+			Concept: uri,
+			Rule:    "registered",
+			Message: fmt.Sprintf("unknown concept: %s", uri),
+		}}
+	}
+
+	enum, ok := entry.storage.(Enumerable)
+	if !ok {
+		return []ConsistencyViolation{{
+			Concept: uri,
+			Rule:    "enumerable",
+			Message: "storage does not support enumeration",
+		}}
+	}
+
+	var violations []ConsistencyViolation
+	for _, relation := range enum.Relations() {
+		for _, key := range enum.Keys(relation) {
+			value, ok := entry.storage.Get(relation, key)
+			if !ok {
+				continue
+			}
+			for _, rule := range rules {
+				if v := rule(relation, key, value, entry.storage); v != nil {
+					v.Concept = uri
+					violations = append(violations, *v)
+				}
+			}
+		}
+	}
+	return violations
+}
+
+// RequiredFields reports entries in relation that are missing any of the
+// given fields or hold a nil value for them.
+func RequiredFields(relation string, fields ...string) ConsistencyRule {
+	return func(rel, key string, value map[string]any, storage Storage) *ConsistencyViolation {
+		if rel != relation {
+			return nil
+		}
+		for _, f := range fields {
+			if v, ok := value[f]; !ok || v == nil {
+				return &ConsistencyViolation{
+					Relation: rel,
+					Key:      key,
+					Rule:     "required_fields",
+					Message:  fmt.Sprintf("missing required field %q", f),
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// ForeignKeyExists reports entries in relation whose field does not name
+// an existing key in targetRelation. Entries without the field are skipped;
+// combine with RequiredFields to enforce presence.
+func ForeignKeyExists(relation, field, targetRelation string) ConsistencyRule {
+	return func(rel, key string, value map[string]any, storage Storage) *ConsistencyViolation {
+		if rel != relation {
+			return nil
+		}
+		raw, ok := value[field]
+		if !ok || raw == nil {
+			return nil
+		}
+		ref, ok := raw.(string)
+		if !ok {
+			return &ConsistencyViolation{
+				Relation: rel,
+				Key:      key,
+				Rule:     "foreign_key",
+				Message:  fmt.Sprintf("field %q is not a string key", field),
+			}
+		}
+		if _, found := storage.Get(targetRelation, ref); !found {
+			return &ConsistencyViolation{
+				Relation: rel,
+				Key:      key,
+				Rule:     "foreign_key",
+				Message:  fmt.Sprintf("field %q references missing %s/%s", field, targetRelation, ref),
+			}
+		}
+		return nil
+	}
+}
+
+// WithConsistencyCheck enables POST /admin/check, which runs the
+// registered consistency rules over every entry of the checked concepts'
+// storage and reports the violations. Requests must carry
+// "Authorization: Bearer <adminToken>".
+func WithConsistencyCheck(adminToken string) ServeOption {
+	return func(c *ServerConfig) {
+		c.consistencyToken = adminToken
+	}
+}
+
+func (s *server) handleAdminCheck(w http.ResponseWriter, r *http.Request) {
+	if s.config.consistencyToken == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorized(r, s.config.consistencyToken) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Concept string `json:"concept"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var uris []string
+	if req.Concept != "" {
+		uris = []string{req.Concept}
+	} else {
+		for uri := range consistencyRules {
+			uris = append(uris, uri)
+		}
+		sort.Strings(uris)
+	}
+
+	violations := []ConsistencyViolation{}
+	for _, uri := range uris {
+		violations = append(violations, ConsistencyCheck(uri, consistencyRules[uri])...)
+	}
+	s.writeJSON(w, r, map[string]any{
+		"consistent": len(violations) == 0,
+		"violations": violations,
+	})
+}
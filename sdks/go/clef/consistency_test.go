@@ -0,0 +1,104 @@
+package clef
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func brokenStorage() *InMemoryStorage {
+	s := NewInMemoryStorage()
+	s.Put("users", "alice", map[string]any{"name": "Alice", "email": "alice@example.com"})
+	s.Put("users", "bob", map[string]any{"name": "Bob"})
+	s.Put("posts", "p1", map[string]any{"author": "alice", "title": "Hello"})
+	s.Put("posts", "p2", map[string]any{"author": "carol", "title": "Orphan"})
+	return s
+}
+
+func TestConsistencyCheckDetectsViolations(t *testing.T) {
+	resetRegistry()
+	Register("urn:test/Blog", &echoHandler{}, brokenStorage())
+
+	violations := ConsistencyCheck("urn:test/Blog", []ConsistencyRule{
+		RequiredFields("users", "name", "email"),
+		ForeignKeyExists("posts", "author", "users"),
+	})
+	if len(violations) != 2 {
+		t.Fatalf("expected 2 violations, got %d: %+v", len(violations), violations)
+	}
+
+	byKey := map[string]ConsistencyViolation{}
+	for _, v := range violations {
+		byKey[v.Relation+"/"+v.Key] = v
+	}
+	if v, ok := byKey["users/bob"]; !ok || v.Rule != "required_fields" {
+		t.Errorf("expected required_fields violation for users/bob, got %+v", v)
+	}
+	if v, ok := byKey["posts/p2"]; !ok || v.Rule != "foreign_key" {
+		t.Errorf("expected foreign_key violation for posts/p2, got %+v", v)
+	}
+	for _, v := range violations {
+		if v.Concept != "urn:test/Blog" {
+			t.Errorf("expected concept to be set, got %q", v.Concept)
+		}
+	}
+}
+
+func TestConsistencyCheckCleanStorage(t *testing.T) {
+	resetRegistry()
+	s := NewInMemoryStorage()
+	s.Put("users", "alice", map[string]any{"name": "Alice"})
+	s.Put("posts", "p1", map[string]any{"author": "alice"})
+	Register("urn:test/Blog", &echoHandler{}, s)
+
+	violations := ConsistencyCheck("urn:test/Blog", []ConsistencyRule{
+		RequiredFields("users", "name"),
+		ForeignKeyExists("posts", "author", "users"),
+	})
+	if len(violations) != 0 {
+		t.Errorf("expected no violations, got %+v", violations)
+	}
+}
+
+func TestConsistencyCheckUnknownConcept(t *testing.T) {
+	resetRegistry()
+	violations := ConsistencyCheck("urn:test/Missing", nil)
+	if len(violations) != 1 || violations[0].Rule != "registered" {
+		t.Errorf("expected unknown concept violation, got %+v", violations)
+	}
+}
+
+func TestAdminCheckEndpoint(t *testing.T) {
+	resetRegistry()
+	Register("urn:test/Blog", &echoHandler{}, brokenStorage())
+	RegisterConsistencyRules("urn:test/Blog", ForeignKeyExists("posts", "author", "users"))
+
+	body := `{"concept":"urn:test/Blog"}`
+	if rec := doRequest(NewHandler(), http.MethodPost, "/admin/check", body); rec.Code != http.StatusNotFound {
+		t.Errorf("without WithConsistencyCheck: status %d, want 404", rec.Code)
+	}
+	h := NewHandler(WithConsistencyCheck("secret"))
+	if rec := doRequest(h, http.MethodPost, "/admin/check", body); rec.Code != http.StatusUnauthorized {
+		t.Errorf("without a token: status %d, want 401", rec.Code)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/admin/check", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	var result struct {
+		Consistent bool                   `json:"consistent"`
+		Violations []ConsistencyViolation `json:"violations"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Consistent {
+		t.Error("expected inconsistent result")
+	}
+	if len(result.Violations) != 1 || result.Violations[0].Key != "p2" {
+		t.Errorf("expected violation for p2, got %+v", result.Violations)
+	}
+}
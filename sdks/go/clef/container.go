@@ -0,0 +1,75 @@
+package clef
+
+import (
+	"context"
+	"sync"
+)
+
+// Container holds shared dependencies, such as database or service
+// clients, keyed by name, so handlers need not reach for globals. It is
+// safe for concurrent use.
+type Container struct {
+	mu     sync.RWMutex
+	values map[string]any
+}
+
+// NewContainer returns an empty container.
+func NewContainer() *Container {
+	return &Container{values: make(map[string]any)}
+}
+
+// Set stores value under key, replacing any previous value.
+func (c *Container) Set(key string, value any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key] = value
+}
+
+// Get returns the value stored under key.
+func (c *Container) Get(key string) (any, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	v, ok := c.values[key]
+	return v, ok
+}
+
+// ContainerAware is implemented by handlers that want the server's
+// container injected once at startup rather than read per invocation.
+type ContainerAware interface {
+	SetContainer(c *Container)
+}
+
+// WithContainer makes c available to handlers. Every registered handler
+// implementing ContainerAware receives it when the server is built, and
+// every invocation context carries it (see ContainerFromContext).
+// Handlers wrapped with Chain hide ContainerAware; read the container
+// from the context instead.
+//
+// Example:
+//
+//	deps := clef.NewContainer()
+//	deps.Set("db", db)
+//	clef.Serve(":8091", clef.WithContainer(deps))
+func WithContainer(c *Container) ServeOption {
+	return func(cfg *ServerConfig) {
+		cfg.container = c
+	}
+}
+
+type containerKey struct{}
+
+// ContainerFromContext returns the container configured with
+// WithContainer, or nil when there is none.
+func ContainerFromContext(ctx context.Context) *Container {
+	c, _ := ctx.Value(containerKey{}).(*Container)
+	return c
+}
+
+// injectContainer hands c to every registered ContainerAware handler.
+func injectContainer(c *Container) {
+	for _, entry := range registry {
+		if ca, ok := entry.handler.(ContainerAware); ok {
+			ca.SetContainer(c)
+		}
+	}
+}
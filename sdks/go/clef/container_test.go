@@ -0,0 +1,58 @@
+package clef
+
+import (
+	"context"
+	"testing"
+)
+
+// fakeDB stands in for a shared database client.
+type fakeDB struct{ name string }
+
+// repoHandler reads its DB from the injected container and, per call,
+// from the invocation context.
+type repoHandler struct {
+	db *fakeDB
+}
+
+func (h *repoHandler) SetContainer(c *Container) {
+	v, _ := c.Get("db")
+	h.db, _ = v.(*fakeDB)
+}
+
+func (h *repoHandler) Handle(action string, input map[string]any, storage Storage) map[string]any {
+	return h.HandleContext(context.Background(), action, input, storage)
+}
+
+func (h *repoHandler) HandleContext(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+	var fromCtx *fakeDB
+	if c := ContainerFromContext(ctx); c != nil {
+		v, _ := c.Get("db")
+		fromCtx, _ = v.(*fakeDB)
+	}
+	return map[string]any{"variant": "ok", "injected": h.db, "fromContext": fromCtx}
+}
+
+func TestContainerInjection(t *testing.T) {
+	resetRegistry()
+	db := &fakeDB{name: "primary"}
+	deps := NewContainer()
+	deps.Set("db", db)
+
+	handler := &repoHandler{}
+	Register("urn:test/Repo", handler, nil)
+	s := newServer([]ServeOption{WithContainer(deps)})
+
+	if handler.db != db {
+		t.Fatalf("expected SetContainer to inject the startup DB, got %v", handler.db)
+	}
+	c := s.dispatch(context.Background(), ActionInvocation{Concept: "urn:test/Repo", Action: "load"})
+	if c.Output["injected"] != db || c.Output["fromContext"] != db {
+		t.Errorf("expected the same DB object from injection and context, got %v", c.Output)
+	}
+}
+
+func TestContainerFromContextWithoutContainer(t *testing.T) {
+	if c := ContainerFromContext(context.Background()); c != nil {
+		t.Errorf("expected nil container, got %v", c)
+	}
+}
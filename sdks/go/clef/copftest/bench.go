@@ -0,0 +1,72 @@
+// Package copftest provides helpers for testing and benchmarking Clef
+// concept handlers without going through the HTTP transport.
+package copftest
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"runtime/pprof"
+	"testing"
+
+	"github.com/clef/go-sdk/clef"
+)
+
+// BenchmarkHandler measures handler.Handle for one action and input,
+// reporting ns/op, allocs/op, and bytes/op. The handler runs against a
+// fresh InMemoryStorage shared by every iteration, so actions that write
+// state see their own earlier writes.
+//
+// Example:
+//
+//	func BenchmarkCheck(b *testing.B) {
+//	    copftest.BenchmarkHandler(b, &RateLimiterHandler{}, "check", map[string]any{"key": "k"})
+//	}
+func BenchmarkHandler(b *testing.B, handler clef.ConceptHandler, action string, input map[string]any) {
+	b.Helper()
+	storage := clef.NewInMemoryStorage()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		handler.Handle(action, input, storage)
+	}
+}
+
+// BenchmarkHandlerParallel is BenchmarkHandler run from multiple
+// goroutines with b.RunParallel. All goroutines share one storage, which
+// exercises the handler under the same contention it sees in a server.
+func BenchmarkHandlerParallel(b *testing.B, handler clef.ConceptHandler, action string, input map[string]any) {
+	b.Helper()
+	storage := clef.NewInMemoryStorage()
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			handler.Handle(action, input, storage)
+		}
+	})
+}
+
+// ProfileHandler calls handler.Handle iterations times against a fresh
+// InMemoryStorage and returns the CPU profile for the run and an
+// allocation profile taken afterwards, both in pprof format. It fails if
+// a CPU profile is already being collected in this process.
+func ProfileHandler(handler clef.ConceptHandler, action string, input map[string]any, iterations int) (cpuProfile []byte, memProfile []byte, err error) {
+	storage := clef.NewInMemoryStorage()
+
+	var cpu bytes.Buffer
+	if err := pprof.StartCPUProfile(&cpu); err != nil {
+		return nil, nil, fmt.Errorf("start cpu profile: %w", err)
+	}
+	for i := 0; i < iterations; i++ {
+		handler.Handle(action, input, storage)
+	}
+	pprof.StopCPUProfile()
+
+	runtime.GC()
+	var mem bytes.Buffer
+	if err := pprof.Lookup("allocs").WriteTo(&mem, 0); err != nil {
+		return nil, nil, fmt.Errorf("write memory profile: %w", err)
+	}
+	return cpu.Bytes(), mem.Bytes(), nil
+}
@@ -0,0 +1,40 @@
+package copftest
+
+import (
+	"testing"
+
+	"github.com/clef/go-sdk/clef"
+)
+
+type counterHandler struct{}
+
+func (counterHandler) Handle(action string, input map[string]any, storage clef.Storage) map[string]any {
+	key, _ := input["key"].(string)
+	n := 0
+	if rec, ok := storage.Get("counts", key); ok {
+		n, _ = rec["n"].(int)
+	}
+	storage.Put("counts", key, map[string]any{"n": n + 1})
+	return map[string]any{"variant": "ok", "n": n + 1}
+}
+
+func TestProfileHandler(t *testing.T) {
+	cpu, mem, err := ProfileHandler(counterHandler{}, "incr", map[string]any{"key": "a"}, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cpu) == 0 {
+		t.Error("expected a CPU profile")
+	}
+	if len(mem) == 0 {
+		t.Error("expected a memory profile")
+	}
+}
+
+func BenchmarkCounter(b *testing.B) {
+	BenchmarkHandler(b, counterHandler{}, "incr", map[string]any{"key": "a"})
+}
+
+func BenchmarkCounterParallel(b *testing.B) {
+	BenchmarkHandlerParallel(b, counterHandler{}, "incr", map[string]any{"key": "a"})
+}
@@ -0,0 +1,64 @@
+package copftest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/clef/go-sdk/clef"
+)
+
+// Fixtures is the fixture file format: entries by relation, then key.
+type Fixtures map[string]map[string]map[string]any
+
+// LoadFixtures seeds storage from the JSON fixture file at path, of the
+// form {"relation": {"key": {...value...}}}, with one Put per entry.
+//
+// Example:
+//
+//	storage := clef.NewInMemoryStorage()
+//	if err := copftest.LoadFixtures(storage, "testdata/articles.json"); err != nil {
+//	    t.Fatal(err)
+//	}
+func LoadFixtures(storage clef.Storage, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var fixtures Fixtures
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	for relation, entries := range fixtures {
+		for key, value := range entries {
+			storage.Put(relation, key, value)
+		}
+	}
+	return nil
+}
+
+// DumpFixtures serializes relations of storage, or every relation if
+// relations is nil, in the format LoadFixtures reads, with keys sorted.
+// storage must implement clef.Enumerable.
+func DumpFixtures(storage clef.Storage, relations []string) ([]byte, error) {
+	fixtures, err := clef.Export(storage, relations)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(fixtures, "", "  ")
+}
+
+// CopyFixtures copies every entry of relations, or of every relation if
+// relations is nil, from src to dst. src must implement clef.Enumerable.
+func CopyFixtures(src, dst clef.Storage, relations []string) error {
+	fixtures, err := clef.Export(src, relations)
+	if err != nil {
+		return err
+	}
+	for relation, entries := range fixtures {
+		for key, value := range entries {
+			dst.Put(relation, key, value)
+		}
+	}
+	return nil
+}
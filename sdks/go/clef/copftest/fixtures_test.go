@@ -0,0 +1,53 @@
+package copftest
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/clef/go-sdk/clef"
+)
+
+func TestFixturesRoundTrip(t *testing.T) {
+	storage := clef.NewInMemoryStorage()
+	if err := LoadFixtures(storage, "testdata/fixtures.json"); err != nil {
+		t.Fatal(err)
+	}
+	for _, relation := range []string{"articles", "users"} {
+		if n := len(storage.Find(relation, nil)); n != 5 {
+			t.Errorf("%s has %d entries, want 5", relation, n)
+		}
+	}
+	if got, _ := storage.Get("users", "grace"); got["bio"] != "compilers" {
+		t.Errorf("users/grace = %v", got)
+	}
+
+	dumped, err := DumpFixtures(storage, []string{"articles", "users"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	original, err := os.ReadFile("testdata/fixtures.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want, got any
+	json.Unmarshal(original, &want)
+	if err := json.Unmarshal(dumped, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dump differs from fixture file:\n%s", dumped)
+	}
+
+	dst := clef.NewInMemoryStorage()
+	if err := CopyFixtures(storage, dst, []string{"users"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(dst.Find("users", nil)); n != 5 {
+		t.Errorf("copied %d users, want 5", n)
+	}
+	if n := len(dst.Find("articles", nil)); n != 0 {
+		t.Errorf("copied %d articles, want none", n)
+	}
+}
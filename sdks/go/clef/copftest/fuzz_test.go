@@ -0,0 +1,69 @@
+package copftest
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/clef/go-sdk/clef"
+)
+
+// lookupHandler indexes input["id"] without checking it is a string.
+type lookupHandler struct{}
+
+func (lookupHandler) Handle(action string, input map[string]any, storage clef.Storage) map[string]any {
+	id := input["id"].(string)
+	record, _ := storage.Get("items", id)
+	return map[string]any{"variant": "ok", "item": record}
+}
+
+// checkedLookupHandler is lookupHandler with the check.
+type checkedLookupHandler struct{}
+
+func (checkedLookupHandler) Handle(action string, input map[string]any, storage clef.Storage) map[string]any {
+	id, ok := input["id"].(string)
+	if !ok {
+		return map[string]any{"variant": "error", "message": "id must be a string"}
+	}
+	record, _ := storage.Get("items", id)
+	return map[string]any{"variant": "ok", "item": record}
+}
+
+func FuzzCheckedLookup(f *testing.F) {
+	FuzzHandler(f, checkedLookupHandler{}, "get", nil, map[string]any{"id": "a1"})
+}
+
+// FuzzUnsafeLookup fails by design; TestFuzzHandlerFindsPanic runs it in
+// a subprocess.
+func FuzzUnsafeLookup(f *testing.F) {
+	if os.Getenv("COPFTEST_FUZZ_UNSAFE") != "1" {
+		f.Skip("run by TestFuzzHandlerFindsPanic")
+	}
+	FuzzHandler(f, lookupHandler{}, "get", nil, map[string]any{"id": "a1"})
+}
+
+func TestFuzzHandlerFindsPanic(t *testing.T) {
+	cmd := exec.Command(os.Args[0], "-test.run=^FuzzUnsafeLookup$")
+	cmd.Env = append(os.Environ(), "COPFTEST_FUZZ_UNSAFE=1")
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("fuzz target passed, want the panic found:\n%s", out)
+	}
+	if !strings.Contains(string(out), "get panicked on input") || !strings.Contains(string(out), "interface conversion") {
+		t.Errorf("unexpected failure:\n%s", out)
+	}
+}
+
+func TestFuzzHandleReportsNilResult(t *testing.T) {
+	nilHandler := clef.HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage clef.Storage) map[string]any {
+		return nil
+	})
+	if err := fuzzHandle(nilHandler, "get", clef.NewInMemoryStorage(), []byte(`{}`)); err == nil || !strings.Contains(err.Error(), "nil map") {
+		t.Errorf("err = %v, want a nil map error", err)
+	}
+	if err := fuzzHandle(nilHandler, "get", clef.NewInMemoryStorage(), []byte(`not json`)); err != nil {
+		t.Errorf("undecodable input: err = %v, want it skipped", err)
+	}
+}
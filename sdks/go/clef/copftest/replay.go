@@ -0,0 +1,34 @@
+package copftest
+
+import (
+	"context"
+
+	"github.com/clef/go-sdk/clef"
+)
+
+// ReplayFromCompletion re-runs the invocation recorded in completion (for
+// example by clef.RecordingMiddleware in production) against handler and
+// storage, and returns the new output. The invocation keeps the recorded
+// ID and flow, so context-aware handlers see the same identifiers. A nil
+// storage means a fresh InMemoryStorage.
+func ReplayFromCompletion(handler clef.ConceptHandler, storage clef.Storage, completion clef.ActionCompletion) map[string]any {
+	if storage == nil {
+		storage = clef.NewInMemoryStorage()
+	}
+	inv := clef.ActionInvocation{
+		ID:      completion.ID,
+		Concept: completion.Concept,
+		Action:  completion.Action,
+		Input:   completion.Input,
+		Flow:    completion.Flow,
+	}
+	ctx := clef.ContextWithInvocation(context.Background(), inv)
+	if cs, ok := storage.(clef.ContextualStorage); ok {
+		storage = cs.WithContext(ctx)
+	}
+
+	if ch, ok := handler.(clef.ContextHandler); ok {
+		return ch.HandleContext(ctx, inv.Action, inv.Input, storage)
+	}
+	return handler.Handle(inv.Action, inv.Input, storage)
+}
@@ -0,0 +1,47 @@
+package copftest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/clef/go-sdk/clef"
+)
+
+// priceHandler computes an order total; buggy drops the quantity.
+type priceHandler struct{ buggy bool }
+
+func (h priceHandler) Handle(action string, input map[string]any, storage clef.Storage) map[string]any {
+	price := input["price"].(float64)
+	qty := input["qty"].(float64)
+	if h.buggy {
+		return map[string]any{"variant": "ok", "total": price}
+	}
+	return map[string]any{"variant": "ok", "total": price * qty}
+}
+
+func TestReplayFromCompletion(t *testing.T) {
+	var recorded []clef.ActionCompletion
+	handler := clef.Chain(priceHandler{buggy: true}, clef.RecordingMiddleware(func(c clef.ActionCompletion) {
+		recorded = append(recorded, c)
+	}))
+
+	inv := clef.ActionInvocation{ID: "inv-7", Flow: "flow-7", Concept: "urn:test/Order", Action: "total", Input: map[string]any{"price": 2.5, "qty": 4.0}}
+	ctx := clef.ContextWithInvocation(context.Background(), inv)
+	handler.(clef.ContextHandler).HandleContext(ctx, inv.Action, inv.Input, clef.NewInMemoryStorage())
+	if len(recorded) != 1 || recorded[0].Output["total"] != 2.5 || recorded[0].ID != "inv-7" {
+		t.Fatalf("unexpected recording %+v", recorded)
+	}
+
+	var seen clef.ActionInvocation
+	fixed := clef.HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage clef.Storage) map[string]any {
+		seen, _ = clef.InvocationFromContext(ctx)
+		return priceHandler{}.Handle(action, input, storage)
+	})
+	output := ReplayFromCompletion(fixed, nil, recorded[0])
+	if output["total"] == recorded[0].Output["total"] || output["total"] != 10.0 {
+		t.Errorf("expected fixed total 10, got %v (original %v)", output["total"], recorded[0].Output["total"])
+	}
+	if seen.ID != "inv-7" || seen.Flow != "flow-7" || seen.Concept != "urn:test/Order" {
+		t.Errorf("expected replay to keep recorded ids, got %+v", seen)
+	}
+}
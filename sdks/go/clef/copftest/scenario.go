@@ -0,0 +1,128 @@
+package copftest
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/clef/go-sdk/clef"
+)
+
+// Scenario is a sequence of invocations against one handler and storage,
+// with assertions on each step's output. Values saved with StoreOutput
+// are merged into the input of every later step, so a step can refer to
+// IDs or tokens produced earlier.
+//
+// Example:
+//
+//	copftest.NewScenario().
+//	    Step("create", map[string]any{"title": "Hello"}).
+//	    AssertVariant("ok").
+//	    StoreOutput("id", "id").
+//	    Step("get", nil).
+//	    AssertOutput("title", "Hello").
+//	    Run(t, &ArticleHandler{}, nil)
+type Scenario struct {
+	steps []*scenarioStep
+}
+
+type scenarioStep struct {
+	action string
+	input  map[string]any
+	checks []func(output map[string]any, scratch map[string]any) error
+}
+
+// NewScenario returns an empty scenario.
+func NewScenario() *Scenario {
+	return &Scenario{}
+}
+
+// Step appends an invocation of action with input. Assertions added after
+// it apply to this step.
+func (s *Scenario) Step(action string, input map[string]any) *Scenario {
+	s.steps = append(s.steps, &scenarioStep{action: action, input: input})
+	return s
+}
+
+// AssertVariant checks the current step's variant.
+func (s *Scenario) AssertVariant(expected string) *Scenario {
+	return s.check(func(output, _ map[string]any) error {
+		if output["variant"] != expected {
+			return fmt.Errorf("expected variant %q, got %v (output %v)", expected, output["variant"], output)
+		}
+		return nil
+	})
+}
+
+// AssertOutput checks that the current step's output[key] equals value.
+func (s *Scenario) AssertOutput(key string, value any) *Scenario {
+	return s.check(func(output, _ map[string]any) error {
+		if got, ok := output[key]; !ok || !reflect.DeepEqual(got, value) {
+			return fmt.Errorf("expected output %q = %#v, got %#v", key, value, got)
+		}
+		return nil
+	})
+}
+
+// StoreOutput saves the current step's output[outputField] under key, to
+// be passed as input key to every later step.
+func (s *Scenario) StoreOutput(key, outputField string) *Scenario {
+	return s.check(func(output, scratch map[string]any) error {
+		v, ok := output[outputField]
+		if !ok {
+			return fmt.Errorf("cannot store %q: output has no field %q (output %v)", key, outputField, output)
+		}
+		scratch[key] = v
+		return nil
+	})
+}
+
+func (s *Scenario) check(fn func(output, scratch map[string]any) error) *Scenario {
+	if len(s.steps) == 0 {
+		panic("copftest: assertion added before any Step")
+	}
+	last := s.steps[len(s.steps)-1]
+	last.checks = append(last.checks, fn)
+	return s
+}
+
+// Run executes the steps in order against handler and storage, reporting
+// failed assertions through t. A nil storage means a fresh
+// InMemoryStorage. Run stops at the first step with a failure, since
+// later steps usually depend on it.
+func (s *Scenario) Run(t *testing.T, handler clef.ConceptHandler, storage clef.Storage) {
+	t.Helper()
+	if storage == nil {
+		storage = clef.NewInMemoryStorage()
+	}
+	scratch := make(map[string]any)
+
+	for i, step := range s.steps {
+		input := make(map[string]any, len(scratch)+len(step.input))
+		for k, v := range scratch {
+			input[k] = v
+		}
+		for k, v := range step.input {
+			input[k] = v
+		}
+
+		var output map[string]any
+		if ch, ok := handler.(clef.ContextHandler); ok {
+			output = ch.HandleContext(context.Background(), step.action, input, storage)
+		} else {
+			output = handler.Handle(step.action, input, storage)
+		}
+
+		failed := false
+		for _, check := range step.checks {
+			if err := check(output, scratch); err != nil {
+				t.Errorf("step %d (%s): %v", i+1, step.action, err)
+				failed = true
+			}
+		}
+		if failed {
+			return
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package copftest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/clef/go-sdk/clef"
+)
+
+// noteHandler creates notes with sequential IDs and reads them back.
+type noteHandler struct {
+	next int
+}
+
+func (h *noteHandler) Handle(action string, input map[string]any, storage clef.Storage) map[string]any {
+	switch action {
+	case "create":
+		h.next++
+		id := fmt.Sprintf("note-%d", h.next)
+		storage.Put("notes", id, map[string]any{"id": id, "text": input["text"]})
+		return map[string]any{"variant": "ok", "id": id}
+	case "get":
+		id, _ := input["id"].(string)
+		note, ok := storage.Get("notes", id)
+		if !ok {
+			return map[string]any{"variant": "notfound"}
+		}
+		return map[string]any{"variant": "ok", "id": note["id"], "text": note["text"]}
+	default:
+		return map[string]any{"variant": "error", "message": "unknown action: " + action}
+	}
+}
+
+func TestScenarioCreateThenRead(t *testing.T) {
+	NewScenario().
+		Step("create", map[string]any{"text": "buy milk"}).
+		AssertVariant("ok").
+		StoreOutput("id", "id").
+		Step("get", nil).
+		AssertVariant("ok").
+		AssertOutput("text", "buy milk").
+		Step("get", map[string]any{"id": "note-99"}).
+		AssertVariant("notfound").
+		Run(t, &noteHandler{}, nil)
+}
@@ -0,0 +1,98 @@
+package clef
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSOptions configures cross-origin access to the transport.
+type CORSOptions struct {
+	// AllowedOrigins lists origins allowed to call the transport. "*"
+	// allows any origin.
+	AllowedOrigins []string
+	// AllowedMethods defaults to GET, POST and OPTIONS.
+	AllowedMethods []string
+	// AllowedHeaders defaults to Content-Type and Authorization.
+	AllowedHeaders []string
+	// MaxAge lets browsers cache preflight responses. Zero omits the
+	// Access-Control-Max-Age header.
+	MaxAge time.Duration
+}
+
+// WithCORS answers preflight requests and adds CORS headers to responses
+// for allowed origins.
+func WithCORS(opts CORSOptions) ServeOption {
+	return func(c *ServerConfig) {
+		c.cors = &opts
+	}
+}
+
+// corsHandler holds CORS settings precomputed once at construction so
+// requests only do a map lookup.
+type corsHandler struct {
+	next      http.Handler
+	anyOrigin bool
+	origins   map[string]bool
+	methods   string
+	headers   string
+	maxAge    string
+}
+
+func newCORSHandler(opts CORSOptions, next http.Handler) *corsHandler {
+	h := &corsHandler{
+		next:    next,
+		origins: make(map[string]bool, len(opts.AllowedOrigins)),
+		methods: "GET, POST, OPTIONS",
+		headers: "Content-Type, Authorization",
+	}
+	for _, o := range opts.AllowedOrigins {
+		if o == "*" {
+			h.anyOrigin = true
+		}
+		h.origins[o] = true
+	}
+	if len(opts.AllowedMethods) > 0 {
+		h.methods = strings.Join(opts.AllowedMethods, ", ")
+	}
+	if len(opts.AllowedHeaders) > 0 {
+		h.headers = strings.Join(opts.AllowedHeaders, ", ")
+	}
+	if secs := int(opts.MaxAge / time.Second); secs > 0 {
+		h.maxAge = strconv.Itoa(secs)
+	}
+	return h
+}
+
+func (h *corsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	// Responses differ by origin and requested headers, so shared caches
+	// must key on them whether or not the origin is allowed.
+	w.Header().Add("Vary", "Origin, Access-Control-Request-Headers")
+
+	allowed := h.anyOrigin || h.origins[origin]
+	preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+	if allowed {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+	if !preflight {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	if allowed {
+		w.Header().Set("Access-Control-Allow-Methods", h.methods)
+		w.Header().Set("Access-Control-Allow-Headers", h.headers)
+		if h.maxAge != "" {
+			w.Header().Set("Access-Control-Max-Age", h.maxAge)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,85 @@
+package clef
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func preflight(h http.Handler, origin string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodOptions, "/invoke", nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	req.Header.Set("Access-Control-Request-Headers", "Content-Type")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCORSPreflightAllowedOrigin(t *testing.T) {
+	h := NewHandler(WithCORS(CORSOptions{
+		AllowedOrigins: []string{"https://app.example.com"},
+		MaxAge:         10 * time.Minute,
+	}))
+
+	rec := preflight(h, "https://app.example.com")
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("unexpected Allow-Origin %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Max-Age"); got != "600" {
+		t.Errorf("expected Max-Age 600, got %q", got)
+	}
+	if got := rec.Header().Get("Vary"); got != "Origin, Access-Control-Request-Headers" {
+		t.Errorf("unexpected Vary %q", got)
+	}
+}
+
+func TestCORSPreflightDisallowedOrigin(t *testing.T) {
+	h := NewHandler(WithCORS(CORSOptions{
+		AllowedOrigins: []string{"https://app.example.com"},
+		MaxAge:         time.Minute,
+	}))
+
+	rec := preflight(h, "https://evil.example.com")
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("expected no Allow-Origin, got %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Max-Age"); got != "" {
+		t.Errorf("expected no Max-Age, got %q", got)
+	}
+	if rec.Header().Get("Vary") == "" {
+		t.Error("expected Vary header for disallowed origin")
+	}
+}
+
+func TestCORSZeroMaxAgeOmitsHeader(t *testing.T) {
+	h := NewHandler(WithCORS(CORSOptions{AllowedOrigins: []string{"*"}}))
+
+	rec := preflight(h, "https://app.example.com")
+	if _, ok := rec.Header()["Access-Control-Max-Age"]; ok {
+		t.Error("expected Max-Age to be omitted when MaxAge is zero")
+	}
+}
+
+func TestCORSSimpleRequestHeaders(t *testing.T) {
+	h := NewHandler(WithCORS(CORSOptions{AllowedOrigins: []string{"https://app.example.com"}}))
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected request to reach /health, got %d", rec.Code)
+	}
+	if rec.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		t.Error("expected Allow-Origin on simple request")
+	}
+	if rec.Header().Get("Vary") == "" {
+		t.Error("expected Vary on simple request")
+	}
+}
@@ -0,0 +1,219 @@
+package clef
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DegradedMode is how much of its functionality a concept can currently
+// offer, e.g. Degraded when a cache is down and reads fall back to the
+// database.
+type DegradedMode int
+
+const (
+	Normal DegradedMode = iota
+	Degraded
+	Critical
+)
+
+func (m DegradedMode) String() string {
+	switch m {
+	case Degraded:
+		return "degraded"
+	case Critical:
+		return "critical"
+	default:
+		return "normal"
+	}
+}
+
+// DegradedHandler is implemented by handlers that adapt to the health of
+// their dependencies. SetMode is called whenever the concept's
+// degradation policies move it to another mode.
+type DegradedHandler interface {
+	SetMode(mode DegradedMode)
+}
+
+// DefaultDegradationInterval is how often degradation policies run their
+// checks when WithDegradationInterval is not set.
+const DefaultDegradationInterval = 10 * time.Second
+
+// degradationConfig is a policy as configured with WithDegradationPolicy.
+// A policy with a target switches that instead of a concept.
+type degradationConfig struct {
+	concept           string
+	target            DegradedHandler
+	checks            []namedHealthCheck
+	degradeThreshold  int
+	criticalThreshold int
+}
+
+// degradationPolicy tracks consecutive failing rounds of a concept's
+// checks. Each server builds its own from the configs, so servers sharing
+// options do not share failure counts.
+type degradationPolicy struct {
+	degradationConfig
+
+	mu       sync.Mutex
+	failures int
+}
+
+// WithDegradationPolicy monitors checks on behalf of concept. The checks
+// run every DefaultDegradationInterval, or the interval set with
+// WithDegradationInterval, whether or not anyone requests /health. Each
+// round in which any check fails counts as a failure; a fully healthy
+// one resets the count. Once the count of consecutive failures reaches
+// degradeThreshold the concept is Degraded, and at criticalThreshold
+// Critical. With several policies for one concept, the concept is in the
+// most severe of their modes. If the concept's handler, or the handler a
+// Chain wraps, implements DegradedHandler, SetMode is called on every
+// change. The mode of each concept is reported under "degradation" in
+// /health.
+func WithDegradationPolicy(concept string, checks []HealthChecker, degradeThreshold, criticalThreshold int) ServeOption {
+	return withDegradationPolicy(degradationConfig{
+		concept:           concept,
+		degradeThreshold:  degradeThreshold,
+		criticalThreshold: criticalThreshold,
+	}, checks)
+}
+
+// withDegradationPolicy adds p, running checks, to the server's policies.
+func withDegradationPolicy(p degradationConfig, checks []HealthChecker) ServeOption {
+	for _, c := range checks {
+		p.checks = append(p.checks, namedHealthCheck{name: p.concept, checker: c})
+	}
+	return func(c *ServerConfig) {
+		c.degradation = append(c.degradation, p)
+	}
+}
+
+// key is what the policy's mode is combined under: its target, or else
+// its concept.
+func (p *degradationConfig) key() any {
+	if p.target != nil {
+		return p.target
+	}
+	return p.concept
+}
+
+// WithDegradationInterval sets how often degradation policies run their
+// checks. The default is DefaultDegradationInterval.
+func WithDegradationInterval(d time.Duration) ServeOption {
+	return func(c *ServerConfig) {
+		c.degradationInterval = d
+	}
+}
+
+// evaluate runs the policy's checks and returns the mode they call for.
+func (p *degradationPolicy) evaluate(ctx context.Context) DegradedMode {
+	failed := false
+	for _, st := range runHealthChecks(ctx, p.checks) {
+		if !st.Healthy {
+			failed = true
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if failed {
+		p.failures++
+	} else {
+		p.failures = 0
+	}
+	switch {
+	case p.criticalThreshold > 0 && p.failures >= p.criticalThreshold:
+		return Critical
+	case p.degradeThreshold > 0 && p.failures >= p.degradeThreshold:
+		return Degraded
+	}
+	return Normal
+}
+
+// degradationMonitor runs a server's degradation policies in rounds and
+// moves each concept, or policy target, to the most severe mode of its
+// policies.
+type degradationMonitor struct {
+	policies []*degradationPolicy
+
+	// round serializes rounds, so modes change in order.
+	round sync.Mutex
+	mu    sync.Mutex
+	// modes is keyed by degradationPolicy.key.
+	modes map[any]DegradedMode
+}
+
+func newDegradationMonitor(configs []degradationConfig) *degradationMonitor {
+	m := &degradationMonitor{modes: make(map[any]DegradedMode)}
+	for _, config := range configs {
+		p := &degradationPolicy{degradationConfig: config}
+		m.policies = append(m.policies, p)
+		m.modes[p.key()] = Normal
+	}
+	return m
+}
+
+// start runs a round every interval until the returned stop is called.
+func (m *degradationMonitor) start(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.run(context.Background())
+			case <-done:
+				return
+			}
+		}
+	}()
+	return sync.OnceFunc(func() { close(done) })
+}
+
+// run evaluates every policy once and applies the resulting modes,
+// calling SetMode for the concepts and targets whose mode changed.
+func (m *degradationMonitor) run(ctx context.Context) {
+	m.round.Lock()
+	defer m.round.Unlock()
+	modes := make(map[any]DegradedMode, len(m.modes))
+	for _, p := range m.policies {
+		modes[p.key()] = max(modes[p.key()], p.evaluate(ctx))
+	}
+
+	m.mu.Lock()
+	changed := make(map[any]DegradedMode)
+	for key, mode := range modes {
+		if m.modes[key] != mode {
+			m.modes[key] = mode
+			changed[key] = mode
+		}
+	}
+	m.mu.Unlock()
+
+	for key, mode := range changed {
+		switch key := key.(type) {
+		case DegradedHandler:
+			key.SetMode(mode)
+		case string:
+			if entry, ok := registry[key]; ok {
+				if dh, ok := findHandler[DegradedHandler](entry.handler); ok {
+					dh.SetMode(mode)
+				}
+			}
+		}
+	}
+}
+
+// report returns the current mode names by concept.
+func (m *degradationMonitor) report() map[string]any {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	report := make(map[string]any, len(m.modes))
+	for key, mode := range m.modes {
+		if concept, ok := key.(string); ok {
+			report[concept] = mode.String()
+		}
+	}
+	return report
+}
@@ -0,0 +1,143 @@
+package clef
+
+import (
+	"context"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// modalHandler records the modes it is switched to.
+type modalHandler struct {
+	echoHandler
+	mu    sync.Mutex
+	modes []DegradedMode
+}
+
+func (h *modalHandler) SetMode(mode DegradedMode) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.modes = append(h.modes, mode)
+}
+
+func (h *modalHandler) calls() []DegradedMode {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return slices.Clone(h.modes)
+}
+
+// switchCheck is a health check that is healthy while up is set.
+func switchCheck(up *atomic.Bool) HealthChecker {
+	return HealthCheckFunc(func(ctx context.Context) HealthStatus {
+		return HealthStatus{Healthy: up.Load(), Message: "dependency unreachable"}
+	})
+}
+
+func TestDegradationPolicy(t *testing.T) {
+	resetRegistry()
+	handler := &modalHandler{}
+	Register("urn:test/Feed", handler, nil)
+
+	var cacheUp atomic.Bool
+	s := newServer([]ServeOption{WithDegradationPolicy("urn:test/Feed", []HealthChecker{switchCheck(&cacheUp)}, 2, 4)})
+	monitor := s.degradation
+
+	mode := func() any {
+		report, _ := s.health(context.Background())
+		return report["degradation"].(map[string]any)["urn:test/Feed"]
+	}
+
+	monitor.run(context.Background())
+	if got := mode(); got != "normal" {
+		t.Errorf("after 1 failure mode = %v, want normal", got)
+	}
+	monitor.run(context.Background())
+	if got := mode(); got != "degraded" {
+		t.Errorf("after 2 failures mode = %v, want degraded", got)
+	}
+	// Health reports the mode without running another round.
+	if got := mode(); got != "degraded" {
+		t.Errorf("after 2 failures and a health request mode = %v, want degraded", got)
+	}
+	monitor.run(context.Background())
+	monitor.run(context.Background())
+	if got := mode(); got != "critical" {
+		t.Errorf("after 4 failures mode = %v, want critical", got)
+	}
+	cacheUp.Store(true)
+	monitor.run(context.Background())
+	if got := mode(); got != "normal" {
+		t.Errorf("after recovery mode = %v, want normal", got)
+	}
+
+	if got, want := handler.calls(), []DegradedMode{Degraded, Critical, Normal}; !slices.Equal(got, want) {
+		t.Errorf("SetMode calls = %v, want %v", got, want)
+	}
+}
+
+func TestDegradationPolicyRunsOnInterval(t *testing.T) {
+	resetRegistry()
+	handler := &modalHandler{}
+	Register("urn:test/Feed", handler, nil)
+
+	var up atomic.Bool
+	h := NewHandler(
+		WithDegradationPolicy("urn:test/Feed", []HealthChecker{switchCheck(&up)}, 1, 0),
+		WithDegradationInterval(time.Millisecond),
+	)
+	deadline := time.Now().Add(time.Second)
+	for len(handler.calls()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := handler.calls(); len(got) == 0 || got[0] != Degraded {
+		t.Errorf("SetMode calls without /health = %v, want degraded", got)
+	}
+	runtime.KeepAlive(h)
+}
+
+func TestDegradationPoliciesCombinePerConcept(t *testing.T) {
+	resetRegistry()
+	handler := &modalHandler{}
+	Register("urn:test/Feed", Chain(handler, RecordingMiddleware(func(ActionCompletion) {})), nil)
+
+	var cacheUp, searchUp atomic.Bool
+	cacheUp.Store(true)
+	monitor := newServer([]ServeOption{
+		WithDegradationPolicy("urn:test/Feed", []HealthChecker{switchCheck(&cacheUp)}, 1, 0),
+		WithDegradationPolicy("urn:test/Feed", []HealthChecker{switchCheck(&searchUp)}, 1, 0),
+	}).degradation
+
+	monitor.run(context.Background())
+	cacheUp.Store(false)
+	searchUp.Store(true)
+	monitor.run(context.Background())
+	cacheUp.Store(true)
+	monitor.run(context.Background())
+
+	// The concept stays degraded while either policy fails, and the
+	// handler behind the middleware is told once per change.
+	if got, want := handler.calls(), []DegradedMode{Degraded, Normal}; !slices.Equal(got, want) {
+		t.Errorf("SetMode calls = %v, want %v", got, want)
+	}
+}
+
+func TestDegradationPolicyCountsPerServer(t *testing.T) {
+	resetRegistry()
+	Register("urn:test/Feed", &modalHandler{}, nil)
+
+	var cacheUp atomic.Bool
+	opts := []ServeOption{WithDegradationPolicy("urn:test/Feed", []HealthChecker{switchCheck(&cacheUp)}, 2, 0)}
+	a, b := newServer(opts), newServer(opts)
+	a.degradation.run(context.Background())
+	b.degradation.run(context.Background())
+
+	for name, s := range map[string]*server{"a": a, "b": b} {
+		report, _ := s.health(context.Background())
+		if got := report["degradation"].(map[string]any)["urn:test/Feed"]; got != "normal" {
+			t.Errorf("server %s after 1 failure: mode = %v, want normal", name, got)
+		}
+	}
+}
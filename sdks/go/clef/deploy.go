@@ -0,0 +1,332 @@
+package clef
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config describes a deployment's concepts, as read from YAML by
+// LoadConfig:
+//
+//	concepts:
+//	  - uri: urn:app/Article
+//	    handler: article          # see RegisterHandlerFactory
+//	    config: {pageSize: 20}    # passed to the handler factory
+//	    storage:
+//	      type: redis             # memory (default) or redis
+//	      options: {addr: "localhost:6379", db: 1}
+//	    middleware:               # outermost first, as in Chain
+//	      - name: logging
+//	      - name: concurrency_limit
+//	        params: {limits: {create: 4}}
+//	    quota:
+//	      maxBytes: 1048576       # storage quota, see NewQuotaStorage
+//	      window: 1m              # per-tenant call quota, see CallerQuota
+//	      limits: {create: 100}
+//	    timeout: 2s
+type Config struct {
+	Concepts []ConceptConfig `yaml:"concepts"`
+}
+
+// ConceptConfig is one concept of a Config.
+type ConceptConfig struct {
+	URI           string             `yaml:"uri"`
+	Handler       string             `yaml:"handler"`
+	HandlerConfig map[string]any     `yaml:"config"`
+	Storage       StorageConfig      `yaml:"storage"`
+	Middleware    []MiddlewareConfig `yaml:"middleware"`
+	Quota         *QuotaConfig       `yaml:"quota"`
+	Timeout       time.Duration      `yaml:"timeout"`
+}
+
+// StorageConfig selects a concept's storage backend: "memory" or "redis"
+// (options addr, password and db).
+type StorageConfig struct {
+	Type    string         `yaml:"type"`
+	Options map[string]any `yaml:"options"`
+}
+
+// MiddlewareConfig names a built-in middleware and its parameters:
+//
+//	logging                                LoggingMiddleware(slog.Default())
+//	redaction            fields            RedactionMiddleware
+//	concurrency_limit    limits            ConcurrencyLimitMiddleware
+//	circuit_breaker      threshold, timeout  CircuitBreakerMiddleware
+//	feature_flags        prefix            FeatureFlagMiddleware(EnvFeatureFlags(prefix))
+//	deprecation_warning                    DeprecationWarningMiddleware(slog.Default())
+//	transaction                            TransactionMiddleware
+//	retry_with_rollback  maxRetries        RetryWithRollbackMiddleware
+//	request_coalescing                     RequestCoalescingMiddleware
+//	caching              ttl, maxSize, actions  CachingMiddleware
+//	recovery             exposeStack       RecoveryMiddleware
+type MiddlewareConfig struct {
+	Name   string         `yaml:"name"`
+	Params map[string]any `yaml:"params"`
+}
+
+// QuotaConfig limits a concept's storage size and per-tenant call rate.
+type QuotaConfig struct {
+	MaxBytes int64            `yaml:"maxBytes"`
+	Window   time.Duration    `yaml:"window"`
+	Limits   map[string]int64 `yaml:"limits"`
+}
+
+// RegisterHandlerType makes a handler type available to Config by name.
+// factory receives the concept's "config" section. It is
+// RegisterHandlerFactory under its older name.
+func RegisterHandlerType(name string, factory HandlerFactory) {
+	RegisterHandlerFactory(name, factory)
+}
+
+// LoadConfig reads and parses the YAML config at path. Nothing is
+// registered until Apply.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// Apply registers every concept in c. All handlers, storages and
+// middleware are built, and every concept checked as Register would,
+// before any is registered, so an invalid config registers nothing.
+func (c *Config) Apply() error {
+	entries := make([]registryEntry, len(c.Concepts))
+	slugs := make(map[string]string, len(c.Concepts))
+	for i, cc := range c.Concepts {
+		handler, storage, opts, err := cc.build()
+		if err != nil {
+			return fmt.Errorf("concept %s: %w", cc.URI, err)
+		}
+		if other, ok := slugs[ConceptSlug(cc.URI)]; ok {
+			return fmt.Errorf("concept %s: same route slug as %s", cc.URI, other)
+		}
+		slugs[ConceptSlug(cc.URI)] = cc.URI
+		if entries[i], err = prepareEntry(cc.URI, handler, storage, opts); err != nil {
+			return fmt.Errorf("concept %s: %w", cc.URI, err)
+		}
+	}
+	for i, cc := range c.Concepts {
+		addEntry(cc.URI, entries[i])
+	}
+	return nil
+}
+
+func (cc ConceptConfig) build() (ConceptHandler, Storage, ConceptOptions, error) {
+	var opts ConceptOptions
+	if cc.URI == "" {
+		return nil, nil, opts, fmt.Errorf("missing uri")
+	}
+	handler, err := CreateHandler(cc.Handler, cc.HandlerConfig)
+	if err != nil {
+		return nil, nil, opts, err
+	}
+	middleware := make([]MiddlewareFunc, 0, len(cc.Middleware))
+	for _, mc := range cc.Middleware {
+		m, err := mc.build()
+		if err != nil {
+			return nil, nil, opts, fmt.Errorf("middleware %s: %w", mc.Name, err)
+		}
+		middleware = append(middleware, m)
+	}
+	handler = Chain(handler, middleware...)
+
+	storage, err := cc.Storage.build()
+	if err != nil {
+		return nil, nil, opts, err
+	}
+	opts.Timeout = cc.Timeout
+	if q := cc.Quota; q != nil {
+		if q.MaxBytes > 0 {
+			storage = NewQuotaStorage(storage, q.MaxBytes)
+		}
+		if len(q.Limits) > 0 {
+			if q.Window <= 0 {
+				return nil, nil, opts, fmt.Errorf("quota limits need a window")
+			}
+			opts.CallerQuota(func(ctx context.Context) string {
+				tenant, _ := TenantFromContext(ctx)
+				return tenant
+			}, q.Limits, q.Window)
+		}
+	}
+	return handler, storage, opts, nil
+}
+
+func (sc StorageConfig) build() (Storage, error) {
+	switch sc.Type {
+	case "", "memory":
+		return NewInMemoryStorage(), nil
+	case "redis":
+		addr, err := paramString(sc.Options, "addr", "localhost:6379")
+		if err != nil {
+			return nil, err
+		}
+		password, err := paramString(sc.Options, "password", "")
+		if err != nil {
+			return nil, err
+		}
+		db, err := paramInt(sc.Options, "db", 0)
+		if err != nil {
+			return nil, err
+		}
+		return NewRedisStorage(addr, password, db)
+	default:
+		return nil, fmt.Errorf("unknown storage type %q", sc.Type)
+	}
+}
+
+func (mc MiddlewareConfig) build() (MiddlewareFunc, error) {
+	p := mc.Params
+	switch mc.Name {
+	case "logging":
+		return LoggingMiddleware(slog.Default()), nil
+	case "deprecation_warning":
+		return DeprecationWarningMiddleware(slog.Default()), nil
+	case "transaction":
+		return TransactionMiddleware(), nil
+	case "request_coalescing":
+		return RequestCoalescingMiddleware(), nil
+	case "redaction":
+		fields, err := paramStrings(p, "fields")
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("fields must list the fields to redact")
+		}
+		return RedactionMiddleware(fields), nil
+	case "concurrency_limit":
+		raw, ok := p["limits"].(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("limits must be a map of action to limit")
+		}
+		limits := make(map[string]int, len(raw))
+		for action := range raw {
+			n, err := paramInt(raw, action, 0)
+			if err != nil {
+				return nil, err
+			}
+			limits[action] = n
+		}
+		return ConcurrencyLimitMiddleware(limits), nil
+	case "circuit_breaker":
+		threshold, err := paramInt(p, "threshold", 5)
+		if err != nil {
+			return nil, err
+		}
+		timeout, err := paramDuration(p, "timeout", 30*time.Second)
+		if err != nil {
+			return nil, err
+		}
+		return CircuitBreakerMiddleware(CircuitBreakerOptions{Threshold: threshold, Timeout: timeout}), nil
+	case "feature_flags":
+		prefix, err := paramString(p, "prefix", "")
+		if err != nil {
+			return nil, err
+		}
+		return FeatureFlagMiddleware(EnvFeatureFlags(prefix)), nil
+	case "caching":
+		ttl, err := paramDuration(p, "ttl", time.Minute)
+		if err != nil {
+			return nil, err
+		}
+		maxSize, err := paramInt(p, "maxSize", 0)
+		if err != nil {
+			return nil, err
+		}
+		actions, err := paramStrings(p, "actions")
+		if err != nil {
+			return nil, err
+		}
+		return CachingMiddleware(CacheOptions{TTL: ttl, MaxSize: maxSize, Actions: actions}), nil
+	case "recovery":
+		expose, err := paramBool(p, "exposeStack", false)
+		if err != nil {
+			return nil, err
+		}
+		return RecoveryMiddleware(RecoveryOptions{ExposeStack: expose}), nil
+	case "retry_with_rollback":
+		n, err := paramInt(p, "maxRetries", 3)
+		if err != nil {
+			return nil, err
+		}
+		return RetryWithRollbackMiddleware(n), nil
+	default:
+		return nil, fmt.Errorf("unknown middleware")
+	}
+}
+
+func paramString(params map[string]any, key, def string) (string, error) {
+	v, ok := params[key]
+	if !ok {
+		return def, nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("%s must be a string", key)
+	}
+	return s, nil
+}
+
+func paramInt(params map[string]any, key string, def int) (int, error) {
+	v, ok := params[key]
+	if !ok {
+		return def, nil
+	}
+	n, ok := v.(int)
+	if !ok {
+		return 0, fmt.Errorf("%s must be an integer", key)
+	}
+	return n, nil
+}
+
+func paramBool(params map[string]any, key string, def bool) (bool, error) {
+	v, ok := params[key]
+	if !ok {
+		return def, nil
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("%s must be a boolean", key)
+	}
+	return b, nil
+}
+
+func paramDuration(params map[string]any, key string, def time.Duration) (time.Duration, error) {
+	s, err := paramString(params, key, "")
+	if err != nil || s == "" {
+		return def, err
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", key, err)
+	}
+	return d, nil
+}
+
+func paramStrings(params map[string]any, key string) ([]string, error) {
+	raw, ok := params[key].([]any)
+	if _, present := params[key]; present && !ok {
+		return nil, fmt.Errorf("%s must be a list of strings", key)
+	}
+	out := make([]string, 0, len(raw))
+	for _, v := range raw {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s must be a list of strings", key)
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
@@ -0,0 +1,127 @@
+package clef
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func init() {
+	RegisterHandlerType("greeter", func(config map[string]any) (ConceptHandler, error) {
+		greeting, _ := config["greeting"].(string)
+		if greeting == "" {
+			return nil, fmt.Errorf("greeting is required")
+		}
+		return HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+			return map[string]any{"variant": "ok", "message": fmt.Sprintf("%s, %v", greeting, input["name"])}
+		}), nil
+	})
+}
+
+func TestLoadConfigApply(t *testing.T) {
+	resetRegistry()
+	cfg, err := LoadConfig("testdata/concepts.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Apply(); err != nil {
+		t.Fatal(err)
+	}
+
+	greeter, ok := registry["urn:test/Greeter"]
+	if !ok {
+		t.Fatal("urn:test/Greeter not registered")
+	}
+	if greeter.options.Timeout != 2*time.Second {
+		t.Errorf("timeout = %v, want 2s", greeter.options.Timeout)
+	}
+	counter, ok := registry["urn:test/Counter"]
+	if !ok {
+		t.Fatal("urn:test/Counter not registered")
+	}
+	if _, ok := counter.storage.(*QuotaStorage); !ok {
+		t.Errorf("storage = %T, want *QuotaStorage", counter.storage)
+	}
+
+	c := InvokeLocal(context.Background(), ActionInvocation{Concept: "urn:test/Greeter", Action: "greet", Input: map[string]any{"name": "Ada"}})
+	if c.Output["message"] != "Hello, Ada" {
+		t.Errorf("unexpected completion %+v", c)
+	}
+	inv := ActionInvocation{Concept: "urn:test/Counter", Action: "greet"}
+	InvokeLocal(context.Background(), inv)
+	if c := InvokeLocal(context.Background(), inv); c.Variant != "quota_exceeded" {
+		t.Errorf("second call variant = %q, want quota_exceeded", c.Variant)
+	}
+}
+
+func TestConfigApplyRejectsInvalid(t *testing.T) {
+	resetRegistry()
+	path := filepath.Join(t.TempDir(), "bad.yaml")
+	os.WriteFile(path, []byte(`
+concepts:
+  - uri: urn:test/Greeter
+    handler: greeter
+    config: {greeting: Hello}
+  - uri: urn:test/Broken
+    handler: greeter
+    config: {greeting: Hi}
+    middleware:
+      - name: no_such_middleware
+`), 0o644)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Apply(); err == nil || !strings.Contains(err.Error(), "no_such_middleware") {
+		t.Fatalf("expected an unknown middleware error, got %v", err)
+	}
+	if _, ok := registry["urn:test/Greeter"]; ok {
+		t.Error("a failed Apply must not register any concept")
+	}
+}
+
+func TestConfigApplyRegistersNothingOnLateFailure(t *testing.T) {
+	for name, second := range map[string]string{
+		"invalid uri": `
+  - uri: not-a-urn
+    handler: greeter
+    config: {greeting: Hi}`,
+		"slug collision": `
+  - uri: urn:test-Greeter/x
+    handler: greeter
+    config: {greeting: Hi}
+  - uri: urn:test.Greeter/x
+    handler: greeter
+    config: {greeting: Hi}`,
+		"redaction without fields": `
+  - uri: urn:test/Redacted
+    handler: greeter
+    config: {greeting: Hi}
+    middleware:
+      - name: redaction`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			resetRegistry()
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			os.WriteFile(path, []byte(`
+concepts:
+  - uri: urn:test/Greeter
+    handler: greeter
+    config: {greeting: Hello}`+second+"\n"), 0o644)
+			cfg, err := LoadConfig(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := cfg.Apply(); err == nil {
+				t.Fatal("Apply succeeded")
+			}
+			if len(registry) != 0 {
+				t.Errorf("a failed Apply registered %v", registry)
+			}
+		})
+	}
+}
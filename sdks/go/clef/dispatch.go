@@ -0,0 +1,85 @@
+package clef
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ActionFunc implements a single concept action.
+type ActionFunc func(input map[string]any, storage Storage) map[string]any
+
+// DispatchHandler is a ConceptHandler that routes each action to the
+// ActionFunc registered for it with On.
+//
+// Example:
+//
+//	h := clef.NewDispatchHandler().
+//	    On("check", checkFn).
+//	    On("reset", resetFn)
+//	clef.MustRegister("urn:app/RateLimiter", h, nil)
+type DispatchHandler struct {
+	actions map[string]ActionFunc
+}
+
+// NewDispatchHandler creates a DispatchHandler with no actions.
+func NewDispatchHandler() *DispatchHandler {
+	return &DispatchHandler{actions: make(map[string]ActionFunc)}
+}
+
+// On registers fn for action, replacing any previous registration.
+func (d *DispatchHandler) On(action string, fn ActionFunc) *DispatchHandler {
+	d.actions[action] = fn
+	return d
+}
+
+// Actions returns the registered action names in sorted order.
+func (d *DispatchHandler) Actions() []string {
+	names := make([]string, 0, len(d.actions))
+	for name := range d.actions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (d *DispatchHandler) Handle(action string, input map[string]any, storage Storage) map[string]any {
+	fn, ok := d.actions[action]
+	if !ok {
+		return map[string]any{"variant": "error", "message": "unknown action: " + action}
+	}
+	return fn(input, storage)
+}
+
+var actionFuncType = reflect.TypeOf(ActionFunc(nil))
+
+// DiscoverActions builds a DispatchHandler from the methods of handler.
+// Every exported method named Handle<Name> with the ActionFunc signature is
+// registered for the action <name> (first letter lowercased), so
+// HandleCreate serves "create" and HandleResetAll serves "resetAll".
+// Methods with other names or signatures are ignored.
+func DiscoverActions(handler interface{}) *DispatchHandler {
+	d := NewDispatchHandler()
+	v := reflect.ValueOf(handler)
+	t := v.Type()
+	for i := 0; i < t.NumMethod(); i++ {
+		m := t.Method(i)
+		name, ok := strings.CutPrefix(m.Name, "Handle")
+		if !ok || name == "" {
+			continue
+		}
+		method := v.Method(i)
+		if !method.Type().ConvertibleTo(actionFuncType) {
+			continue
+		}
+		d.On(lowerFirst(name), method.Convert(actionFuncType).Interface().(ActionFunc))
+	}
+	return d
+}
+
+func lowerFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(r)) + s[size:]
+}
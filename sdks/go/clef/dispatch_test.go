@@ -0,0 +1,64 @@
+package clef
+
+import (
+	"reflect"
+	"testing"
+)
+
+type counterActions struct{ prefix string }
+
+func (c *counterActions) HandleCreate(input map[string]any, storage Storage) map[string]any {
+	name, _ := input["name"].(string)
+	storage.Put("counters", name, map[string]any{"value": 0})
+	return map[string]any{"variant": "ok", "name": c.prefix + name}
+}
+
+func (c *counterActions) HandleResetAll(input map[string]any, storage Storage) map[string]any {
+	return map[string]any{"variant": "ok"}
+}
+
+// Wrong signature: must be ignored.
+func (c *counterActions) HandleBroken(input map[string]any) map[string]any {
+	return nil
+}
+
+// Not a Handle<Name> method: must be ignored.
+func (c *counterActions) Describe(input map[string]any, storage Storage) map[string]any {
+	return nil
+}
+
+func TestDiscoverActions(t *testing.T) {
+	d := DiscoverActions(&counterActions{prefix: "ctr-"})
+
+	want := []string{"create", "resetAll"}
+	if got := d.Actions(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected actions %v, got %v", want, got)
+	}
+
+	s := NewInMemoryStorage()
+	result := d.Handle("create", map[string]any{"name": "hits"}, s)
+	if result["variant"] != "ok" || result["name"] != "ctr-hits" {
+		t.Errorf("unexpected result %v", result)
+	}
+	if _, ok := s.Get("counters", "hits"); !ok {
+		t.Error("expected discovered method to receive storage")
+	}
+}
+
+func TestDiscoverActionsIgnoresNonMatching(t *testing.T) {
+	d := DiscoverActions(&counterActions{})
+	for _, action := range []string{"broken", "describe", "Describe", ""} {
+		if d.Handle(action, nil, NewInMemoryStorage())["variant"] != "error" {
+			t.Errorf("expected %q to be unregistered", action)
+		}
+	}
+}
+
+func TestDispatchHandlerOn(t *testing.T) {
+	d := NewDispatchHandler().On("ping", func(input map[string]any, storage Storage) map[string]any {
+		return map[string]any{"variant": "ok", "pong": true}
+	})
+	if d.Handle("ping", nil, nil)["pong"] != true {
+		t.Error("expected ping to dispatch")
+	}
+}
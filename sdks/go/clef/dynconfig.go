@@ -0,0 +1,208 @@
+package clef
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ConfigRelation is the relation POST /config/{concept} writes to when
+// the concept's handler holds no DynamicConfig.
+const ConfigRelation = "_config"
+
+// DefaultConfigKey is the key POST /config/{concept} writes to when the
+// request has no "key" query parameter and the concept's handler holds no
+// DynamicConfig.
+const DefaultConfigKey = "default"
+
+// DefaultDynamicConfigTTL is how long a DynamicConfig caches its record.
+const DefaultDynamicConfigTTL = 5 * time.Second
+
+// maxConfigSetAttempts bounds the compare-and-swap retries of Set.
+const maxConfigSetAttempts = 10
+
+// configGeneration is bumped by Set and POST /config/{concept} to invalidate
+// every DynamicConfig cache.
+var configGeneration atomic.Uint64
+
+// DynamicConfig reads handler settings from one storage record, so they
+// can change without a restart. Reads are cached for a TTL; writes
+// through Set or POST /config/{concept} take effect at once.
+//
+// Example:
+//
+//	type RateLimiter struct{ *clef.DynamicConfig }
+//
+//	storage := clef.NewInMemoryStorage()
+//	h := &RateLimiter{clef.NewDynamicConfig(storage, clef.ConfigRelation, clef.DefaultConfigKey)}
+//	clef.MustRegister("urn:app/RateLimiter", h, storage)
+//
+//	// in a handler:
+//	limit := h.Get("limit", 100)
+type DynamicConfig struct {
+	storage  Storage
+	relation string
+	key      string
+	ttl      time.Duration
+
+	mu         sync.Mutex
+	cached     map[string]any
+	fetchedAt  time.Time
+	generation uint64
+}
+
+// NewDynamicConfig reads settings from the record at relation/key of
+// storage.
+func NewDynamicConfig(storage Storage, relation, key string) *DynamicConfig {
+	return &DynamicConfig{storage: storage, relation: relation, key: key, ttl: DefaultDynamicConfigTTL}
+}
+
+// WithTTL sets how long reads are cached. Zero disables caching.
+func (c *DynamicConfig) WithTTL(ttl time.Duration) *DynamicConfig {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl = ttl
+	return c
+}
+
+// Get returns field, or defaultVal if it is not set.
+func (c *DynamicConfig) Get(field string, defaultVal any) any {
+	if v, ok := c.record()[field]; ok {
+		return v
+	}
+	return defaultVal
+}
+
+// dynamicConfig returns c. It makes every handler that embeds
+// *DynamicConfig a configHolder.
+func (c *DynamicConfig) dynamicConfig() *DynamicConfig {
+	return c
+}
+
+// configHolder is implemented by handlers that embed *DynamicConfig.
+type configHolder interface {
+	dynamicConfig() *DynamicConfig
+}
+
+// Values returns a copy of every setting. POST /query answers
+// ConfigRelation with it for handlers that embed *DynamicConfig.
+func (c *DynamicConfig) Values() map[string]any {
+	return maps.Clone(c.record())
+}
+
+// record returns the cached record, refetching it once the TTL has
+// elapsed or the cache was invalidated.
+func (c *DynamicConfig) record() map[string]any {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	gen := configGeneration.Load()
+	if c.cached != nil && gen == c.generation && time.Since(c.fetchedAt) < c.ttl {
+		return c.cached
+	}
+	record, _ := c.storage.Get(c.relation, c.key)
+	if record == nil {
+		record = map[string]any{}
+	}
+	c.cached, c.fetchedAt, c.generation = record, time.Now(), gen
+	return record
+}
+
+// Set writes field, keeping the record's other fields, and invalidates
+// every DynamicConfig cache. It fails only if concurrent writers keep
+// winning the race.
+func (c *DynamicConfig) Set(field string, value any) error {
+	if err := patchConfig(c.storage, c.relation, c.key, map[string]any{field: value}); err != nil {
+		return err
+	}
+	configGeneration.Add(1)
+	return nil
+}
+
+// patchConfig shallow-merges fields into the record at relation/key.
+func patchConfig(storage Storage, relation, key string, fields map[string]any) error {
+	for range maxConfigSetAttempts {
+		current, found := storage.Get(relation, key)
+		next := make(map[string]any, len(current)+len(fields))
+		for k, v := range current {
+			next[k] = v
+		}
+		for k, v := range fields {
+			next[k] = v
+		}
+		var expected map[string]any
+		var compare []string
+		if found {
+			expected = current
+			for k := range current {
+				compare = append(compare, k)
+			}
+		}
+		if ok, _ := storage.CompareAndSwap(relation, key, expected, next, compare); ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("clef: config %s/%s: too many concurrent writes", relation, key)
+}
+
+// WithConfigEndpoint enables POST /config/{concept}, which merges the
+// JSON object in the body into the record the concept's DynamicConfig
+// reads and invalidates every DynamicConfig cache. The "key" query
+// parameter writes another record of the same relation. For handlers
+// without a DynamicConfig it writes the ConfigRelation record (the "key"
+// query parameter, or DefaultConfigKey) of the concept's storage, inside
+// its namespace under WithNamespaceIsolation. Requests must carry
+// "Authorization: Bearer <adminToken>".
+func WithConfigEndpoint(adminToken string) ServeOption {
+	return func(c *ServerConfig) {
+		c.configToken = adminToken
+	}
+}
+
+// handleConfig serves POST /config/{concept}. {concept} is the concept's
+// slug as in /invoke/{concept}/{action}.
+func (s *server) handleConfig(w http.ResponseWriter, r *http.Request) {
+	if s.config.configToken == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorized(r, s.config.configToken) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	slug := r.PathValue("concept")
+	uri := conceptForSlug(slug)
+	if uri == "" {
+		http.Error(w, "unknown concept: "+slug, http.StatusNotFound)
+		return
+	}
+	var fields map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entry := isolate(uri, registry[uri])
+	storage, relation, key := entry.storage, ConfigRelation, DefaultConfigKey
+	if h, ok := findHandler[configHolder](entry.handler); ok {
+		c := h.dynamicConfig()
+		storage, relation, key = c.storage, c.relation, c.key
+	}
+	if k := r.URL.Query().Get("key"); k != "" {
+		key = k
+	}
+	if err := patchConfig(storage, relation, key, fields); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	configGeneration.Add(1)
+	record, _ := storage.Get(relation, key)
+	s.writeJSON(w, r, record)
+}
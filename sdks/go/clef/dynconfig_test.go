@@ -0,0 +1,114 @@
+package clef
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// greetingHandler reads its greeting from a DynamicConfig on every call.
+type greetingHandler struct {
+	*DynamicConfig
+}
+
+func (h *greetingHandler) Handle(action string, input map[string]any, storage Storage) map[string]any {
+	return map[string]any{"variant": "ok", "message": h.Get("greeting", "Hello")}
+}
+
+func TestDynamicConfigEndpoint(t *testing.T) {
+	resetRegistry()
+	storage := NewInMemoryStorage()
+	h := &greetingHandler{NewDynamicConfig(storage, ConfigRelation, DefaultConfigKey).WithTTL(time.Hour)}
+	Register("urn:test/Greeter", h, storage)
+	handler := NewHandler(WithConfigEndpoint("secret"))
+
+	greet := func() any {
+		return InvokeLocal(context.Background(), ActionInvocation{Concept: "urn:test/Greeter", Action: "greet"}).Output["message"]
+	}
+	if got := greet(); got != "Hello" {
+		t.Fatalf("default greeting = %v", got)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/config/test-Greeter", strings.NewReader(`{"greeting":"Howdy"}`))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("unauthenticated POST: %d, want 401", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/config/test-Greeter", strings.NewReader(`{"greeting":"Howdy"}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /config: %d %s", rec.Code, rec.Body)
+	}
+	if got := greet(); got != "Howdy" {
+		t.Errorf("greeting after update = %v, want Howdy despite the cache TTL", got)
+	}
+}
+
+func TestDynamicConfigCache(t *testing.T) {
+	storage := NewInMemoryStorage()
+	cfg := NewDynamicConfig(storage, "settings", "limits").WithTTL(20 * time.Millisecond)
+	if err := cfg.Set("max", 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Set("min", 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Get("max", 0); got != 10 {
+		t.Fatalf("max = %v, want 10", got)
+	}
+
+	// A write that bypasses DynamicConfig is seen once the TTL elapses.
+	storage.Put("settings", "limits", map[string]any{"max": 20, "min": 1})
+	if got := cfg.Get("max", 0); got != 10 {
+		t.Errorf("max within TTL = %v, want cached 10", got)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if got := cfg.Get("max", 0); got != 20 {
+		t.Errorf("max after TTL = %v, want 20", got)
+	}
+	if got := cfg.Get("missing", "x"); got != "x" {
+		t.Errorf("missing field = %v, want default", got)
+	}
+}
+
+func TestDynamicConfigEndpointWritesWhereHandlerReads(t *testing.T) {
+	resetRegistry()
+	storage := NewInMemoryStorage()
+	h := &greetingHandler{NewDynamicConfig(storage, "settings", "greeter").WithTTL(time.Hour)}
+	Register("urn:test/Greeter", Chain(h, LoggingMiddleware(nil)), storage)
+	Register("urn:test/Plain", &echoHandler{}, storage)
+	handler := NewHandler(WithConfigEndpoint("secret"), WithNamespaceIsolation())
+
+	post := func(path string) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"greeting":"Howdy"}`))
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("POST %s: %d %s", path, rec.Code, rec.Body)
+		}
+	}
+
+	// The handler's DynamicConfig reads its own relation and key of the
+	// storage it was given, not the concept's namespace.
+	post("/config/test-Greeter")
+	if got := h.Get("greeting", "Hello"); got != "Howdy" {
+		t.Errorf("greeting = %v, want Howdy", got)
+	}
+
+	post("/config/test-Plain")
+	if _, ok := storage.Get(ConfigRelation, DefaultConfigKey); ok {
+		t.Error("config written outside the concept's namespace")
+	}
+	if _, ok := NamespacedStorage("urn:test/Plain", storage).Get(ConfigRelation, DefaultConfigKey); !ok {
+		t.Error("config missing from the concept's namespace")
+	}
+}
@@ -0,0 +1,106 @@
+package clef
+
+import (
+	"maps"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// InputEnricher adds request-derived fields to an invocation's input
+// before it is dispatched. inv.Input is never nil when it is called, and
+// is a copy the enricher may write to.
+//
+// Name added fields with a leading underscore, like the enrichers here:
+// such metadata fields are left out of the keys of CachingMiddleware,
+// ContentAddressedCache and CoalescingMiddleware, so a per-request field
+// does not defeat them, and are not checked by
+// SchemaValidationMiddleware. A handler whose output depends on one
+// needs a CacheOptions.KeyFunc that includes it.
+type InputEnricher func(r *http.Request, inv *ActionInvocation)
+
+// WithInputEnrichment runs enrichers, in order, on every invocation
+// received over HTTP, before tenant extraction and the handler.
+// Enrichers overwrite fields the caller sent under the same names.
+//
+// Example:
+//
+//	clef.Serve(":8091", clef.WithInputEnrichment(clef.RequestIDEnricher(), clef.LocaleEnricher()))
+func WithInputEnrichment(enrichers ...InputEnricher) ServeOption {
+	return func(c *ServerConfig) {
+		c.enrichers = append(c.enrichers, enrichers...)
+	}
+}
+
+// enrich applies the configured enrichers to inv.
+func (s *server) enrich(r *http.Request, inv *ActionInvocation) {
+	if len(s.config.enrichers) == 0 {
+		return
+	}
+	if inv.Input == nil {
+		inv.Input = map[string]any{}
+	} else {
+		inv.Input = maps.Clone(inv.Input)
+	}
+	for _, e := range s.config.enrichers {
+		e(r, inv)
+	}
+}
+
+// withoutMetadata returns input without its "_"-prefixed metadata
+// fields, or input itself if it has none.
+func withoutMetadata(input map[string]any) map[string]any {
+	isMetadata := func(k string, _ any) bool { return strings.HasPrefix(k, "_") }
+	for k, v := range input {
+		if isMetadata(k, v) {
+			stripped := maps.Clone(input)
+			maps.DeleteFunc(stripped, isMetadata)
+			return stripped
+		}
+	}
+	return input
+}
+
+// RequestIDEnricher sets "_request_id" from the X-Request-ID header, or
+// to a new UUID when the header is absent.
+func RequestIDEnricher() InputEnricher {
+	return func(r *http.Request, inv *ActionInvocation) {
+		id := r.Header.Get("X-Request-ID")
+		if id == "" {
+			id = uuid.NewString()
+		}
+		inv.Input["_request_id"] = id
+	}
+}
+
+// LocaleEnricher sets "_locale" to the first language of the
+// Accept-Language header, e.g. "fr-CH" for "fr-CH, fr;q=0.9". It sets
+// nothing when the header is absent.
+func LocaleEnricher() InputEnricher {
+	return func(r *http.Request, inv *ActionInvocation) {
+		first, _, _ := strings.Cut(r.Header.Get("Accept-Language"), ",")
+		locale, _, _ := strings.Cut(first, ";")
+		if locale = strings.TrimSpace(locale); locale != "" && locale != "*" {
+			inv.Input["_locale"] = locale
+		}
+	}
+}
+
+// ForwardedForEnricher sets "_client_ip" to the first address of the
+// X-Forwarded-For header, falling back to the connection's remote
+// address. Only trust the header behind a proxy that sets it.
+func ForwardedForEnricher() InputEnricher {
+	return func(r *http.Request, inv *ActionInvocation) {
+		first, _, _ := strings.Cut(r.Header.Get("X-Forwarded-For"), ",")
+		ip := strings.TrimSpace(first)
+		if ip == "" {
+			ip = r.RemoteAddr
+			if host, _, err := net.SplitHostPort(ip); err == nil {
+				ip = host
+			}
+		}
+		inv.Input["_client_ip"] = ip
+	}
+}
@@ -0,0 +1,86 @@
+package clef
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInputEnrichment(t *testing.T) {
+	resetRegistry()
+	var seen map[string]any
+	Register("urn:test/Spy", HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		seen = input
+		return map[string]any{"variant": "ok"}
+	}), nil)
+	h := NewHandler(WithInputEnrichment(RequestIDEnricher(), LocaleEnricher(), ForwardedForEnricher()))
+
+	req := httptest.NewRequest(http.MethodPost, "/invoke", strings.NewReader(`{"concept":"urn:test/Spy","action":"look"}`))
+	req.Header.Set("X-Request-ID", "req-42")
+	req.Header.Set("Accept-Language", "fr-CH, fr;q=0.9, en;q=0.8")
+	req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	want := map[string]any{"_request_id": "req-42", "_locale": "fr-CH", "_client_ip": "203.0.113.7"}
+	for k, v := range want {
+		if seen[k] != v {
+			t.Errorf("input[%q] = %v, want %v", k, seen[k], v)
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/invoke", strings.NewReader(`{"concept":"urn:test/Spy","action":"look","input":{"q":1}}`))
+	req.RemoteAddr = "198.51.100.2:5555"
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if id, _ := seen["_request_id"].(string); id == "" {
+		t.Error("expected a generated request ID")
+	}
+	if _, ok := seen["_locale"]; ok {
+		t.Error("no locale expected without Accept-Language")
+	}
+	if seen["_client_ip"] != "198.51.100.2" || seen["q"] != float64(1) {
+		t.Errorf("unexpected input %v", seen)
+	}
+}
+
+func TestEnrichedFieldsKeepCachesAndSchemasWorking(t *testing.T) {
+	resetRegistry()
+	calls := 0
+	Register("urn:test/Article", Chain(HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		calls++
+		return map[string]any{"variant": "ok"}
+	}),
+		SchemaValidationMiddleware(map[string]map[string]any{"get": {
+			"type":                 "object",
+			"properties":           map[string]any{"slug": map[string]any{"type": "string"}},
+			"additionalProperties": false,
+		}}),
+		CachingMiddleware(CacheOptions{TTL: time.Minute, Actions: []string{"get"}}),
+	), nil)
+	h := NewHandler(WithInputEnrichment(RequestIDEnricher()))
+
+	for range 3 {
+		rec := doRequest(h, "POST", "/invoke", `{"concept":"urn:test/Article","action":"get","input":{"slug":"a"}}`)
+		if !strings.Contains(rec.Body.String(), `"variant":"ok"`) {
+			t.Fatalf("response %s", rec.Body)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("handler called %d times for one input with fresh request IDs, want 1", calls)
+	}
+}
+
+func TestInputEnrichmentCopiesInput(t *testing.T) {
+	s := &server{config: ServerConfig{enrichers: []InputEnricher{RequestIDEnricher()}}}
+	input := map[string]any{"slug": "a"}
+	inv := ActionInvocation{Input: input}
+	s.enrich(httptest.NewRequest(http.MethodPost, "/invoke", nil), &inv)
+	if _, ok := input["_request_id"]; ok {
+		t.Error("enricher wrote to the caller's input")
+	}
+	if _, ok := inv.Input["_request_id"]; !ok {
+		t.Error("enriched input has no request ID")
+	}
+}
@@ -0,0 +1,93 @@
+package clef
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sort"
+)
+
+// errorCodes maps concept URIs to the error codes they declare, with a
+// human-readable description for each.
+var errorCodes = make(map[string]map[string]string)
+
+// RegisterErrorCodes declares the error codes a concept may return in
+// output["code"], keyed by code with a description as the value. Codes
+// accumulate across calls; re-registering a code replaces its description.
+//
+// Example:
+//
+//	clef.RegisterErrorCodes("urn:app/User", map[string]string{
+//	    "not_found":       "no user with the given id",
+//	    "duplicate_email": "email is already registered",
+//	})
+func RegisterErrorCodes(uri string, codes map[string]string) {
+	if errorCodes[uri] == nil {
+		errorCodes[uri] = make(map[string]string)
+	}
+	for code, desc := range codes {
+		errorCodes[uri][code] = desc
+	}
+}
+
+// ErrorCodeEntry is one concept's registration of an error code.
+type ErrorCodeEntry struct {
+	Concept     string `json:"concept"`
+	Description string `json:"description"`
+}
+
+// errorCatalog merges registered codes into code → registrations, limited
+// to one concept when concept is non-empty. Registrations are sorted by
+// concept URI so the encoding is stable.
+func errorCatalog(concept string) map[string][]ErrorCodeEntry {
+	catalog := make(map[string][]ErrorCodeEntry)
+	for uri, codes := range errorCodes {
+		if concept != "" && uri != concept {
+			continue
+		}
+		for code, desc := range codes {
+			catalog[code] = append(catalog[code], ErrorCodeEntry{Concept: uri, Description: desc})
+		}
+	}
+	for _, entries := range catalog {
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Concept < entries[j].Concept })
+	}
+	return catalog
+}
+
+// ExportErrorCatalog returns the full error catalog as JSON, in the same
+// format served by GET /error-catalog, for embedding in generated clients.
+func ExportErrorCatalog() ([]byte, error) {
+	return json.Marshal(errorCatalog(""))
+}
+
+func (s *server) handleErrorCatalog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	catalog := errorCatalog(r.URL.Query().Get("concept"))
+	var body []byte
+	var err error
+	if s.pretty(r) {
+		body, err = json.MarshalIndent(catalog, "", "  ")
+	} else {
+		body, err = json.Marshal(catalog)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
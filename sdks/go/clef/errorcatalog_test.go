@@ -0,0 +1,91 @@
+package clef
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func registerCatalogConcepts() {
+	resetRegistry()
+	RegisterErrorCodes("urn:test/User", map[string]string{
+		"not_found":       "no such user",
+		"duplicate_email": "email taken",
+	})
+	RegisterErrorCodes("urn:test/Article", map[string]string{
+		"not_found": "no such article",
+	})
+}
+
+func TestErrorCatalogAggregatesConcepts(t *testing.T) {
+	registerCatalogConcepts()
+
+	rec := doRequest(NewHandler(), http.MethodGet, "/error-catalog", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var catalog map[string][]ErrorCodeEntry
+	if err := json.NewDecoder(rec.Body).Decode(&catalog); err != nil {
+		t.Fatal(err)
+	}
+	if len(catalog) != 2 {
+		t.Fatalf("expected 2 codes, got %v", catalog)
+	}
+	nf := catalog["not_found"]
+	if len(nf) != 2 || nf[0].Concept != "urn:test/Article" || nf[1].Concept != "urn:test/User" {
+		t.Errorf("expected not_found from both concepts, got %+v", nf)
+	}
+	if catalog["duplicate_email"][0].Description != "email taken" {
+		t.Errorf("unexpected duplicate_email entry %+v", catalog["duplicate_email"])
+	}
+}
+
+func TestErrorCatalogFilterByConcept(t *testing.T) {
+	registerCatalogConcepts()
+
+	rec := doRequest(NewHandler(), http.MethodGet, "/error-catalog?concept=urn:test/Article", "")
+	var catalog map[string][]ErrorCodeEntry
+	json.NewDecoder(rec.Body).Decode(&catalog)
+	if len(catalog) != 1 || len(catalog["not_found"]) != 1 {
+		t.Errorf("expected only Article codes, got %+v", catalog)
+	}
+}
+
+func TestErrorCatalogETag(t *testing.T) {
+	registerCatalogConcepts()
+	h := NewHandler()
+
+	rec := doRequest(h, http.MethodGet, "/error-catalog", "")
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("expected ETag header")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/error-catalog", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for matching ETag, got %d", rec.Code)
+	}
+
+	RegisterErrorCodes("urn:test/User", map[string]string{"locked": "account locked"})
+	rec = doRequest(h, http.MethodGet, "/error-catalog", "")
+	if rec.Header().Get("ETag") == etag {
+		t.Error("expected ETag to change with catalog content")
+	}
+}
+
+func TestExportErrorCatalogMatchesEndpoint(t *testing.T) {
+	registerCatalogConcepts()
+
+	exported, err := ExportErrorCatalog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := doRequest(NewHandler(), http.MethodGet, "/error-catalog", "")
+	if rec.Body.String() != string(exported) {
+		t.Errorf("export and endpoint differ:\n%s\n%s", exported, rec.Body.String())
+	}
+}
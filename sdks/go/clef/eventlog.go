@@ -0,0 +1,261 @@
+package clef
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrCompacted is returned when an operation needs events that have been
+// removed by CompactBefore.
+var ErrCompacted = errors.New("clef: events compacted")
+
+// Event is one entry in an EventLog stream. Sequence numbers start at 1
+// and increase by one per append within a relation.
+type Event struct {
+	Seq       int64          `json:"seq"`
+	Timestamp time.Time      `json:"timestamp"`
+	Data      map[string]any `json:"data"`
+}
+
+// Reducer folds an event into state and returns the new state. It may
+// modify and return state in place.
+type Reducer func(state map[string]any, event map[string]any) map[string]any
+
+// MergeReducer shallow-merges each event's fields into the state.
+func MergeReducer(state, event map[string]any) map[string]any {
+	for k, v := range event {
+		state[k] = v
+	}
+	return state
+}
+
+// EventLog is an append-only, per-relation event store for event-sourced
+// concepts. State is derived by reducing events; Snapshot, ReplayFrom and
+// CompactBefore cover the rebuild cycle.
+type EventLog struct {
+	mu      sync.RWMutex
+	reducer Reducer
+	streams map[string]*eventStream
+	// storage persists the streams; nil keeps them in memory only.
+	storage Storage
+}
+
+// eventLogRelation holds the sequence numbers of a persistent EventLog's
+// streams, keyed by relation. The events of relation are stored in
+// eventLogRelation + "/" + relation, keyed by eventSeqKey.
+const eventLogRelation = "_eventlog"
+
+type eventStream struct {
+	events []Event
+	// compacted is the first sequence number still retained.
+	compacted int64
+	lastSeq   int64
+}
+
+// NewEventLog creates an empty event log. A nil reducer defaults to
+// MergeReducer.
+func NewEventLog(reducer Reducer) *EventLog {
+	if reducer == nil {
+		reducer = MergeReducer
+	}
+	return &EventLog{
+		reducer: reducer,
+		streams: make(map[string]*eventStream),
+	}
+}
+
+// NewPersistentEventLog is NewEventLog keeping its events in storage as
+// well, so they survive a restart when storage does. A stream is read
+// back from storage the first time it is used.
+//
+// Example:
+//
+//	log := clef.NewPersistentEventLog(nil, redisStorage)
+//	log.Append("orders", map[string]any{"type": "placed", "id": "o1"})
+func NewPersistentEventLog(reducer Reducer, storage Storage) *EventLog {
+	l := NewEventLog(reducer)
+	l.storage = storage
+	return l
+}
+
+// stream returns relation's stream, loading it from storage or creating
+// it on first use. The caller holds mu for writing.
+func (l *EventLog) stream(relation string) *eventStream {
+	st, ok := l.streams[relation]
+	if !ok {
+		st = l.readStream(relation)
+		l.streams[relation] = st
+	}
+	return st
+}
+
+// load makes sure relation's stream has been read from storage, for the
+// methods that only hold mu for reading.
+func (l *EventLog) load(relation string) {
+	if l.storage == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stream(relation)
+}
+
+// readStream reads relation's stream from storage, or returns an empty
+// one for an in-memory log.
+func (l *EventLog) readStream(relation string) *eventStream {
+	st := &eventStream{compacted: 1}
+	if l.storage == nil {
+		return st
+	}
+	if meta, ok := l.storage.Get(eventLogRelation, relation); ok {
+		st.lastSeq, _ = toInt64(meta["lastSeq"])
+		if compacted, _ := toInt64(meta["compacted"]); compacted > 1 {
+			st.compacted = compacted
+		}
+	}
+	for _, rec := range l.storage.Find(eventLogRelation+"/"+relation, nil) {
+		seq, _ := toInt64(rec["seq"])
+		ts, _ := rec["timestamp"].(string)
+		timestamp, _ := time.Parse(time.RFC3339Nano, ts)
+		data, _ := rec["data"].(map[string]any)
+		st.events = append(st.events, Event{Seq: seq, Timestamp: timestamp, Data: data})
+	}
+	slices.SortFunc(st.events, func(a, b Event) int { return cmp.Compare(a.Seq, b.Seq) })
+	return st
+}
+
+// eventSeqKey is the storage key of event seq, padded so keys sort in
+// sequence order.
+func eventSeqKey(seq int64) string {
+	return fmt.Sprintf("%020d", seq)
+}
+
+// persistMeta stores st's sequence numbers. The caller holds mu.
+func (l *EventLog) persistMeta(relation string, st *eventStream) {
+	l.storage.Put(eventLogRelation, relation, map[string]any{"lastSeq": st.lastSeq, "compacted": st.compacted})
+}
+
+// Append records an event and returns its sequence number.
+func (l *EventLog) Append(relation string, data map[string]any) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	st := l.stream(relation)
+	st.lastSeq++
+	e := Event{Seq: st.lastSeq, Timestamp: time.Now(), Data: data}
+	st.events = append(st.events, e)
+	if l.storage != nil {
+		l.storage.Put(eventLogRelation+"/"+relation, eventSeqKey(e.Seq), map[string]any{
+			"seq":       e.Seq,
+			"timestamp": e.Timestamp.Format(time.RFC3339Nano),
+			"data":      data,
+		})
+		l.persistMeta(relation, st)
+	}
+	return st.lastSeq
+}
+
+// Events returns the retained events of a relation in sequence order.
+func (l *EventLog) Events(relation string) []Event {
+	l.load(relation)
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	st, ok := l.streams[relation]
+	if !ok {
+		return nil
+	}
+	return append([]Event(nil), st.events...)
+}
+
+// Snapshot reduces all events with Seq <= upTo into a state map. It fails
+// with ErrCompacted if any of those events have been compacted away.
+func (l *EventLog) Snapshot(relation string, upTo int64) (map[string]any, error) {
+	l.load(relation)
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	st, ok := l.streams[relation]
+	if !ok {
+		return map[string]any{}, nil
+	}
+	if st.compacted > 1 {
+		return nil, fmt.Errorf("%w: %s retains events from seq %d", ErrCompacted, relation, st.compacted)
+	}
+	return l.reduce(st, map[string]any{}, 0, upTo), nil
+}
+
+// ReplayFrom applies all events with Seq > fromSeq on top of a copy of
+// snapshot. It fails with ErrCompacted if events between fromSeq and the
+// oldest retained event are missing.
+func (l *EventLog) ReplayFrom(relation string, snapshot map[string]any, fromSeq int64) (map[string]any, error) {
+	l.load(relation)
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	state := make(map[string]any, len(snapshot))
+	for k, v := range snapshot {
+		state[k] = v
+	}
+	st, ok := l.streams[relation]
+	if !ok {
+		return state, nil
+	}
+	if fromSeq+1 < st.compacted {
+		return nil, fmt.Errorf("%w: %s needs seq %d but retains from %d", ErrCompacted, relation, fromSeq+1, st.compacted)
+	}
+	return l.reduce(st, state, fromSeq, st.lastSeq), nil
+}
+
+// CompactBefore deletes events with Seq < seq and returns how many were
+// removed. Take a Snapshot first; compacted events cannot be replayed.
+func (l *EventLog) CompactBefore(relation string, seq int64) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	st := l.stream(relation)
+	if st.lastSeq == 0 {
+		return 0, nil
+	}
+	if seq > st.lastSeq+1 {
+		return 0, fmt.Errorf("clef: cannot compact %s before seq %d, last seq is %d", relation, seq, st.lastSeq)
+	}
+
+	n := 0
+	for n < len(st.events) && st.events[n].Seq < seq {
+		n++
+	}
+	if l.storage != nil && n > 0 {
+		keys := make([]string, n)
+		for i, e := range st.events[:n] {
+			keys[i] = eventSeqKey(e.Seq)
+		}
+		l.storage.BulkDelete(eventLogRelation+"/"+relation, keys)
+	}
+	st.events = append([]Event(nil), st.events[n:]...)
+	if seq > st.compacted {
+		st.compacted = seq
+	}
+	if l.storage != nil {
+		l.persistMeta(relation, st)
+	}
+	return n, nil
+}
+
+// reduce folds events with from < Seq <= to into state.
+func (l *EventLog) reduce(st *eventStream, state map[string]any, from, to int64) map[string]any {
+	for _, e := range st.events {
+		if e.Seq <= from {
+			continue
+		}
+		if e.Seq > to {
+			break
+		}
+		state = l.reducer(state, e.Data)
+	}
+	return state
+}
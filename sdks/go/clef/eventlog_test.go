@@ -0,0 +1,116 @@
+package clef
+
+import (
+	"errors"
+	"testing"
+)
+
+// accountReducer applies deposit and withdrawal events to a balance.
+func accountReducer(state, event map[string]any) map[string]any {
+	balance, _ := state["balance"].(int)
+	amount, _ := event["amount"].(int)
+	switch event["type"] {
+	case "opened":
+		state["owner"] = event["owner"]
+	case "deposited":
+		balance += amount
+	case "withdrew":
+		balance -= amount
+	}
+	state["balance"] = balance
+	return state
+}
+
+func bankLog() *EventLog {
+	l := NewEventLog(accountReducer)
+	l.Append("acct-1", map[string]any{"type": "opened", "owner": "alice"})
+	l.Append("acct-1", map[string]any{"type": "deposited", "amount": 100})
+	l.Append("acct-1", map[string]any{"type": "withdrew", "amount": 30})
+	l.Append("acct-1", map[string]any{"type": "deposited", "amount": 50})
+	return l
+}
+
+func TestEventLogSnapshot(t *testing.T) {
+	l := bankLog()
+	snap, err := l.Snapshot("acct-1", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap["balance"] != 70 || snap["owner"] != "alice" {
+		t.Errorf("unexpected snapshot %v", snap)
+	}
+}
+
+func TestEventLogReplayFrom(t *testing.T) {
+	l := bankLog()
+	snap, _ := l.Snapshot("acct-1", 3)
+	state, err := l.ReplayFrom("acct-1", snap, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state["balance"] != 120 {
+		t.Errorf("expected balance 120, got %v", state["balance"])
+	}
+	if snap["balance"] != 70 {
+		t.Error("ReplayFrom must not mutate the snapshot")
+	}
+}
+
+func TestEventLogRebuildCycle(t *testing.T) {
+	l := bankLog()
+	snap, _ := l.Snapshot("acct-1", 3)
+
+	removed, err := l.CompactBefore("acct-1", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 3 {
+		t.Errorf("expected 3 events removed, got %d", removed)
+	}
+	if len(l.Events("acct-1")) != 1 {
+		t.Errorf("expected 1 retained event, got %d", len(l.Events("acct-1")))
+	}
+
+	l.Append("acct-1", map[string]any{"type": "withdrew", "amount": 20})
+	state, err := l.ReplayFrom("acct-1", snap, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state["balance"] != 100 {
+		t.Errorf("expected balance 100 after rebuild, got %v", state["balance"])
+	}
+}
+
+func TestEventLogCompactedReplayFails(t *testing.T) {
+	l := bankLog()
+	l.CompactBefore("acct-1", 3)
+
+	if _, err := l.Snapshot("acct-1", 4); !errors.Is(err, ErrCompacted) {
+		t.Errorf("expected ErrCompacted from Snapshot, got %v", err)
+	}
+	if _, err := l.ReplayFrom("acct-1", map[string]any{}, 0); !errors.Is(err, ErrCompacted) {
+		t.Errorf("expected ErrCompacted from ReplayFrom, got %v", err)
+	}
+}
+
+func TestPersistentEventLog(t *testing.T) {
+	storage := NewInMemoryStorage()
+	l := NewPersistentEventLog(accountReducer, storage)
+	l.Append("acct-1", map[string]any{"type": "opened", "owner": "alice"})
+	l.Append("acct-1", map[string]any{"type": "deposited", "amount": 100})
+	l.Append("acct-1", map[string]any{"type": "withdrew", "amount": 30})
+	if _, err := l.CompactBefore("acct-1", 2); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := NewPersistentEventLog(accountReducer, storage)
+	if events := restarted.Events("acct-1"); len(events) != 2 || events[0].Seq != 2 {
+		t.Fatalf("events after restart = %+v", events)
+	}
+	if _, err := restarted.Snapshot("acct-1", 3); !errors.Is(err, ErrCompacted) {
+		t.Errorf("Snapshot over compacted events: err = %v", err)
+	}
+	if seq := restarted.Append("acct-1", map[string]any{"type": "deposited", "amount": 5}); seq != 4 {
+		t.Errorf("seq after restart = %d, want 4", seq)
+	}
+}
@@ -0,0 +1,61 @@
+package clef
+
+// EventStore keeps an append-only event stream per relation and key
+// alongside a Storage, for concepts whose records are derived by
+// replaying events rather than overwritten with Put. It is an EventLog
+// with one stream per key instead of one per relation, persisted in the
+// same storage, so the events survive a restart when the storage does.
+//
+// EventStore is itself a Storage: reads and writes go to inner, which
+// holds the states RebuildState derives.
+//
+// Example:
+//
+//	store := clef.NewEventSourcedStorage(clef.NewInMemoryStorage())
+//	store.AppendEvent("accounts", "a1", map[string]any{"opened": true})
+//	store.AppendEvent("accounts", "a1", map[string]any{"balance": 100})
+//	account := store.RebuildState("accounts", "a1", clef.MergeReducer)
+type EventStore struct {
+	Storage
+	log *EventLog
+}
+
+// NewEventSourcedStorage returns an EventStore over inner, with the
+// events already stored in inner.
+func NewEventSourcedStorage(inner Storage) *EventStore {
+	return &EventStore{Storage: inner, log: NewPersistentEventLog(nil, inner)}
+}
+
+// keyStream names the EventLog stream of relation/key.
+func keyStream(relation, key string) string {
+	return relation + "\x00" + key
+}
+
+// AppendEvent records event as the next event of relation/key.
+func (s *EventStore) AppendEvent(relation, key string, event map[string]any) {
+	s.log.Append(keyStream(relation, key), event)
+}
+
+// ReadEvents returns the events of relation/key with Seq > fromSeq, in
+// sequence order. fromSeq 0 returns them all.
+func (s *EventStore) ReadEvents(relation, key string, fromSeq int64) []Event {
+	events := s.log.Events(keyStream(relation, key))
+	for i, e := range events {
+		if e.Seq > fromSeq {
+			return events[i:]
+		}
+	}
+	return nil
+}
+
+// RebuildState reduces every event of relation/key, oldest first, into an
+// empty state, stores the result in the inner storage at relation/key and
+// returns it.
+func (s *EventStore) RebuildState(relation, key string, reducer Reducer) map[string]any {
+	state := map[string]any{}
+	for _, e := range s.ReadEvents(relation, key, 0) {
+		state = reducer(state, e.Data)
+	}
+	s.Storage.Put(relation, key, state)
+	return state
+}
@@ -0,0 +1,62 @@
+package clef
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEventStore(t *testing.T) {
+	store := NewEventSourcedStorage(NewInMemoryStorage())
+	store.AppendEvent("accounts", "a1", map[string]any{"owner": "ada", "balance": 0})
+	store.AppendEvent("accounts", "a1", map[string]any{"balance": 100})
+	store.AppendEvent("accounts", "a1", map[string]any{"frozen": true})
+	store.AppendEvent("accounts", "a2", map[string]any{"owner": "alan"})
+
+	events := store.ReadEvents("accounts", "a1", 0)
+	if len(events) != 3 {
+		t.Fatalf("got %d events, want 3", len(events))
+	}
+	for i, e := range events {
+		if e.Seq != int64(i+1) || e.Timestamp.IsZero() {
+			t.Errorf("event %d = %+v", i, e)
+		}
+	}
+	if tail := store.ReadEvents("accounts", "a1", 2); len(tail) != 1 || tail[0].Seq != 3 {
+		t.Errorf("ReadEvents from 2 = %+v, want only seq 3", tail)
+	}
+	if none := store.ReadEvents("accounts", "missing", 0); len(none) != 0 {
+		t.Errorf("unknown key has events: %+v", none)
+	}
+
+	merge := func(state, event map[string]any) map[string]any {
+		for k, v := range event {
+			state[k] = v
+		}
+		return state
+	}
+	want := map[string]any{"owner": "ada", "balance": 100, "frozen": true}
+	if got := store.RebuildState("accounts", "a1", merge); !reflect.DeepEqual(got, want) {
+		t.Errorf("RebuildState = %v, want %v", got, want)
+	}
+	if got, _ := store.Get("accounts", "a1"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Get after rebuild = %v, want %v", got, want)
+	}
+}
+
+func TestEventStorePersistsEvents(t *testing.T) {
+	inner := NewInMemoryStorage()
+	store := NewEventSourcedStorage(inner)
+	store.AppendEvent("accounts", "a1", map[string]any{"owner": "ada"})
+	store.AppendEvent("accounts", "a1", map[string]any{"balance": 100})
+
+	// A new store over the same storage, as after a restart.
+	restarted := NewEventSourcedStorage(inner)
+	events := restarted.ReadEvents("accounts", "a1", 0)
+	if len(events) != 2 || events[1].Seq != 2 || events[1].Data["balance"] != 100 {
+		t.Fatalf("events after restart = %+v", events)
+	}
+	restarted.AppendEvent("accounts", "a1", map[string]any{"frozen": true})
+	if events := restarted.ReadEvents("accounts", "a1", 2); len(events) != 1 || events[0].Seq != 3 {
+		t.Errorf("appended after restart = %+v, want seq 3", events)
+	}
+}
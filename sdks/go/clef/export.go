@@ -0,0 +1,65 @@
+package clef
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// Export reads every entry of relations from storage as
+// {relation: {key: value}}, e.g. to move data to another backend with
+// Import. A nil relations exports every relation. storage must implement
+// Enumerable.
+//
+// Example:
+//
+//	data, err := clef.Export(memory, nil)
+//	if err != nil { ... }
+//	n, err := clef.Import(redis, data)
+func Export(storage Storage, relations []string) (map[string]map[string]map[string]any, error) {
+	enum, ok := storage.(Enumerable)
+	if !ok {
+		return nil, fmt.Errorf("clef: export: storage does not support enumeration")
+	}
+	if relations == nil {
+		relations = enum.Relations()
+	}
+	data := make(map[string]map[string]map[string]any, len(relations))
+	for _, relation := range relations {
+		entries := make(map[string]map[string]any)
+		for _, key := range enum.Keys(relation) {
+			if value, ok := storage.Get(relation, key); ok {
+				entries[key] = value
+			}
+		}
+		data[relation] = entries
+	}
+	return data, nil
+}
+
+// Import writes data, as returned by Export, to storage with one BulkPut
+// per relation, in relation order, and returns how many entries were
+// written. Existing entries with the same keys are overwritten. If
+// storage reports an error for a batch (see ReportStorageError), such as
+// a QuotaStorage rejecting it, Import stops and returns that error with
+// the count of the batches before it.
+func Import(storage Storage, data map[string]map[string]map[string]any) (int, error) {
+	ctx, errs := withStorageErrors(context.Background())
+	storage = bindStorage(ctx, storage)
+	imported := 0
+	relations := make([]string, 0, len(data))
+	for relation := range data {
+		relations = append(relations, relation)
+	}
+	slices.Sort(relations)
+	for _, relation := range relations {
+		if len(data[relation]) > 0 {
+			n := storage.BulkPut(relation, data[relation])
+			if err := errs.since(0); err != nil {
+				return imported, fmt.Errorf("clef: import: %w", err)
+			}
+			imported += n
+		}
+	}
+	return imported, nil
+}
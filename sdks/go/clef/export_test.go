@@ -0,0 +1,60 @@
+package clef
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestExportImport(t *testing.T) {
+	src := NewInMemoryStorage()
+	src.Put("articles", "a1", map[string]any{"title": "Hello", "tags": []string{"go"}, "views": 3})
+	src.Put("articles", "a2", map[string]any{"title": "World", "draft": true})
+	src.Put("users", "u1", map[string]any{"name": "ada", "profile": map[string]any{"bio": "math"}})
+
+	data, err := Export(src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := NewInMemoryStorage()
+	n, err := Import(dst, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("imported %d entries, want 3", n)
+	}
+	for _, relation := range src.Relations() {
+		for _, key := range src.Keys(relation) {
+			want, _ := src.Get(relation, key)
+			got, ok := dst.Get(relation, key)
+			if !ok || !reflect.DeepEqual(got, want) {
+				t.Errorf("%s/%s = %v, %v; want %v", relation, key, got, ok, want)
+			}
+		}
+	}
+
+	only, err := Export(src, []string{"users"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(only) != 1 || len(only["users"]) != 1 {
+		t.Errorf("Export(users) = %v", only)
+	}
+}
+
+func TestExportRequiresEnumerable(t *testing.T) {
+	if _, err := Export(struct{ Storage }{NewInMemoryStorage()}, nil); err == nil {
+		t.Fatal("expected error for non-enumerable storage")
+	}
+}
+
+func TestImportQuotaExceeded(t *testing.T) {
+	data := map[string]map[string]map[string]any{
+		"articles": {"a1": {"body": "a long enough body to exceed the quota"}},
+	}
+	_, err := Import(NewQuotaStorage(NewInMemoryStorage(), 8), data)
+	if !errors.Is(err, ErrStorageQuotaExceeded) {
+		t.Fatalf("err = %v, want ErrStorageQuotaExceeded", err)
+	}
+}
@@ -0,0 +1,140 @@
+package clef
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+)
+
+// HandlerFactory builds a handler from its configuration, such as the
+// "config" section of a ConceptConfig.
+type HandlerFactory func(config map[string]any) (ConceptHandler, error)
+
+var (
+	handlerTypesMu sync.RWMutex
+	// handlerTypes maps handler type names to their factories.
+	handlerTypes = make(map[string]HandlerFactory)
+)
+
+func init() {
+	handlerTypes["echo"] = newEchoHandler
+	handlerTypes["static"] = newStaticHandler
+	handlerTypes["router"] = newRouterHandler
+}
+
+// RegisterHandlerFactory makes a handler type available by name to
+// CreateHandler and Config, replacing any factory registered under it.
+// The built-in types are:
+//
+//	echo                    returns the input, with variant "ok" unless it has one
+//	static  output          returns a copy of output for every action
+//	router  routes, default routes each action to the handler built from
+//	                        routes[action], or from default
+//
+// A router's handlers are given as {"type": <name>, "config": {...}}:
+//
+//	handler: router
+//	config:
+//	  routes:
+//	    ping: {type: static, config: {output: {variant: ok, reply: pong}}}
+//	  default: {type: echo}
+func RegisterHandlerFactory(typeName string, factory HandlerFactory) {
+	handlerTypesMu.Lock()
+	defer handlerTypesMu.Unlock()
+	handlerTypes[typeName] = factory
+}
+
+// CreateHandler builds a handler of the type registered as typeName.
+func CreateHandler(typeName string, config map[string]any) (ConceptHandler, error) {
+	handlerTypesMu.RLock()
+	factory, ok := handlerTypes[typeName]
+	handlerTypesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown handler type %q", typeName)
+	}
+	return factory(config)
+}
+
+func newEchoHandler(map[string]any) (ConceptHandler, error) {
+	return HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		out := maps.Clone(input)
+		if out == nil {
+			out = map[string]any{}
+		}
+		if _, ok := out["variant"]; !ok {
+			out["variant"] = "ok"
+		}
+		return out
+	}), nil
+}
+
+func newStaticHandler(config map[string]any) (ConceptHandler, error) {
+	output, ok := config["output"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("output must be a map")
+	}
+	return HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		return maps.Clone(output)
+	}), nil
+}
+
+// routerHandler routes each action to the handler registered for it by
+// name.
+type routerHandler struct {
+	routes   map[string]ConceptHandler
+	fallback ConceptHandler
+}
+
+func newRouterHandler(config map[string]any) (ConceptHandler, error) {
+	raw, ok := config["routes"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("routes must be a map of action to handler")
+	}
+	r := &routerHandler{routes: make(map[string]ConceptHandler, len(raw))}
+	for action, spec := range raw {
+		h, err := createSubHandler(spec)
+		if err != nil {
+			return nil, fmt.Errorf("route %s: %w", action, err)
+		}
+		r.routes[action] = h
+	}
+	if spec, ok := config["default"]; ok {
+		h, err := createSubHandler(spec)
+		if err != nil {
+			return nil, fmt.Errorf("default: %w", err)
+		}
+		r.fallback = h
+	}
+	return r, nil
+}
+
+// createSubHandler builds a handler from {"type": ..., "config": ...}.
+func createSubHandler(spec any) (ConceptHandler, error) {
+	m, ok := spec.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("handler must be a map with a type")
+	}
+	typeName, err := paramString(m, "type", "")
+	if err != nil {
+		return nil, err
+	}
+	config, _ := m["config"].(map[string]any)
+	return CreateHandler(typeName, config)
+}
+
+func (r *routerHandler) Handle(action string, input map[string]any, storage Storage) map[string]any {
+	return r.HandleContext(context.Background(), action, input, storage)
+}
+
+// HandleContext passes ctx on to context-aware sub-handlers.
+func (r *routerHandler) HandleContext(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+	h, ok := r.routes[action]
+	if !ok {
+		h = r.fallback
+	}
+	if h == nil {
+		return map[string]any{"variant": "error", "message": "unknown action: " + action}
+	}
+	return callHandler(ctx, h, action, input, storage)
+}
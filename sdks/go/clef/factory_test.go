@@ -0,0 +1,68 @@
+package clef
+
+import (
+	"testing"
+)
+
+func TestCreateHandlerEcho(t *testing.T) {
+	h, err := CreateHandler("echo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := h.Handle("test", map[string]any{"x": 1}, NewInMemoryStorage())
+	if out["x"] != 1 || out["variant"] != "ok" {
+		t.Fatalf("output = %v, want x=1", out)
+	}
+}
+
+func TestCreateHandlerStaticAndRouter(t *testing.T) {
+	h, err := CreateHandler("router", map[string]any{
+		"routes": map[string]any{
+			"ping": map[string]any{"type": "static", "config": map[string]any{"output": map[string]any{"variant": "ok", "reply": "pong"}}},
+		},
+		"default": map[string]any{"type": "echo"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage := NewInMemoryStorage()
+	if out := h.Handle("ping", map[string]any{"x": 1}, storage); out["reply"] != "pong" || out["x"] != nil {
+		t.Errorf("ping = %v", out)
+	}
+	if out := h.Handle("other", map[string]any{"x": 1}, storage); out["x"] != 1 {
+		t.Errorf("default route = %v", out)
+	}
+
+	noDefault, err := CreateHandler("router", map[string]any{"routes": map[string]any{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := noDefault.Handle("other", nil, storage); out["variant"] != "error" {
+		t.Errorf("unrouted action = %v", out)
+	}
+}
+
+func TestCreateHandlerErrors(t *testing.T) {
+	if _, err := CreateHandler("nope", nil); err == nil {
+		t.Error("expected error for unknown type")
+	}
+	if _, err := CreateHandler("static", map[string]any{}); err == nil {
+		t.Error("expected error for static without output")
+	}
+	if _, err := CreateHandler("router", map[string]any{"routes": map[string]any{"a": map[string]any{"type": "nope"}}}); err == nil {
+		t.Error("expected error for route of unknown type")
+	}
+}
+
+func TestRegisterHandlerFactory(t *testing.T) {
+	RegisterHandlerFactory("test-constant", func(config map[string]any) (ConceptHandler, error) {
+		return CreateHandler("static", map[string]any{"output": map[string]any{"variant": "ok", "n": config["n"]}})
+	})
+	h, err := CreateHandler("test-constant", map[string]any{"n": 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := h.Handle("any", nil, nil); out["n"] != 7 {
+		t.Fatalf("output = %v", out)
+	}
+}
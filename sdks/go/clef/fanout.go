@@ -0,0 +1,83 @@
+package clef
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// LocalCall names one invocation of a FanOut.
+type LocalCall struct {
+	URI    string
+	Action string
+	Input  map[string]any
+}
+
+// FanOutError reports which calls of a FanOut completed with the "error"
+// variant.
+type FanOutError struct {
+	// Failed holds the indices of the failed calls, in order.
+	Failed []int
+}
+
+func (e *FanOutError) Error() string {
+	return fmt.Sprintf("clef: %d fan-out call(s) failed: %v", len(e.Failed), e.Failed)
+}
+
+// FanOut runs calls concurrently with InvokeLocal, in the flow of the
+// invocation in ctx, and returns their outputs in the order of calls. A
+// call that fails still has its error output at its index, and the
+// returned *FanOutError lists every such index.
+//
+// Example:
+//
+//	results, err := clef.FanOut(ctx, []clef.LocalCall{
+//	    {URI: "urn:app/Article", Action: "list", Input: q},
+//	    {URI: "urn:app/Comment", Action: "list", Input: q},
+//	})
+//	return clef.MergeResults(results, "items")
+func FanOut(ctx context.Context, calls []LocalCall) ([]map[string]any, error) {
+	results := make([]map[string]any, len(calls))
+	var wg sync.WaitGroup
+	for i, call := range calls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := InvokeLocal(ctx, ActionInvocation{Concept: call.URI, Action: call.Action, Input: call.Input})
+			results[i] = c.Output
+			if results[i] == nil {
+				results[i] = map[string]any{"variant": c.Variant}
+			}
+		}()
+	}
+	wg.Wait()
+
+	var failed []int
+	for i, r := range results {
+		if r["variant"] == "error" {
+			failed = append(failed, i)
+		}
+	}
+	if failed != nil {
+		return results, &FanOutError{Failed: failed}
+	}
+	return results, nil
+}
+
+// MergeResults concatenates the slices stored under mergeKey in results,
+// in order, into one "ok" output. Results without a slice under mergeKey,
+// such as failed calls, are skipped.
+func MergeResults(results []map[string]any, mergeKey string) map[string]any {
+	merged := []any{}
+	for _, r := range results {
+		v := reflect.ValueOf(r[mergeKey])
+		if v.Kind() != reflect.Slice {
+			continue
+		}
+		for i := 0; i < v.Len(); i++ {
+			merged = append(merged, v.Index(i).Interface())
+		}
+	}
+	return map[string]any{"variant": "ok", mergeKey: merged}
+}
@@ -0,0 +1,46 @@
+package clef
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFanOut(t *testing.T) {
+	resetRegistry()
+	list := func(items ...any) ConceptHandler {
+		return HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+			return map[string]any{"variant": "ok", "items": items}
+		})
+	}
+	Register("urn:test/Articles", list("a1", "a2"), nil)
+	Register("urn:test/Broken", HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		return map[string]any{"variant": "error", "message": "backend down"}
+	}), nil)
+	Register("urn:test/Comments", list("c1"), nil)
+
+	results, err := FanOut(context.Background(), []LocalCall{
+		{URI: "urn:test/Articles", Action: "list"},
+		{URI: "urn:test/Broken", Action: "list"},
+		{URI: "urn:test/Comments", Action: "list"},
+	})
+	var fe *FanOutError
+	if !errors.As(err, &fe) || !reflect.DeepEqual(fe.Failed, []int{1}) {
+		t.Fatalf("err = %v, want call 1 failed", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	if results[0]["variant"] != "ok" || results[2]["variant"] != "ok" {
+		t.Errorf("successful calls not populated: %v", results)
+	}
+	if results[1]["variant"] != "error" || results[1]["message"] != "backend down" {
+		t.Errorf("failed call result = %v", results[1])
+	}
+
+	merged := MergeResults(results, "items")
+	if want := []any{"a1", "a2", "c1"}; !reflect.DeepEqual(merged["items"], want) {
+		t.Errorf("merged items = %v, want %v", merged["items"], want)
+	}
+}
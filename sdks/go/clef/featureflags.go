@@ -0,0 +1,85 @@
+package clef
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// FeatureFlagSource decides whether an action may run. Implementations
+// are consulted on every invocation, so they can change at run time.
+type FeatureFlagSource interface {
+	IsEnabled(concept, action string) bool
+}
+
+// FeatureFlagMiddleware rejects invocations of actions that source
+// reports as disabled with a "disabled" error, without calling the
+// handler. The concept comes from the invocation in the context; it is
+// empty when the handler is called outside the transport.
+//
+// Example:
+//
+//	flags := clef.EnvFeatureFlags("COPF_FLAG")
+//	clef.MustRegister("urn:app/Article", clef.Chain(&ArticleHandler{}, clef.FeatureFlagMiddleware(flags)), nil)
+func FeatureFlagMiddleware(source FeatureFlagSource) MiddlewareFunc {
+	return func(next ConceptHandler) ConceptHandler {
+		return HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+			inv, _ := InvocationFromContext(ctx)
+			if !source.IsEnabled(inv.Concept, action) {
+				return map[string]any{"variant": "error", "code": "disabled", "message": "action disabled by feature flag"}
+			}
+			return callHandler(ctx, next, action, input, storage)
+		})
+	}
+}
+
+type staticFeatureFlags map[string]bool
+
+// StaticFeatureFlags returns a source backed by a fixed map. Keys are
+// concept + "/" + action, e.g. "urn:app/Article/delete", or a concept URI
+// alone to cover all its actions; the more specific key wins. Actions
+// with no key are enabled. The map is copied.
+func StaticFeatureFlags(enabled map[string]bool) FeatureFlagSource {
+	flags := make(staticFeatureFlags, len(enabled))
+	for k, v := range enabled {
+		flags[k] = v
+	}
+	return flags
+}
+
+func (f staticFeatureFlags) IsEnabled(concept, action string) bool {
+	if on, ok := f[concept+"/"+action]; ok {
+		return on
+	}
+	if on, ok := f[concept]; ok {
+		return on
+	}
+	return true
+}
+
+type envFeatureFlags struct {
+	prefix string
+}
+
+// EnvFeatureFlags returns a source that reads environment variables on
+// every call, so flags can be flipped without a restart. The variable
+// for an action is PREFIX_CONCEPT_ACTION in upper case, with the concept
+// written as by ConceptSlug and every non-alphanumeric character
+// replaced by "_": with prefix "COPF_FLAG", urn:app/Article's "delete"
+// is COPF_FLAG_APP_ARTICLE_DELETE. A value that strconv.ParseBool reads
+// as false disables the action; anything else, or no variable, leaves it
+// enabled.
+func EnvFeatureFlags(prefix string) FeatureFlagSource {
+	return envFeatureFlags{prefix: strings.TrimSuffix(prefix, "_")}
+}
+
+func (f envFeatureFlags) IsEnabled(concept, action string) bool {
+	on, err := strconv.ParseBool(os.Getenv(f.variable(concept, action)))
+	return err != nil || on
+}
+
+func (f envFeatureFlags) variable(concept, action string) string {
+	name := f.prefix + "_" + ConceptSlug(concept) + "_" + ConceptSlug(action)
+	return strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
@@ -0,0 +1,50 @@
+package clef
+
+import "testing"
+
+func TestFeatureFlagMiddleware(t *testing.T) {
+	resetRegistry()
+	register := func(flags FeatureFlagSource) {
+		Register("urn:test/Echo", Chain(&echoHandler{}, FeatureFlagMiddleware(flags)), nil)
+	}
+	body := `{"concept":"urn:test/Echo","action":"echo","input":{"message":"hi"}}`
+
+	register(StaticFeatureFlags(map[string]bool{"urn:test/Echo/echo": false}))
+	c := invokeRecorder(t, body)
+	if c.Variant != "error" || c.Output["code"] != "disabled" || c.Output["message"] != "action disabled by feature flag" {
+		t.Fatalf("disabled: %+v", c)
+	}
+
+	register(StaticFeatureFlags(map[string]bool{"urn:test/Echo/echo": true}))
+	if c := invokeRecorder(t, body); c.Variant != "ok" || c.Output["message"] != "hi" {
+		t.Fatalf("re-enabled: %+v", c)
+	}
+}
+
+func TestStaticFeatureFlagsPrecedence(t *testing.T) {
+	flags := StaticFeatureFlags(map[string]bool{"urn:app/A": false, "urn:app/A/read": true})
+	if flags.IsEnabled("urn:app/A", "write") {
+		t.Error("concept-wide flag should disable write")
+	}
+	if !flags.IsEnabled("urn:app/A", "read") {
+		t.Error("action flag should override concept flag")
+	}
+	if !flags.IsEnabled("urn:app/B", "write") {
+		t.Error("unflagged actions should be enabled")
+	}
+}
+
+func TestEnvFeatureFlags(t *testing.T) {
+	flags := EnvFeatureFlags("COPF_FLAG")
+	if !flags.IsEnabled("urn:app/Article", "delete") {
+		t.Fatal("expected enabled with no variable")
+	}
+	t.Setenv("COPF_FLAG_APP_ARTICLE_DELETE", "false")
+	if flags.IsEnabled("urn:app/Article", "delete") {
+		t.Fatal("expected disabled")
+	}
+	t.Setenv("COPF_FLAG_APP_ARTICLE_DELETE", "true")
+	if !flags.IsEnabled("urn:app/Article", "delete") {
+		t.Fatal("expected re-enabled")
+	}
+}
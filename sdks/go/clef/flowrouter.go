@@ -0,0 +1,104 @@
+package clef
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+// FlowRouter maps a flow ID to a concept URI suffix, so every invocation
+// in a flow reaches the same handler instance (e.g. a tenant shard).
+// Route returns false when the flow has no preferred instance.
+type FlowRouter interface {
+	Route(flow string) (string, bool)
+}
+
+// FlowRouterFunc adapts an ordinary function to a FlowRouter.
+type FlowRouterFunc func(flow string) (string, bool)
+
+// Route calls f(flow).
+func (f FlowRouterFunc) Route(flow string) (string, bool) {
+	return f(flow)
+}
+
+// WithFlowRouter routes invocations by flow ID. The suffix returned by
+// router is appended to the invoked concept URI; if a concept is
+// registered under the result it handles the invocation, otherwise the
+// base URI does.
+//
+// Example:
+//
+//	clef.MustRegister("urn:app/Cart#a", cartA, nil)
+//	clef.MustRegister("urn:app/Cart#b", cartB, nil)
+//	clef.Serve(":8091", clef.WithFlowRouter(clef.ConsistentHashFlowRouter([]string{"#a", "#b"})))
+func WithFlowRouter(router FlowRouter) ServeOption {
+	return func(c *ServerConfig) {
+		c.flowRouter = router
+	}
+}
+
+// lookup returns the registry entry that should handle inv, applying the
+// flow router if configured. Its storage is wrapped by wrapStorage.
+func (s *server) lookup(inv ActionInvocation) (registryEntry, bool) {
+	if s.config.flowRouter != nil {
+		if suffix, ok := s.config.flowRouter.Route(inv.Flow); ok {
+			if entry, found := registry[inv.Concept+suffix]; found {
+				return s.wrapStorage(inv.Concept+suffix, entry), true
+			}
+		}
+	}
+	entry, ok := registry[inv.Concept]
+	if !ok {
+		return entry, false
+	}
+	return s.wrapStorage(inv.Concept, entry), true
+}
+
+// ringReplicas is the number of points each URI occupies on the hash
+// ring; more points spread flows more evenly.
+const ringReplicas = 128
+
+type ringPoint struct {
+	hash uint64
+	uri  string
+}
+
+// ConsistentHashFlowRouter routes each flow to one of uris (URI suffixes)
+// by consistent hashing. A flow always maps to the same suffix, and
+// adding or removing a suffix only moves the flows that hashed to it.
+// With no uris every flow falls back to the base URI.
+func ConsistentHashFlowRouter(uris []string) FlowRouter {
+	ring := make([]ringPoint, 0, len(uris)*ringReplicas)
+	for _, uri := range uris {
+		for i := 0; i < ringReplicas; i++ {
+			ring = append(ring, ringPoint{hash: hash64(uri + "#" + strconv.Itoa(i)), uri: uri})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool { return ring[i].hash < ring[j].hash })
+
+	return FlowRouterFunc(func(flow string) (string, bool) {
+		if len(ring) == 0 {
+			return "", false
+		}
+		h := hash64(flow)
+		i := sort.Search(len(ring), func(i int) bool { return ring[i].hash >= h })
+		if i == len(ring) {
+			i = 0
+		}
+		return ring[i].uri, true
+	})
+}
+
+// hash64 is FNV-1a followed by a 64-bit finalizer; FNV alone clusters
+// near-identical keys such as "flow-1", "flow-2" on the ring.
+func hash64(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
@@ -0,0 +1,88 @@
+package clef
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// shardHandler reports which shard handled the invocation.
+type shardHandler struct {
+	name string
+}
+
+func (h shardHandler) Handle(action string, input map[string]any, storage Storage) map[string]any {
+	return map[string]any{"variant": "ok", "shard": h.name}
+}
+
+func TestConsistentHashFlowRouterIsStable(t *testing.T) {
+	uris := []string{"#a", "#b", "#c"}
+	router := ConsistentHashFlowRouter(uris)
+
+	first, ok := router.Route("flow-42")
+	if !ok {
+		t.Fatal("expected a route")
+	}
+	for i := 0; i < 10000; i++ {
+		if got, _ := router.Route("flow-42"); got != first {
+			t.Fatalf("call %d routed to %s, expected %s", i, got, first)
+		}
+	}
+
+	// A fresh router over the same URIs agrees.
+	if got, _ := ConsistentHashFlowRouter(uris).Route("flow-42"); got != first {
+		t.Errorf("expected rebuilt router to route to %s, got %s", first, got)
+	}
+}
+
+func TestConsistentHashFlowRouterSpreadsFlows(t *testing.T) {
+	router := ConsistentHashFlowRouter([]string{"#a", "#b", "#c"})
+	counts := map[string]int{}
+	for i := 0; i < 3000; i++ {
+		uri, _ := router.Route(fmt.Sprintf("flow-%d", i))
+		counts[uri]++
+	}
+	for _, uri := range []string{"#a", "#b", "#c"} {
+		if counts[uri] < 500 {
+			t.Errorf("expected %s to receive a fair share, got %v", uri, counts)
+		}
+	}
+}
+
+func TestConsistentHashFlowRouterEmpty(t *testing.T) {
+	if _, ok := ConsistentHashFlowRouter(nil).Route("flow"); ok {
+		t.Error("expected no route without URIs")
+	}
+}
+
+func TestWithFlowRouterPicksVariant(t *testing.T) {
+	resetRegistry()
+	Register("urn:test/Cart", shardHandler{"base"}, nil)
+	Register("urn:test/Cart#a", shardHandler{"a"}, nil)
+	router := FlowRouterFunc(func(flow string) (string, bool) {
+		switch flow {
+		case "to-a":
+			return "#a", true
+		case "to-missing":
+			return "#z", true
+		}
+		return "", false
+	})
+	h := NewHandler(WithFlowRouter(router))
+
+	cases := map[string]string{"to-a": "a", "to-missing": "base", "other": "base"}
+	for flow, want := range cases {
+		rec := doRequest(h, http.MethodPost, "/invoke", `{"concept":"urn:test/Cart","action":"add","flow":"`+flow+`"}`)
+		var c ActionCompletion
+		if err := json.Unmarshal(rec.Body.Bytes(), &c); err != nil {
+			t.Fatal(err)
+		}
+		if c.Output["shard"] != want {
+			t.Errorf("flow %s: expected shard %s, got %v", flow, want, c.Output["shard"])
+		}
+		if c.Concept != "urn:test/Cart" {
+			t.Errorf("flow %s: completion should keep the invoked URI, got %s", flow, c.Concept)
+		}
+	}
+}
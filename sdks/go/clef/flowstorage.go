@@ -0,0 +1,254 @@
+package clef
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// DefaultFlowStorageTTL is how long FlowStorage keeps an entry after it
+// is written.
+const DefaultFlowStorageTTL = time.Hour
+
+// flowExpiresKey holds an entry's expiry, in Unix nanoseconds, inside the
+// value stored in the inner storage. It is stripped on read.
+const flowExpiresKey = "_flowExpiresAt"
+
+// flowStorage scopes relations to one flow and expires entries.
+type flowStorage struct {
+	prefix string
+	inner  Storage
+	ttl    time.Duration
+	clock  Clock
+	// pinned keeps clock when bound to a context, for the server's flow
+	// storage, whose sweep reads the same clock.
+	pinned bool
+}
+
+// FlowStorage returns storage for state shared by the steps of one flow,
+// such as a saga passing data between concepts. Every relation is stored
+// in inner as flowID + "/flow/" + relation, and entries expire
+// DefaultFlowStorageTTL after they are written.
+func FlowStorage(flowID string, inner Storage) Storage {
+	return FlowStorageWithTTL(flowID, inner, DefaultFlowStorageTTL)
+}
+
+// FlowStorageWithTTL is FlowStorage with entries expiring ttl after they
+// are written. Expired entries are invisible, and are removed from inner
+// when next read by key. Time is read from the clock of the context the
+// storage is bound to, see ClockMiddleware.
+func FlowStorageWithTTL(flowID string, inner Storage, ttl time.Duration) Storage {
+	return &flowStorage{prefix: flowID + "/flow/", inner: inner, ttl: ttl, clock: realClock{}}
+}
+
+// stamp returns a copy of value carrying its expiry.
+func (s *flowStorage) stamp(value map[string]any) map[string]any {
+	out := make(map[string]any, len(value)+1)
+	for k, v := range value {
+		out[k] = v
+	}
+	out[flowExpiresKey] = float64(s.clock.Now().Add(s.ttl).UnixNano())
+	return out
+}
+
+// unstamp returns value without its expiry, and whether it is still live
+// at now.
+func unstamp(value map[string]any, now time.Time) (map[string]any, bool) {
+	if value == nil {
+		return nil, true
+	}
+	expires, _ := value[flowExpiresKey].(float64)
+	out := make(map[string]any, len(value))
+	for k, v := range value {
+		if k != flowExpiresKey {
+			out[k] = v
+		}
+	}
+	return out, expires == 0 || now.UnixNano() < int64(expires)
+}
+
+// live drops expired records and strips the expiry from the rest.
+func (s *flowStorage) live(records []map[string]any) []map[string]any {
+	now := s.clock.Now()
+	out := records[:0:0]
+	for _, r := range records {
+		if v, ok := unstamp(r, now); ok {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func (s *flowStorage) Get(relation, key string) (map[string]any, bool) {
+	value, ok := s.inner.Get(s.prefix+relation, key)
+	if !ok {
+		return nil, false
+	}
+	value, fresh := unstamp(value, s.clock.Now())
+	if !fresh {
+		s.inner.Delete(s.prefix+relation, key)
+		return nil, false
+	}
+	return value, true
+}
+
+func (s *flowStorage) Put(relation, key string, value map[string]any) {
+	s.inner.Put(s.prefix+relation, key, s.stamp(value))
+}
+
+func (s *flowStorage) Delete(relation, key string) bool {
+	_, ok := s.Get(relation, key)
+	return s.inner.Delete(s.prefix+relation, key) && ok
+}
+
+func (s *flowStorage) Find(relation string, args map[string]any) []map[string]any {
+	return s.live(s.inner.Find(s.prefix+relation, args))
+}
+
+func (s *flowStorage) FindSorted(relation string, args map[string]any, sortField string, ascending bool) []map[string]any {
+	return s.live(s.inner.FindSorted(s.prefix+relation, args, sortField, ascending))
+}
+
+func (s *flowStorage) Range(relation, startKey, endKey string, inclusive bool) []map[string]any {
+	return s.live(s.inner.Range(s.prefix+relation, startKey, endKey, inclusive))
+}
+
+func (s *flowStorage) BulkPut(relation string, entries map[string]map[string]any) int {
+	stamped := make(map[string]map[string]any, len(entries))
+	for key, value := range entries {
+		stamped[key] = s.stamp(value)
+	}
+	return s.inner.BulkPut(s.prefix+relation, stamped)
+}
+
+func (s *flowStorage) BulkDelete(relation string, keys []string) int {
+	n := 0
+	for _, key := range keys {
+		if _, ok := s.Get(relation, key); ok {
+			n++
+		}
+	}
+	s.inner.BulkDelete(s.prefix+relation, keys)
+	return n
+}
+
+// CompareAndSwap treats an expired entry as missing.
+func (s *flowStorage) CompareAndSwap(relation, key string, expected, replacement map[string]any, compareFields []string) (bool, map[string]any) {
+	s.Get(relation, key)
+	swapped, current := s.inner.CompareAndSwap(s.prefix+relation, key, expected, s.stamp(replacement), compareFields)
+	current, _ = unstamp(current, s.clock.Now())
+	return swapped, current
+}
+
+// Relations implements Enumerable when the inner storage does, listing
+// the flow's relations.
+func (s *flowStorage) Relations() []string {
+	enum, ok := s.inner.(Enumerable)
+	if !ok {
+		return nil
+	}
+	var names []string
+	for _, name := range enum.Relations() {
+		if rel, ok := strings.CutPrefix(name, s.prefix); ok {
+			names = append(names, rel)
+		}
+	}
+	return names
+}
+
+// Keys implements Enumerable when the inner storage does. Expired
+// entries not yet removed are listed, but read as missing.
+func (s *flowStorage) Keys(relation string) []string {
+	enum, ok := s.inner.(Enumerable)
+	if !ok {
+		return nil
+	}
+	return enum.Keys(s.prefix + relation)
+}
+
+// sweepExpired deletes flow entries expired at now from store under its
+// write lock, so an entry rewritten concurrently is never lost.
+func sweepExpired(store *InMemoryStorage, now time.Time) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	for relation, rel := range store.relations {
+		for key, e := range rel {
+			if _, fresh := unstamp(e.Value, now); !fresh {
+				delete(rel, key)
+				store.removeKey(relation, key)
+				store.notify(relation, key, WatchEvent{Key: key, Event: "delete"})
+			}
+		}
+	}
+}
+
+// WithContext implements ContextualStorage by binding the inner storage
+// and, unless the clock is pinned, taking the context's clock.
+func (s *flowStorage) WithContext(ctx context.Context) Storage {
+	clock := s.clock
+	if !s.pinned {
+		clock = ClockFromContext(ctx)
+	}
+	return &flowStorage{prefix: s.prefix, inner: bindStorage(ctx, s.inner), ttl: s.ttl, clock: clock, pinned: s.pinned}
+}
+
+// WithFlowStorage enables flow-local storage: each invocation can reach a
+// FlowStorage for its flow ID through FlowStorageFromContext. All
+// concepts share one in-memory store, so any step of a flow can read what
+// an earlier step wrote. Entries expire ttl after they are written; zero
+// means DefaultFlowStorageTTL. Expiry, and the sweep removing expired
+// entries from the store, follow the server's clock (see WithClock).
+func WithFlowStorage(ttl time.Duration) ServeOption {
+	return func(c *ServerConfig) {
+		if ttl <= 0 {
+			ttl = DefaultFlowStorageTTL
+		}
+		c.flowStorageTTL = ttl
+	}
+}
+
+type flowStorageKey struct{}
+
+// flowStorageScope is what dispatch attaches to the invocation context.
+type flowStorageScope struct {
+	flow Storage // nil when flow storage is disabled
+	main Storage
+}
+
+// withFlowStorage attaches the storages FlowStorageFromContext returns.
+// At most once per TTL of the server's clock it also sweeps expired
+// entries from the shared store in the background.
+func (s *server) withFlowStorage(ctx context.Context, inv ActionInvocation, entry registryEntry) context.Context {
+	scope := flowStorageScope{main: entry.storage}
+	if s.flowStore != nil {
+		clock := s.clock()
+		scope.flow = &flowStorage{prefix: inv.Flow + "/flow/", inner: s.flowStore, ttl: s.config.flowStorageTTL, clock: clock, pinned: true}
+		now := clock.Now()
+		last := s.flowSweptAt.Load()
+		if now.UnixNano()-last >= int64(s.config.flowStorageTTL) && s.flowSweptAt.CompareAndSwap(last, now.UnixNano()) {
+			go sweepExpired(s.flowStore, now)
+		}
+	}
+	return context.WithValue(ctx, flowStorageKey{}, scope)
+}
+
+// FlowStorageFromContext returns the current flow's FlowStorage when the
+// server runs WithFlowStorage, otherwise the concept's own storage. It
+// returns nil outside an invocation.
+//
+// Example:
+//
+//	// Step 1 (urn:app/Cart)
+//	clef.FlowStorageFromContext(ctx).Put("checkout", "cart", cart)
+//	// Step 2 (urn:app/Payment), same flow
+//	cart, _ := clef.FlowStorageFromContext(ctx).Get("checkout", "cart")
+func FlowStorageFromContext(ctx context.Context) Storage {
+	scope, ok := ctx.Value(flowStorageKey{}).(flowStorageScope)
+	if !ok {
+		return nil
+	}
+	if scope.flow != nil {
+		return bindStorage(ctx, scope.flow)
+	}
+	return bindStorage(ctx, scope.main)
+}
@@ -0,0 +1,103 @@
+package clef
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func registerFlowSteps() {
+	Register("urn:test/Cart", HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		FlowStorageFromContext(ctx).Put("checkout", "cart", map[string]any{"total": input["total"]})
+		return map[string]any{"variant": "ok"}
+	}), nil)
+	Register("urn:test/Payment", HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		cart, ok := FlowStorageFromContext(ctx).Get("checkout", "cart")
+		if !ok {
+			return map[string]any{"variant": "notfound"}
+		}
+		return map[string]any{"variant": "ok", "total": cart["total"]}
+	}), nil)
+}
+
+func TestFlowStorageSharedWithinFlow(t *testing.T) {
+	resetRegistry()
+	registerFlowSteps()
+	h := NewHandler(WithFlowStorage(time.Minute))
+
+	invoke := func(body string) ActionCompletion {
+		var c ActionCompletion
+		if err := json.Unmarshal(doRequest(h, "POST", "/invoke", body).Body.Bytes(), &c); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	invoke(`{"concept":"urn:test/Cart","action":"add","flow":"f1","input":{"total":42}}`)
+	if got := invoke(`{"concept":"urn:test/Payment","action":"charge","flow":"f1"}`); got.Variant != "ok" || got.Output["total"] != float64(42) {
+		t.Fatalf("same flow: %+v", got)
+	}
+	if got := invoke(`{"concept":"urn:test/Payment","action":"charge","flow":"f2"}`); got.Variant != "notfound" {
+		t.Fatalf("other flow: %+v", got)
+	}
+}
+
+func TestFlowStorageFallsBackToConceptStorage(t *testing.T) {
+	resetRegistry()
+	main := NewInMemoryStorage()
+	var got Storage
+	Register("urn:test/Step", HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		got = FlowStorageFromContext(ctx)
+		return map[string]any{"variant": "ok"}
+	}), main)
+	doRequest(NewHandler(), "POST", "/invoke", `{"concept":"urn:test/Step","action":"run"}`)
+	if got != main {
+		t.Fatalf("FlowStorageFromContext = %T, want the concept's storage", got)
+	}
+	if FlowStorageFromContext(context.Background()) != nil {
+		t.Fatal("expected nil outside an invocation")
+	}
+}
+
+func TestFlowStorageExpiresAndPrefixes(t *testing.T) {
+	inner := NewInMemoryStorage()
+	fs := FlowStorageWithTTL("f1", inner, 20*time.Millisecond)
+	fs.Put("state", "k", map[string]any{"v": 1})
+
+	if _, ok := inner.Get("f1/flow/state", "k"); !ok {
+		t.Fatal("expected entry under the flow prefix")
+	}
+	if v, ok := fs.Get("state", "k"); !ok || v["v"] != 1 || v[flowExpiresKey] != nil {
+		t.Fatalf("Get = %v, %v", v, ok)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := fs.Get("state", "k"); ok {
+		t.Fatal("expected entry to expire")
+	}
+	if len(fs.Find("state", nil)) != 0 {
+		t.Fatal("expected Find to skip expired entries")
+	}
+
+	fs.Put("state", "k2", map[string]any{"v": 2})
+	time.Sleep(30 * time.Millisecond)
+	sweepExpired(inner, time.Now())
+	if _, ok := inner.Get("f1/flow/state", "k2"); ok {
+		t.Fatal("expected sweep to remove expired entry")
+	}
+}
+
+func TestFlowStorageExportsOnlyItsFlow(t *testing.T) {
+	inner := NewInMemoryStorage()
+	FlowStorage("f1", inner).Put("state", "k", map[string]any{"v": 1})
+	FlowStorage("f2", inner).Put("state", "other", map[string]any{"v": 2})
+	inner.Put("unrelated", "x", map[string]any{"v": 3})
+
+	data, err := Export(FlowStorage("f1", inner), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 1 || len(data["state"]) != 1 || data["state"]["k"]["v"] != 1 || data["state"]["k"][flowExpiresKey] != nil {
+		t.Errorf("Export = %v, want only f1's state/k without its expiry", data)
+	}
+}
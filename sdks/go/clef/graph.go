@@ -0,0 +1,128 @@
+package clef
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+)
+
+// dependencies maps a concept URI to the URIs it depends on.
+var (
+	dependenciesMu sync.Mutex
+	dependencies   = make(map[string][]string)
+)
+
+// DependsOn records that the concept at uri needs deps warmed up before
+// its own WarmUp runs. Concepts in the graph are warmed by WarmAll rather
+// than by Register, so call DependsOn before registering them.
+// Dependencies whose handlers are not Warmers count as warm.
+//
+// Example:
+//
+//	clef.DependsOn("urn:app/Search", "urn:app/Article", "urn:app/User")
+//	clef.MustRegister("urn:app/Article", &ArticleHandler{}, nil)
+//	clef.MustRegister("urn:app/User", &UserHandler{}, nil)
+//	clef.MustRegister("urn:app/Search", &SearchHandler{}, nil)
+//	if err := clef.WarmAll(ctx); err != nil {
+//	    log.Fatal(err)
+//	}
+func DependsOn(uri string, deps ...string) {
+	dependenciesMu.Lock()
+	defer dependenciesMu.Unlock()
+	current := dependencies[uri]
+	for _, dep := range deps {
+		if !slices.Contains(current, dep) {
+			current = append(current, dep)
+		}
+	}
+	dependencies[uri] = current
+	for _, dep := range deps {
+		if _, ok := dependencies[dep]; !ok {
+			dependencies[dep] = nil
+		}
+	}
+}
+
+// inDependencyGraph reports whether uri depends on, or is depended on
+// by, another concept.
+func inDependencyGraph(uri string) bool {
+	dependenciesMu.Lock()
+	defer dependenciesMu.Unlock()
+	_, ok := dependencies[uri]
+	return ok
+}
+
+// dependenciesOf returns a copy of uri's dependencies.
+func dependenciesOf(uri string) []string {
+	dependenciesMu.Lock()
+	defer dependenciesMu.Unlock()
+	return slices.Clone(dependencies[uri])
+}
+
+// dependencyOrder returns the URIs of the graph with every concept after
+// its dependencies, or an error naming a cycle.
+func dependencyOrder() ([]string, error) {
+	dependenciesMu.Lock()
+	defer dependenciesMu.Unlock()
+	uris := make([]string, 0, len(dependencies))
+	for uri := range dependencies {
+		uris = append(uris, uri)
+	}
+	slices.Sort(uris)
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(uris))
+	order := make([]string, 0, len(uris))
+	var path []string
+	var visit func(uri string) error
+	visit = func(uri string) error {
+		switch state[uri] {
+		case visited:
+			return nil
+		case visiting:
+			cycle := append(path[slices.Index(path, uri):], uri)
+			return fmt.Errorf("clef: dependency cycle: %v", cycle)
+		}
+		state[uri] = visiting
+		path = append(path, uri)
+		for _, dep := range dependencies[uri] {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[uri] = visited
+		order = append(order, uri)
+		return nil
+	}
+	for _, uri := range uris {
+		if err := visit(uri); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// handleGraph serves GET /concepts/graph: every registered or DependsOn
+// concept, mapped to the URIs it depends on.
+func (s *server) handleGraph(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	graph := make(map[string][]string, len(registry))
+	for uri := range registry {
+		graph[uri] = []string{}
+	}
+	dependenciesMu.Lock()
+	for uri, deps := range dependencies {
+		graph[uri] = append([]string{}, deps...)
+		slices.Sort(graph[uri])
+	}
+	dependenciesMu.Unlock()
+	s.writeJSON(w, r, graph)
+}
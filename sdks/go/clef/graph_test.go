@@ -0,0 +1,132 @@
+package clef
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// orderedWarmer logs the start and end of its WarmUp, and can wait for
+// another warmer to start, to show the two run in parallel.
+type orderedWarmer struct {
+	echoHandler
+	name     string
+	log      *warmLog
+	started  chan struct{}
+	awaiting chan struct{}
+}
+
+type warmLog struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *warmLog) add(event string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+}
+
+func (h *orderedWarmer) WarmUp(ctx context.Context, storage Storage) error {
+	h.log.add("start " + h.name)
+	close(h.started)
+	if h.awaiting != nil {
+		select {
+		case <-h.awaiting:
+		case <-time.After(2 * time.Second):
+			h.log.add(h.name + " ran alone")
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	h.log.add("end " + h.name)
+	return nil
+}
+
+func TestWarmAllDependencyOrder(t *testing.T) {
+	resetRegistry()
+	log := &warmLog{}
+	warmers := map[string]*orderedWarmer{}
+	for _, name := range []string{"Config", "Store", "Search", "Audit"} {
+		warmers[name] = &orderedWarmer{name: name, log: log, started: make(chan struct{})}
+	}
+	// Config ← Store ← Search is a chain; Audit, which also needs
+	// Config, warms in parallel with Store.
+	warmers["Store"].awaiting = warmers["Audit"].started
+	warmers["Audit"].awaiting = warmers["Store"].started
+	DependsOn("urn:test/Store", "urn:test/Config")
+	DependsOn("urn:test/Search", "urn:test/Store")
+	DependsOn("urn:test/Audit", "urn:test/Config")
+	for _, name := range []string{"Search", "Audit", "Store", "Config"} {
+		Register("urn:test/"+name, warmers[name], nil)
+	}
+
+	if pending, _ := readiness(); len(pending) != 4 {
+		t.Errorf("pending before WarmAll = %v, want all four", pending)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := WarmAll(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	events := log.events
+	at := func(event string) int {
+		i := slices.Index(events, event)
+		if i < 0 {
+			t.Fatalf("%q missing from %v", event, events)
+		}
+		return i
+	}
+	for _, edge := range [][2]string{{"Config", "Store"}, {"Store", "Search"}, {"Config", "Audit"}} {
+		if at("end "+edge[0]) > at("start "+edge[1]) {
+			t.Errorf("%s started before %s finished: %v", edge[1], edge[0], events)
+		}
+	}
+	for _, e := range events {
+		if strings.HasSuffix(e, "ran alone") {
+			t.Errorf("Store and Audit did not warm in parallel: %v", events)
+		}
+	}
+}
+
+func TestWarmAllDetectsCycle(t *testing.T) {
+	resetRegistry()
+	DependsOn("urn:test/A", "urn:test/B")
+	DependsOn("urn:test/B", "urn:test/C")
+	DependsOn("urn:test/C", "urn:test/A")
+	err := WarmAll(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("err = %v, want a cycle error", err)
+	}
+}
+
+func TestConceptsGraphEndpoint(t *testing.T) {
+	resetRegistry()
+	DependsOn("urn:test/Search", "urn:test/Store", "urn:test/Config")
+	DependsOn("urn:test/Store", "urn:test/Config")
+	Register("urn:test/Echo", &echoHandler{}, nil)
+
+	rec := doRequest(NewHandler(), http.MethodGet, "/concepts/graph", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d", rec.Code)
+	}
+	var graph map[string][]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &graph); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"urn:test/Search": {"urn:test/Config", "urn:test/Store"},
+		"urn:test/Store":  {"urn:test/Config"},
+		"urn:test/Config": {},
+		"urn:test/Echo":   {},
+	}
+	if !reflect.DeepEqual(graph, want) {
+		t.Errorf("graph = %v, want %v", graph, want)
+	}
+}
@@ -0,0 +1,67 @@
+package clef
+
+import (
+	"context"
+	"strings"
+)
+
+// GroupHandler is a ConceptHandler that routes actions to sub-handlers by
+// action name prefix, for concepts whose actions split across handlers.
+//
+// Example:
+//
+//	h := clef.NewGroupHandler().
+//	    When("admin_", &AdminHandler{}).
+//	    Otherwise(&UserHandler{})
+//	clef.MustRegister("urn:app/User", h, nil)
+type GroupHandler struct {
+	groups   []handlerGroup
+	fallback ConceptHandler
+}
+
+type handlerGroup struct {
+	prefix  string
+	handler ConceptHandler
+}
+
+// NewGroupHandler creates a GroupHandler with no groups.
+func NewGroupHandler() *GroupHandler {
+	return &GroupHandler{}
+}
+
+// When routes actions starting with prefix to handler. Groups are tried
+// in the order they were added, so list longer prefixes first.
+func (g *GroupHandler) When(prefix string, handler ConceptHandler) *GroupHandler {
+	g.groups = append(g.groups, handlerGroup{prefix: prefix, handler: handler})
+	return g
+}
+
+// Otherwise routes actions that match no group to handler. Without it
+// they get an "unknown action" error.
+func (g *GroupHandler) Otherwise(handler ConceptHandler) *GroupHandler {
+	g.fallback = handler
+	return g
+}
+
+// route returns the handler for action, or nil.
+func (g *GroupHandler) route(action string) ConceptHandler {
+	for _, group := range g.groups {
+		if strings.HasPrefix(action, group.prefix) {
+			return group.handler
+		}
+	}
+	return g.fallback
+}
+
+func (g *GroupHandler) Handle(action string, input map[string]any, storage Storage) map[string]any {
+	return g.HandleContext(context.Background(), action, input, storage)
+}
+
+// HandleContext passes ctx on to context-aware sub-handlers.
+func (g *GroupHandler) HandleContext(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+	h := g.route(action)
+	if h == nil {
+		return map[string]any{"variant": "error", "message": "unknown action: " + action}
+	}
+	return callHandler(ctx, h, action, input, storage)
+}
@@ -0,0 +1,35 @@
+package clef
+
+import "testing"
+
+// namedHandler reports its name in every output.
+type namedHandler struct{ name string }
+
+func (h namedHandler) Handle(action string, input map[string]any, storage Storage) map[string]any {
+	return map[string]any{"variant": "ok", "handler": h.name}
+}
+
+func TestGroupHandler(t *testing.T) {
+	g := NewGroupHandler().
+		When("admin_", namedHandler{"admin"}).
+		Otherwise(namedHandler{"main"})
+
+	s := NewInMemoryStorage()
+	for action, want := range map[string]string{
+		"admin_reset": "admin",
+		"create":      "main",
+		"admin":       "main",
+		"list_admin_": "main",
+	} {
+		if got := g.Handle(action, nil, s)["handler"]; got != want {
+			t.Errorf("%s went to %v, want %s", action, got, want)
+		}
+	}
+}
+
+func TestGroupHandlerWithoutDefault(t *testing.T) {
+	g := NewGroupHandler().When("admin_", namedHandler{"admin"})
+	if out := g.Handle("create", nil, NewInMemoryStorage()); out["variant"] != "error" {
+		t.Errorf("expected unknown action error, got %v", out)
+	}
+}
@@ -0,0 +1,227 @@
+package clef
+
+import (
+	"context"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// grpcServiceName is the fully qualified service in proto/clef.proto.
+const grpcServiceName = "clef.v1.ConceptService"
+
+// GRPCClaimsExtractor authenticates a gRPC call from its metadata and
+// returns the caller's claims, which ACLs and tenant routing see as
+// ClaimsFromContext does for HTTP requests. nil claims mean an
+// unauthenticated caller; an error fails the call with
+// codes.Unauthenticated.
+type GRPCClaimsExtractor func(ctx context.Context, md metadata.MD) (map[string]any, error)
+
+// WithGRPCServerOptions passes opts, e.g. grpc.Creds or
+// grpc.ChainUnaryInterceptor, to the server NewGRPCServer creates. The
+// HTTP transport ignores them.
+func WithGRPCServerOptions(opts ...grpc.ServerOption) ServeOption {
+	return func(c *ServerConfig) {
+		c.grpcOptions = append(c.grpcOptions, opts...)
+	}
+}
+
+// WithGRPCClaims sets how the gRPC transport determines the claims of a
+// call. Without it, gRPC callers have no claims, so an ACL only lets
+// them through if it allows unauthenticated callers.
+//
+// Example:
+//
+//	clef.WithGRPCClaims(func(ctx context.Context, md metadata.MD) (map[string]any, error) {
+//	    tokens := md.Get("authorization")
+//	    if len(tokens) == 0 {
+//	        return nil, nil
+//	    }
+//	    return verifyJWT(strings.TrimPrefix(tokens[0], "Bearer "))
+//	})
+func WithGRPCClaims(fn GRPCClaimsExtractor) ServeOption {
+	return func(c *ServerConfig) {
+		c.grpcClaims = fn
+	}
+}
+
+// grpcEmpty is google.protobuf.Empty.
+type grpcEmpty struct{}
+
+// grpcCodec encodes the SDK's wire types with the hand-written protobuf
+// codec, so no generated code is needed. It registers as "proto" and the
+// bytes match proto/clef.proto, so clients generated from that file
+// interoperate.
+type grpcCodec struct{}
+
+func (grpcCodec) Marshal(v any) ([]byte, error) {
+	if _, ok := v.(*grpcEmpty); ok {
+		return nil, nil
+	}
+	return MarshalProto(v)
+}
+
+func (grpcCodec) Unmarshal(data []byte, v any) error {
+	if _, ok := v.(*grpcEmpty); ok {
+		return nil
+	}
+	return UnmarshalProto(data, v)
+}
+
+func (grpcCodec) Name() string { return "proto" }
+
+// conceptService is the HandlerType of the service descriptor.
+type conceptService interface {
+	dispatch(ctx context.Context, inv ActionInvocation) ActionCompletion
+	query(q ConceptQuery) []map[string]any
+	health(ctx context.Context) (map[string]any, bool)
+}
+
+var conceptServiceDesc = grpc.ServiceDesc{
+	ServiceName: grpcServiceName,
+	HandlerType: (*conceptService)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Invoke", Handler: grpcInvoke},
+		{MethodName: "Query", Handler: grpcQuery},
+		{MethodName: "Health", Handler: grpcHealth},
+	},
+	Metadata: "proto/clef.proto",
+}
+
+// unary attaches the caller's claims to ctx and runs fn through the
+// server's interceptor, if any.
+func unary(ctx context.Context, srv any, method string, req any, interceptor grpc.UnaryServerInterceptor, fn func(ctx context.Context, req any) (any, error)) (any, error) {
+	if extract := srv.(*server).config.grpcClaims; extract != nil {
+		md, _ := metadata.FromIncomingContext(ctx)
+		claims, err := extract(ctx, md)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		ctx = ContextWithClaims(ctx, claims)
+	}
+	if interceptor == nil {
+		return fn(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + grpcServiceName + "/" + method}
+	return interceptor(ctx, req, info, fn)
+}
+
+func grpcInvoke(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	inv := new(ActionInvocation)
+	if err := dec(inv); err != nil {
+		return nil, err
+	}
+	return unary(ctx, srv, "Invoke", inv, interceptor, func(ctx context.Context, req any) (any, error) {
+		c := srv.(*server).dispatch(ctx, *req.(*ActionInvocation))
+		return &c, nil
+	})
+}
+
+func grpcQuery(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	q := new(ConceptQuery)
+	if err := dec(q); err != nil {
+		return nil, err
+	}
+	return unary(ctx, srv, "Query", q, interceptor, func(ctx context.Context, req any) (any, error) {
+		return srv.(*server).query(*req.(*ConceptQuery)), nil
+	})
+}
+
+func grpcHealth(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	if err := dec(new(grpcEmpty)); err != nil {
+		return nil, err
+	}
+	return unary(ctx, srv, "Health", &grpcEmpty{}, interceptor, func(ctx context.Context, _ any) (any, error) {
+		report, _ := srv.(*server).health(ctx)
+		return report, nil
+	})
+}
+
+// NewGRPCServer returns a gRPC server exposing the registered concepts as
+// clef.v1.ConceptService, for callers that manage their own listener. It
+// shares the registry and dispatch logic of the HTTP transport, so
+// routing, ACLs and per-concept options behave identically; see
+// WithGRPCClaims for how callers are authenticated, and
+// WithGRPCServerOptions for TLS and interceptors. HTTP-only options such
+// as CORS are ignored.
+func NewGRPCServer(opts ...ServeOption) *grpc.Server {
+	s := newServer(opts)
+	gs := grpc.NewServer(append([]grpc.ServerOption{grpc.ForceServerCodec(grpcCodec{})}, s.config.grpcOptions...)...)
+	gs.RegisterService(&conceptServiceDesc, s)
+	return gs
+}
+
+// ServeGRPC serves the registered concepts over gRPC on addr until ctx is
+// cancelled, then stops gracefully.
+//
+// RPCs (see proto/clef.proto):
+//
+//	Invoke(ActionInvocation) → ActionCompletion  (as POST /invoke)
+//	Query(ConceptQuery)      → QueryResult       (as POST /query)
+//	Health(Empty)            → Struct            (as GET /health)
+func ServeGRPC(ctx context.Context, addr string, opts ...ServeOption) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	gs := NewGRPCServer(opts...)
+	stopped := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			gs.GracefulStop()
+		case <-stopped:
+		}
+	}()
+	defer close(stopped)
+	return gs.Serve(lis)
+}
+
+// GRPCClient calls a ConceptService over gRPC.
+type GRPCClient struct {
+	conn *grpc.ClientConn
+}
+
+// DialGRPC creates a client for the ConceptService at addr. opts must
+// include transport credentials, e.g.
+// grpc.WithTransportCredentials(insecure.NewCredentials()).
+func DialGRPC(addr string, opts ...grpc.DialOption) (*GRPCClient, error) {
+	conn, err := grpc.NewClient(addr, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &GRPCClient{conn: conn}, nil
+}
+
+// Close closes the underlying connection.
+func (c *GRPCClient) Close() error {
+	return c.conn.Close()
+}
+
+func (c *GRPCClient) call(ctx context.Context, method string, req, reply any) error {
+	return c.conn.Invoke(ctx, "/"+grpcServiceName+"/"+method, req, reply, grpc.ForceCodec(grpcCodec{}))
+}
+
+// Invoke sends an invocation and returns its completion.
+func (c *GRPCClient) Invoke(ctx context.Context, inv ActionInvocation) (ActionCompletion, error) {
+	var out ActionCompletion
+	err := c.call(ctx, "Invoke", &inv, &out)
+	return out, err
+}
+
+// Query returns the records matching q.
+func (c *GRPCClient) Query(ctx context.Context, q ConceptQuery) ([]map[string]any, error) {
+	out := []map[string]any{}
+	err := c.call(ctx, "Query", &q, &out)
+	return out, err
+}
+
+// Health returns the server's health report.
+func (c *GRPCClient) Health(ctx context.Context) (map[string]any, error) {
+	var out map[string]any
+	err := c.call(ctx, "Health", &grpcEmpty{}, &out)
+	return out, err
+}
@@ -0,0 +1,158 @@
+package clef
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// startGRPC serves the registry over an in-process connection.
+func startGRPC(t *testing.T, opts ...ServeOption) *GRPCClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	gs := NewGRPCServer(opts...)
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+
+	client, err := DialGRPC("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestGRPCInvokeRoundTrip(t *testing.T) {
+	resetRegistry()
+	Register("urn:test/Echo", &echoHandler{}, nil)
+	client := startGRPC(t)
+
+	c, err := client.Invoke(context.Background(), ActionInvocation{
+		ID:      "inv-1",
+		Concept: "urn:test/Echo",
+		Action:  "echo",
+		Input:   map[string]any{"message": "over grpc"},
+		Flow:    "flow-1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Variant != "ok" || c.Output["message"] != "over grpc" {
+		t.Errorf("unexpected completion %+v", c)
+	}
+	if c.ID != "inv-1" || c.Flow != "flow-1" || c.Concept != "urn:test/Echo" {
+		t.Errorf("expected invocation metadata to round-trip, got %+v", c)
+	}
+
+	c, err = client.Invoke(context.Background(), ActionInvocation{Concept: "urn:test/Missing", Action: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Variant != "error" || c.Output["code"] != "not_found" {
+		t.Errorf("expected not_found completion, got %+v", c)
+	}
+}
+
+func TestGRPCQuery(t *testing.T) {
+	resetRegistry()
+	storage := NewInMemoryStorage()
+	storage.Put("users", "alice", map[string]any{"name": "Alice", "role": "admin"})
+	storage.Put("users", "bob", map[string]any{"name": "Bob", "role": "user"})
+	Register("urn:test/User", &echoHandler{}, storage)
+	client := startGRPC(t)
+
+	records, err := client.Query(context.Background(), ConceptQuery{
+		Concept:  "urn:test/User",
+		Relation: "users",
+		Args:     map[string]any{"role": "admin"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0]["name"] != "Alice" {
+		t.Errorf("unexpected records %v", records)
+	}
+}
+
+func TestGRPCHealthAndACL(t *testing.T) {
+	resetRegistry()
+	Register("urn:test/Echo", &echoHandler{}, nil)
+	client := startGRPC(t,
+		WithHealthCheck("db", HealthCheckFunc(func(ctx context.Context) HealthStatus { return HealthStatus{Healthy: true} })),
+		WithACL(func(ctx context.Context, concept, action string, claims map[string]any) bool { return action != "fail" }),
+	)
+
+	report, err := client.Health(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report["healthy"] != true {
+		t.Errorf("expected healthy report, got %v", report)
+	}
+	if checks, _ := report["checks"].([]any); len(checks) != 1 {
+		t.Errorf("expected one check, got %v", report["checks"])
+	}
+
+	c, err := client.Invoke(context.Background(), ActionInvocation{Concept: "urn:test/Echo", Action: "fail"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Output["code"] != "forbidden" {
+		t.Errorf("expected ACL to apply over gRPC, got %+v", c)
+	}
+}
+
+func TestGRPCClaimsAndServerOptions(t *testing.T) {
+	resetRegistry()
+	Register("urn:test/Echo", &echoHandler{}, nil)
+	var intercepted []string
+	client := startGRPC(t,
+		WithACL(func(ctx context.Context, concept, action string, claims map[string]any) bool {
+			return claims["role"] == "admin"
+		}),
+		WithGRPCClaims(func(ctx context.Context, md metadata.MD) (map[string]any, error) {
+			switch tokens := md.Get("authorization"); {
+			case len(tokens) == 0:
+				return nil, nil
+			case tokens[0] == "Bearer admin":
+				return map[string]any{"role": "admin"}, nil
+			default:
+				return nil, errors.New("bad token")
+			}
+		}),
+		WithGRPCServerOptions(grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			role, _ := ClaimsFromContext(ctx)["role"].(string)
+			intercepted = append(intercepted, info.FullMethod+" "+role)
+			return handler(ctx, req)
+		})),
+	)
+	inv := ActionInvocation{Concept: "urn:test/Echo", Action: "echo"}
+
+	if c, err := client.Invoke(context.Background(), inv); err != nil || c.Output["code"] != "forbidden" {
+		t.Errorf("without claims: %+v, %v; want forbidden", c, err)
+	}
+	admin := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer admin")
+	if c, err := client.Invoke(admin, inv); err != nil || c.Variant != "ok" {
+		t.Errorf("with admin claims: %+v, %v", c, err)
+	}
+	bad := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer nope")
+	if _, err := client.Invoke(bad, inv); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("bad token: err = %v, want Unauthenticated", err)
+	}
+	want := []string{"/clef.v1.ConceptService/Invoke ", "/clef.v1.ConceptService/Invoke admin"}
+	if !slices.Equal(intercepted, want) {
+		t.Errorf("interceptor saw %q, want %q", intercepted, want)
+	}
+}
@@ -1,6 +1,6 @@
 // Package clef implements the Clef concept handler protocol for Go.
 //
-// This is a thin protocol library (~300 LOC total), NOT a code generator.
+// This is a protocol library, NOT a code generator.
 // It lets Go developers write concept handlers that communicate with the
 // Clef sync engine over HTTP.
 //
@@ -22,7 +22,7 @@
 //	}
 //
 //	func main() {
-//	    clef.Register("urn:app/RateLimiter", &RateLimiterHandler{}, nil)
+//	    clef.MustRegister("urn:app/RateLimiter", &RateLimiterHandler{}, nil)
 //	    clef.Serve(":8091")
 //	}
 //
@@ -32,6 +32,11 @@
 //	don't use ConceptManifest, and don't integrate with the compiler pipeline.
 package clef
 
+import (
+	"context"
+	"fmt"
+)
+
 // ConceptHandler is the interface that concept handler implementations must satisfy.
 // Each action method receives the action name, input fields, and a storage instance.
 type ConceptHandler interface {
@@ -39,3 +44,42 @@ type ConceptHandler interface {
 	// The returned map must contain at minimum a "variant" key.
 	Handle(action string, input map[string]any, storage Storage) map[string]any
 }
+
+// ContextHandler is an optional extension of ConceptHandler for handlers
+// that need the invocation context (deadlines, cancellation, values set by
+// the transport). When a handler implements it, the transport calls
+// HandleContext instead of Handle.
+type ContextHandler interface {
+	HandleContext(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any
+}
+
+// callHandler dispatches to HandleContext when available, else Handle.
+// Storage that implements ContextualStorage is bound to ctx first. An
+// error a storage reports during the call (see ReportStorageError), such
+// as a QuotaStorage rejection, becomes an error result.
+func callHandler(ctx context.Context, h ConceptHandler, action string, input map[string]any, storage Storage) (result map[string]any) {
+	ctx, errs := withStorageErrors(ctx)
+	reported := errs.count()
+	storage = bindStorage(ctx, storage)
+	if ch, ok := h.(ContextHandler); ok {
+		result = ch.HandleContext(ctx, action, input, storage)
+	} else {
+		result = h.Handle(action, input, storage)
+	}
+	if err := errs.since(reported); err != nil {
+		return storageErrorResult(err)
+	}
+	return result
+}
+
+// callHandlerRecovered is callHandler for handlers run on a goroutine of
+// their own, where an unrecovered panic would crash the process: a panic
+// becomes an error result with code "panic".
+func callHandlerRecovered(ctx context.Context, h ConceptHandler, action string, input map[string]any, storage Storage) (result map[string]any) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = map[string]any{"variant": "error", "code": "panic", "message": fmt.Sprintf("panic: %v", r)}
+		}
+	}()
+	return callHandler(ctx, h, action, input, storage)
+}
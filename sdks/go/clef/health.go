@@ -0,0 +1,114 @@
+package clef
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// healthCheckTimeout bounds each registered checker.
+const healthCheckTimeout = 5 * time.Second
+
+// HealthStatus is the result of a single health check.
+type HealthStatus struct {
+	Healthy bool   `json:"healthy"`
+	Name    string `json:"name"`
+	Message string `json:"message,omitempty"`
+}
+
+// HealthChecker verifies one dependency (a storage backend, a downstream
+// service, an invariant) for the /health endpoint.
+type HealthChecker interface {
+	Check(ctx context.Context) HealthStatus
+}
+
+// HealthCheckFunc adapts a function to the HealthChecker interface.
+type HealthCheckFunc func(ctx context.Context) HealthStatus
+
+func (f HealthCheckFunc) Check(ctx context.Context) HealthStatus {
+	return f(ctx)
+}
+
+type namedHealthCheck struct {
+	name    string
+	checker HealthChecker
+}
+
+// WithHealthCheck registers a named checker that /health runs on every
+// request. The endpoint reports 503 if any checker is unhealthy.
+func WithHealthCheck(name string, checker HealthChecker) ServeOption {
+	return func(c *ServerConfig) {
+		c.healthChecks = append(c.healthChecks, namedHealthCheck{name: name, checker: checker})
+	}
+}
+
+// runHealthChecks runs all checkers concurrently, each under its own
+// timeout, and returns their statuses in registration order.
+func runHealthChecks(ctx context.Context, checks []namedHealthCheck) []HealthStatus {
+	statuses := make([]HealthStatus, len(checks))
+	var wg sync.WaitGroup
+	for i, hc := range checks {
+		wg.Add(1)
+		go func(i int, hc namedHealthCheck) {
+			defer wg.Done()
+			statuses[i] = runHealthCheck(ctx, hc)
+		}(i, hc)
+	}
+	wg.Wait()
+	return statuses
+}
+
+func runHealthCheck(ctx context.Context, hc namedHealthCheck) HealthStatus {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	done := make(chan HealthStatus, 1)
+	go func() {
+		done <- hc.checker.Check(ctx)
+	}()
+
+	var status HealthStatus
+	select {
+	case status = <-done:
+	case <-ctx.Done():
+		status = HealthStatus{Healthy: false, Message: "health check timed out"}
+	}
+	if status.Name == "" {
+		status.Name = hc.name
+	}
+	return status
+}
+
+func (s *server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	report, healthy := s.health(r.Context())
+	status := http.StatusOK
+	if !healthy {
+		status = http.StatusServiceUnavailable
+	}
+	s.writeJSONStatus(w, r, status, report)
+}
+
+// health runs the registered checks and returns the report served by GET
+// /health, with the current mode of each concept under a degradation
+// policy.
+func (s *server) health(ctx context.Context) (map[string]any, bool) {
+	start := time.Now()
+	statuses := runHealthChecks(ctx, s.config.healthChecks)
+
+	healthy := true
+	for _, st := range statuses {
+		if !st.Healthy {
+			healthy = false
+		}
+	}
+	report := map[string]any{
+		"healthy":   healthy,
+		"latencyMs": time.Since(start).Milliseconds(),
+		"checks":    statuses,
+	}
+	if s.degradation != nil {
+		report["degradation"] = s.degradation.report()
+	}
+	return report, healthy
+}
@@ -0,0 +1,54 @@
+package clef
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestHealthDefaultsToHealthy(t *testing.T) {
+	rec := doRequest(NewHandler(), http.MethodGet, "/health", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var body map[string]any
+	json.NewDecoder(rec.Body).Decode(&body)
+	if body["healthy"] != true {
+		t.Errorf("expected healthy=true, got %v", body["healthy"])
+	}
+}
+
+func TestHealthFailingCheckerReturns503(t *testing.T) {
+	h := NewHandler(
+		WithHealthCheck("storage", HealthCheckFunc(func(ctx context.Context) HealthStatus {
+			return HealthStatus{Healthy: true}
+		})),
+		WithHealthCheck("payments-api", HealthCheckFunc(func(ctx context.Context) HealthStatus {
+			return HealthStatus{Healthy: false, Message: "connection refused"}
+		})),
+	)
+
+	rec := doRequest(h, http.MethodGet, "/health", "")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rec.Code)
+	}
+
+	var body struct {
+		Healthy bool           `json:"healthy"`
+		Checks  []HealthStatus `json:"checks"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Healthy {
+		t.Error("expected healthy=false")
+	}
+	if len(body.Checks) != 2 {
+		t.Fatalf("expected 2 checks, got %d", len(body.Checks))
+	}
+	failed := body.Checks[1]
+	if failed.Name != "payments-api" || failed.Healthy || failed.Message != "connection refused" {
+		t.Errorf("unexpected failed check detail: %+v", failed)
+	}
+}
@@ -0,0 +1,146 @@
+package clef
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"sync"
+)
+
+// ReplayResult is the response of POST /concepts/{concept}/replay/{id}.
+type ReplayResult struct {
+	Original ActionCompletion `json:"original"`
+	Replay   ActionCompletion `json:"replay"`
+}
+
+// InvocationHistory keeps the most recent completions of each concept
+// and tenant, oldest first.
+type InvocationHistory struct {
+	max int
+
+	mu      sync.Mutex
+	entries map[historyKey][]ActionCompletion
+}
+
+// historyKey is the tenant and concept a history belongs to.
+type historyKey struct {
+	tenant  string
+	concept string
+}
+
+// NewInvocationHistory keeps up to maxPerConcept completions per concept
+// and tenant.
+func NewInvocationHistory(maxPerConcept int) *InvocationHistory {
+	return &InvocationHistory{max: maxPerConcept, entries: make(map[historyKey][]ActionCompletion)}
+}
+
+// Record appends a copy of c to the history of its concept in tenant
+// ("" without tenancy), dropping the oldest completion once the history
+// is full. The copy has its own input and output maps, so later changes
+// to c's do not alter the history.
+func (h *InvocationHistory) Record(tenant string, c ActionCompletion) {
+	c.Input = maps.Clone(c.Input)
+	c.Output = maps.Clone(c.Output)
+	key := historyKey{tenant: tenant, concept: c.Concept}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entries := append(h.entries[key], c)
+	if len(entries) > h.max {
+		entries = append(entries[:0:0], entries[len(entries)-h.max:]...)
+	}
+	h.entries[key] = entries
+}
+
+// Completions returns the recorded completions of concept in tenant,
+// oldest first.
+func (h *InvocationHistory) Completions(tenant, concept string) []ActionCompletion {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]ActionCompletion{}, h.entries[historyKey{tenant: tenant, concept: concept}]...)
+}
+
+// Lookup returns the recorded completion of concept in tenant with the
+// given ID.
+func (h *InvocationHistory) Lookup(tenant, concept, id string) (ActionCompletion, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, c := range h.entries[historyKey{tenant: tenant, concept: concept}] {
+		if c.ID == id {
+			return c, true
+		}
+	}
+	return ActionCompletion{}, false
+}
+
+// WithInvocationHistory records the last maxPerConcept completions of
+// each concept and tenant, serves them at GET /concepts/{concept}/history
+// and enables POST /concepts/{concept}/replay/{id}, which runs a recorded
+// invocation again against the current handler and storage and returns
+// both completions as a ReplayResult. {concept} is the concept's slug as
+// in /invoke/{concept}/{action}; the tenant is taken from the request as
+// for /invoke. Requests must carry "Authorization: Bearer <adminToken>",
+// since histories hold invocation inputs and outputs.
+func WithInvocationHistory(maxPerConcept int, adminToken string) ServeOption {
+	return func(c *ServerConfig) {
+		c.historySize = maxPerConcept
+		c.historyToken = adminToken
+	}
+}
+
+// historyConcept resolves the {concept} path value of a history route
+// and the request's tenant, writing 404 when history is disabled or the
+// concept is unknown and 401 without the admin token.
+func (s *server) historyConcept(w http.ResponseWriter, r *http.Request, method string) (uri, tenant string, ok bool) {
+	if s.history == nil {
+		http.NotFound(w, r)
+		return "", "", false
+	}
+	if !authorized(r, s.config.historyToken) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return "", "", false
+	}
+	if r.Method != method {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return "", "", false
+	}
+	slug := r.PathValue("concept")
+	if uri = conceptForSlug(slug); uri == "" {
+		http.Error(w, "unknown concept: "+slug, http.StatusNotFound)
+		return "", "", false
+	}
+	tenant, _ = TenantFromContext(s.withTenant(r.Context(), ActionInvocation{Concept: uri}, r))
+	return uri, tenant, true
+}
+
+// handleHistory serves GET /concepts/{concept}/history.
+func (s *server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	uri, tenant, ok := s.historyConcept(w, r, http.MethodGet)
+	if !ok {
+		return
+	}
+	s.writeJSON(w, r, s.history.Completions(tenant, uri))
+}
+
+// handleReplay serves POST /concepts/{concept}/replay/{id}. The replay
+// gets a new ID, keeps the original's flow and runs in the tenant the
+// original was recorded for.
+func (s *server) handleReplay(w http.ResponseWriter, r *http.Request) {
+	uri, tenant, ok := s.historyConcept(w, r, http.MethodPost)
+	if !ok {
+		return
+	}
+	id := r.PathValue("id")
+	original, ok := s.history.Lookup(tenant, uri, id)
+	if !ok {
+		http.Error(w, "unknown invocation: "+id, http.StatusNotFound)
+		return
+	}
+	ctx := context.WithValue(r.Context(), tenantKey{}, tenant)
+	replay := s.dispatchHTTP(r.WithContext(ctx), ActionInvocation{
+		Concept: original.Concept,
+		Action:  original.Action,
+		Input:   maps.Clone(original.Input),
+		Flow:    original.Flow,
+	})
+	s.writeJSON(w, r, ReplayResult{Original: original, Replay: replay})
+}
@@ -0,0 +1,147 @@
+package clef
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// historyRequest sends an admin request for the history endpoints as
+// tenant, if set.
+func historyRequest(h http.Handler, method, path, tenant string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(""))
+	req.Header.Set("Authorization", "Bearer secret")
+	if tenant != "" {
+		req.Header.Set(TenantHeader, tenant)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// registerCounter registers a concept whose "add" action returns its
+// input n and the number of calls so far, kept in its storage.
+func registerCounter() {
+	Register("urn:test/Counter", HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		rec, _ := storage.Get("counter", "calls")
+		calls, _ := rec["n"].(int)
+		calls++
+		storage.Put("counter", "calls", map[string]any{"n": calls})
+		return map[string]any{"variant": "ok", "n": input["n"], "calls": calls}
+	}), NewInMemoryStorage())
+}
+
+func TestInvocationHistoryAndReplay(t *testing.T) {
+	resetRegistry()
+	registerCounter()
+	h := NewHandler(WithInvocationHistory(10, "secret"))
+
+	for i := 1; i <= 5; i++ {
+		doRequest(h, "POST", "/invoke", fmt.Sprintf(`{"id":"inv-%d","concept":"urn:test/Counter","action":"add","input":{"n":%d}}`, i, i))
+	}
+
+	if rec := doRequest(h, "GET", "/concepts/test-Counter/history", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("history without token: status %d", rec.Code)
+	}
+	rec := historyRequest(h, "GET", "/concepts/test-Counter/history", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("history status %d: %s", rec.Code, rec.Body.String())
+	}
+	var history []ActionCompletion
+	if err := json.Unmarshal(rec.Body.Bytes(), &history); err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 5 {
+		t.Fatalf("history has %d completions, want 5", len(history))
+	}
+	for i, c := range history {
+		if want := fmt.Sprintf("inv-%d", i+1); c.ID != want {
+			t.Errorf("history[%d].ID = %s, want %s", i, c.ID, want)
+		}
+	}
+
+	rec = historyRequest(h, "POST", "/concepts/test-Counter/replay/inv-3", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("replay status %d: %s", rec.Code, rec.Body.String())
+	}
+	var result ReplayResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Original.ID != "inv-3" || result.Original.Output["calls"] != float64(3) {
+		t.Errorf("original = %+v", result.Original)
+	}
+	if result.Replay.ID == "inv-3" || result.Replay.Variant != "ok" {
+		t.Errorf("replay = %+v", result.Replay)
+	}
+	if result.Replay.Output["n"] != float64(3) || result.Replay.Output["calls"] != float64(6) {
+		t.Errorf("replay should rerun input n=3 against current storage: %v", result.Replay.Output)
+	}
+
+	if rec := historyRequest(h, "POST", "/concepts/test-Counter/replay/missing", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown id: status %d", rec.Code)
+	}
+	if rec := historyRequest(h, "GET", "/concepts/test-Nope/history", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown concept: status %d", rec.Code)
+	}
+}
+
+func TestInvocationHistoryKeepsMostRecent(t *testing.T) {
+	history := NewInvocationHistory(2)
+	for i := 1; i <= 3; i++ {
+		history.Record("", ActionCompletion{ID: fmt.Sprintf("inv-%d", i), Concept: "urn:test/Counter"})
+	}
+	got := history.Completions("", "urn:test/Counter")
+	if len(got) != 2 || got[0].ID != "inv-2" || got[1].ID != "inv-3" {
+		t.Fatalf("Completions = %+v", got)
+	}
+	if _, ok := history.Lookup("", "urn:test/Counter", "inv-1"); ok {
+		t.Fatal("evicted completion still found")
+	}
+}
+
+func TestInvocationHistoryScopedByTenant(t *testing.T) {
+	resetRegistry()
+	registerCounter()
+	h := NewHandler(WithInvocationHistory(10, "secret"))
+
+	req := httptest.NewRequest("POST", "/invoke", strings.NewReader(`{"id":"inv-1","concept":"urn:test/Counter","action":"add","input":{"n":1}}`))
+	req.Header.Set(TenantHeader, "acme")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	var history []ActionCompletion
+	json.Unmarshal(historyRequest(h, "GET", "/concepts/test-Counter/history", "globex").Body.Bytes(), &history)
+	if len(history) != 0 {
+		t.Errorf("another tenant sees %d completions", len(history))
+	}
+	if rec := historyRequest(h, "POST", "/concepts/test-Counter/replay/inv-1", "globex"); rec.Code != http.StatusNotFound {
+		t.Errorf("another tenant replays: status %d", rec.Code)
+	}
+	json.Unmarshal(historyRequest(h, "GET", "/concepts/test-Counter/history", "acme").Body.Bytes(), &history)
+	if len(history) != 1 {
+		t.Errorf("tenant sees %d completions, want 1", len(history))
+	}
+}
+
+func TestInvocationHistoryCopiesInput(t *testing.T) {
+	history := NewInvocationHistory(1)
+	input := map[string]any{"n": 1}
+	history.Record("", ActionCompletion{ID: "inv-1", Concept: "urn:test/Counter", Input: input})
+	input["n"] = 2
+	if c, _ := history.Lookup("", "urn:test/Counter", "inv-1"); c.Input["n"] != 1 {
+		t.Errorf("recorded input = %v after the caller changed it", c.Input)
+	}
+}
+
+func TestInvocationHistoryDisabled(t *testing.T) {
+	resetRegistry()
+	registerCounter()
+	h := NewHandler()
+	if rec := doRequest(h, "GET", "/concepts/test-Counter/history", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("status %d, want 404", rec.Code)
+	}
+}
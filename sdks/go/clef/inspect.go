@@ -0,0 +1,85 @@
+package clef
+
+import (
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// InflightInvocation describes an invocation being dispatched, as served
+// by GET /inspect/inflight. The input is left out, since it may hold
+// secrets.
+type InflightInvocation struct {
+	ID        string    `json:"id"`
+	Concept   string    `json:"concept"`
+	Action    string    `json:"action"`
+	Flow      string    `json:"flow"`
+	StartedAt time.Time `json:"started_at"`
+	ElapsedMs int64     `json:"elapsed_ms"`
+}
+
+// WithRequestInspector tracks the invocations received over HTTP or gRPC,
+// or made with InvokeLocal, while they are dispatched and serves them at
+// GET /inspect/inflight, oldest first. Invocations without an ID or flow
+// are listed with the ones dispatch generates.
+func WithRequestInspector() ServeOption {
+	return func(c *ServerConfig) {
+		c.requestInspector = true
+	}
+}
+
+// inflightTracker holds the invocations being dispatched. Entries are
+// keyed by a sequence number rather than the invocation ID, which callers
+// choose and may reuse.
+type inflightTracker struct {
+	next    atomic.Uint64
+	entries sync.Map // uint64 → InflightInvocation
+}
+
+// track records inv as in flight; call the returned function once it
+// completes. It is a no-op on a nil tracker.
+func (t *inflightTracker) track(inv ActionInvocation) (done func()) {
+	if t == nil {
+		return func() {}
+	}
+	seq := t.next.Add(1)
+	t.entries.Store(seq, InflightInvocation{
+		ID:        inv.ID,
+		Concept:   inv.Concept,
+		Action:    inv.Action,
+		Flow:      inv.Flow,
+		StartedAt: time.Now().UTC(),
+	})
+	return func() { t.entries.Delete(seq) }
+}
+
+// snapshot returns the tracked invocations, oldest first.
+func (t *inflightTracker) snapshot() []InflightInvocation {
+	now := time.Now()
+	out := []InflightInvocation{}
+	t.entries.Range(func(_, v any) bool {
+		e := v.(InflightInvocation)
+		e.ElapsedMs = now.Sub(e.StartedAt).Milliseconds()
+		out = append(out, e)
+		return true
+	})
+	slices.SortFunc(out, func(a, b InflightInvocation) int {
+		return a.StartedAt.Compare(b.StartedAt)
+	})
+	return out
+}
+
+// handleInflight serves GET /inspect/inflight.
+func (s *server) handleInflight(w http.ResponseWriter, r *http.Request) {
+	if s.inflight == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.writeJSON(w, r, s.inflight.snapshot())
+}
@@ -0,0 +1,93 @@
+package clef
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRequestInspector(t *testing.T) {
+	resetRegistry()
+	var started, release sync.WaitGroup
+	release.Add(1)
+	Register("urn:test/Blocking", HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		started.Done()
+		release.Wait()
+		return map[string]any{"variant": "ok"}
+	}), nil)
+	h := NewHandler(WithRequestInspector())
+
+	var done sync.WaitGroup
+	for _, id := range []string{"inv-1", "inv-2"} {
+		started.Add(1)
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			doRequest(h, "POST", "/invoke", `{"id":"`+id+`","concept":"urn:test/Blocking","action":"wait","input":{"password":"hunter2"},"flow":"f-`+id+`"}`)
+		}()
+	}
+	started.Wait()
+
+	rec := doRequest(h, "GET", "/inspect/inflight", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "hunter2") {
+		t.Errorf("input leaked: %s", rec.Body.String())
+	}
+	var inflight []InflightInvocation
+	if err := json.Unmarshal(rec.Body.Bytes(), &inflight); err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]InflightInvocation{}
+	for _, e := range inflight {
+		got[e.ID] = e
+	}
+	for _, id := range []string{"inv-1", "inv-2"} {
+		e, ok := got[id]
+		if !ok {
+			t.Fatalf("%s missing from %s", id, rec.Body.String())
+		}
+		if e.Concept != "urn:test/Blocking" || e.Action != "wait" || e.Flow != "f-"+id || e.StartedAt.IsZero() {
+			t.Errorf("%s = %+v", id, e)
+		}
+	}
+
+	release.Done()
+	done.Wait()
+	rec = doRequest(h, "GET", "/inspect/inflight", "")
+	if body := strings.TrimSpace(rec.Body.String()); body != "[]" {
+		t.Errorf("after completion got %s, want []", body)
+	}
+}
+
+func TestRequestInspectorTracksDispatch(t *testing.T) {
+	resetRegistry()
+	s := newServer([]ServeOption{WithRequestInspector()})
+	var seen []InflightInvocation
+	Register("urn:test/Peek", HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		seen = s.inflight.snapshot()
+		return map[string]any{"variant": "ok"}
+	}), nil)
+
+	// gRPC calls and InvokeLocal reach dispatch directly, without an ID
+	// or flow.
+	c := s.dispatch(context.Background(), ActionInvocation{Concept: "urn:test/Peek", Action: "look"})
+	if len(seen) != 1 || seen[0].ID != c.ID || seen[0].ID == "" || seen[0].Flow != c.Flow || seen[0].Flow == "" {
+		t.Fatalf("in flight = %+v, completion %s/%s", seen, c.ID, c.Flow)
+	}
+	if left := s.inflight.snapshot(); len(left) != 0 {
+		t.Errorf("after completion: %+v", left)
+	}
+}
+
+func TestRequestInspectorDisabled(t *testing.T) {
+	resetRegistry()
+	rec := doRequest(NewHandler(), "GET", "/inspect/inflight", "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,85 @@
+// Package plugintest exercises clef.LoadPlugin. It lives outside package
+// clef because a plugin only loads into a host whose copy of clef is
+// built from identical sources, which excludes clef's own test binary.
+package plugintest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/clef/go-sdk/clef"
+)
+
+func buildPlugin(t *testing.T, dir string) string {
+	t.Helper()
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" && runtime.GOOS != "freebsd" {
+		t.Skipf("plugins unsupported on %s", runtime.GOOS)
+	}
+	out := filepath.Join(dir, "greeter.so")
+	args := []string{"build", "-buildmode=plugin", "-o", out}
+	if raceEnabled {
+		args = append(args, "-race")
+	}
+	cmd := exec.Command("go", append(args, "./testdata/greeter")...)
+	if msg, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("cannot build plugin: %v\n%s", err, msg)
+	}
+	return out
+}
+
+func invokeGreeter(t *testing.T, h http.Handler) map[string]any {
+	t.Helper()
+	body, _ := json.Marshal(clef.ActionInvocation{
+		ID: "1", Concept: "urn:plugintest/Greeter", Action: "greet",
+		Input: map[string]any{"name": "Ada"},
+	})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/invoke", bytes.NewReader(body)))
+	var c clef.ActionCompletion
+	if err := json.Unmarshal(rec.Body.Bytes(), &c); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	return c.Output
+}
+
+// A plugin can be opened only once per process, so loading through
+// WithPluginDir and LoadPlugin share one test.
+func TestLoadPlugin(t *testing.T) {
+	dir := t.TempDir()
+	path := buildPlugin(t, dir)
+
+	out := invokeGreeter(t, clef.NewHandler(clef.WithPluginDir(dir)))
+	if out["variant"] != "ok" || out["message"] != "Hello, Ada" {
+		t.Fatalf("output = %v", out)
+	}
+
+	// Another server with the same plugin dir does not register the
+	// plugin's handlers again.
+	clef.Register("urn:plugintest/Greeter", clef.HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage clef.Storage) map[string]any {
+		return map[string]any{"variant": "ok", "message": "replaced"}
+	}), nil)
+	if out := invokeGreeter(t, clef.NewHandler(clef.WithPluginDir(dir))); out["message"] != "replaced" {
+		t.Fatalf("plugin reloaded for a second server: %v", out)
+	}
+
+	// Reopening the same file is a no-op that re-registers the handlers.
+	if err := clef.LoadPlugin(path); err != nil {
+		t.Fatal(err)
+	}
+	if out := invokeGreeter(t, clef.NewHandler()); out["message"] != "Hello, Ada" {
+		t.Fatalf("output = %v", out)
+	}
+}
+
+func TestLoadPluginMissing(t *testing.T) {
+	if err := clef.LoadPlugin(filepath.Join(t.TempDir(), "missing.so")); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,7 @@
+//go:build race
+
+package plugintest
+
+// raceEnabled is set in race builds. A plugin only loads into a host
+// built with the same flags, so buildPlugin then builds it with -race.
+const raceEnabled = true
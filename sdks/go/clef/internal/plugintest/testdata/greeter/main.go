@@ -0,0 +1,19 @@
+// Command greeter is the plugin fixture for the plugin loading tests.
+package main
+
+import "github.com/clef/go-sdk/clef"
+
+func ConceptHandlers() map[string]clef.ConceptHandler {
+	return map[string]clef.ConceptHandler{
+		"urn:plugintest/Greeter": greeter{},
+	}
+}
+
+type greeter struct{}
+
+func (greeter) Handle(action string, input map[string]any, storage clef.Storage) map[string]any {
+	name, _ := input["name"].(string)
+	return map[string]any{"variant": "ok", "message": "Hello, " + name}
+}
+
+func main() {}
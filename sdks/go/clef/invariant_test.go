@@ -0,0 +1,61 @@
+package clef
+
+import (
+	"errors"
+	"testing"
+)
+
+// accountHandler withdraws without checking funds, relying on the
+// transport's invariant to reject overdrafts.
+type accountHandler struct{}
+
+func (h *accountHandler) Handle(action string, input map[string]any, storage Storage) map[string]any {
+	balance := 100
+	amount, _ := input["amount"].(float64)
+	return map[string]any{"variant": "ok", "balance": balance - int(amount)}
+}
+
+func registerAccount() {
+	resetRegistry()
+	var opts ConceptOptions
+	opts.OutputInvariant("withdraw", func(out map[string]any) error {
+		if bal, _ := out["balance"].(int); bal < 0 {
+			return errors.New("balance must never be negative")
+		}
+		return nil
+	})
+	RegisterWithOptions("urn:test/Account", &accountHandler{}, nil, opts)
+}
+
+func TestOutputInvariantBlocksViolation(t *testing.T) {
+	registerAccount()
+
+	c := invokeRecorder(t, `{"concept":"urn:test/Account","action":"withdraw","input":{"amount":150}}`)
+	if c.Variant != "invariant_violated" {
+		t.Fatalf("expected invariant_violated, got %s", c.Variant)
+	}
+	if c.Output["message"] != "balance must never be negative" {
+		t.Errorf("unexpected message %v", c.Output["message"])
+	}
+	if _, leaked := c.Output["balance"]; leaked {
+		t.Error("violating output must not be returned")
+	}
+}
+
+func TestOutputInvariantAllowsValidOutput(t *testing.T) {
+	registerAccount()
+
+	c := invokeRecorder(t, `{"concept":"urn:test/Account","action":"withdraw","input":{"amount":30}}`)
+	if c.Variant != "ok" || c.Output["balance"] != float64(70) {
+		t.Errorf("expected ok with balance 70, got %s %v", c.Variant, c.Output)
+	}
+}
+
+func TestOutputInvariantScopedToAction(t *testing.T) {
+	registerAccount()
+
+	c := invokeRecorder(t, `{"concept":"urn:test/Account","action":"preview","input":{"amount":500}}`)
+	if c.Variant != "ok" {
+		t.Errorf("invariants for withdraw must not apply to preview, got %s", c.Variant)
+	}
+}
@@ -0,0 +1,178 @@
+package clef
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// jobSweepInterval is how often startJob and CompleteJob evict the async
+// jobs older than JobRetention.
+const jobSweepInterval = time.Minute
+
+// AsyncActionCompletion is the 202 response for an action that finishes
+// later. Poll PollURL, served with WithJobEndpoint, until it returns the
+// final ActionCompletion.
+type AsyncActionCompletion struct {
+	ID      string `json:"id"`
+	Concept string `json:"concept"`
+	Action  string `json:"action"`
+	Flow    string `json:"flow"`
+	JobID   string `json:"jobId"`
+	PollURL string `json:"pollUrl"`
+}
+
+// job is an asynchronous invocation tracked for GET /jobs/{jobID}. Either
+// side may arrive first: the transport records the invocation, and
+// CompleteJob records the result.
+type job struct {
+	completion *ActionCompletion
+	result     map[string]any
+	updatedAt  time.Time
+}
+
+var (
+	jobsMu      sync.Mutex
+	jobs        = make(map[string]*job)
+	jobsSweptAt time.Time
+)
+
+// WithJobEndpoint serves /jobs/{jobID}: GET returns the completion of an
+// async action or the record of a job from ScheduleInvoke, and DELETE
+// cancels a scheduled job. Both include the job's input, so requests
+// must carry "Authorization: Bearer <adminToken>".
+func WithJobEndpoint(adminToken string) ServeOption {
+	return func(c *ServerConfig) {
+		c.jobsToken = adminToken
+	}
+}
+
+// updateJob returns the job recorded as jobID, creating it if needed,
+// and marks it updated at now. It evicts the jobs last updated more than
+// JobRetention before now, at most once per jobSweepInterval. jobsMu
+// must be held.
+func updateJob(jobID string, now time.Time) *job {
+	if now.Sub(jobsSweptAt) >= jobSweepInterval {
+		jobsSweptAt = now
+		for id, j := range jobs {
+			if now.Sub(j.updatedAt) > JobRetention {
+				delete(jobs, id)
+			}
+		}
+	}
+	j, ok := jobs[jobID]
+	if !ok {
+		j = &job{}
+		jobs[jobID] = j
+	}
+	j.updatedAt = now
+	return j
+}
+
+// startJob records c, a "pending" completion, as a job. Its output must
+// name the job in "job_id"; otherwise c is not treated as async.
+func startJob(c *ActionCompletion) (AsyncActionCompletion, bool) {
+	jobID, _ := c.Output["job_id"].(string)
+	if jobID == "" {
+		return AsyncActionCompletion{}, false
+	}
+
+	jobsMu.Lock()
+	j := updateJob(jobID, time.Now())
+	pending := *c
+	j.completion = &pending
+	jobsMu.Unlock()
+
+	return AsyncActionCompletion{
+		ID:      c.ID,
+		Concept: c.Concept,
+		Action:  c.Action,
+		Flow:    c.Flow,
+		JobID:   jobID,
+		PollURL: "/jobs/" + jobID,
+	}, true
+}
+
+// CompleteJob delivers the result of an action that returned
+// {"variant": "pending", "job_id": jobID}. result is the action's final
+// output, including its variant. It may be called from any goroutine,
+// even before the pending response has been sent. The result is kept for
+// JobRetention; a job that gets no result within JobRetention of
+// starting is forgotten.
+//
+// Example:
+//
+//	func (h *VideoHandler) Handle(action string, input map[string]any, storage clef.Storage) map[string]any {
+//	    jobID := uuid.NewString()
+//	    go func() {
+//	        url := encode(input["source"].(string))
+//	        clef.CompleteJob(jobID, map[string]any{"variant": "ok", "url": url})
+//	    }()
+//	    return map[string]any{"variant": "pending", "job_id": jobID}
+//	}
+func CompleteJob(jobID string, result map[string]any) {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	updateJob(jobID, time.Now()).result = result
+}
+
+// jobStatus returns the final completion of a job, or nil while it is
+// pending. ok is false for unknown jobs.
+func jobStatus(jobID string) (c *ActionCompletion, ok bool) {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	j, ok := jobs[jobID]
+	if !ok || j.completion == nil {
+		return nil, false
+	}
+	if j.result == nil {
+		return nil, true
+	}
+	done := *j.completion
+	done.Output = j.result
+	done.Variant, _ = j.result["variant"].(string)
+	if done.Variant == "" {
+		done.Variant = "ok"
+	}
+	done.Timestamp = time.Now().UTC().Format(time.RFC3339)
+	return &done, true
+}
+
+// handleJob serves /jobs/{jobID} for both async actions and jobs from
+// ScheduleInvoke. GET returns an async action's completion, or a
+// scheduled job's record; DELETE cancels a scheduled job.
+func (s *server) handleJob(w http.ResponseWriter, r *http.Request) {
+	if s.config.jobsToken == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if !authorized(r, s.config.jobsToken) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodDelete:
+		s.handleCancelJob(w, r)
+		return
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	jobID := r.PathValue("jobID")
+	c, ok := jobStatus(jobID)
+	if !ok {
+		if record, scheduled := scheduledJob(jobID); scheduled {
+			s.writeJSON(w, r, record)
+			return
+		}
+	}
+	switch {
+	case !ok:
+		http.Error(w, "unknown job", http.StatusNotFound)
+	case c == nil:
+		s.writeJSON(w, r, map[string]any{"status": "pending"})
+	default:
+		s.writeNegotiated(w, r, c.StatusCode(), c)
+	}
+}
@@ -0,0 +1,109 @@
+package clef
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// jobRequest sends an authorized request to a /jobs endpoint of h.
+func jobRequest(h http.Handler, method, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAsyncJobLifecycle(t *testing.T) {
+	resetRegistry()
+	Register("urn:test/Video", HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		return map[string]any{"variant": "pending", "job_id": "job-1"}
+	}), nil)
+	h := NewHandler(WithJobEndpoint("secret"))
+
+	rec := doRequest(h, http.MethodPost, "/invoke", `{"concept":"urn:test/Video","action":"encode","input":{"source":"a.mov"}}`)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rec.Code, rec.Body)
+	}
+	var async AsyncActionCompletion
+	if err := json.Unmarshal(rec.Body.Bytes(), &async); err != nil {
+		t.Fatal(err)
+	}
+	if async.JobID != "job-1" || async.PollURL != "/jobs/job-1" || async.Concept != "urn:test/Video" {
+		t.Fatalf("unexpected async completion %+v", async)
+	}
+
+	rec = jobRequest(h, http.MethodGet, async.PollURL)
+	var pending map[string]any
+	json.Unmarshal(rec.Body.Bytes(), &pending)
+	if rec.Code != http.StatusOK || pending["status"] != "pending" {
+		t.Fatalf("expected pending status, got %d %v", rec.Code, pending)
+	}
+
+	CompleteJob("job-1", map[string]any{"variant": "ok", "url": "a.mp4"})
+
+	rec = jobRequest(h, http.MethodGet, async.PollURL)
+	var done ActionCompletion
+	if err := json.Unmarshal(rec.Body.Bytes(), &done); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || done.Variant != "ok" || done.Output["url"] != "a.mp4" {
+		t.Errorf("unexpected final result %d %+v", rec.Code, done)
+	}
+	if done.ID != async.ID || done.Flow != async.Flow || done.Input["source"] != "a.mov" {
+		t.Errorf("expected final completion to keep the invocation, got %+v", done)
+	}
+}
+
+func TestCompleteJobBeforeResponse(t *testing.T) {
+	resetRegistry()
+	Register("urn:test/Video", HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		CompleteJob("job-2", map[string]any{"variant": "error", "code": "not_found", "message": "no source"})
+		return map[string]any{"variant": "pending", "job_id": "job-2"}
+	}), nil)
+	h := NewHandler(WithJobEndpoint("secret"))
+
+	doRequest(h, http.MethodPost, "/invoke", `{"concept":"urn:test/Video","action":"encode"}`)
+	rec := jobRequest(h, http.MethodGet, "/jobs/job-2")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected failed job to report its error status 404, got %d", rec.Code)
+	}
+	var c ActionCompletion
+	if err := json.Unmarshal(rec.Body.Bytes(), &c); err != nil || c.Variant != "error" {
+		t.Errorf("expected error completion, got %s", rec.Body)
+	}
+	if rec := jobRequest(h, http.MethodGet, "/jobs/unknown"); rec.Code != http.StatusNotFound || rec.Header().Get("Content-Type") == "application/json" {
+		t.Errorf("expected plain 404 for unknown job, got %d", rec.Code)
+	}
+}
+
+func TestJobEndpointRequiresToken(t *testing.T) {
+	resetRegistry()
+	CompleteJob("job-3", map[string]any{"variant": "ok"})
+	if rec := doRequest(NewHandler(), http.MethodGet, "/jobs/job-3", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("without WithJobEndpoint: %d, want 404", rec.Code)
+	}
+	if rec := doRequest(NewHandler(WithJobEndpoint("secret")), http.MethodGet, "/jobs/job-3", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("without the admin token: %d, want 401", rec.Code)
+	}
+}
+
+func TestAsyncJobsEvicted(t *testing.T) {
+	resetRegistry()
+	start := time.Now()
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	updateJob("old", start).completion = &ActionCompletion{}
+	updateJob("pending", start.Add(time.Hour))
+	updateJob("new", start.Add(JobRetention+time.Minute))
+	if _, ok := jobs["old"]; ok {
+		t.Error("job older than JobRetention kept")
+	}
+	if _, ok := jobs["pending"]; !ok {
+		t.Error("job within JobRetention evicted")
+	}
+}
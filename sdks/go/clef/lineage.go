@@ -0,0 +1,149 @@
+package clef
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// lineageRelation holds lineage entries in the audit storage.
+const lineageRelation = "_lineage"
+
+// lineageTimeFormat is a fixed-width RFC 3339 layout, so timestamps sort
+// chronologically as strings.
+const lineageTimeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+// LineageEntry records one mutation of a stored record and the
+// invocation that caused it.
+type LineageEntry struct {
+	Relation     string    `json:"relation"`
+	Key          string    `json:"key"`
+	Op           string    `json:"op"`
+	Timestamp    time.Time `json:"timestamp"`
+	Flow         string    `json:"flow"`
+	InvocationID string    `json:"invocationId"`
+	Concept      string    `json:"concept"`
+	Action       string    `json:"action"`
+}
+
+// LineageStorage is a Storage decorator that records, for every Put and
+// Delete, which invocation made the change. Entries are written to a
+// separate audit storage so they survive the records they describe.
+type LineageStorage struct {
+	Storage
+	audit Storage
+	ctx   context.Context
+	// seq disambiguates entries written within the same clock tick.
+	seq *atomic.Uint64
+}
+
+// DataLineage wraps storage so each mutation writes a LineageEntry to
+// auditStorage, keyed by relation, key, and timestamp. The flow and
+// invocation IDs come from the invocation context the transport binds
+// before each call; writes made outside an invocation are recorded with
+// empty IDs.
+func DataLineage(storage Storage, auditStorage Storage) *LineageStorage {
+	return &LineageStorage{Storage: storage, audit: auditStorage, ctx: context.Background(), seq: new(atomic.Uint64)}
+}
+
+// WithContext implements ContextualStorage.
+func (s *LineageStorage) WithContext(ctx context.Context) Storage {
+	return &LineageStorage{Storage: bindStorage(ctx, s.Storage), audit: s.audit, ctx: ctx, seq: s.seq}
+}
+
+// Relations implements Enumerable when the inner storage does.
+func (s *LineageStorage) Relations() []string {
+	if enum, ok := s.Storage.(Enumerable); ok {
+		return enum.Relations()
+	}
+	return nil
+}
+
+// Keys implements Enumerable when the inner storage does.
+func (s *LineageStorage) Keys(relation string) []string {
+	if enum, ok := s.Storage.(Enumerable); ok {
+		return enum.Keys(relation)
+	}
+	return nil
+}
+
+func (s *LineageStorage) Put(relation, key string, value map[string]any) {
+	s.Storage.Put(relation, key, value)
+	s.record(relation, key, "put")
+}
+
+func (s *LineageStorage) Delete(relation, key string) bool {
+	ok := s.Storage.Delete(relation, key)
+	if ok {
+		s.record(relation, key, "delete")
+	}
+	return ok
+}
+
+func (s *LineageStorage) BulkPut(relation string, entries map[string]map[string]any) int {
+	n := s.Storage.BulkPut(relation, entries)
+	for key := range entries {
+		s.record(relation, key, "put")
+	}
+	return n
+}
+
+// CompareAndSwap records a lineage entry only when the swap happens.
+func (s *LineageStorage) CompareAndSwap(relation, key string, expected, replacement map[string]any, compareFields []string) (bool, map[string]any) {
+	swapped, current := s.Storage.CompareAndSwap(relation, key, expected, replacement, compareFields)
+	if swapped {
+		s.record(relation, key, "put")
+	}
+	return swapped, current
+}
+
+// BulkDelete records a lineage entry for each key that existed.
+func (s *LineageStorage) BulkDelete(relation string, keys []string) int {
+	var existing []string
+	for _, key := range keys {
+		if _, ok := s.Storage.Get(relation, key); ok {
+			existing = append(existing, key)
+		}
+	}
+	n := s.Storage.BulkDelete(relation, keys)
+	for _, key := range existing {
+		s.record(relation, key, "delete")
+	}
+	return n
+}
+
+func (s *LineageStorage) record(relation, key, op string) {
+	inv, _ := InvocationFromContext(s.ctx)
+	ts := time.Now().UTC().Format(lineageTimeFormat)
+	id := fmt.Sprintf("%s:%s:%s:%d", relation, key, ts, s.seq.Add(1))
+	s.audit.Put(lineageRelation, id, map[string]any{
+		"relation":     relation,
+		"key":          key,
+		"op":           op,
+		"timestamp":    ts,
+		"flow":         inv.Flow,
+		"invocationId": inv.ID,
+		"concept":      inv.Concept,
+		"action":       inv.Action,
+	})
+}
+
+// LineageFor returns the recorded mutations of one record, oldest first.
+func (s *LineageStorage) LineageFor(relation, key string) []LineageEntry {
+	records := s.audit.FindSorted(lineageRelation, map[string]any{"relation": relation, "key": key}, "timestamp", true)
+	entries := make([]LineageEntry, 0, len(records))
+	for _, rec := range records {
+		e := LineageEntry{Relation: relation, Key: key}
+		e.Op, _ = rec["op"].(string)
+		e.Flow, _ = rec["flow"].(string)
+		e.InvocationID, _ = rec["invocationId"].(string)
+		e.Concept, _ = rec["concept"].(string)
+		e.Action, _ = rec["action"].(string)
+		if ts, ok := rec["timestamp"].(string); ok {
+			e.Timestamp, _ = time.Parse(lineageTimeFormat, ts)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
@@ -0,0 +1,64 @@
+package clef
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// profileHandler stores the input under input["id"].
+type profileHandler struct{}
+
+func (profileHandler) Handle(action string, input map[string]any, storage Storage) map[string]any {
+	id, _ := input["id"].(string)
+	storage.Put("profiles", id, input)
+	return map[string]any{"variant": "ok"}
+}
+
+func TestDataLineageRecordsEachPut(t *testing.T) {
+	resetRegistry()
+	audit := NewInMemoryStorage()
+	storage := DataLineage(NewInMemoryStorage(), audit)
+	Register("urn:test/Profile", profileHandler{}, storage)
+
+	for i := 1; i <= 3; i++ {
+		invokeRecorder(t, fmt.Sprintf(`{"id":"inv-%d","flow":"flow-%d","concept":"urn:test/Profile","action":"update","input":{"id":"alice","n":%d}}`, i, i, i))
+	}
+
+	entries := storage.LineageFor("profiles", "alice")
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 lineage entries, got %d", len(entries))
+	}
+	for i, e := range entries {
+		if e.Flow != fmt.Sprintf("flow-%d", i+1) || e.InvocationID != fmt.Sprintf("inv-%d", i+1) {
+			t.Errorf("entry %d: expected flow-%d/inv-%d, got %s/%s", i, i+1, i+1, e.Flow, e.InvocationID)
+		}
+		if e.Op != "put" || e.Action != "update" || e.Concept != "urn:test/Profile" {
+			t.Errorf("entry %d: unexpected %+v", i, e)
+		}
+		if i > 0 && e.Timestamp.Before(entries[i-1].Timestamp) {
+			t.Errorf("entry %d is out of chronological order", i)
+		}
+	}
+	if len(storage.LineageFor("profiles", "bob")) != 0 {
+		t.Error("expected no lineage for an untouched record")
+	}
+}
+
+func TestDataLineageRecordsDelete(t *testing.T) {
+	storage := DataLineage(NewInMemoryStorage(), NewInMemoryStorage())
+	ctx := ContextWithInvocation(context.Background(), ActionInvocation{ID: "i1", Flow: "f1"})
+	bound := storage.WithContext(ctx)
+
+	bound.Put("profiles", "alice", map[string]any{"id": "alice"})
+	bound.Delete("profiles", "alice")
+	bound.Delete("profiles", "alice")
+
+	entries := storage.LineageFor("profiles", "alice")
+	if len(entries) != 2 || entries[0].Op != "put" || entries[1].Op != "delete" {
+		t.Fatalf("expected put then delete, got %+v", entries)
+	}
+	if entries[1].Flow != "f1" {
+		t.Errorf("expected flow f1, got %q", entries[1].Flow)
+	}
+}
@@ -0,0 +1,130 @@
+package clef
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LoadMetrics reports how busy a handler process is, for autoscalers
+// deciding when to scale out. It is served by GET /load.
+type LoadMetrics struct {
+	// InFlight is the number of invocations being dispatched.
+	InFlight int64 `json:"inFlight"`
+	// QueueDepth is the number of invocations received whose request body
+	// is still being read.
+	QueueDepth int64 `json:"queueDepth"`
+	// P95LatencyMs is the 95th percentile dispatch latency over the last
+	// 1000 invocations.
+	P95LatencyMs float64 `json:"p95LatencyMs"`
+	// ErrorRate is the fraction of invocations in the last 60 seconds
+	// that completed with the "error" variant.
+	ErrorRate float64 `json:"errorRate"`
+}
+
+const (
+	loadLatencyWindow = 1000
+	loadErrorWindow   = 60 // seconds
+)
+
+// WithLoadMetrics tracks HTTP invocations and serves the current
+// LoadMetrics at GET /load.
+func WithLoadMetrics() ServeOption {
+	return func(c *ServerConfig) {
+		c.loadMetrics = true
+	}
+}
+
+// loadTracker accumulates LoadMetrics.
+type loadTracker struct {
+	inFlight   atomic.Int64
+	queueDepth atomic.Int64
+
+	mu sync.Mutex
+	// latencies is a circular buffer of the most recent durations in ms.
+	latencies [loadLatencyWindow]float64
+	count     int
+	next      int
+	// seconds holds per-second invocation and error counts, indexed by
+	// unix second modulo the window.
+	seconds [loadErrorWindow]loadSecond
+}
+
+type loadSecond struct {
+	unix   int64
+	total  int64
+	errors int64
+}
+
+// record adds one finished invocation.
+func (t *loadTracker) record(d time.Duration, failed bool) {
+	now := time.Now().Unix()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.latencies[t.next] = float64(d) / float64(time.Millisecond)
+	t.next = (t.next + 1) % loadLatencyWindow
+	if t.count < loadLatencyWindow {
+		t.count++
+	}
+
+	b := &t.seconds[now%loadErrorWindow]
+	if b.unix != now {
+		*b = loadSecond{unix: now}
+	}
+	b.total++
+	if failed {
+		b.errors++
+	}
+}
+
+// snapshot returns the current metrics.
+func (t *loadTracker) snapshot() LoadMetrics {
+	m := LoadMetrics{InFlight: t.inFlight.Load(), QueueDepth: t.queueDepth.Load()}
+	now := time.Now().Unix()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.count > 0 {
+		sorted := make([]float64, t.count)
+		copy(sorted, t.latencies[:t.count])
+		sort.Float64s(sorted)
+		m.P95LatencyMs = sorted[(t.count*95+99)/100-1]
+	}
+	var total, errors int64
+	for _, b := range t.seconds {
+		if now-b.unix < loadErrorWindow {
+			total += b.total
+			errors += b.errors
+		}
+	}
+	if total > 0 {
+		m.ErrorRate = float64(errors) / float64(total)
+	}
+	return m
+}
+
+// handleLoad serves GET /load.
+func (s *server) handleLoad(w http.ResponseWriter, r *http.Request) {
+	if s.load == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.writeJSON(w, r, s.load.snapshot())
+}
+
+// enqueue counts a request whose body is being read; call the returned
+// function once it has been decoded. It is a no-op on a nil tracker.
+func (t *loadTracker) enqueue() (dequeue func()) {
+	if t == nil {
+		return func() {}
+	}
+	t.queueDepth.Add(1)
+	return func() { t.queueDepth.Add(-1) }
+}
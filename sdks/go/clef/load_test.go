@@ -0,0 +1,76 @@
+package clef
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func getLoad(t *testing.T, h http.Handler) LoadMetrics {
+	t.Helper()
+	rec := doRequest(h, "GET", "/load", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /load: status %d", rec.Code)
+	}
+	var m LoadMetrics
+	if err := json.Unmarshal(rec.Body.Bytes(), &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestLoadMetrics(t *testing.T) {
+	resetRegistry()
+	release := make(chan struct{})
+	Register("urn:test/Slow", HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		<-release
+		if action == "fail" {
+			return map[string]any{"variant": "error", "message": "boom"}
+		}
+		return map[string]any{"variant": "ok"}
+	}), nil)
+	h := NewHandler(WithLoadMetrics())
+
+	var wg sync.WaitGroup
+	for i := range 50 {
+		action := "ok"
+		if i%5 == 0 {
+			action = "fail"
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			doRequest(h, "POST", "/invoke", `{"concept":"urn:test/Slow","action":"`+action+`"}`)
+		}()
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for getLoad(t, h).InFlight == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("InFlight stayed 0 while requests were running")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	m := getLoad(t, h)
+	if m.InFlight != 0 || m.QueueDepth != 0 {
+		t.Errorf("after completion: %+v", m)
+	}
+	if m.P95LatencyMs <= 0 {
+		t.Errorf("P95LatencyMs = %v", m.P95LatencyMs)
+	}
+	if m.ErrorRate != 0.2 {
+		t.Errorf("ErrorRate = %v, want 0.2", m.ErrorRate)
+	}
+}
+
+func TestLoadMetricsDisabled(t *testing.T) {
+	if rec := doRequest(NewHandler(), "GET", "/load", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,40 @@
+package clef
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+)
+
+// LoggingMiddleware logs every invocation at info level after it
+// completes, with its concept, action, flow, input, variant and
+// duration. Input values are logged only from the redacted copy
+// RedactionMiddleware puts in the context; without one, for instance when
+// that middleware is missing or placed after this one, only the input's
+// field names are logged, as input_keys.
+func LoggingMiddleware(logger *slog.Logger) MiddlewareFunc {
+	return func(next ConceptHandler) ConceptHandler {
+		return HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+			start := time.Now()
+			output := callHandler(ctx, next, action, input, storage)
+
+			logged := slog.Any("input_keys", slices.Sorted(maps.Keys(input)))
+			if redacted, ok := RedactedInputFromContext(ctx); ok {
+				logged = slog.Any("input", redacted)
+			}
+			inv, _ := InvocationFromContext(ctx)
+			variant, _ := output["variant"].(string)
+			logger.InfoContext(ctx, "action invoked",
+				"concept", inv.Concept,
+				"action", action,
+				"flow", inv.Flow,
+				logged,
+				"variant", variant,
+				"duration", time.Since(start),
+			)
+			return output
+		})
+	}
+}
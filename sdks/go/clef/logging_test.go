@@ -0,0 +1,26 @@
+package clef
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLoggingMiddleware(t *testing.T) {
+	resetRegistry()
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	Register("urn:test/Echo", Chain(&echoHandler{}, LoggingMiddleware(logger)), nil)
+
+	invokeRecorder(t, `{"concept":"urn:test/Echo","action":"echo","flow":"f1","input":{"message":"s3cret"}}`)
+	out := logs.String()
+	for _, want := range []string{"action invoked", "concept=urn:test/Echo", "action=echo", "flow=f1", "input_keys=[message]", "variant=ok", "duration="} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log missing %q: %s", want, out)
+		}
+	}
+	if strings.Contains(out, "s3cret") {
+		t.Errorf("input value logged without RedactionMiddleware: %s", out)
+	}
+}
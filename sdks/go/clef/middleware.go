@@ -0,0 +1,75 @@
+package clef
+
+import "context"
+
+// HandlerFunc adapts an ordinary function to a ConceptHandler that also
+// receives the invocation context.
+type HandlerFunc func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any
+
+// Handle calls f with a background context.
+func (f HandlerFunc) Handle(action string, input map[string]any, storage Storage) map[string]any {
+	return f(context.Background(), action, input, storage)
+}
+
+// HandleContext calls f.
+func (f HandlerFunc) HandleContext(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+	return f(ctx, action, input, storage)
+}
+
+// MiddlewareFunc wraps a ConceptHandler with cross-cutting behaviour such
+// as validation or logging. Middleware that calls next should pass the
+// context through so context-aware handlers keep working.
+type MiddlewareFunc func(next ConceptHandler) ConceptHandler
+
+// Chain wraps h in middleware, outermost first: Chain(h, a, b) runs a,
+// then b, then h.
+//
+// Example:
+//
+//	clef.MustRegister("urn:app/User", clef.Chain(&UserHandler{}, clef.SchemaValidationMiddleware(schemas)), nil)
+func Chain(h ConceptHandler, middleware ...MiddlewareFunc) ConceptHandler {
+	if len(middleware) == 0 {
+		return h
+	}
+	base := h
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+	return chainedHandler{ConceptHandler: h, base: base}
+}
+
+// chainedHandler is the handler Chain returns: the outermost middleware,
+// remembering the handler it wraps so optional interfaces of that
+// handler can still be found, see findHandler.
+type chainedHandler struct {
+	ConceptHandler
+	base ConceptHandler
+}
+
+// HandleContext passes ctx on to the outermost middleware.
+func (c chainedHandler) HandleContext(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+	if ch, ok := c.ConceptHandler.(ContextHandler); ok {
+		return ch.HandleContext(ctx, action, input, storage)
+	}
+	return c.ConceptHandler.Handle(action, input, storage)
+}
+
+// findHandler returns h as a T or, if Chain returned h, its outermost
+// middleware or the handler it wraps, so an interface like
+// DegradedHandler is found behind middleware.
+func findHandler[T any](h ConceptHandler) (T, bool) {
+	for {
+		if t, ok := h.(T); ok {
+			return t, true
+		}
+		c, ok := h.(chainedHandler)
+		if !ok {
+			var zero T
+			return zero, false
+		}
+		if t, ok := c.ConceptHandler.(T); ok {
+			return t, true
+		}
+		h = c.base
+	}
+}
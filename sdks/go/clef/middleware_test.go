@@ -0,0 +1,44 @@
+package clef
+
+import (
+	"context"
+	"testing"
+)
+
+func tagMiddleware(tag string, order *[]string) MiddlewareFunc {
+	return func(next ConceptHandler) ConceptHandler {
+		return HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+			*order = append(*order, tag)
+			return callHandler(ctx, next, action, input, storage)
+		})
+	}
+}
+
+func TestChainOrder(t *testing.T) {
+	var order []string
+	h := Chain(HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		order = append(order, "handler")
+		return map[string]any{"variant": "ok"}
+	}), tagMiddleware("a", &order), tagMiddleware("b", &order))
+
+	h.Handle("run", nil, NewInMemoryStorage())
+	if len(order) != 3 || order[0] != "a" || order[1] != "b" || order[2] != "handler" {
+		t.Errorf("expected [a b handler], got %v", order)
+	}
+}
+
+type ctxKey struct{}
+
+func TestChainPassesContext(t *testing.T) {
+	var got any
+	h := Chain(HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		got = ctx.Value(ctxKey{})
+		return map[string]any{"variant": "ok"}
+	}), tagMiddleware("a", new([]string)))
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "v")
+	callHandler(ctx, h, "run", nil, NewInMemoryStorage())
+	if got != "v" {
+		t.Errorf("expected context value to reach handler, got %v", got)
+	}
+}
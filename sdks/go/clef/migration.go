@@ -0,0 +1,103 @@
+package clef
+
+import (
+	"fmt"
+	"sort"
+)
+
+// migrationsRelation records, per migrated relation, the highest
+// migration version applied to it.
+const migrationsRelation = "_migrations"
+
+// MigrationFunc upgrades one stored record and returns its new value, or
+// nil to leave the record as it is.
+type MigrationFunc func(relation, key string, old map[string]any) map[string]any
+
+// MigrationRunner applies versioned record migrations to a relation.
+// Each relation remembers the last version applied to it, so Run only
+// applies migrations added since the previous run.
+//
+// Example:
+//
+//	var m clef.MigrationRunner
+//	m.AddMigration(2, func(rel, key string, old map[string]any) map[string]any {
+//	    if _, ok := old["bio"]; !ok {
+//	        old["bio"] = ""
+//	    }
+//	    return old
+//	})
+//	n, err := m.Run(storage, "users")
+type MigrationRunner struct {
+	migrations map[int]MigrationFunc
+}
+
+// AddMigration registers fn as migration version. Versions run in
+// ascending order and must be unique and positive.
+func (m *MigrationRunner) AddMigration(version int, fn MigrationFunc) {
+	if version <= 0 {
+		panic(fmt.Sprintf("clef: migration version must be positive, got %d", version))
+	}
+	if _, dup := m.migrations[version]; dup {
+		panic(fmt.Sprintf("clef: duplicate migration version %d", version))
+	}
+	if m.migrations == nil {
+		m.migrations = make(map[int]MigrationFunc)
+	}
+	m.migrations[version] = fn
+}
+
+// Run applies every pending migration to each entry in relation and
+// returns how many entries were rewritten. storage must implement
+// Enumerable. The applied version is recorded only after every entry has
+// been migrated, so an interrupted run is repeated in full next time;
+// migrations should therefore be idempotent.
+func (m *MigrationRunner) Run(storage Storage, relation string) (migrated int, err error) {
+	enum, ok := storage.(Enumerable)
+	if !ok {
+		return 0, fmt.Errorf("clef: migrating %s: storage does not support enumeration", relation)
+	}
+
+	current := AppliedMigrationVersion(storage, relation)
+	var pending []int
+	for v := range m.migrations {
+		if v > current {
+			pending = append(pending, v)
+		}
+	}
+	if len(pending) == 0 {
+		return 0, nil
+	}
+	sort.Ints(pending)
+
+	for _, key := range enum.Keys(relation) {
+		value, ok := storage.Get(relation, key)
+		if !ok {
+			continue
+		}
+		changed := false
+		for _, v := range pending {
+			if next := m.migrations[v](relation, key, value); next != nil {
+				value = next
+				changed = true
+			}
+		}
+		if changed {
+			storage.Put(relation, key, value)
+			migrated++
+		}
+	}
+
+	storage.Put(migrationsRelation, relation, map[string]any{"version": pending[len(pending)-1]})
+	return migrated, nil
+}
+
+// AppliedMigrationVersion returns the last migration version applied to
+// relation, or 0 if it has never been migrated.
+func AppliedMigrationVersion(storage Storage, relation string) int {
+	rec, ok := storage.Get(migrationsRelation, relation)
+	if !ok {
+		return 0
+	}
+	v, _ := toFloat(rec["version"])
+	return int(v)
+}
@@ -0,0 +1,99 @@
+package clef
+
+import "testing"
+
+func TestMigrationRunnerAddsDefaultField(t *testing.T) {
+	s := NewInMemoryStorage()
+	s.Put("users", "alice", map[string]any{"name": "Alice"})
+	s.Put("users", "bob", map[string]any{"name": "Bob", "bio": "hi"})
+
+	var m MigrationRunner
+	m.AddMigration(1, func(rel, key string, old map[string]any) map[string]any {
+		if _, ok := old["bio"]; ok {
+			return nil
+		}
+		next := map[string]any{"bio": ""}
+		for k, v := range old {
+			next[k] = v
+		}
+		return next
+	})
+
+	n, err := m.Run(s, "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 migrated record, got %d", n)
+	}
+	for _, rec := range s.Find("users", nil) {
+		if _, ok := rec["bio"]; !ok {
+			t.Errorf("record %v missing bio", rec)
+		}
+	}
+	if v := AppliedMigrationVersion(s, "users"); v != 1 {
+		t.Errorf("expected applied version 1, got %d", v)
+	}
+}
+
+func TestMigrationRunnerAppliesOnlyPendingInOrder(t *testing.T) {
+	s := NewInMemoryStorage()
+	s.Put("users", "alice", map[string]any{"name": "Alice"})
+
+	var order []int
+	step := func(v int) func(string, string, map[string]any) map[string]any {
+		return func(rel, key string, old map[string]any) map[string]any {
+			order = append(order, v)
+			return map[string]any{"name": old["name"], "version": v}
+		}
+	}
+	var m MigrationRunner
+	m.AddMigration(2, step(2))
+	m.AddMigration(1, step(1))
+	if _, err := m.Run(s, "users"); err != nil {
+		t.Fatal(err)
+	}
+
+	m.AddMigration(3, step(3))
+	if _, err := m.Run(s, "users"); err != nil {
+		t.Fatal(err)
+	}
+	if len(order) != 3 || order[0] != 1 || order[1] != 2 || order[2] != 3 {
+		t.Errorf("expected migrations 1,2,3 once each, got %v", order)
+	}
+	if rec, _ := s.Get("users", "alice"); rec["version"] != 3 || rec["name"] != "Alice" {
+		t.Errorf("unexpected record %v", rec)
+	}
+	if n, _ := m.Run(s, "users"); n != 0 {
+		t.Errorf("expected nothing pending, migrated %d", n)
+	}
+}
+
+func TestMigrationRunnerRequiresEnumerable(t *testing.T) {
+	var m MigrationRunner
+	m.AddMigration(1, func(string, string, map[string]any) map[string]any { return nil })
+	if _, err := m.Run(struct{ Storage }{NewInMemoryStorage()}, "users"); err == nil {
+		t.Error("expected error for non-enumerable storage")
+	}
+}
+
+func TestMigrationRunnerThroughDecorators(t *testing.T) {
+	var key [32]byte
+	for name, s := range map[string]Storage{
+		"audit":     NewAuditStorage(NewInMemoryStorage(), NopAuditSink()),
+		"lineage":   DataLineage(NewInMemoryStorage(), NewInMemoryStorage()),
+		"encrypted": EncryptedStorage(NewInMemoryStorage(), key),
+	} {
+		s.Put("users", "alice", map[string]any{"name": "Alice"})
+		var m MigrationRunner
+		m.AddMigration(1, func(rel, key string, old map[string]any) map[string]any {
+			return map[string]any{"name": old["name"], "bio": ""}
+		})
+		if n, err := m.Run(s, "users"); err != nil || n != 1 {
+			t.Errorf("%s: Run = %d, %v", name, n, err)
+		}
+		if got, _ := s.Get("users", "alice"); got["bio"] != "" {
+			t.Errorf("%s: alice = %v, want migrated", name, got)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package clef
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+)
+
+// namespacedStorage prefixes every relation with a namespace, so several
+// concepts can share one storage without their relations colliding.
+type namespacedStorage struct {
+	prefix string
+	inner  Storage
+}
+
+// NamespacedStorage wraps inner so every relation is stored as
+// namespace + ":" + relation. It lists only its own relations when inner
+// implements Enumerable.
+func NamespacedStorage(namespace string, inner Storage) Storage {
+	return &namespacedStorage{prefix: namespace + ":", inner: inner}
+}
+
+func (s *namespacedStorage) Get(relation, key string) (map[string]any, bool) {
+	return s.inner.Get(s.prefix+relation, key)
+}
+
+func (s *namespacedStorage) Put(relation, key string, value map[string]any) {
+	s.inner.Put(s.prefix+relation, key, value)
+}
+
+func (s *namespacedStorage) Delete(relation, key string) bool {
+	return s.inner.Delete(s.prefix+relation, key)
+}
+
+func (s *namespacedStorage) Find(relation string, args map[string]any) []map[string]any {
+	return s.inner.Find(s.prefix+relation, args)
+}
+
+func (s *namespacedStorage) FindSorted(relation string, args map[string]any, sortField string, ascending bool) []map[string]any {
+	return s.inner.FindSorted(s.prefix+relation, args, sortField, ascending)
+}
+
+func (s *namespacedStorage) Range(relation, startKey, endKey string, inclusive bool) []map[string]any {
+	return s.inner.Range(s.prefix+relation, startKey, endKey, inclusive)
+}
+
+func (s *namespacedStorage) BulkPut(relation string, entries map[string]map[string]any) int {
+	return s.inner.BulkPut(s.prefix+relation, entries)
+}
+
+func (s *namespacedStorage) BulkDelete(relation string, keys []string) int {
+	return s.inner.BulkDelete(s.prefix+relation, keys)
+}
+
+func (s *namespacedStorage) CompareAndSwap(relation, key string, expected, replacement map[string]any, compareFields []string) (bool, map[string]any) {
+	return s.inner.CompareAndSwap(s.prefix+relation, key, expected, replacement, compareFields)
+}
+
+// Relations returns the namespace's relations without the prefix.
+func (s *namespacedStorage) Relations() []string {
+	enum, ok := s.inner.(Enumerable)
+	if !ok {
+		return nil
+	}
+	var names []string
+	for _, name := range enum.Relations() {
+		if rel, ok := strings.CutPrefix(name, s.prefix); ok {
+			names = append(names, rel)
+		}
+	}
+	return names
+}
+
+func (s *namespacedStorage) Keys(relation string) []string {
+	enum, ok := s.inner.(Enumerable)
+	if !ok {
+		return nil
+	}
+	return enum.Keys(s.prefix + relation)
+}
+
+// WithContext implements ContextualStorage by binding the inner storage.
+func (s *namespacedStorage) WithContext(ctx context.Context) Storage {
+	return &namespacedStorage{prefix: s.prefix, inner: bindStorage(ctx, s.inner)}
+}
+
+// WithNamespaceIsolation gives every concept its own namespace within
+// its storage, using the concept URI, so concepts registered with a
+// shared storage cannot see each other's relations. The registered
+// storage itself is wrapped in a NamespacedStorage, for the concepts
+// already registered when the server is created and for those
+// registered after, so everything reading a concept's storage goes
+// through the namespace: invocations, queries, polls, consistency
+// checks, atomic groups and dynamic config alike. Isolation applies to
+// the whole process once any server enables it.
+func WithNamespaceIsolation() ServeOption {
+	return func(c *ServerConfig) {
+		c.namespaceIsolation = true
+	}
+}
+
+// namespaceIsolation is set once a server is created with
+// WithNamespaceIsolation.
+var namespaceIsolation atomic.Bool
+
+// enableNamespaceIsolation turns on namespace isolation and wraps the
+// storage of every concept registered so far.
+func enableNamespaceIsolation() {
+	namespaceIsolation.Store(true)
+	for uri, entry := range registry {
+		registry[uri] = isolate(uri, entry)
+	}
+	for _, entries := range tenantRegistry {
+		for uri, entry := range entries {
+			entries[uri] = isolate(uri, entry)
+		}
+	}
+}
+
+// isolate wraps the storage of entry, registered at uri, in the uri's
+// namespace if isolation is on and it is not wrapped yet.
+func isolate(uri string, entry registryEntry) registryEntry {
+	if namespaceIsolation.Load() && !entry.namespaced {
+		entry.storage = NamespacedStorage(uri, entry.storage)
+		entry.namespaced = true
+	}
+	return entry
+}
+
+// MergeRelation implements Merger when the inner storage does, and
+// otherwise merges entry by entry.
+func (s *namespacedStorage) MergeRelation(relation string, entries map[string]map[string]any, strategy MergeStrategy) (int, error) {
+	return MergeRelation(s.inner, s.prefix+relation, entries, strategy)
+}
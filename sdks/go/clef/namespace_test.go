@@ -0,0 +1,112 @@
+package clef
+
+import (
+	"context"
+	"testing"
+)
+
+// sessionHandler stores a session under the input id and lists all
+// sessions it can see.
+func sessionHandler(owner string) HandlerFunc {
+	return func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		switch action {
+		case "start":
+			id, _ := input["id"].(string)
+			storage.Put("sessions", id, map[string]any{"id": id, "owner": owner})
+			return map[string]any{"variant": "ok"}
+		default:
+			return map[string]any{"variant": "ok", "sessions": storage.Find("sessions", nil)}
+		}
+	}
+}
+
+func TestNamespaceIsolationSeparatesSharedStorage(t *testing.T) {
+	resetRegistry()
+	shared := NewInMemoryStorage()
+	Register("urn:test/Auth", sessionHandler("auth"), shared)
+	Register("urn:test/Cart", sessionHandler("cart"), shared)
+	s := newServer([]ServeOption{WithNamespaceIsolation()})
+	ctx := context.Background()
+
+	s.dispatch(ctx, ActionInvocation{Concept: "urn:test/Auth", Action: "start", Input: map[string]any{"id": "s1"}})
+	s.dispatch(ctx, ActionInvocation{Concept: "urn:test/Cart", Action: "start", Input: map[string]any{"id": "s1"}})
+
+	for _, uri := range []string{"urn:test/Auth", "urn:test/Cart"} {
+		c := s.dispatch(ctx, ActionInvocation{Concept: uri, Action: "list"})
+		sessions := c.Output["sessions"].([]map[string]any)
+		if len(sessions) != 1 {
+			t.Fatalf("%s: expected 1 session, got %v", uri, sessions)
+		}
+		if got := s.query(ConceptQuery{Concept: uri, Relation: "sessions"}); len(got) != 1 || got[0]["owner"] != sessions[0]["owner"] {
+			t.Errorf("%s: query saw %v, handler saw %v", uri, got, sessions)
+		}
+	}
+	if got := shared.Find("urn:test/Auth:sessions", nil); len(got) != 1 || got[0]["owner"] != "auth" {
+		t.Errorf("expected Auth sessions under its namespace, got %v", got)
+	}
+}
+
+func TestNamespaceIsolationAppliesOutsideDispatch(t *testing.T) {
+	resetRegistry()
+	shared := NewInMemoryStorage()
+	Register("urn:test/Auth", sessionHandler("auth"), shared)
+	newServer([]ServeOption{WithNamespaceIsolation()})
+	Register("urn:test/Cart", sessionHandler("cart"), shared)
+
+	// Atomic groups write through the namespace.
+	if _, err := NewAtomicGroup().
+		Add("urn:test/Auth", "start", map[string]any{"id": "s1"}).
+		Add("urn:test/Cart", "start", map[string]any{"id": "s2"}).
+		Execute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, rel := range []string{"urn:test/Auth:sessions", "urn:test/Cart:sessions"} {
+		if got := shared.Find(rel, nil); len(got) != 1 {
+			t.Errorf("%s holds %v", rel, got)
+		}
+	}
+
+	// Consistency checks see only the concept's own relations.
+	var seen []string
+	ConsistencyCheck("urn:test/Auth", []ConsistencyRule{func(relation, key string, value map[string]any, storage Storage) *ConsistencyViolation {
+		seen = append(seen, relation+"/"+key)
+		return nil
+	}})
+	if len(seen) != 1 || seen[0] != "sessions/s1" {
+		t.Errorf("consistency check saw %v", seen)
+	}
+}
+
+func TestWithoutNamespaceIsolationSharedStorageMixes(t *testing.T) {
+	resetRegistry()
+	shared := NewInMemoryStorage()
+	Register("urn:test/Auth", sessionHandler("auth"), shared)
+	Register("urn:test/Cart", sessionHandler("cart"), shared)
+	s := newServer(nil)
+	ctx := context.Background()
+
+	s.dispatch(ctx, ActionInvocation{Concept: "urn:test/Auth", Action: "start", Input: map[string]any{"id": "a"}})
+	s.dispatch(ctx, ActionInvocation{Concept: "urn:test/Cart", Action: "start", Input: map[string]any{"id": "b"}})
+	if got := s.query(ConceptQuery{Concept: "urn:test/Auth", Relation: "sessions"}); len(got) != 2 {
+		t.Errorf("expected shared relation without isolation, got %v", got)
+	}
+}
+
+func TestNamespacedStorageEnumerable(t *testing.T) {
+	inner := NewInMemoryStorage()
+	a := NamespacedStorage("a", inner)
+	b := NamespacedStorage("b", inner)
+	a.Put("users", "u1", map[string]any{})
+	b.Put("posts", "p1", map[string]any{})
+
+	enum := a.(Enumerable)
+	if rels := enum.Relations(); len(rels) != 1 || rels[0] != "users" {
+		t.Errorf("expected only a's relations, got %v", rels)
+	}
+	if keys := enum.Keys("users"); len(keys) != 1 || keys[0] != "u1" {
+		t.Errorf("unexpected keys %v", keys)
+	}
+	if _, ok := b.Get("users", "u1"); ok {
+		t.Error("namespace b should not see a's users")
+	}
+}
@@ -0,0 +1,178 @@
+package clef
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ActionSpec describes one action of a concept for generated API docs.
+// Schemas are JSON Schema objects; nil means any object.
+type ActionSpec struct {
+	Name         string
+	Description  string
+	InputSchema  map[string]any
+	OutputSchema map[string]any
+}
+
+// Introspectable is an optional interface for handlers that describe
+// their actions. GET /openapi.json documents every registered concept
+// whose handler implements it.
+type Introspectable interface {
+	ActionSpecs() []ActionSpec
+}
+
+// ConceptSlug returns the path segment used for uri in
+// /invoke/{concept}/{action}: the URI without its "urn:" scheme, with
+// every character other than letters and digits replaced by "-".
+func ConceptSlug(uri string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '-'
+	}, strings.TrimPrefix(uri, "urn:"))
+}
+
+// OpenAPISpec builds an OpenAPI 3.0 document for the registered
+// Introspectable concepts. Each action is documented as
+// POST /invoke/{concept}/{action}, tagged with its concept URI, taking
+// the action input as the request body and returning the completion.
+func OpenAPISpec() map[string]any {
+	uris := make([]string, 0, len(registry))
+	for uri, entry := range registry {
+		if _, ok := entry.handler.(Introspectable); ok {
+			uris = append(uris, uri)
+		}
+	}
+	sort.Strings(uris)
+
+	paths := map[string]any{}
+	tags := make([]any, 0, len(uris))
+	for _, uri := range uris {
+		slug := ConceptSlug(uri)
+		tags = append(tags, map[string]any{"name": uri})
+		for _, spec := range registry[uri].handler.(Introspectable).ActionSpecs() {
+			paths["/invoke/"+slug+"/"+spec.Name] = map[string]any{
+				"post": openAPIOperation(uri, slug, spec),
+			}
+		}
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "Clef concepts",
+			"version": "1.0.0",
+		},
+		"tags":  tags,
+		"paths": paths,
+	}
+}
+
+func openAPIOperation(uri, slug string, spec ActionSpec) map[string]any {
+	input := spec.InputSchema
+	if input == nil {
+		input = map[string]any{"type": "object"}
+	}
+	output := spec.OutputSchema
+	if output == nil {
+		output = map[string]any{"type": "object"}
+	}
+	completion := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"id":        map[string]any{"type": "string"},
+			"concept":   map[string]any{"type": "string"},
+			"action":    map[string]any{"type": "string"},
+			"input":     input,
+			"variant":   map[string]any{"type": "string"},
+			"output":    output,
+			"flow":      map[string]any{"type": "string"},
+			"timestamp": map[string]any{"type": "string", "format": "date-time"},
+		},
+	}
+
+	op := map[string]any{
+		"operationId": slug + "." + spec.Name,
+		"tags":        []any{uri},
+		"requestBody": map[string]any{
+			"required": true,
+			"content":  map[string]any{"application/json": map[string]any{"schema": input}},
+		},
+		"responses": map[string]any{
+			"200": map[string]any{
+				"description": "Completion",
+				"content":     map[string]any{"application/json": map[string]any{"schema": completion}},
+			},
+			"default": map[string]any{
+				"description": "Error completion",
+				"content":     map[string]any{"application/json": map[string]any{"schema": completion}},
+			},
+		},
+	}
+	if spec.Description != "" {
+		op["summary"] = spec.Description
+	}
+	return op
+}
+
+func (s *server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.writeJSON(w, r, OpenAPISpec())
+}
+
+// handleInvokeAction serves POST /invoke/{concept}/{action}, the
+// per-action form of /invoke documented by /openapi.json. The body is the
+// action input.
+func (s *server) handleInvokeAction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	slug := r.PathValue("concept")
+	inv := ActionInvocation{Concept: conceptForSlug(slug), Action: r.PathValue("action")}
+	if inv.Concept == "" {
+		http.Error(w, "unknown concept: "+slug, http.StatusNotFound)
+		return
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&inv.Input); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	s.serveInvocation(w, r, inv)
+}
+
+// conceptForSlug returns the registered URI whose ConceptSlug is slug, or
+// "" if there is none. Registration rejects URIs with the same slug (see
+// checkSlug), so there is at most one.
+func conceptForSlug(slug string) string {
+	for uri := range registry {
+		if ConceptSlug(uri) == slug {
+			return uri
+		}
+	}
+	return ""
+}
+
+// checkSlug returns an error if a concept other than uri is registered
+// with the same ConceptSlug, which would make the per-concept routes
+// ambiguous.
+func checkSlug(uri string) error {
+	slug := ConceptSlug(uri)
+	for other := range registry {
+		if other != uri && ConceptSlug(other) == slug {
+			return fmt.Errorf("clef: %s and %s share the route slug %q", uri, other, slug)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package clef
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// articleSpecHandler is an echoHandler that describes two actions.
+type articleSpecHandler struct{ echoHandler }
+
+func (articleSpecHandler) ActionSpecs() []ActionSpec {
+	return []ActionSpec{
+		{
+			Name:         "echo",
+			Description:  "Echo a message",
+			InputSchema:  map[string]any{"type": "object", "properties": map[string]any{"message": map[string]any{"type": "string"}}},
+			OutputSchema: map[string]any{"type": "object", "properties": map[string]any{"message": map[string]any{"type": "string"}}},
+		},
+		{Name: "fail"},
+	}
+}
+
+func TestOpenAPIDocumentsIntrospectableConcepts(t *testing.T) {
+	resetRegistry()
+	Register("urn:app/Article", &articleSpecHandler{}, nil)
+	Register("urn:app/Hidden", &echoHandler{}, nil)
+
+	rec := doRequest(NewHandler(), http.MethodGet, "/openapi.json", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var spec struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+			RequestBody struct {
+				Content map[string]struct {
+					Schema map[string]any `json:"schema"`
+				} `json:"content"`
+			} `json:"requestBody"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &spec); err != nil {
+		t.Fatal(err)
+	}
+	if spec.OpenAPI != "3.0.3" {
+		t.Errorf("unexpected openapi version %q", spec.OpenAPI)
+	}
+	if len(spec.Paths) != 2 {
+		t.Errorf("expected 2 paths, got %v", spec.Paths)
+	}
+	want := map[string]string{
+		"/invoke/app-Article/echo": "app-Article.echo",
+		"/invoke/app-Article/fail": "app-Article.fail",
+	}
+	for path, opID := range want {
+		op, ok := spec.Paths[path]["post"]
+		if !ok {
+			t.Errorf("missing POST %s", path)
+			continue
+		}
+		if op.OperationID != opID {
+			t.Errorf("%s: expected operationId %q, got %q", path, opID, op.OperationID)
+		}
+	}
+	schema := spec.Paths["/invoke/app-Article/echo"]["post"].RequestBody.Content["application/json"].Schema
+	if props, _ := schema["properties"].(map[string]any); props["message"] == nil {
+		t.Errorf("expected input schema in request body, got %v", schema)
+	}
+}
+
+func TestInvokeActionPath(t *testing.T) {
+	resetRegistry()
+	Register("urn:app/Article", &articleSpecHandler{}, nil)
+	h := NewHandler()
+
+	rec := doRequest(h, http.MethodPost, "/invoke/app-Article/echo", `{"message":"hi"}`)
+	var c ActionCompletion
+	if err := json.Unmarshal(rec.Body.Bytes(), &c); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || c.Concept != "urn:app/Article" || c.Output["message"] != "hi" {
+		t.Errorf("unexpected response %d %+v", rec.Code, c)
+	}
+	if rec := doRequest(h, http.MethodPost, "/invoke/app-Missing/echo", `{}`); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown slug, got %d", rec.Code)
+	}
+}
+
+func TestRegisterRejectsSlugCollision(t *testing.T) {
+	resetRegistry()
+	if err := Register("urn:app.v1/Article", &articleSpecHandler{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := Register("urn:app-v1/Article", &articleSpecHandler{}, nil); err == nil {
+		t.Fatal("registered a concept whose slug collides with another's")
+	}
+	if _, ok := registry["urn:app-v1/Article"]; ok {
+		t.Error("colliding concept was registered")
+	}
+	if err := Register("urn:app.v1/Article", &articleSpecHandler{}, nil); err != nil {
+		t.Errorf("re-registering the same URI: %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package clef
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// InvokeLocal runs inv against the registered concepts in-process,
+// without a transport. Called from within an invocation, it dispatches
+// through the same server, so its options (ACLs, namespace isolation,
+// quotas, tenants, flow storage) apply as they do over HTTP; otherwise
+// only aliases and per-concept options apply. An empty inv.Flow is taken
+// from the invocation in ctx, if any, so nested calls stay in the
+// caller's flow.
+func InvokeLocal(ctx context.Context, inv ActionInvocation) ActionCompletion {
+	if inv.Flow == "" {
+		if parent, ok := InvocationFromContext(ctx); ok {
+			inv.Flow = parent.Flow
+		}
+	}
+	s, ok := ctx.Value(serverKey{}).(*server)
+	if !ok {
+		s = &server{}
+	}
+	return s.dispatch(ctx, inv)
+}
+
+// PipelineStep names one concept action in a ConceptPipeline.
+type PipelineStep struct {
+	Concept string
+	Action  string
+}
+
+// ConceptPipeline runs concept actions in sequence, passing each step's
+// output (without its variant) as the next step's input. All steps share
+// one flow.
+//
+// Example:
+//
+//	out, err := clef.NewConceptPipeline().
+//	    Then("urn:app/Order", "place").
+//	    Then("urn:app/Inventory", "debit").
+//	    Then("urn:app/Payment", "charge").
+//	    OnError(clef.PipelineStep{Concept: "urn:app/Order", Action: "cancel"}).
+//	    Execute(ctx, map[string]any{"sku": "A1", "qty": 2})
+type ConceptPipeline struct {
+	steps         []PipelineStep
+	compensations []PipelineStep
+}
+
+// NewConceptPipeline returns an empty pipeline.
+func NewConceptPipeline() *ConceptPipeline {
+	return &ConceptPipeline{}
+}
+
+// Then appends a step.
+func (p *ConceptPipeline) Then(concept, action string) *ConceptPipeline {
+	p.steps = append(p.steps, PipelineStep{Concept: concept, Action: action})
+	return p
+}
+
+// OnError sets the compensation chain run when a step fails. It runs like
+// a pipeline of its own, starting from the input of the failed step.
+func (p *ConceptPipeline) OnError(compensations ...PipelineStep) *ConceptPipeline {
+	p.compensations = compensations
+	return p
+}
+
+// PipelineError reports the step at which a ConceptPipeline stopped.
+type PipelineError struct {
+	// Step is the zero-based index of the failed step.
+	Step    int
+	Concept string
+	Action  string
+	// Output is the failed step's output.
+	Output map[string]any
+}
+
+func (e *PipelineError) Error() string {
+	return fmt.Sprintf("clef: pipeline step %d (%s/%s) failed: %v", e.Step, e.Concept, e.Action, e.Output["message"])
+}
+
+// Execute runs the steps in order starting from initialInput and returns
+// the last step's output. If a step completes with any variant other than
+// "ok", such as "error" or "precondition_failed", Execute stops, runs the compensation chain, and returns the step's
+// output with a *PipelineError, joined with any compensation failure.
+func (p *ConceptPipeline) Execute(ctx context.Context, initialInput map[string]any) (map[string]any, error) {
+	flow := ""
+	if parent, ok := InvocationFromContext(ctx); ok {
+		flow = parent.Flow
+	}
+
+	input := initialInput
+	for i, step := range p.steps {
+		c := InvokeLocal(ctx, ActionInvocation{Concept: step.Concept, Action: step.Action, Input: input, Flow: flow})
+		flow = c.Flow
+		if c.Variant != "ok" {
+			err := error(&PipelineError{Step: i, Concept: step.Concept, Action: step.Action, Output: c.Output})
+			if cerr := p.compensate(ctx, flow, input); cerr != nil {
+				err = errors.Join(err, cerr)
+			}
+			return c.Output, err
+		}
+		input = withoutVariant(c.Output)
+	}
+	return input, nil
+}
+
+// compensate runs the compensation chain, stopping at its first failure.
+func (p *ConceptPipeline) compensate(ctx context.Context, flow string, input map[string]any) error {
+	for _, step := range p.compensations {
+		c := InvokeLocal(ctx, ActionInvocation{Concept: step.Concept, Action: step.Action, Input: input, Flow: flow})
+		if c.Variant != "ok" {
+			return fmt.Errorf("clef: compensation %s/%s failed: %v", step.Concept, step.Action, c.Output["message"])
+		}
+		input = withoutVariant(c.Output)
+	}
+	return nil
+}
+
+// withoutVariant copies output without its "variant" field.
+func withoutVariant(output map[string]any) map[string]any {
+	next := make(map[string]any, len(output))
+	for k, v := range output {
+		if k != "variant" {
+			next[k] = v
+		}
+	}
+	return next
+}
@@ -0,0 +1,133 @@
+package clef
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestInvokeLocal(t *testing.T) {
+	resetRegistry()
+	Register("urn:test/Echo", &echoHandler{}, nil)
+
+	parent := ContextWithInvocation(context.Background(), ActionInvocation{Flow: "flow-1"})
+	c := InvokeLocal(parent, ActionInvocation{Concept: "urn:test/Echo", Action: "echo", Input: map[string]any{"message": "hi"}})
+	if c.Variant != "ok" || c.Output["message"] != "hi" || c.Flow != "flow-1" || c.ID == "" {
+		t.Errorf("unexpected completion %+v", c)
+	}
+	if c := InvokeLocal(context.Background(), ActionInvocation{Concept: "urn:test/Missing", Action: "x"}); c.Output["code"] != "not_found" {
+		t.Errorf("expected not_found, got %+v", c)
+	}
+}
+
+func TestConceptPipelineRunsStepsInSequence(t *testing.T) {
+	resetRegistry()
+	var flows []string
+	step := func(field string) HandlerFunc {
+		return func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+			inv, _ := InvocationFromContext(ctx)
+			flows = append(flows, inv.Flow)
+			out := map[string]any{"variant": "ok", field: true}
+			for k, v := range input {
+				out[k] = v
+			}
+			return out
+		}
+	}
+	Register("urn:test/Order", step("placed"), nil)
+	Register("urn:test/Inventory", step("debited"), nil)
+
+	out, err := NewConceptPipeline().
+		Then("urn:test/Order", "place").
+		Then("urn:test/Inventory", "debit").
+		Execute(context.Background(), map[string]any{"sku": "A1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out["sku"] != "A1" || out["placed"] != true || out["debited"] != true {
+		t.Errorf("expected outputs threaded through steps, got %v", out)
+	}
+	if _, ok := out["variant"]; ok {
+		t.Error("expected variant stripped from pipeline output")
+	}
+	if len(flows) != 2 || flows[0] == "" || flows[0] != flows[1] {
+		t.Errorf("expected steps to share one flow, got %v", flows)
+	}
+}
+
+func TestConceptPipelineCompensatesOnError(t *testing.T) {
+	resetRegistry()
+	var compensated map[string]any
+	Register("urn:test/Order", HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		switch action {
+		case "place":
+			return map[string]any{"variant": "ok", "orderId": "o-1"}
+		case "cancel":
+			compensated = input
+			return map[string]any{"variant": "ok"}
+		}
+		return map[string]any{"variant": "error", "message": "unknown action"}
+	}), nil)
+	Register("urn:test/Payment", HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		return map[string]any{"variant": "error", "code": "card_declined", "message": "card declined"}
+	}), nil)
+
+	out, err := NewConceptPipeline().
+		Then("urn:test/Order", "place").
+		Then("urn:test/Payment", "charge").
+		OnError(PipelineStep{Concept: "urn:test/Order", Action: "cancel"}).
+		Execute(context.Background(), map[string]any{"sku": "A1"})
+
+	var perr *PipelineError
+	if !errors.As(err, &perr) || perr.Step != 1 || perr.Action != "charge" {
+		t.Fatalf("expected PipelineError at step 1, got %v", err)
+	}
+	if out["code"] != "card_declined" {
+		t.Errorf("expected failed step output, got %v", out)
+	}
+	if compensated == nil || compensated["orderId"] != "o-1" {
+		t.Errorf("expected cancel compensation with the order id, got %v", compensated)
+	}
+}
+
+func TestConceptPipelineStopsOnGuardVariant(t *testing.T) {
+	resetRegistry()
+	var opts ConceptOptions
+	opts.Precondition("charge", func(Storage) error { return errors.New("no card on file") })
+	RegisterWithOptions("urn:test/Payment", &echoHandler{}, nil, opts)
+	Register("urn:test/Receipt", HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		t.Error("step after a failed precondition ran")
+		return map[string]any{"variant": "ok"}
+	}), nil)
+
+	_, err := NewConceptPipeline().
+		Then("urn:test/Payment", "charge").
+		Then("urn:test/Receipt", "send").
+		Execute(context.Background(), map[string]any{})
+	var perr *PipelineError
+	if !errors.As(err, &perr) || perr.Step != 0 {
+		t.Fatalf("expected PipelineError at step 0, got %v", err)
+	}
+}
+
+func TestInvokeLocalUsesCallingServer(t *testing.T) {
+	resetRegistry()
+	Register("urn:test/Secret", &echoHandler{}, nil)
+	Register("urn:test/Proxy", HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		c := InvokeLocal(ctx, ActionInvocation{Concept: "urn:test/Secret", Action: "echo", Input: input})
+		return map[string]any{"variant": "ok", "inner": c.Variant}
+	}), nil)
+	h := NewHandler(WithACL(func(ctx context.Context, concept, action string, claims map[string]any) bool {
+		return concept != "urn:test/Secret"
+	}))
+
+	var c ActionCompletion
+	rec := doRequest(h, "POST", "/invoke", `{"concept":"urn:test/Proxy","action":"call","input":{"message":"hi"}}`)
+	if err := json.Unmarshal(rec.Body.Bytes(), &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Output["inner"] != "error" {
+		t.Fatalf("nested call bypassed the server's ACL: %v", c.Output)
+	}
+}
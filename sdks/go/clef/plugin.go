@@ -0,0 +1,88 @@
+package clef
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"plugin"
+	"sort"
+	"sync"
+)
+
+// PluginSymbol is the function a concept plugin must export. It returns
+// the plugin's handlers keyed by concept URI.
+const PluginSymbol = "ConceptHandlers"
+
+// LoadPlugin opens the Go plugin at path (built with
+// -buildmode=plugin), calls its ConceptHandlers function and registers
+// each returned handler with in-memory storage. The plugin must be built
+// with the same Go toolchain and the same version of this SDK as the
+// host; cmd/copf-plugin is a starting point.
+func LoadPlugin(path string) error {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return fmt.Errorf("clef: loading plugin %s: %w", path, err)
+	}
+	sym, err := p.Lookup(PluginSymbol)
+	if err != nil {
+		return fmt.Errorf("clef: loading plugin %s: %w", path, err)
+	}
+	fn, ok := sym.(func() map[string]ConceptHandler)
+	if !ok {
+		return fmt.Errorf("clef: loading plugin %s: %s is %T, want func() map[string]clef.ConceptHandler", path, PluginSymbol, sym)
+	}
+
+	handlers := fn()
+	uris := make([]string, 0, len(handlers))
+	for uri := range handlers {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+	for _, uri := range uris {
+		if err := Register(uri, handlers[uri], nil); err != nil {
+			return fmt.Errorf("clef: loading plugin %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// dirPlugins holds the paths WithPluginDir has loaded, so servers created
+// later do not register the plugins' handlers again and replace their
+// storage.
+var (
+	dirPluginsMu sync.Mutex
+	dirPlugins   = make(map[string]bool)
+)
+
+// WithPluginDir loads every .so file in dir with LoadPlugin when the
+// server starts. Each file is loaded once per process, however many
+// servers use dir. A plugin that fails to load is logged and skipped.
+func WithPluginDir(dir string) ServeOption {
+	return func(c *ServerConfig) {
+		c.pluginDirs = append(c.pluginDirs, dir)
+	}
+}
+
+// loadPluginDir loads the plugins in dir, logging failures.
+func loadPluginDir(dir string) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.so"))
+	if err != nil {
+		log.Printf("clef: plugin dir %s: %v", dir, err)
+		return
+	}
+	dirPluginsMu.Lock()
+	defer dirPluginsMu.Unlock()
+	for _, path := range paths {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		if dirPlugins[path] {
+			continue
+		}
+		if err := LoadPlugin(path); err != nil {
+			log.Print(err)
+			continue
+		}
+		dirPlugins[path] = true
+	}
+}
@@ -0,0 +1,232 @@
+package clef
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// changeLogSize is how many recent changes InMemoryStorage keeps per
+// polled relation for WaitForChanges.
+const changeLogSize = 1024
+
+// ErrPollGap is returned by WaitForChanges when changes after the
+// caller's last sequence number are no longer known, because it fell
+// behind the change log or polls the relation for the first time. The
+// caller must reload the relation and wait from the sequence number
+// returned with the error.
+var ErrPollGap = errors.New("clef: changes after last_seq are no longer available")
+
+// DefaultPollTimeout is how long POST /poll waits for a change.
+const DefaultPollTimeout = 30 * time.Second
+
+// Pollable is implemented by storages that number their mutations, so
+// clients can wait for changes after the last one they saw.
+type Pollable interface {
+	// WaitForChanges returns the changes to relation with a sequence
+	// number above afterSeq, waiting until there is at least one or ctx
+	// is done. It also returns the storage's latest sequence number, and
+	// ErrPollGap if some of the changes after afterSeq are not known.
+	WaitForChanges(ctx context.Context, relation string, afterSeq uint64) ([]WatchEvent, uint64, error)
+}
+
+// changeLog holds the recent changes of a polled relation: every change
+// with a sequence number above since.
+type changeLog struct {
+	events []WatchEvent
+	since  uint64
+}
+
+// record numbers ev and adds it to the relation's change log, if it has
+// been polled, waking waiters. The caller holds s.mu for writing.
+func (s *InMemoryStorage) record(relation string, ev *WatchEvent) {
+	s.mutationSeq++
+	ev.Seq = s.mutationSeq
+	log, ok := s.changes[relation]
+	if !ok {
+		return
+	}
+	log.events = append(log.events, *ev)
+	if len(log.events) > changeLogSize {
+		dropped := len(log.events) - changeLogSize
+		log.since = log.events[dropped-1].Seq
+		log.events = append(log.events[:0:0], log.events[dropped:]...)
+	}
+	if s.changed != nil {
+		close(s.changed)
+		s.changed = nil
+	}
+}
+
+// WaitForChanges implements Pollable. The changes of a relation are only
+// kept from its first poll on, and only the last 1024 of them, so a first
+// poll, or one further behind, gets ErrPollGap.
+func (s *InMemoryStorage) WaitForChanges(ctx context.Context, relation string, afterSeq uint64) ([]WatchEvent, uint64, error) {
+	for {
+		s.mu.Lock()
+		log, ok := s.changes[relation]
+		if !ok {
+			if s.changes == nil {
+				s.changes = make(map[string]*changeLog)
+			}
+			log = &changeLog{since: s.mutationSeq}
+			s.changes[relation] = log
+		}
+		seq := s.mutationSeq
+		if afterSeq < log.since {
+			s.mu.Unlock()
+			return nil, seq, ErrPollGap
+		}
+		i := len(log.events)
+		for i > 0 && log.events[i-1].Seq > afterSeq {
+			i--
+		}
+		if i < len(log.events) {
+			out := append([]WatchEvent(nil), log.events[i:]...)
+			s.mu.Unlock()
+			return out, seq, nil
+		}
+		if s.changed == nil {
+			s.changed = make(chan struct{})
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, seq, nil
+		}
+	}
+}
+
+// WaitForChanges implements Pollable when the inner storage does.
+func (s *namespacedStorage) WaitForChanges(ctx context.Context, relation string, afterSeq uint64) ([]WatchEvent, uint64, error) {
+	return waitForChanges(ctx, s.inner, s.prefix+relation, afterSeq)
+}
+
+// WaitForChanges implements Pollable when the inner storage does.
+func (s *QuotaStorage) WaitForChanges(ctx context.Context, relation string, afterSeq uint64) ([]WatchEvent, uint64, error) {
+	return waitForChanges(ctx, s.Storage, relation, afterSeq)
+}
+
+// WaitForChanges implements Pollable when the inner storage does.
+func (s *WALStorage) WaitForChanges(ctx context.Context, relation string, afterSeq uint64) ([]WatchEvent, uint64, error) {
+	return waitForChanges(ctx, s.Storage, relation, afterSeq)
+}
+
+// WaitForChanges implements Pollable when the inner storage does.
+func (s *AuditStorage) WaitForChanges(ctx context.Context, relation string, afterSeq uint64) ([]WatchEvent, uint64, error) {
+	return waitForChanges(ctx, s.Storage, relation, afterSeq)
+}
+
+// WaitForChanges implements Pollable when the inner storage does.
+func (s *LineageStorage) WaitForChanges(ctx context.Context, relation string, afterSeq uint64) ([]WatchEvent, uint64, error) {
+	return waitForChanges(ctx, s.Storage, relation, afterSeq)
+}
+
+// WaitForChanges implements Pollable when the inner storage does. The
+// values of put events are decrypted; one that fails to decrypt is
+// reported and left out of its event.
+func (s *encryptedStorage) WaitForChanges(ctx context.Context, relation string, afterSeq uint64) ([]WatchEvent, uint64, error) {
+	changes, seq, err := waitForChanges(ctx, s.inner, relation, afterSeq)
+	for i, ev := range changes {
+		changes[i].Value, _ = s.open(relation, ev.Key, ev.Value)
+	}
+	return changes, seq, err
+}
+
+// waitForChanges calls storage's WaitForChanges, or returns at once if it
+// is not Pollable.
+func waitForChanges(ctx context.Context, storage Storage, relation string, afterSeq uint64) ([]WatchEvent, uint64, error) {
+	p, ok := storage.(Pollable)
+	if !ok {
+		return nil, 0, nil
+	}
+	return p.WaitForChanges(ctx, relation, afterSeq)
+}
+
+// canPoll reports whether storage, seen through the decorators that
+// pass WaitForChanges through, supports it.
+func canPoll(storage Storage) bool {
+	switch s := storage.(type) {
+	case *namespacedStorage:
+		return canPoll(s.inner)
+	case *QuotaStorage:
+		return canPoll(s.Storage)
+	case *WALStorage:
+		return canPoll(s.Storage)
+	case *AuditStorage:
+		return canPoll(s.Storage)
+	case *LineageStorage:
+		return canPoll(s.Storage)
+	case *encryptedStorage:
+		return canPoll(s.inner)
+	}
+	_, ok := storage.(Pollable)
+	return ok
+}
+
+// WithPollTimeout sets how long POST /poll holds a request open waiting
+// for a change. The default is DefaultPollTimeout.
+func WithPollTimeout(d time.Duration) ServeOption {
+	return func(c *ServerConfig) {
+		c.pollTimeout = d
+	}
+}
+
+// PollRequest is the body of POST /poll.
+type PollRequest struct {
+	Concept  string `json:"concept"`
+	Relation string `json:"relation"`
+	LastSeq  uint64 `json:"last_seq"`
+}
+
+// PollResponse is the reply to POST /poll. Changes is empty when the poll
+// timed out; Seq is the value to send as last_seq next time. Resync is
+// set, with no changes, when the changes after last_seq are no longer
+// known (see ErrPollGap): the client reloads the relation before polling
+// from Seq.
+type PollResponse struct {
+	Changes []WatchEvent `json:"changes"`
+	Seq     uint64       `json:"seq"`
+	Resync  bool         `json:"resync,omitempty"`
+}
+
+// handleLongPoll serves POST /poll, a long-polling alternative to
+// WebSockets: it replies as soon as the relation has changes after
+// last_seq, or with no changes once the poll timeout elapses.
+func (s *server) handleLongPoll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req PollRequest
+	if err := decodeBody(r, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entry, ok := registry[req.Concept]
+	if !ok {
+		http.Error(w, "unknown concept: "+req.Concept, http.StatusNotFound)
+		return
+	}
+	storage := s.wrapStorage(req.Concept, entry).storage
+	if !canPoll(storage) {
+		http.Error(w, "storage of "+req.Concept+" does not support polling", http.StatusNotImplemented)
+		return
+	}
+
+	timeout := s.config.pollTimeout
+	if timeout <= 0 {
+		timeout = DefaultPollTimeout
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	changes, seq, err := waitForChanges(ctx, storage, req.Relation, req.LastSeq)
+	if changes == nil {
+		changes = []WatchEvent{}
+	}
+	s.writeJSON(w, r, PollResponse{Changes: changes, Seq: seq, Resync: errors.Is(err, ErrPollGap)})
+}
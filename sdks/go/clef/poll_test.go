@@ -0,0 +1,151 @@
+package clef
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func pollRequest(t *testing.T, h http.Handler, lastSeq uint64) (PollResponse, int) {
+	t.Helper()
+	rec := doRequest(h, "POST", "/poll", `{"concept":"urn:test/Feed","relation":"items","last_seq":`+strconv.FormatUint(lastSeq, 10)+`}`)
+	var resp PollResponse
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp, rec.Code
+}
+
+func TestLongPollReturnsOnWrite(t *testing.T) {
+	resetRegistry()
+	storage := NewInMemoryStorage()
+	Register("urn:test/Feed", &echoHandler{}, storage)
+	storage.Put("items", "old", map[string]any{"n": 0})
+	h := NewHandler(WithPollTimeout(5 * time.Second))
+
+	// The first poll of a relation asks the client to resync: the write
+	// before it is not in the change log.
+	resp, _ := pollRequest(t, h, 0)
+	if !resp.Resync || len(resp.Changes) != 0 || resp.Seq == 0 {
+		t.Fatalf("initial poll = %+v", resp)
+	}
+
+	type result struct {
+		resp PollResponse
+		at   time.Time
+	}
+	done := make(chan result, 1)
+	go func() {
+		r, _ := pollRequest(t, h, resp.Seq)
+		done <- result{r, time.Now()}
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	storage.Put("other", "x", map[string]any{"n": 1}) // different relation
+	wrote := time.Now()
+	storage.Put("items", "new", map[string]any{"n": 2})
+
+	select {
+	case r := <-done:
+		if d := r.at.Sub(wrote); d > 500*time.Millisecond {
+			t.Errorf("poll returned %v after the write", d)
+		}
+		if len(r.resp.Changes) != 1 || r.resp.Changes[0].Key != "new" || r.resp.Changes[0].Event != "put" {
+			t.Fatalf("changes = %+v", r.resp.Changes)
+		}
+		if r.resp.Seq <= resp.Seq || r.resp.Changes[0].Seq != r.resp.Seq {
+			t.Errorf("seq = %d (change %d), previous %d", r.resp.Seq, r.resp.Changes[0].Seq, resp.Seq)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("poll did not return after write")
+	}
+}
+
+func TestLongPollTimesOut(t *testing.T) {
+	resetRegistry()
+	storage := NewInMemoryStorage()
+	Register("urn:test/Feed", &echoHandler{}, storage)
+	storage.Put("items", "a", map[string]any{"n": 1})
+	h := NewHandler(WithPollTimeout(100 * time.Millisecond))
+
+	first, _ := pollRequest(t, h, 0)
+	start := time.Now()
+	resp, code := pollRequest(t, h, first.Seq)
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("poll returned after %v, want the full timeout", elapsed)
+	}
+	if code != http.StatusOK || len(resp.Changes) != 0 || resp.Seq != first.Seq {
+		t.Fatalf("timed-out poll = %d %+v", code, resp)
+	}
+}
+
+func TestWaitForChangesHonoursContext(t *testing.T) {
+	s := NewInMemoryStorage()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if changes, seq, err := s.WaitForChanges(ctx, "items", 0); changes != nil || seq != 0 || err != nil {
+		t.Fatalf("WaitForChanges = %v, %d, %v", changes, seq, err)
+	}
+}
+
+func TestWaitForChangesReportsGap(t *testing.T) {
+	s := NewInMemoryStorage()
+	s.Put("items", "a", map[string]any{"n": 0})
+	if len(s.changes) != 0 {
+		t.Fatal("change log kept before the first poll")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, seq, err := s.WaitForChanges(ctx, "items", 0)
+	if !errors.Is(err, ErrPollGap) {
+		t.Fatalf("first poll after a write: err = %v, want ErrPollGap", err)
+	}
+
+	for i := range changeLogSize + 1 {
+		s.Put("items", "a", map[string]any{"n": i})
+	}
+	if _, _, err := s.WaitForChanges(ctx, "items", seq); !errors.Is(err, ErrPollGap) {
+		t.Errorf("poller behind the change log: err = %v, want ErrPollGap", err)
+	}
+	changes, _, err := s.WaitForChanges(ctx, "items", seq+1)
+	if err != nil || len(changes) != changeLogSize {
+		t.Errorf("poller at the start of the change log: %d changes, err %v", len(changes), err)
+	}
+}
+
+func TestLongPollThroughStorageDecorators(t *testing.T) {
+	var key [32]byte
+	decorators := map[string]func(Storage) Storage{
+		"audit":     func(s Storage) Storage { return NewAuditStorage(s, NopAuditSink()) },
+		"lineage":   func(s Storage) Storage { return DataLineage(s, NewInMemoryStorage()) },
+		"encrypted": func(s Storage) Storage { return EncryptedStorage(s, key) },
+		"wal":       func(s Storage) Storage { return NewWALStorage(s, &bytes.Buffer{}) },
+	}
+	for name, wrap := range decorators {
+		resetRegistry()
+		storage := wrap(NewInMemoryStorage())
+		Register("urn:test/Feed", &echoHandler{}, storage)
+		h := NewHandler(WithPollTimeout(time.Millisecond))
+
+		resp, code := pollRequest(t, h, 0)
+		if code != http.StatusOK {
+			t.Errorf("%s: initial poll: status %d", name, code)
+			continue
+		}
+		storage.Put("items", "new", map[string]any{"n": 2})
+		resp, _ = pollRequest(t, h, resp.Seq)
+		if len(resp.Changes) != 1 || resp.Changes[0].Key != "new" || resp.Changes[0].Value["n"] != float64(2) {
+			t.Errorf("%s: changes = %+v", name, resp.Changes)
+		}
+	}
+	if canPoll(NewAuditStorage(struct{ Storage }{NewInMemoryStorage()}, NopAuditSink())) {
+		t.Error("canPoll reports a decorator over a storage that cannot poll")
+	}
+}
@@ -0,0 +1,114 @@
+package clef
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	runtimepprof "runtime/pprof"
+	"strconv"
+	"strings"
+)
+
+// defaultProfileIterations is the iterations of /debug/concepts/profile
+// when the request does not set it.
+const defaultProfileIterations = 100
+
+// WithPprof enables the net/http/pprof handlers under /debug/pprof/ and
+// GET /debug/concepts/profile?concept=<uri>&action=<action>&iterations=<n>,
+// which runs one action n times (default 100, at most 100000) against its
+// registered storage and returns a CPU profile of the run. An optional
+// input parameter holds the action's input as JSON. Requests must carry
+// "Authorization: Bearer <adminToken>".
+//
+// The CPU profiler samples the whole process, so samples from concurrent
+// traffic are included; those from the profiled calls carry the pprof
+// labels concept and action, e.g. for go tool pprof -tagfocus.
+func WithPprof(adminToken string) ServeOption {
+	return func(c *ServerConfig) {
+		c.pprofToken = adminToken
+	}
+}
+
+// handlePprof serves /debug/pprof/ with the standard handlers.
+func (s *server) handlePprof(w http.ResponseWriter, r *http.Request) {
+	if !s.pprofAllowed(w, r) {
+		return
+	}
+	switch name := strings.TrimPrefix(r.URL.Path, "/debug/pprof/"); name {
+	case "cmdline":
+		pprof.Cmdline(w, r)
+	case "profile":
+		pprof.Profile(w, r)
+	case "symbol":
+		pprof.Symbol(w, r)
+	case "trace":
+		pprof.Trace(w, r)
+	default:
+		pprof.Index(w, r)
+	}
+}
+
+// handleConceptProfile serves GET /debug/concepts/profile.
+func (s *server) handleConceptProfile(w http.ResponseWriter, r *http.Request) {
+	if !s.pprofAllowed(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	inv := ActionInvocation{Concept: q.Get("concept"), Action: q.Get("action"), Input: map[string]any{}}
+	entry, ok := s.lookup(inv)
+	if !ok || inv.Action == "" {
+		http.Error(w, "unknown concept: "+inv.Concept, http.StatusNotFound)
+		return
+	}
+	if v := q.Get("input"); v != "" {
+		if err := json.Unmarshal([]byte(v), &inv.Input); err != nil {
+			http.Error(w, "input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	n := defaultProfileIterations
+	if v := q.Get("iterations"); v != "" {
+		var err error
+		if n, err = strconv.Atoi(v); err != nil || n < 1 || n > maxBenchIterations {
+			http.Error(w, "iterations must be between 1 and 100000", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var profile bytes.Buffer
+	if err := runtimepprof.StartCPUProfile(&profile); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	ctx := ContextWithInvocation(r.Context(), inv)
+	runtimepprof.Do(ctx, runtimepprof.Labels("concept", inv.Concept, "action", inv.Action), func(ctx context.Context) {
+		for range n {
+			callHandler(ctx, entry.handler, inv.Action, inv.Input, entry.storage)
+		}
+	})
+	runtimepprof.StopCPUProfile()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="profile"`)
+	w.Write(profile.Bytes())
+}
+
+// pprofAllowed writes 404 when pprof is disabled and 401 for a missing
+// or wrong token.
+func (s *server) pprofAllowed(w http.ResponseWriter, r *http.Request) bool {
+	if s.config.pprofToken == "" {
+		http.NotFound(w, r)
+		return false
+	}
+	if !authorized(r, s.config.pprofToken) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
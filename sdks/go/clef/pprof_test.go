@@ -0,0 +1,45 @@
+package clef
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPprofEndpoints(t *testing.T) {
+	resetRegistry()
+	Register("urn:test/Echo", &echoHandler{}, nil)
+	h := NewHandler(WithPprof("secret"))
+
+	get := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	profile := "/debug/concepts/profile?concept=urn:test/Echo&action=echo&iterations=10"
+
+	for _, path := range []string{"/debug/pprof/heap", profile} {
+		if rec := get(path, ""); rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s without token: %d, want 401", path, rec.Code)
+		}
+		if rec := get(path, "wrong"); rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s with wrong token: %d, want 401", path, rec.Code)
+		}
+		rec := get(path, "secret")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: %d %s", path, rec.Code, rec.Body)
+		}
+		if !bytes.HasPrefix(rec.Body.Bytes(), []byte("\x1f\x8b")) {
+			t.Errorf("%s did not return a gzipped pprof profile", path)
+		}
+	}
+
+	if rec := doRequest(NewHandler(), http.MethodGet, "/debug/pprof/heap", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("pprof without WithPprof: %d, want 404", rec.Code)
+	}
+}
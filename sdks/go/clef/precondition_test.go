@@ -0,0 +1,77 @@
+package clef
+
+import (
+	"errors"
+	"testing"
+)
+
+// userHandler creates users and counts how often it ran.
+type userHandler struct {
+	calls int
+}
+
+func (h *userHandler) Handle(action string, input map[string]any, storage Storage) map[string]any {
+	h.calls++
+	name, _ := input["name"].(string)
+	storage.Put("users", name, map[string]any{"name": name, "role": "admin"})
+	return map[string]any{"variant": "ok"}
+}
+
+func registerUsers(h *userHandler) Storage {
+	resetRegistry()
+	storage := NewInMemoryStorage()
+	var opts ConceptOptions
+	opts.Precondition("createAdmin", func(s Storage) error {
+		if len(s.Find("users", map[string]any{"role": "admin"})) > 0 {
+			return errors.New("an admin user already exists")
+		}
+		return nil
+	})
+	RegisterWithOptions("urn:test/User", h, storage, opts)
+	return storage
+}
+
+func TestPreconditionBlocksHandler(t *testing.T) {
+	h := &userHandler{}
+	storage := registerUsers(h)
+	storage.Put("users", "root", map[string]any{"name": "root", "role": "admin"})
+
+	c := invokeRecorder(t, `{"concept":"urn:test/User","action":"createAdmin","input":{"name":"eve"}}`)
+	if c.Variant != "precondition_failed" {
+		t.Fatalf("expected precondition_failed, got %s", c.Variant)
+	}
+	if c.Output["message"] != "an admin user already exists" {
+		t.Errorf("unexpected message %v", c.Output["message"])
+	}
+	if h.calls != 0 {
+		t.Errorf("handler must not run, ran %d times", h.calls)
+	}
+	if _, ok := storage.Get("users", "eve"); ok {
+		t.Error("blocked invocation must not write")
+	}
+}
+
+func TestPreconditionAllowsHandler(t *testing.T) {
+	h := &userHandler{}
+	registerUsers(h)
+
+	first := invokeRecorder(t, `{"concept":"urn:test/User","action":"createAdmin","input":{"name":"root"}}`)
+	if first.Variant != "ok" || h.calls != 1 {
+		t.Fatalf("expected first admin to be created, got %s", first.Variant)
+	}
+	second := invokeRecorder(t, `{"concept":"urn:test/User","action":"createAdmin","input":{"name":"eve"}}`)
+	if second.Variant != "precondition_failed" {
+		t.Errorf("expected second admin to be blocked, got %s", second.Variant)
+	}
+}
+
+func TestPreconditionOnlyAppliesToItsAction(t *testing.T) {
+	h := &userHandler{}
+	storage := registerUsers(h)
+	storage.Put("users", "root", map[string]any{"name": "root", "role": "admin"})
+
+	c := invokeRecorder(t, `{"concept":"urn:test/User","action":"createUser","input":{"name":"eve"}}`)
+	if c.Variant != "ok" || h.calls != 1 {
+		t.Errorf("expected other actions to run, got %s", c.Variant)
+	}
+}
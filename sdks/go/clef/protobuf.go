@@ -0,0 +1,603 @@
+package clef
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ContentTypeProtobuf selects the Protocol Buffers encoding on /invoke and
+// /query. Messages follow proto/clef.proto.
+const ContentTypeProtobuf = "application/protobuf"
+
+// MarshalProto encodes an *ActionInvocation, *ActionCompletion,
+// *ConceptQuery, query result ([]map[string]any) or bare Struct
+// (map[string]any) in the protobuf wire format of proto/clef.proto.
+func MarshalProto(v any) ([]byte, error) {
+	b := make([]byte, 0, protoSizeHint(v))
+	var err error
+	switch m := v.(type) {
+	case *ActionInvocation:
+		b = appendString(b, 1, m.ID)
+		b = appendString(b, 2, m.Concept)
+		b = appendString(b, 3, m.Action)
+		if b, err = appendStruct(b, 4, m.Input); err != nil {
+			return nil, err
+		}
+		b = appendString(b, 5, m.Flow)
+	case *ActionCompletion:
+		b = appendString(b, 1, m.ID)
+		b = appendString(b, 2, m.Concept)
+		b = appendString(b, 3, m.Action)
+		if b, err = appendStruct(b, 4, m.Input); err != nil {
+			return nil, err
+		}
+		b = appendString(b, 5, m.Variant)
+		if b, err = appendStruct(b, 6, m.Output); err != nil {
+			return nil, err
+		}
+		b = appendString(b, 7, m.Flow)
+		b = appendString(b, 8, m.Timestamp)
+		if m.Partial {
+			b = protowire.AppendTag(b, 9, protowire.VarintType)
+			b = protowire.AppendVarint(b, 1)
+		}
+		b = appendString(b, 10, m.AliasedFrom)
+		if m.CacheableOutput {
+			b = protowire.AppendTag(b, 11, protowire.VarintType)
+			b = protowire.AppendVarint(b, 1)
+		}
+	case *ConceptQuery:
+		b = appendString(b, 1, m.Concept)
+		b = appendString(b, 2, m.Relation)
+		if b, err = appendStruct(b, 3, m.Args); err != nil {
+			return nil, err
+		}
+	case map[string]any:
+		// A bare google.protobuf.Struct, e.g. the health report.
+		if m, _, err = normalizeStruct(m); err != nil {
+			return nil, err
+		}
+		b = appendStructFields(b, m)
+	case []map[string]any:
+		for _, rec := range m {
+			if rec == nil {
+				rec = map[string]any{}
+			}
+			if b, err = appendStruct(b, 1, rec); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("clef: cannot marshal %T as protobuf", v)
+	}
+	return b, nil
+}
+
+// UnmarshalProto decodes data produced by MarshalProto into v, which must
+// be an *ActionInvocation, *ActionCompletion, *ConceptQuery,
+// *[]map[string]any or *map[string]any.
+func UnmarshalProto(data []byte, v any) error {
+	d := newProtoDecoder(data)
+	if m, ok := v.(*map[string]any); ok {
+		var err error
+		*m, err = d.decodeStruct(data)
+		return err
+	}
+	return consumeFields(data, func(num protowire.Number, typ protowire.Type, val []byte, n uint64) error {
+		var err error
+		switch m := v.(type) {
+		case *ActionInvocation:
+			switch num {
+			case 1:
+				m.ID = d.str(val)
+			case 2:
+				m.Concept = d.str(val)
+			case 3:
+				m.Action = d.str(val)
+			case 4:
+				m.Input, err = d.decodeStruct(val)
+			case 5:
+				m.Flow = d.str(val)
+			}
+		case *ActionCompletion:
+			switch num {
+			case 1:
+				m.ID = d.str(val)
+			case 2:
+				m.Concept = d.str(val)
+			case 3:
+				m.Action = d.str(val)
+			case 4:
+				m.Input, err = d.decodeStruct(val)
+			case 5:
+				m.Variant = d.str(val)
+			case 6:
+				m.Output, err = d.decodeStruct(val)
+			case 7:
+				m.Flow = d.str(val)
+			case 8:
+				m.Timestamp = d.str(val)
+			case 9:
+				m.Partial = n != 0
+			case 10:
+				m.AliasedFrom = d.str(val)
+			case 11:
+				m.CacheableOutput = n != 0
+			}
+		case *ConceptQuery:
+			switch num {
+			case 1:
+				m.Concept = d.str(val)
+			case 2:
+				m.Relation = d.str(val)
+			case 3:
+				m.Args, err = d.decodeStruct(val)
+			}
+		case *[]map[string]any:
+			if num == 1 {
+				var rec map[string]any
+				if rec, err = d.decodeStruct(val); err == nil {
+					*m = append(*m, rec)
+				}
+			}
+		default:
+			return fmt.Errorf("clef: cannot unmarshal protobuf into %T", v)
+		}
+		return err
+	})
+}
+
+// protoSizeHint estimates the encoded size of v so MarshalProto can
+// allocate its buffer once in the common case.
+func protoSizeHint(v any) int {
+	const overhead = 64
+	switch m := v.(type) {
+	case *ActionInvocation:
+		return overhead + len(m.ID) + len(m.Concept) + len(m.Action) + len(m.Flow) + max(structSize(m.Input), 0)
+	case *ActionCompletion:
+		return overhead + len(m.ID) + len(m.Concept) + len(m.Action) + len(m.Variant) + len(m.Flow) + len(m.Timestamp) + len(m.AliasedFrom) +
+			max(structSize(m.Input), 0) + max(structSize(m.Output), 0)
+	case *ConceptQuery:
+		return overhead + len(m.Concept) + len(m.Relation) + max(structSize(m.Args), 0)
+	case map[string]any:
+		return overhead + max(structSize(m), 0)
+	case []map[string]any:
+		n := overhead
+		for _, rec := range m {
+			n += 8 + max(structSize(rec), 0)
+		}
+		return n
+	}
+	return 0
+}
+
+func appendString(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+// Field numbers from google/protobuf/struct.proto. Struct values are
+// encoded and decoded directly rather than through structpb so that a
+// request costs no more allocations than the equivalent JSON.
+const (
+	structFields protowire.Number = 1
+
+	entryKey   protowire.Number = 1
+	entryValue protowire.Number = 2
+
+	valueNull   protowire.Number = 1
+	valueNumber protowire.Number = 2
+	valueString protowire.Number = 3
+	valueBool   protowire.Number = 4
+	valueStruct protowire.Number = 5
+	valueList   protowire.Number = 6
+
+	listValues protowire.Number = 1
+)
+
+func appendStruct(b []byte, num protowire.Number, m map[string]any) ([]byte, error) {
+	if m == nil {
+		return b, nil
+	}
+	m, size, err := normalizeStruct(m)
+	if err != nil {
+		return nil, err
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	b = protowire.AppendVarint(b, uint64(size))
+	return appendStructFields(b, m), nil
+}
+
+// normalizeStruct returns m and its encoded size. Handler outputs may hold
+// types Struct can't represent directly (typed slices, structs); those are
+// normalized through JSON first.
+func normalizeStruct(m map[string]any) (map[string]any, int, error) {
+	if size := structSize(m); size >= 0 {
+		return m, size, nil
+	}
+	raw, err := json.Marshal(m)
+	if err != nil {
+		return nil, 0, err
+	}
+	var out map[string]any
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, 0, err
+	}
+	return out, structSize(out), nil
+}
+
+// structSize returns the encoded size of m as a Struct, or -1 if it holds
+// a value with no Struct representation.
+func structSize(m map[string]any) int {
+	n := 0
+	for k, v := range m {
+		e := entrySize(k, v)
+		if e < 0 {
+			return -1
+		}
+		n += protowire.SizeTag(structFields) + protowire.SizeBytes(e)
+	}
+	return n
+}
+
+func entrySize(k string, v any) int {
+	vs := valueSize(v)
+	if vs < 0 {
+		return -1
+	}
+	return protowire.SizeTag(entryKey) + protowire.SizeBytes(len(k)) +
+		protowire.SizeTag(entryValue) + protowire.SizeBytes(vs)
+}
+
+func valueSize(v any) int {
+	switch x := v.(type) {
+	case nil:
+		return protowire.SizeTag(valueNull) + 1
+	case bool:
+		return protowire.SizeTag(valueBool) + 1
+	case string:
+		return protowire.SizeTag(valueString) + protowire.SizeBytes(len(x))
+	case map[string]any:
+		n := structSize(x)
+		if n < 0 {
+			return -1
+		}
+		return protowire.SizeTag(valueStruct) + protowire.SizeBytes(n)
+	case []any:
+		n := listSize(x)
+		if n < 0 {
+			return -1
+		}
+		return protowire.SizeTag(valueList) + protowire.SizeBytes(n)
+	}
+	if _, ok := toFloat(v); ok {
+		return protowire.SizeTag(valueNumber) + protowire.SizeFixed64()
+	}
+	return -1
+}
+
+func listSize(l []any) int {
+	n := 0
+	for _, v := range l {
+		vs := valueSize(v)
+		if vs < 0 {
+			return -1
+		}
+		n += protowire.SizeTag(listValues) + protowire.SizeBytes(vs)
+	}
+	return n
+}
+
+func appendStructFields(b []byte, m map[string]any) []byte {
+	for k, v := range m {
+		b = protowire.AppendTag(b, structFields, protowire.BytesType)
+		b = protowire.AppendVarint(b, uint64(entrySize(k, v)))
+		b = protowire.AppendTag(b, entryKey, protowire.BytesType)
+		b = protowire.AppendString(b, k)
+		b = protowire.AppendTag(b, entryValue, protowire.BytesType)
+		b = protowire.AppendVarint(b, uint64(valueSize(v)))
+		b = appendValue(b, v)
+	}
+	return b
+}
+
+func appendValue(b []byte, v any) []byte {
+	switch x := v.(type) {
+	case nil:
+		b = protowire.AppendTag(b, valueNull, protowire.VarintType)
+		return protowire.AppendVarint(b, 0)
+	case bool:
+		b = protowire.AppendTag(b, valueBool, protowire.VarintType)
+		return protowire.AppendVarint(b, protowire.EncodeBool(x))
+	case string:
+		b = protowire.AppendTag(b, valueString, protowire.BytesType)
+		return protowire.AppendString(b, x)
+	case map[string]any:
+		b = protowire.AppendTag(b, valueStruct, protowire.BytesType)
+		b = protowire.AppendVarint(b, uint64(structSize(x)))
+		return appendStructFields(b, x)
+	case []any:
+		b = protowire.AppendTag(b, valueList, protowire.BytesType)
+		b = protowire.AppendVarint(b, uint64(listSize(x)))
+		for _, e := range x {
+			b = protowire.AppendTag(b, listValues, protowire.BytesType)
+			b = protowire.AppendVarint(b, uint64(valueSize(e)))
+			b = appendValue(b, e)
+		}
+		return b
+	}
+	f, _ := toFloat(v)
+	b = protowire.AppendTag(b, valueNumber, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(f))
+}
+
+// countFields returns how many top-level num fields data holds, so
+// decoders can size maps and slices up front.
+func countFields(data []byte, want protowire.Number) int {
+	count := 0
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return count
+		}
+		data = data[n:]
+		if n = protowire.ConsumeFieldValue(num, typ, data); n < 0 {
+			return count
+		}
+		data = data[n:]
+		if num == want {
+			count++
+		}
+	}
+	return count
+}
+
+// protoDecoder copies the message into a single string once and slices
+// every decoded string out of it, instead of allocating per string.
+type protoDecoder struct {
+	src     string
+	rootCap int
+}
+
+func newProtoDecoder(data []byte) *protoDecoder {
+	return &protoDecoder{src: string(data), rootCap: cap(data)}
+}
+
+// str returns the decoded string for b, which must be a subslice of the
+// message passed to newProtoDecoder.
+func (d *protoDecoder) str(b []byte) string {
+	off := d.rootCap - cap(b)
+	return d.src[off : off+len(b)]
+}
+
+func (d *protoDecoder) decodeStruct(data []byte) (map[string]any, error) {
+	m := make(map[string]any, countFields(data, structFields))
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		data = data[n:]
+		if num != structFields || typ != protowire.BytesType {
+			if n = protowire.ConsumeFieldValue(num, typ, data); n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			data = data[n:]
+			continue
+		}
+		entry, n := protowire.ConsumeBytes(data)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		var key string
+		var value any
+		for len(entry) > 0 {
+			num, typ, n := protowire.ConsumeTag(entry)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			entry = entry[n:]
+			if typ != protowire.BytesType {
+				if n = protowire.ConsumeFieldValue(num, typ, entry); n < 0 {
+					return nil, protowire.ParseError(n)
+				}
+				entry = entry[n:]
+				continue
+			}
+			field, n := protowire.ConsumeBytes(entry)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			entry = entry[n:]
+			switch num {
+			case entryKey:
+				key = d.str(field)
+			case entryValue:
+				v, err := d.decodeValue(field)
+				if err != nil {
+					return nil, err
+				}
+				value = v
+			}
+		}
+		m[key] = value
+	}
+	return m, nil
+}
+
+func (d *protoDecoder) decodeValue(data []byte) (any, error) {
+	var value any
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		data = data[n:]
+		switch {
+		case num == valueNull && typ == protowire.VarintType:
+			_, n = protowire.ConsumeVarint(data)
+			value = nil
+		case num == valueNumber && typ == protowire.Fixed64Type:
+			var bits uint64
+			bits, n = protowire.ConsumeFixed64(data)
+			value = math.Float64frombits(bits)
+		case num == valueBool && typ == protowire.VarintType:
+			var x uint64
+			x, n = protowire.ConsumeVarint(data)
+			value = protowire.DecodeBool(x)
+		case num == valueString && typ == protowire.BytesType:
+			var field []byte
+			field, n = protowire.ConsumeBytes(data)
+			value = d.str(field)
+		case num == valueStruct && typ == protowire.BytesType:
+			var field []byte
+			if field, n = protowire.ConsumeBytes(data); n >= 0 {
+				var err error
+				if value, err = d.decodeStruct(field); err != nil {
+					return nil, err
+				}
+			}
+		case num == valueList && typ == protowire.BytesType:
+			var field []byte
+			if field, n = protowire.ConsumeBytes(data); n >= 0 {
+				var err error
+				if value, err = d.decodeList(field); err != nil {
+					return nil, err
+				}
+			}
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		data = data[n:]
+	}
+	return value, nil
+}
+
+func (d *protoDecoder) decodeList(data []byte) ([]any, error) {
+	list := make([]any, 0, countFields(data, listValues))
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		data = data[n:]
+		if num != listValues || typ != protowire.BytesType {
+			if n = protowire.ConsumeFieldValue(num, typ, data); n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			data = data[n:]
+			continue
+		}
+		field, n := protowire.ConsumeBytes(data)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		data = data[n:]
+		v, err := d.decodeValue(field)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	return list, nil
+}
+
+// consumeFields walks the top-level fields of a message. For bytes fields
+// val holds the payload; for varint fields n holds the value.
+func consumeFields(data []byte, fn func(num protowire.Number, typ protowire.Type, val []byte, n uint64) error) error {
+	for len(data) > 0 {
+		num, typ, tagLen := protowire.ConsumeTag(data)
+		if tagLen < 0 {
+			return protowire.ParseError(tagLen)
+		}
+		data = data[tagLen:]
+
+		var val []byte
+		var n uint64
+		var fieldLen int
+		switch typ {
+		case protowire.BytesType:
+			val, fieldLen = protowire.ConsumeBytes(data)
+		case protowire.VarintType:
+			n, fieldLen = protowire.ConsumeVarint(data)
+		default:
+			fieldLen = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if fieldLen < 0 {
+			return protowire.ParseError(fieldLen)
+		}
+		data = data[fieldLen:]
+
+		if typ == protowire.BytesType || typ == protowire.VarintType {
+			if err := fn(num, typ, val, n); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// isProtobuf reports whether a Content-Type or Accept header value names
+// the protobuf encoding.
+func isProtobuf(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		mt, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err == nil && (mt == ContentTypeProtobuf || mt == "application/x-protobuf") {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeBody decodes a request body according to its Content-Type.
+func decodeBody(r *http.Request, v any) error {
+	if isProtobuf(r.Header.Get("Content-Type")) {
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			return err
+		}
+		return UnmarshalProto(data, v)
+	}
+	return json.NewDecoder(r.Body).Decode(v)
+}
+
+// wantsProtobuf reports whether the response should be protobuf: either
+// the client asked for it, or it sent protobuf and expressed no preference.
+func wantsProtobuf(r *http.Request) bool {
+	if accept := r.Header.Get("Accept"); accept != "" && accept != "*/*" {
+		return isProtobuf(accept)
+	}
+	return isProtobuf(r.Header.Get("Content-Type"))
+}
+
+// writeNegotiated writes data with the given status as protobuf or JSON
+// depending on the request.
+func (s *server) writeNegotiated(w http.ResponseWriter, r *http.Request, status int, data any) {
+	if !wantsProtobuf(r) {
+		s.writeJSONStatus(w, r, status, data)
+		return
+	}
+	body, err := MarshalProto(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", ContentTypeProtobuf)
+	w.WriteHeader(status)
+	w.Write(body)
+}
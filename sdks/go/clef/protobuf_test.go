@@ -0,0 +1,160 @@
+package clef
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func protoRequest(t testing.TB, h http.Handler, path string, msg any) *httptest.ResponseRecorder {
+	t.Helper()
+	body, err := MarshalProto(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	req.Header.Set("Content-Type", ContentTypeProtobuf)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestProtoRoundTrip(t *testing.T) {
+	in := ActionCompletion{
+		ID: "i1", Concept: "urn:test/Echo", Action: "echo",
+		Input:   map[string]any{"message": "hi", "n": float64(2)},
+		Variant: "ok", Output: map[string]any{"variant": "ok", "tags": []any{"a", "b"}},
+		Flow: "f1", Timestamp: "2024-01-01T00:00:00Z", Partial: true, AliasedFrom: "say", CacheableOutput: true,
+	}
+	data, err := MarshalProto(&in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out ActionCompletion
+	if err := UnmarshalProto(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("round trip mismatch:\n in=%+v\nout=%+v", in, out)
+	}
+}
+
+func TestProtobufInvokeMatchesJSON(t *testing.T) {
+	resetRegistry()
+	Register("urn:test/Echo", &echoHandler{}, nil)
+	h := NewHandler()
+
+	inv := ActionInvocation{ID: "inv-1", Concept: "urn:test/Echo", Action: "echo", Input: map[string]any{"message": "hello"}, Flow: "flow-1"}
+
+	rec := protoRequest(t, h, "/invoke", &inv)
+	if ct := rec.Header().Get("Content-Type"); ct != ContentTypeProtobuf {
+		t.Fatalf("expected protobuf response, got %q", ct)
+	}
+	var fromProto ActionCompletion
+	if err := UnmarshalProto(rec.Body.Bytes(), &fromProto); err != nil {
+		t.Fatal(err)
+	}
+
+	jsonBody, _ := json.Marshal(inv)
+	rec = doRequest(h, http.MethodPost, "/invoke", string(jsonBody))
+	var fromJSON ActionCompletion
+	if err := json.NewDecoder(rec.Body).Decode(&fromJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	fromProto.Timestamp, fromJSON.Timestamp = "", ""
+	if !reflect.DeepEqual(fromProto, fromJSON) {
+		t.Errorf("protobuf and JSON completions differ:\nproto=%+v\njson=%+v", fromProto, fromJSON)
+	}
+}
+
+func TestProtobufQueryAndAcceptNegotiation(t *testing.T) {
+	resetRegistry()
+	s := NewInMemoryStorage()
+	s.Put("users", "alice", map[string]any{"name": "Alice"})
+	Register("urn:test/Users", &echoHandler{}, s)
+	h := NewHandler()
+
+	rec := protoRequest(t, h, "/query", &ConceptQuery{Concept: "urn:test/Users", Relation: "users"})
+	var results []map[string]any
+	if err := UnmarshalProto(rec.Body.Bytes(), &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0]["name"] != "Alice" {
+		t.Errorf("unexpected protobuf query results %v", results)
+	}
+
+	// A JSON request may still ask for a protobuf response.
+	req := httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(`{"concept":"urn:test/Users","relation":"users"}`))
+	req.Header.Set("Accept", ContentTypeProtobuf)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Header().Get("Content-Type") != ContentTypeProtobuf {
+		t.Errorf("expected Accept to select protobuf, got %q", rec.Header().Get("Content-Type"))
+	}
+}
+
+// benchInvocation builds an invocation whose JSON encoding is ~512 bytes.
+func benchInvocation() ActionInvocation {
+	return ActionInvocation{
+		ID:      "0b9f3c1e-6a4d-4f1b-9c2e-7d8a5e6f4b3a",
+		Concept: "urn:bench/Echo",
+		Action:  "echo",
+		Flow:    "5c2d7e9a-1b3f-4c8d-a6e2-9f0b1d3c5e7a",
+		Input: map[string]any{
+			"message": strings.Repeat("x", 300),
+			"user":    "alice",
+			"count":   float64(42),
+			"tags":    []any{"go", "clef", "bench"},
+		},
+	}
+}
+
+func BenchmarkDecodeInvocationJSON(b *testing.B) {
+	data, _ := json.Marshal(benchInvocation())
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		var inv ActionInvocation
+		if err := json.Unmarshal(data, &inv); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeInvocationProtobuf(b *testing.B) {
+	inv := benchInvocation()
+	data, _ := MarshalProto(&inv)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		var out ActionInvocation
+		if err := UnmarshalProto(data, &out); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodeInvocationJSON(b *testing.B) {
+	inv := benchInvocation()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := json.Marshal(&inv); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodeInvocationProtobuf(b *testing.B) {
+	inv := benchInvocation()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := MarshalProto(&inv); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
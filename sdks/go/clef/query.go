@@ -0,0 +1,119 @@
+package clef
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Pseudo-relations answered by POST /query from the concept's
+// registration rather than its storage. ConfigRelation is answered the
+// same way when the handler holds a DynamicConfig.
+const (
+	// SchemaRelation lists the concept's ActionSpecs, one record per
+	// action, when its handler is Introspectable.
+	SchemaRelation = "_schema"
+	// MetricsRelation is a single record of the concept's invocation
+	// counts and latency histogram.
+	MetricsRelation = "_metrics"
+)
+
+// latencyBucketsMs are the upper bounds of the MetricsRelation latency
+// histogram; a final bucket counts slower invocations.
+var latencyBucketsMs = []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// conceptMetrics holds the invocation statistics of every concept
+// dispatched to since startup, by URI.
+var (
+	conceptMetricsMu sync.Mutex
+	conceptMetrics   = make(map[string]*conceptStats)
+)
+
+type conceptStats struct {
+	invocations int64
+	errors      int64
+	actions     map[string]int64
+	latency     []int64 // per bucket of latencyBucketsMs, plus overflow
+}
+
+// recordInvocation adds one completed invocation of uri's action.
+func recordInvocation(uri, action string, d time.Duration, failed bool) {
+	ms := float64(d) / float64(time.Millisecond)
+	conceptMetricsMu.Lock()
+	defer conceptMetricsMu.Unlock()
+	st, ok := conceptMetrics[uri]
+	if !ok {
+		st = &conceptStats{actions: make(map[string]int64), latency: make([]int64, len(latencyBucketsMs)+1)}
+		conceptMetrics[uri] = st
+	}
+	st.invocations++
+	if failed {
+		st.errors++
+	}
+	st.actions[action]++
+	i := 0
+	for i < len(latencyBucketsMs) && ms > latencyBucketsMs[i] {
+		i++
+	}
+	st.latency[i]++
+}
+
+// metricsRecord returns the MetricsRelation record of uri.
+func metricsRecord(uri string) map[string]any {
+	conceptMetricsMu.Lock()
+	defer conceptMetricsMu.Unlock()
+	record := map[string]any{
+		"invocations":        int64(0),
+		"errors":             int64(0),
+		"actions":            map[string]int64{},
+		"latency_buckets_ms": slices.Clone(latencyBucketsMs),
+		"latency_counts":     make([]int64, len(latencyBucketsMs)+1),
+	}
+	if st, ok := conceptMetrics[uri]; ok {
+		actions := make(map[string]int64, len(st.actions))
+		for a, n := range st.actions {
+			actions[a] = n
+		}
+		record["invocations"] = st.invocations
+		record["errors"] = st.errors
+		record["actions"] = actions
+		record["latency_counts"] = append([]int64(nil), st.latency...)
+	}
+	return record
+}
+
+// pseudoRelation answers q from entry's registration when q.Relation is
+// a pseudo-relation, reporting false otherwise.
+func pseudoRelation(q ConceptQuery, entry registryEntry) ([]map[string]any, bool) {
+	var records []map[string]any
+	switch q.Relation {
+	case ConfigRelation:
+		h, ok := findHandler[configHolder](entry.handler)
+		if !ok {
+			return nil, false
+		}
+		records = []map[string]any{h.dynamicConfig().Values()}
+	case SchemaRelation:
+		if in, ok := entry.handler.(Introspectable); ok {
+			for _, spec := range in.ActionSpecs() {
+				records = append(records, map[string]any{
+					"name":         spec.Name,
+					"description":  spec.Description,
+					"inputSchema":  spec.InputSchema,
+					"outputSchema": spec.OutputSchema,
+				})
+			}
+		}
+	case MetricsRelation:
+		records = []map[string]any{metricsRecord(q.Concept)}
+	default:
+		return nil, false
+	}
+	results := []map[string]any{}
+	for _, r := range records {
+		if matchesArgs(r, q.Args) {
+			results = append(results, r)
+		}
+	}
+	return results, true
+}
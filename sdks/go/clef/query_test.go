@@ -0,0 +1,105 @@
+package clef
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// findCountingStorage counts the Find calls reaching it.
+type findCountingStorage struct {
+	*InMemoryStorage
+	finds int
+}
+
+func (s *findCountingStorage) Find(relation string, args map[string]any) []map[string]any {
+	s.finds++
+	return s.InMemoryStorage.Find(relation, args)
+}
+
+// configuredArticleHandler has action specs and a DynamicConfig.
+type configuredArticleHandler struct {
+	articleSpecHandler
+	*DynamicConfig
+}
+
+func queryRecords(t *testing.T, h http.Handler, body string) []map[string]any {
+	t.Helper()
+	rec := doRequest(h, http.MethodPost, "/query", body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /query: status %d: %s", rec.Code, rec.Body.String())
+	}
+	var records []map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &records); err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestQueryPseudoRelations(t *testing.T) {
+	resetRegistry()
+	config := NewDynamicConfig(NewInMemoryStorage(), ConfigRelation, DefaultConfigKey)
+	if err := config.Set("pageSize", 20); err != nil {
+		t.Fatal(err)
+	}
+	storage := &findCountingStorage{InMemoryStorage: NewInMemoryStorage()}
+	Register("urn:app/Article", &configuredArticleHandler{DynamicConfig: config}, storage)
+	h := NewHandler()
+	for range 3 {
+		invokeRecorder(t, `{"concept":"urn:app/Article","action":"echo","input":{"message":"hi"}}`)
+	}
+	invokeRecorder(t, `{"concept":"urn:app/Article","action":"fail"}`)
+
+	configs := queryRecords(t, h, `{"concept":"urn:app/Article","relation":"_config"}`)
+	if len(configs) != 1 || configs[0]["pageSize"] != float64(20) {
+		t.Errorf("_config = %v, want the DynamicConfig values", configs)
+	}
+
+	schema := queryRecords(t, h, `{"concept":"urn:app/Article","relation":"_schema"}`)
+	if len(schema) != 2 || schema[0]["name"] != "echo" || schema[0]["description"] != "Echo a message" || schema[1]["name"] != "fail" {
+		t.Errorf("_schema = %v", schema)
+	}
+	if fail := queryRecords(t, h, `{"concept":"urn:app/Article","relation":"_schema","args":{"name":"fail"}}`); len(fail) != 1 {
+		t.Errorf("_schema filtered by name = %v", fail)
+	}
+
+	metrics := queryRecords(t, h, `{"concept":"urn:app/Article","relation":"_metrics"}`)
+	if len(metrics) != 1 {
+		t.Fatalf("_metrics = %v", metrics)
+	}
+	m := metrics[0]
+	actions, _ := m["actions"].(map[string]any)
+	if m["invocations"] != float64(4) || m["errors"] != float64(1) || actions["echo"] != float64(3) || actions["fail"] != float64(1) {
+		t.Errorf("_metrics = %v", m)
+	}
+	var total float64
+	for _, n := range m["latency_counts"].([]any) {
+		total += n.(float64)
+	}
+	if total != 4 {
+		t.Errorf("latency histogram counts %v invocations, want 4", total)
+	}
+
+	if storage.finds != 0 {
+		t.Errorf("pseudo-relations reached storage %d times", storage.finds)
+	}
+	queryRecords(t, h, `{"concept":"urn:app/Article","relation":"articles"}`)
+	if storage.finds != 1 {
+		t.Errorf("ordinary relation reached storage %d times, want 1", storage.finds)
+	}
+}
+
+func TestQueryConfigFallsBackToStorage(t *testing.T) {
+	resetRegistry()
+	storage := NewInMemoryStorage()
+	storage.Put(ConfigRelation, DefaultConfigKey, map[string]any{"limit": 5})
+	Register("urn:app/Article", &echoHandler{}, storage)
+
+	configs := queryRecords(t, NewHandler(), `{"concept":"urn:app/Article","relation":"_config"}`)
+	if len(configs) != 1 || configs[0]["limit"] != float64(5) {
+		t.Errorf("_config = %v, want the stored record", configs)
+	}
+	if schema := queryRecords(t, NewHandler(), `{"concept":"urn:app/Article","relation":"_schema"}`); len(schema) != 0 {
+		t.Errorf("_schema of a non-Introspectable handler = %v", schema)
+	}
+}
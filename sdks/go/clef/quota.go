@@ -0,0 +1,82 @@
+package clef
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// quotaRelation holds per-caller counters in the concept's own storage.
+const quotaRelation = "_quota"
+
+type callerQuota struct {
+	mu        sync.Mutex
+	extractor func(ctx context.Context) string
+	limits    map[string]int64
+	window    time.Duration
+	sweptAt   time.Time
+}
+
+// CallerQuota limits how often each caller may invoke each action within
+// a fixed window. extractor derives the caller key (e.g. a tenant ID from
+// ClaimsFromContext); an empty key is counted as one anonymous caller.
+// limits maps action names to the maximum calls per window; unlisted
+// actions are unlimited. Counters live in the concept's "_quota" relation
+// and expire with their window; expired counters are deleted at most
+// once per window, on the next call. Callers over quota get a
+// "quota_exceeded" completion with retryAfter set to the time remaining.
+func (o *ConceptOptions) CallerQuota(extractor func(ctx context.Context) string, limits map[string]int64, window time.Duration) *ConceptOptions {
+	o.quota = &callerQuota{extractor: extractor, limits: limits, window: window}
+	return o
+}
+
+// allow records one call and returns the time until the caller's window
+// resets if the call exceeds the quota.
+func (q *callerQuota) allow(ctx context.Context, action string, storage Storage) (time.Duration, bool) {
+	limit, ok := q.limits[action]
+	if !ok {
+		return 0, true
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(q.sweptAt) >= q.window {
+		q.sweep(storage, now)
+		q.sweptAt = now
+	}
+	key := q.extractor(ctx) + ":" + action
+	count := int64(0)
+	expiresAt := now.Add(q.window)
+	if rec, ok := storage.Get(quotaRelation, key); ok {
+		if exp, _ := toInt64(rec["expiresAt"]); now.UnixNano() < exp {
+			count, _ = toInt64(rec["count"])
+			expiresAt = time.Unix(0, exp)
+		}
+	}
+
+	if count >= limit {
+		return expiresAt.Sub(now), false
+	}
+	storage.Put(quotaRelation, key, map[string]any{
+		"key":       key,
+		"count":     count + 1,
+		"expiresAt": expiresAt.UnixNano(),
+	})
+	return 0, true
+}
+
+// sweep deletes the counters whose window ended before now.
+func (q *callerQuota) sweep(storage Storage, now time.Time) {
+	var expired []string
+	for _, rec := range storage.Find(quotaRelation, nil) {
+		key, _ := rec["key"].(string)
+		if exp, _ := toInt64(rec["expiresAt"]); key != "" && exp <= now.UnixNano() {
+			expired = append(expired, key)
+		}
+	}
+	if len(expired) > 0 {
+		storage.BulkDelete(quotaRelation, expired)
+	}
+}
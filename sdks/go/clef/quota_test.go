@@ -0,0 +1,127 @@
+package clef
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func tenantFromClaims(ctx context.Context) string {
+	tenant, _ := ClaimsFromContext(ctx)["tenant"].(string)
+	return tenant
+}
+
+func invokeAsTenant(t *testing.T, h http.Handler, tenant, action string) ActionCompletion {
+	t.Helper()
+	body := `{"concept":"urn:test/Echo","action":"` + action + `","input":{"message":"hi"}}`
+	req := httptest.NewRequest(http.MethodPost, "/invoke", strings.NewReader(body))
+	req = req.WithContext(ContextWithClaims(req.Context(), map[string]any{"tenant": tenant}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	var c ActionCompletion
+	if err := json.NewDecoder(rec.Body).Decode(&c); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestCallerQuotaIsolatesCallers(t *testing.T) {
+	resetRegistry()
+	var opts ConceptOptions
+	opts.CallerQuota(tenantFromClaims, map[string]int64{"echo": 2}, time.Minute)
+	storage := NewInMemoryStorage()
+	RegisterWithOptions("urn:test/Echo", &echoHandler{}, storage, opts)
+	h := NewHandler()
+
+	for i := 0; i < 2; i++ {
+		if c := invokeAsTenant(t, h, "acme", "echo"); c.Variant != "ok" {
+			t.Fatalf("call %d for acme: expected ok, got %s", i, c.Variant)
+		}
+	}
+	c := invokeAsTenant(t, h, "acme", "echo")
+	if c.Variant != "quota_exceeded" {
+		t.Fatalf("expected acme to exceed quota, got %s", c.Variant)
+	}
+	if ra, _ := c.Output["retryAfter"].(string); ra == "" {
+		t.Error("expected retryAfter")
+	}
+
+	if c := invokeAsTenant(t, h, "globex", "echo"); c.Variant != "ok" {
+		t.Errorf("expected globex to be unaffected, got %s", c.Variant)
+	}
+	if _, ok := storage.Get(quotaRelation, "acme:echo"); !ok {
+		t.Error("expected quota counter in _quota relation")
+	}
+}
+
+func TestCallerQuotaWindowResets(t *testing.T) {
+	resetRegistry()
+	var opts ConceptOptions
+	opts.CallerQuota(tenantFromClaims, map[string]int64{"echo": 1}, 30*time.Millisecond)
+	RegisterWithOptions("urn:test/Echo", &echoHandler{}, nil, opts)
+	h := NewHandler()
+
+	invokeAsTenant(t, h, "acme", "echo")
+	if c := invokeAsTenant(t, h, "acme", "echo"); c.Variant != "quota_exceeded" {
+		t.Fatalf("expected quota_exceeded, got %s", c.Variant)
+	}
+	time.Sleep(40 * time.Millisecond)
+	if c := invokeAsTenant(t, h, "acme", "echo"); c.Variant != "ok" {
+		t.Errorf("expected quota to reset after window, got %s", c.Variant)
+	}
+}
+
+func TestCallerQuotaUnlistedActionUnlimited(t *testing.T) {
+	resetRegistry()
+	var opts ConceptOptions
+	opts.CallerQuota(tenantFromClaims, map[string]int64{"fail": 1}, time.Minute)
+	RegisterWithOptions("urn:test/Echo", &echoHandler{}, nil, opts)
+	h := NewHandler()
+
+	for i := 0; i < 5; i++ {
+		if c := invokeAsTenant(t, h, "acme", "echo"); c.Variant != "ok" {
+			t.Fatalf("expected unlisted action to be unlimited, got %s", c.Variant)
+		}
+	}
+}
+
+func TestCallerQuotaOverJSONStorage(t *testing.T) {
+	resetRegistry()
+	storage, _ := newTestRedisStorage(t)
+	var opts ConceptOptions
+	opts.CallerQuota(tenantFromClaims, map[string]int64{"echo": 2}, time.Minute)
+	RegisterWithOptions("urn:test/Echo", &echoHandler{}, storage, opts)
+	h := NewHandler()
+
+	for i := 0; i < 2; i++ {
+		if c := invokeAsTenant(t, h, "acme", "echo"); c.Variant != "ok" {
+			t.Fatalf("call %d: expected ok, got %s", i, c.Variant)
+		}
+	}
+	if c := invokeAsTenant(t, h, "acme", "echo"); c.Variant != "quota_exceeded" {
+		t.Fatalf("expected quota_exceeded after a JSON round-trip, got %s", c.Variant)
+	}
+}
+
+func TestCallerQuotaDeletesExpiredCounters(t *testing.T) {
+	resetRegistry()
+	var opts ConceptOptions
+	opts.CallerQuota(tenantFromClaims, map[string]int64{"echo": 2}, 20*time.Millisecond)
+	storage := NewInMemoryStorage()
+	RegisterWithOptions("urn:test/Echo", &echoHandler{}, storage, opts)
+	h := NewHandler()
+
+	invokeAsTenant(t, h, "acme", "echo")
+	time.Sleep(30 * time.Millisecond)
+	invokeAsTenant(t, h, "globex", "echo")
+	if _, ok := storage.Get(quotaRelation, "acme:echo"); ok {
+		t.Error("expired counter was not deleted")
+	}
+	if _, ok := storage.Get(quotaRelation, "globex:echo"); !ok {
+		t.Error("live counter was deleted")
+	}
+}
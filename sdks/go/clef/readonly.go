@@ -0,0 +1,70 @@
+package clef
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// ReadOnlyMiddleware rejects every action but readOnlyActions while
+// isReadOnly returns true, with {"variant": "error", "code": "read_only"}.
+// Pair it with a ReadOnlySwitch to enter read-only mode while a storage
+// health check fails.
+//
+// Example:
+//
+//	ro := clef.NewReadOnlySwitch()
+//	clef.MustRegister("urn:app/Article", clef.Chain(&ArticleHandler{},
+//	    clef.ReadOnlyMiddleware(ro.Enabled, []string{"get", "list", "search"})), storage)
+//	clef.Serve(":8080", clef.WithHealthCheck("storage", storageCheck), ro.Watch(storageCheck))
+func ReadOnlyMiddleware(isReadOnly func() bool, readOnlyActions []string) MiddlewareFunc {
+	allowed := make(map[string]bool, len(readOnlyActions))
+	for _, a := range readOnlyActions {
+		allowed[a] = true
+	}
+	return func(next ConceptHandler) ConceptHandler {
+		return HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+			if !allowed[action] && isReadOnly() {
+				return map[string]any{"variant": "error", "code": "read_only", "message": "concept is in read-only mode"}
+			}
+			return callHandler(ctx, next, action, input, storage)
+		})
+	}
+}
+
+// ReadOnlySwitch holds whether concepts are in read-only mode. It is
+// switched by hand with Set, or by the health checkers it Watches. It is
+// a DegradedHandler that is read-only in any mode but Normal.
+type ReadOnlySwitch struct {
+	enabled atomic.Bool
+}
+
+// NewReadOnlySwitch returns a switch that is off.
+func NewReadOnlySwitch() *ReadOnlySwitch {
+	return &ReadOnlySwitch{}
+}
+
+// Enabled reports whether read-only mode is on. Pass it to
+// ReadOnlyMiddleware.
+func (s *ReadOnlySwitch) Enabled() bool {
+	return s.enabled.Load()
+}
+
+// Set turns read-only mode on or off.
+func (s *ReadOnlySwitch) Set(readOnly bool) {
+	s.enabled.Store(readOnly)
+}
+
+// SetMode turns read-only mode on in Degraded and Critical mode and off
+// in Normal mode.
+func (s *ReadOnlySwitch) SetMode(mode DegradedMode) {
+	s.Set(mode != Normal)
+}
+
+// Watch returns a ServeOption that runs checkers with the server's
+// degradation policies (see WithDegradationPolicy and
+// WithDegradationInterval). Read-only mode is switched on in the first
+// round in which any checker fails, and off once all of them, including
+// those of other Watch calls on s, pass again.
+func (s *ReadOnlySwitch) Watch(checkers ...HealthChecker) ServeOption {
+	return withDegradationPolicy(degradationConfig{concept: "read-only", target: s, degradeThreshold: 1}, checkers)
+}
@@ -0,0 +1,66 @@
+package clef
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+)
+
+func TestReadOnlyMiddleware(t *testing.T) {
+	ro := NewReadOnlySwitch()
+	h := Chain(HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		if action == "create" {
+			storage.Put("articles", "a1", input)
+		}
+		return map[string]any{"variant": "ok"}
+	}), ReadOnlyMiddleware(ro.Enabled, []string{"get", "list"}))
+	storage := NewInMemoryStorage()
+
+	ro.Set(true)
+	out := h.Handle("create", map[string]any{"title": "t"}, storage)
+	if out["variant"] != "error" || out["code"] != "read_only" {
+		t.Fatalf("write in read-only mode = %v", out)
+	}
+	if _, ok := storage.Get("articles", "a1"); ok {
+		t.Fatal("rejected write reached storage")
+	}
+	if out := h.Handle("get", nil, storage); out["variant"] != "ok" {
+		t.Fatalf("read in read-only mode = %v", out)
+	}
+
+	ro.Set(false)
+	if out := h.Handle("create", map[string]any{"title": "t"}, storage); out["variant"] != "ok" {
+		t.Fatalf("write after leaving read-only mode = %v", out)
+	}
+}
+
+func TestReadOnlySwitchWatchesHealthCheck(t *testing.T) {
+	resetRegistry()
+	ro := NewReadOnlySwitch()
+	var storageUp, searchUp atomic.Bool
+	Register("urn:test/Article", Chain(&echoHandler{}, ReadOnlyMiddleware(ro.Enabled, []string{"echo"})), NewInMemoryStorage())
+	s := newServer([]ServeOption{ro.Watch(switchCheck(&storageUp)), ro.Watch(switchCheck(&searchUp))})
+	monitor := s.degradation
+
+	monitor.run(context.Background())
+	if !ro.Enabled() {
+		t.Fatal("failing check did not enable read-only mode")
+	}
+	if c := invokeRecorder(t, `{"concept":"urn:test/Article","action":"create","input":{}}`); c.Variant != "error" || c.Output["code"] != "read_only" {
+		t.Fatalf("write = %+v", c)
+	}
+	if c := invokeRecorder(t, `{"concept":"urn:test/Article","action":"echo","input":{"message":"hi"}}`); c.Variant != "ok" {
+		t.Fatalf("read = %+v", c)
+	}
+
+	storageUp.Store(true)
+	monitor.run(context.Background())
+	if !ro.Enabled() {
+		t.Fatal("read-only mode turned off while a check still fails")
+	}
+	searchUp.Store(true)
+	monitor.run(context.Background())
+	if ro.Enabled() {
+		t.Fatal("passing checks did not disable read-only mode")
+	}
+}
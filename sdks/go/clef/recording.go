@@ -0,0 +1,36 @@
+package clef
+
+import (
+	"context"
+	"time"
+)
+
+// RecordingMiddleware passes every completion the wrapped handler
+// produces to sink, so it can be stored and later replayed with
+// copftest.ReplayFromCompletion. ID, concept and flow come from the
+// invocation in the context; they are empty when the handler is called
+// outside the transport.
+func RecordingMiddleware(sink func(ActionCompletion)) MiddlewareFunc {
+	return func(next ConceptHandler) ConceptHandler {
+		return HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+			output := callHandler(ctx, next, action, input, storage)
+
+			inv, _ := InvocationFromContext(ctx)
+			variant, _ := output["variant"].(string)
+			if variant == "" {
+				variant = "ok"
+			}
+			sink(ActionCompletion{
+				ID:        inv.ID,
+				Concept:   inv.Concept,
+				Action:    action,
+				Input:     input,
+				Variant:   variant,
+				Output:    output,
+				Flow:      inv.Flow,
+				Timestamp: time.Now().UTC().Format(time.RFC3339),
+			})
+			return output
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package clef
+
+import "testing"
+
+func TestRecordingMiddlewareCapturesInvocation(t *testing.T) {
+	resetRegistry()
+	var recorded []ActionCompletion
+	Register("urn:test/Echo", Chain(&echoHandler{}, RecordingMiddleware(func(c ActionCompletion) {
+		recorded = append(recorded, c)
+	})), nil)
+
+	invokeRecorder(t, `{"id":"inv-1","flow":"flow-1","concept":"urn:test/Echo","action":"echo","input":{"message":"hi"}}`)
+	invokeRecorder(t, `{"concept":"urn:test/Echo","action":"fail","input":{}}`)
+
+	if len(recorded) != 2 {
+		t.Fatalf("expected 2 recorded completions, got %d", len(recorded))
+	}
+	first := recorded[0]
+	if first.ID != "inv-1" || first.Flow != "flow-1" || first.Concept != "urn:test/Echo" || first.Action != "echo" {
+		t.Errorf("unexpected invocation fields %+v", first)
+	}
+	if first.Input["message"] != "hi" || first.Output["message"] != "hi" || first.Variant != "ok" || first.Timestamp == "" {
+		t.Errorf("unexpected completion %+v", first)
+	}
+	if recorded[1].Variant != "error" || recorded[1].ID == "" {
+		t.Errorf("expected error variant with generated id, got %+v", recorded[1])
+	}
+}
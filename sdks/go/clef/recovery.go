@@ -0,0 +1,100 @@
+package clef
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+)
+
+// ErrorReporter forwards handler panics to an error tracking service.
+type ErrorReporter interface {
+	// Report is called with the recovered value, the panicking
+	// goroutine's stack and the invocation that was running.
+	Report(ctx context.Context, err any, stack []byte, inv ActionInvocation)
+}
+
+// RecoveryOptions configures RecoveryMiddleware.
+type RecoveryOptions struct {
+	// Logger receives each panic, with its stack trace, at error level.
+	// Nil means slog.Default().
+	Logger *slog.Logger
+	// ExposeStack includes the stack trace in the error output under
+	// "stack_trace". Leave it off in production, where stacks would leak
+	// implementation details to callers.
+	ExposeStack bool
+	// Reporter, if set, also receives each panic.
+	Reporter ErrorReporter
+}
+
+// RecoveryMiddleware turns a handler panic into an error output with
+// code "panic", capturing the stack trace for the log, the Reporter and,
+// with ExposeStack, the caller.
+//
+// Example:
+//
+//	clef.MustRegister("urn:app/Article", clef.Chain(&ArticleHandler{}, clef.RecoveryMiddleware(clef.RecoveryOptions{
+//	    Logger:   slog.Default(),
+//	    Reporter: clef.SentryReporter(os.Getenv("SENTRY_DSN")),
+//	})), nil)
+func RecoveryMiddleware(opts RecoveryOptions) MiddlewareFunc {
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	return func(next ConceptHandler) ConceptHandler {
+		return HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) (output map[string]any) {
+			defer func() {
+				r := recover()
+				if r == nil {
+					return
+				}
+				stack := debug.Stack()
+				inv, _ := InvocationFromContext(ctx)
+				opts.Logger.ErrorContext(ctx, "handler panicked",
+					"concept", inv.Concept,
+					"action", action,
+					"flow", inv.Flow,
+					"panic", fmt.Sprint(r),
+					"stack_trace", string(stack),
+				)
+				if opts.Reporter != nil {
+					opts.Reporter.Report(ctx, r, stack, inv)
+				}
+				output = map[string]any{"variant": "error", "code": "panic", "message": fmt.Sprintf("panic: %v", r)}
+				if opts.ExposeStack {
+					output["stack_trace"] = string(stack)
+				}
+			}()
+			return callHandler(ctx, next, action, input, storage)
+		})
+	}
+}
+
+// LogErrorReporter reports panics to logger at error level.
+func LogErrorReporter(logger *slog.Logger) ErrorReporter {
+	return logReporter{logger}
+}
+
+type logReporter struct{ logger *slog.Logger }
+
+func (r logReporter) Report(ctx context.Context, err any, stack []byte, inv ActionInvocation) {
+	r.logger.ErrorContext(ctx, "panic reported",
+		"concept", inv.Concept,
+		"action", inv.Action,
+		"flow", inv.Flow,
+		"panic", fmt.Sprint(err),
+		"stack_trace", string(stack),
+	)
+}
+
+// SentryReporter returns a reporter for the Sentry project at dsn. It is
+// a stub that discards every report, so the SDK does not depend on the
+// Sentry client; wire one up by implementing ErrorReporter with
+// sentry.CurrentHub().Recover or similar.
+func SentryReporter(dsn string) ErrorReporter {
+	return sentryReporter{dsn: dsn}
+}
+
+type sentryReporter struct{ dsn string }
+
+func (sentryReporter) Report(ctx context.Context, err any, stack []byte, inv ActionInvocation) {}
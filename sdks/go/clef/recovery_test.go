@@ -0,0 +1,78 @@
+package clef
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+type recordingReporter struct {
+	err   any
+	stack []byte
+	inv   ActionInvocation
+}
+
+func (r *recordingReporter) Report(ctx context.Context, err any, stack []byte, inv ActionInvocation) {
+	r.err, r.stack, r.inv = err, stack, inv
+}
+
+func panickingHandler() ConceptHandler {
+	return HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		panic("nil article")
+	})
+}
+
+func TestRecoveryMiddleware(t *testing.T) {
+	resetRegistry()
+	var logs bytes.Buffer
+	reporter := &recordingReporter{}
+	Register("urn:test/Article", Chain(panickingHandler(), RecoveryMiddleware(RecoveryOptions{
+		Logger:   slog.New(slog.NewJSONHandler(&logs, nil)),
+		Reporter: reporter,
+	})), nil)
+
+	c := invokeRecorder(t, `{"concept":"urn:test/Article","action":"get","flow":"f1"}`)
+	if c.Variant != "error" || c.Output["code"] != "panic" || c.Output["message"] != "panic: nil article" {
+		t.Errorf("output = %v, want a panic error", c.Output)
+	}
+	if _, ok := c.Output["stack_trace"]; ok {
+		t.Error("stack trace exposed without ExposeStack")
+	}
+
+	var record map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &record); err != nil {
+		t.Fatalf("log: %v: %s", err, logs.String())
+	}
+	stack, _ := record["stack_trace"].(string)
+	if record["level"] != "ERROR" || record["concept"] != "urn:test/Article" || record["flow"] != "f1" ||
+		!strings.Contains(stack, "panickingHandler") {
+		t.Errorf("log record = %v, want the panic with its stack", record)
+	}
+	if reporter.err != "nil article" || !bytes.Contains(reporter.stack, []byte("panickingHandler")) || reporter.inv.Action != "get" {
+		t.Errorf("reporter got %v, %q, %+v", reporter.err, reporter.stack, reporter.inv)
+	}
+}
+
+func TestRecoveryMiddlewareExposeStack(t *testing.T) {
+	h := Chain(panickingHandler(), RecoveryMiddleware(RecoveryOptions{
+		Logger:      slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)),
+		ExposeStack: true,
+	}))
+	out := callHandler(context.Background(), h, "get", nil, NewInMemoryStorage())
+	if stack, _ := out["stack_trace"].(string); !strings.Contains(stack, "panickingHandler") {
+		t.Errorf("stack_trace = %q", stack)
+	}
+}
+
+func TestLogErrorReporter(t *testing.T) {
+	var logs bytes.Buffer
+	LogErrorReporter(slog.New(slog.NewTextHandler(&logs, nil))).Report(context.Background(), "boom", []byte("goroutine 1"), ActionInvocation{Concept: "urn:test/Article", Action: "get"})
+	for _, want := range []string{"level=ERROR", "panic=boom", "concept=urn:test/Article", "goroutine 1"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("log missing %q: %s", want, logs.String())
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package clef
+
+import "context"
+
+// Redacted replaces redacted field values in logged inputs.
+const Redacted = "[REDACTED]"
+
+type redactedInputKey struct{}
+
+// RedactionMiddleware stores a copy of each input with the values of
+// redactFields replaced by "[REDACTED]" in the context, for
+// LoggingMiddleware and other loggers to use in place of the input. The
+// handler still receives the original input. Only top-level fields are
+// redacted. Place it before LoggingMiddleware in Chain; otherwise
+// LoggingMiddleware logs only the input's field names.
+//
+// Example:
+//
+//	clef.MustRegister("urn:app/User", clef.Chain(&UserHandler{},
+//	    clef.RedactionMiddleware([]string{"password", "ssn"}),
+//	    clef.LoggingMiddleware(slog.Default()),
+//	), nil)
+func RedactionMiddleware(redactFields []string) MiddlewareFunc {
+	return func(next ConceptHandler) ConceptHandler {
+		return HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+			redacted := make(map[string]any, len(input))
+			for k, v := range input {
+				redacted[k] = v
+			}
+			for _, f := range redactFields {
+				if _, ok := redacted[f]; ok {
+					redacted[f] = Redacted
+				}
+			}
+			ctx = context.WithValue(ctx, redactedInputKey{}, redacted)
+			return callHandler(ctx, next, action, input, storage)
+		})
+	}
+}
+
+// RedactedInputFromContext returns the input copy made by
+// RedactionMiddleware, if any.
+func RedactedInputFromContext(ctx context.Context) (map[string]any, bool) {
+	input, ok := ctx.Value(redactedInputKey{}).(map[string]any)
+	return input, ok
+}
@@ -0,0 +1,45 @@
+package clef
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRedactionMiddleware(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+	var received map[string]any
+	h := Chain(HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		received = input
+		return map[string]any{"variant": "ok"}
+	}), RedactionMiddleware([]string{"password", "ssn"}), LoggingMiddleware(logger))
+
+	input := map[string]any{"email": "a@b.c", "password": "hunter2", "ssn": "123-45-6789"}
+	callHandler(context.Background(), h, "register", input, NewInMemoryStorage())
+
+	out := logs.String()
+	if !strings.Contains(out, `"[REDACTED]"`) || !strings.Contains(out, "a@b.c") {
+		t.Errorf("log = %s", out)
+	}
+	if strings.Contains(out, "hunter2") || strings.Contains(out, "123-45-6789") {
+		t.Errorf("log leaks PII: %s", out)
+	}
+	if received["password"] != "hunter2" || received["ssn"] != "123-45-6789" {
+		t.Errorf("handler received %v", received)
+	}
+	if input["password"] != "hunter2" {
+		t.Error("caller's input was modified")
+	}
+
+	// Chained in the wrong order, the logger sees no redacted copy and
+	// logs only field names.
+	logs.Reset()
+	h = Chain(&echoHandler{}, LoggingMiddleware(logger), RedactionMiddleware([]string{"password"}))
+	callHandler(context.Background(), h, "register", input, NewInMemoryStorage())
+	if out := logs.String(); strings.Contains(out, "hunter2") || !strings.Contains(out, `"input_keys":["email","password","ssn"]`) {
+		t.Errorf("log with RedactionMiddleware after LoggingMiddleware = %s", out)
+	}
+}
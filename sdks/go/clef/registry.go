@@ -1,26 +1,194 @@
 package clef
 
+import (
+	"fmt"
+	"time"
+)
+
 // registryEntry holds a handler and its associated storage.
 type registryEntry struct {
 	handler ConceptHandler
 	storage Storage
+	options ConceptOptions
+	// aliases maps deprecated action names to their replacements.
+	aliases map[string]string
+	// namespaced is set once storage is wrapped for namespace isolation.
+	namespaced bool
 }
 
 // registry maps concept URIs to handler+storage pairs.
 var registry = make(map[string]registryEntry)
 
 // Register associates a concept URI with a handler and optional storage.
-// If storage is nil, a new InMemoryStorage is created.
+// The URI must have the form urn:<namespace>/<ConceptName> (see
+// ValidateURIFormat), and its ConceptSlug must differ from those of the
+// other registered concepts. If storage is nil, a new InMemoryStorage is
+// created. If the handler implements ConceptValidator, Register runs
+// Validate first and returns its error, leaving the concept
+// unregistered. If the handler implements Warmer, its WarmUp starts in
+// the background.
+//
+// Example:
+//
+//	if err := clef.Register("urn:app/RateLimiter", &RateLimiterHandler{}, nil); err != nil {
+//	    log.Fatal(err)
+//	}
+func Register(uri string, handler ConceptHandler, storage Storage) error {
+	return RegisterWithOptions(uri, handler, storage, ConceptOptions{})
+}
+
+// MustRegister is Register that panics if registration fails, for use in
+// main or init.
+func MustRegister(uri string, handler ConceptHandler, storage Storage) {
+	if err := Register(uri, handler, storage); err != nil {
+		panic(err)
+	}
+}
+
+// ConceptValidator is an optional interface for handlers that check
+// their prerequisites, such as required storage entries, environment
+// variables or indexes, before they accept invocations.
+type ConceptValidator interface {
+	Validate(storage Storage) error
+}
+
+// validate runs handler's Validate, turning a panic into an error.
+func validate(uri string, handler ConceptHandler, storage Storage) (err error) {
+	v, ok := handler.(ConceptValidator)
+	if !ok {
+		return nil
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("clef: validating %s: panic: %v", uri, r)
+		}
+	}()
+	if err := v.Validate(storage); err != nil {
+		return fmt.Errorf("clef: validating %s: %w", uri, err)
+	}
+	return nil
+}
+
+// ConceptOptions configures how the transport runs a concept's handler.
+// The zero value runs the handler synchronously with no timeout.
+type ConceptOptions struct {
+	// Timeout bounds each invocation. Zero means no timeout.
+	Timeout time.Duration
+	// TimeoutMode selects what the transport returns when Timeout elapses.
+	TimeoutMode TimeoutMode
+
+	invariants    map[string][]func(output map[string]any) error
+	preconditions map[string][]func(storage Storage) error
+	quota         *callerQuota
+}
+
+// OutputInvariant adds a post-condition for action. After the handler
+// returns, every invariant for the action runs against its output; the
+// first error replaces the completion with an "invariant_violated"
+// variant. Writes the handler already made to storage are not undone.
 //
 // Example:
 //
-//	clef.Register("urn:app/RateLimiter", &RateLimiterHandler{}, nil)
-func Register(uri string, handler ConceptHandler, storage Storage) {
+//	var opts clef.ConceptOptions
+//	opts.OutputInvariant("withdraw", func(out map[string]any) error {
+//	    if bal, _ := out["balance"].(int); bal < 0 {
+//	        return errors.New("balance must never be negative")
+//	    }
+//	    return nil
+//	})
+func (o *ConceptOptions) OutputInvariant(action string, check func(output map[string]any) error) *ConceptOptions {
+	if o.invariants == nil {
+		o.invariants = make(map[string][]func(map[string]any) error)
+	}
+	o.invariants[action] = append(o.invariants[action], check)
+	return o
+}
+
+// checkInvariants returns the first invariant violation for action.
+func (o *ConceptOptions) checkInvariants(action string, output map[string]any) error {
+	for _, check := range o.invariants[action] {
+		if err := check(output); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Precondition adds a guard for action. Before the handler runs, every
+// precondition for the action is checked against the concept's storage
+// as it is at invocation time; the first error short-circuits the call
+// with a "precondition_failed" variant and the handler is not invoked.
+// Checks are not transactional, so a concurrent write may still race
+// the handler.
+//
+// Example:
+//
+//	opts.Precondition("createAdmin", func(s clef.Storage) error {
+//	    if len(s.Find("users", map[string]any{"role": "admin"})) > 0 {
+//	        return errors.New("an admin user already exists")
+//	    }
+//	    return nil
+//	})
+func (o *ConceptOptions) Precondition(action string, check func(storage Storage) error) *ConceptOptions {
+	if o.preconditions == nil {
+		o.preconditions = make(map[string][]func(Storage) error)
+	}
+	o.preconditions[action] = append(o.preconditions[action], check)
+	return o
+}
+
+// checkPreconditions returns the first failed precondition for action.
+func (o *ConceptOptions) checkPreconditions(action string, storage Storage) error {
+	for _, check := range o.preconditions[action] {
+		if err := check(storage); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisterWithOptions is Register with per-concept transport options.
+//
+// Example:
+//
+//	clef.RegisterWithOptions("urn:app/Search", &SearchHandler{}, nil, clef.ConceptOptions{
+//	    Timeout:     2 * time.Second,
+//	    TimeoutMode: clef.ReturnPartialOnTimeout,
+//	})
+func RegisterWithOptions(uri string, handler ConceptHandler, storage Storage, opts ConceptOptions) error {
+	entry, err := prepareEntry(uri, handler, storage, opts)
+	if err != nil {
+		return err
+	}
+	addEntry(uri, entry)
+	return nil
+}
+
+// prepareEntry checks uri and builds and validates its registry entry,
+// without registering it.
+func prepareEntry(uri string, handler ConceptHandler, storage Storage, opts ConceptOptions) (registryEntry, error) {
+	if err := ValidateURIFormat(uri); err != nil {
+		return registryEntry{}, err
+	}
+	if err := checkSlug(uri); err != nil {
+		return registryEntry{}, err
+	}
 	if storage == nil {
 		storage = NewInMemoryStorage()
 	}
-	registry[uri] = registryEntry{
+	entry := isolate(uri, registryEntry{
 		handler: handler,
 		storage: storage,
+		options: opts,
+	})
+	if err := validate(uri, handler, entry.storage); err != nil {
+		return registryEntry{}, err
 	}
+	return entry, nil
+}
+
+// addEntry registers an entry from prepareEntry and starts its warm-up.
+func addEntry(uri string, entry registryEntry) {
+	registry[uri] = entry
+	startWarmUp(uri, entry.handler, entry.storage)
 }
@@ -0,0 +1,98 @@
+package clef
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// configHandler requires a "config/main" entry in its storage.
+type configHandler struct {
+	echoHandler
+	panics bool
+}
+
+func (h *configHandler) Validate(storage Storage) error {
+	if h.panics {
+		panic("index build failed")
+	}
+	if _, ok := storage.Get("config", "main"); !ok {
+		return errors.New("missing config/main")
+	}
+	return nil
+}
+
+func TestRegisterSurfacesValidateError(t *testing.T) {
+	resetRegistry()
+	err := Register("urn:test/Config", &configHandler{}, nil)
+	if err == nil || !strings.Contains(err.Error(), "missing config/main") {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	if _, ok := registry["urn:test/Config"]; ok {
+		t.Error("expected failed concept to stay unregistered")
+	}
+
+	storage := NewInMemoryStorage()
+	storage.Put("config", "main", map[string]any{})
+	if err := Register("urn:test/Config", &configHandler{}, storage); err != nil {
+		t.Errorf("expected valid registration, got %v", err)
+	}
+}
+
+func TestRegisterRecoversValidatePanic(t *testing.T) {
+	resetRegistry()
+	err := Register("urn:test/Config", &configHandler{panics: true}, nil)
+	if err == nil || !strings.Contains(err.Error(), "index build failed") {
+		t.Errorf("expected panic as error, got %v", err)
+	}
+}
+
+func TestMustRegisterPanics(t *testing.T) {
+	resetRegistry()
+	defer func() {
+		if recover() == nil {
+			t.Error("expected MustRegister to panic")
+		}
+	}()
+	MustRegister("urn:test/Config", &configHandler{}, nil)
+}
+
+func TestRegisterValidatesURI(t *testing.T) {
+	resetRegistry()
+	for _, uri := range []string{"urn:app/RateLimiter", "urn:my-app.v2/Rate_Limiter", "urn:app/Cart#a"} {
+		if err := ValidateURIFormat(uri); err != nil {
+			t.Errorf("ValidateURIFormat(%q) = %v", uri, err)
+		}
+	}
+	for _, uri := range []string{"not-a-urn", "", "urn:app", "urn:/Foo", "urn:app/", "urn:app/9Lives", "urn:app/Foo/Bar", "URN:app/Foo", "urn:app/Foo bar"} {
+		if err := ValidateURIFormat(uri); err == nil {
+			t.Errorf("ValidateURIFormat(%q) = nil, want an error", uri)
+		}
+	}
+
+	if err := Register("not-a-urn", &echoHandler{}, nil); err == nil {
+		t.Error("Register accepted not-a-urn")
+	}
+	if _, ok := registry["not-a-urn"]; ok {
+		t.Error("invalid URI was registered")
+	}
+	if err := Register("urn:test/Echo", &echoHandler{}, nil); err != nil {
+		t.Errorf("Register(urn:test/Echo) = %v", err)
+	}
+}
+
+func TestNormalizeURI(t *testing.T) {
+	for in, want := range map[string]string{
+		"urn:MyApp/rate_limiter": "urn:myapp/RateLimiter",
+		"urn:APP/userProfile":    "urn:app/UserProfile",
+		"urn:app/cart-item#b":    "urn:app/CartItem#b",
+		"urn:app/RateLimiter":    "urn:app/RateLimiter",
+	} {
+		if got, err := NormalizeURI(in); err != nil || got != want {
+			t.Errorf("NormalizeURI(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := NormalizeURI("not-a-urn"); err == nil {
+		t.Error("NormalizeURI(not-a-urn) succeeded")
+	}
+}
@@ -0,0 +1,132 @@
+package clef
+
+import (
+	"context"
+	"fmt"
+	"runtime/metrics"
+	"sync"
+	"time"
+)
+
+// The sandbox middleware below is a best-effort defence against
+// misbehaving handlers, not isolation. Go cannot preempt or kill a
+// goroutine, so a handler that ignores its context keeps running (and
+// holding memory) after the middleware has returned an error for it, and
+// memory is accounted for the whole process, so concurrent handlers are
+// charged for each other's allocations. Untrusted handlers that need real
+// limits should run in a separate process or container.
+
+// memoryPollInterval is how often WithMemoryLimit samples allocations.
+const memoryPollInterval = 5 * time.Millisecond
+
+// WithCPUTimeout runs the handler in its own goroutine and, once d has
+// elapsed, cancels its context (ctx.Err() becomes context.Canceled) and
+// returns a "timeout" error without waiting for the handler to return.
+//
+// Example:
+//
+//	clef.MustRegister("urn:thirdparty/Resize", clef.Chain(h, clef.WithCPUTimeout(2*time.Second)), nil)
+func WithCPUTimeout(d time.Duration) MiddlewareFunc {
+	return func(next ConceptHandler) ConceptHandler {
+		return HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			timer := time.NewTimer(d)
+			defer timer.Stop()
+
+			return runSandboxed(ctx, next, action, input, storage, func() map[string]any {
+				select {
+				case <-timer.C:
+					cancel()
+					return map[string]any{"variant": "error", "code": "timeout", "message": "handler exceeded CPU timeout of " + d.String()}
+				case <-ctx.Done():
+					return sandboxCancelled(ctx)
+				}
+			})
+		})
+	}
+}
+
+// WithMemoryLimit runs the handler in its own goroutine and returns a
+// "memory_limit_exceeded" error, cancelling the handler's context, once
+// more than limit bytes have been allocated on the heap since the call
+// started. Allocations are counted whether or not they are freed again,
+// and, since the runtime counts them for the whole process, concurrent
+// calls are charged for each other's. Garbage collector settings are
+// left alone. A deadline on the invocation context, such as
+// ConceptOptions.Timeout, still applies.
+func WithMemoryLimit(limit int64) MiddlewareFunc {
+	return func(next ConceptHandler) ConceptHandler {
+		return HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			baseline := allocatedBytes()
+
+			ticker := time.NewTicker(memoryPollInterval)
+			defer ticker.Stop()
+			return runSandboxed(ctx, next, action, input, storage, func() map[string]any {
+				for {
+					select {
+					case <-ticker.C:
+						if allocated := int64(allocatedBytes() - baseline); allocated > limit {
+							cancel()
+							return map[string]any{
+								"variant": "error",
+								"code":    "memory_limit_exceeded",
+								"message": fmt.Sprintf("handler allocated %d bytes, limit %d", allocated, limit),
+							}
+						}
+					case <-ctx.Done():
+						return sandboxCancelled(ctx)
+					}
+				}
+			})
+		})
+	}
+}
+
+// runSandboxed calls next in a goroutine and returns its result, or the
+// result of watch if watch returns first. watch must return when ctx is
+// done.
+func runSandboxed(ctx context.Context, next ConceptHandler, action string, input map[string]any, storage Storage, watch func() map[string]any) map[string]any {
+	done := make(chan map[string]any, 1)
+	go func() {
+		done <- callHandlerRecovered(ctx, next, action, input, storage)
+	}()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	tripped := make(chan map[string]any, 1)
+	go func() {
+		select {
+		case tripped <- watch():
+		case <-stop:
+		}
+	}()
+
+	select {
+	case result := <-done:
+		return result
+	case result := <-tripped:
+		return result
+	}
+}
+
+// sandboxCancelled is the result when the invocation context ends first.
+func sandboxCancelled(ctx context.Context) map[string]any {
+	return map[string]any{"variant": "error", "code": "timeout", "message": ctx.Err().Error()}
+}
+
+var (
+	memSampleMu sync.Mutex
+	memSample   = []metrics.Sample{{Name: "/gc/heap/allocs:bytes"}}
+)
+
+// allocatedBytes returns the bytes allocated on the heap since the
+// process started, without stopping the world.
+func allocatedBytes() uint64 {
+	memSampleMu.Lock()
+	defer memSampleMu.Unlock()
+	metrics.Read(memSample)
+	return memSample[0].Value.Uint64()
+}
@@ -0,0 +1,74 @@
+package clef
+
+import (
+	"context"
+	"runtime/debug"
+	"testing"
+	"time"
+)
+
+func TestWithCPUTimeout(t *testing.T) {
+	cancelled := make(chan error, 1)
+	slow := HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		select {
+		case <-time.After(5 * time.Second):
+		case <-ctx.Done():
+			cancelled <- ctx.Err()
+		}
+		return map[string]any{"variant": "ok"}
+	})
+	d := 50 * time.Millisecond
+	h := Chain(slow, WithCPUTimeout(d))
+
+	start := time.Now()
+	out := callHandler(context.Background(), h, "run", nil, NewInMemoryStorage())
+	if elapsed := time.Since(start); elapsed > d+100*time.Millisecond {
+		t.Errorf("returned after %v, want under %v", elapsed, d+100*time.Millisecond)
+	}
+	if out["variant"] != "error" || out["code"] != "timeout" {
+		t.Fatalf("output = %v", out)
+	}
+	select {
+	case err := <-cancelled:
+		if err != context.Canceled {
+			t.Errorf("handler ctx.Err() = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("handler context was not cancelled")
+	}
+
+	fast := Chain(&echoHandler{}, WithCPUTimeout(time.Second))
+	if out := callHandler(context.Background(), fast, "echo", map[string]any{"message": "hi"}, NewInMemoryStorage()); out["message"] != "hi" {
+		t.Errorf("fast handler output = %v", out)
+	}
+}
+
+func TestWithMemoryLimit(t *testing.T) {
+	before := debug.SetMemoryLimit(-1)
+	gcPercent := debug.SetGCPercent(-1)
+	debug.SetGCPercent(gcPercent)
+	hog := HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		var held [][]byte
+		for ctx.Err() == nil && len(held) < 1024 {
+			held = append(held, make([]byte, 1<<20))
+			time.Sleep(time.Millisecond)
+		}
+		return map[string]any{"variant": "ok", "held": len(held)}
+	})
+
+	out := callHandler(context.Background(), Chain(hog, WithMemoryLimit(16<<20)), "run", nil, NewInMemoryStorage())
+	if out["variant"] != "error" || out["code"] != "memory_limit_exceeded" {
+		t.Fatalf("output = %v", out)
+	}
+	if after := debug.SetMemoryLimit(-1); after != before {
+		t.Errorf("memory limit = %d after call, want %d untouched", after, before)
+	}
+	if after := debug.SetGCPercent(gcPercent); after != gcPercent {
+		t.Errorf("GC percent = %d after call, want %d untouched", after, gcPercent)
+	}
+
+	small := Chain(&echoHandler{}, WithMemoryLimit(16<<20))
+	if out := callHandler(context.Background(), small, "echo", map[string]any{"message": "hi"}, NewInMemoryStorage()); out["message"] != "hi" {
+		t.Errorf("small handler output = %v", out)
+	}
+}
@@ -0,0 +1,255 @@
+package clef
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SchedulerConcept is the concept whose registered storage holds the jobs
+// of ScheduleInvoke, in the "jobs" relation. Register it with persistent
+// storage, e.g. via RegisterScheduler, so scheduled jobs survive a
+// restart and can be re-armed with ResumeScheduled. Without it, jobs are
+// kept in memory.
+const SchedulerConcept = "urn:clef/Scheduler"
+
+const schedulerRelation = "jobs"
+
+// Scheduled job statuses, as reported by GET /jobs/{jobID}.
+const (
+	JobScheduled = "scheduled"
+	JobRunning   = "running"
+	JobDone      = "done"
+	JobCancelled = "cancelled"
+)
+
+// JobRetention is how long a job's record is kept for GET /jobs/{jobID}
+// after it finishes: completes, is cancelled or, for async actions, gets
+// its result.
+const JobRetention = 24 * time.Hour
+
+var (
+	schedulerMu       sync.Mutex
+	schedulerTimers   = make(map[string]*time.Timer)
+	schedulerFallback = NewInMemoryStorage()
+)
+
+// schedulerStorage returns the storage of SchedulerConcept, or the
+// in-memory fallback.
+func schedulerStorage() Storage {
+	if entry, ok := registry[SchedulerConcept]; ok {
+		return entry.storage
+	}
+	return schedulerFallback
+}
+
+// RegisterScheduler registers SchedulerConcept with storage for its jobs
+// and a handler with two actions taking {"job_id": ...}: "status", which
+// returns the job record, and "cancel".
+func RegisterScheduler(storage Storage) error {
+	return Register(SchedulerConcept, HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		jobID, _ := input["job_id"].(string)
+		record, ok := storage.Get(schedulerRelation, jobID)
+		if !ok {
+			return map[string]any{"variant": "error", "code": "not_found", "message": "unknown job: " + jobID}
+		}
+		switch action {
+		case "status":
+			out := map[string]any{"variant": "ok"}
+			for k, v := range record {
+				out[k] = v
+			}
+			return out
+		case "cancel":
+			if !CancelScheduled(jobID) {
+				status, _ := record["status"].(string)
+				return map[string]any{"variant": "error", "code": "conflict", "message": "job is " + status}
+			}
+			return map[string]any{"variant": "ok"}
+		default:
+			return map[string]any{"variant": "error", "message": "unknown action: " + action}
+		}
+	}), storage)
+}
+
+// ScheduleInvoke runs inv with InvokeLocal after delay and returns the
+// job's ID, which GET /jobs/{jobID} reports on and DELETE /jobs/{jobID}
+// or CancelScheduled cancels. The job is recorded in SchedulerConcept's
+// storage. Values in ctx, such as the calling invocation's flow, are
+// kept; its cancellation is not.
+//
+// Example:
+//
+//	jobID, err := clef.ScheduleInvoke(ctx, 24*time.Hour, clef.ActionInvocation{
+//	    Concept: "urn:app/Email", Action: "sendReminder", Input: map[string]any{"user": id},
+//	})
+func ScheduleInvoke(ctx context.Context, delay time.Duration, inv ActionInvocation) (jobID string, err error) {
+	jobID = uuid.NewString()
+	runAt := time.Now().Add(delay).UTC()
+	record := map[string]any{
+		"jobId":   jobID,
+		"status":  JobScheduled,
+		"runAt":   runAt.Format(time.RFC3339Nano),
+		"concept": inv.Concept,
+		"action":  inv.Action,
+		"input":   inv.Input,
+		"id":      inv.ID,
+		"flow":    inv.Flow,
+	}
+	if ok, _ := schedulerStorage().CompareAndSwap(schedulerRelation, jobID, nil, record, nil); !ok {
+		return "", &ScheduleError{JobID: jobID}
+	}
+	armJob(context.WithoutCancel(ctx), jobID, inv, delay)
+	return jobID, nil
+}
+
+// ScheduleError reports a job ID collision in the scheduler storage.
+type ScheduleError struct {
+	JobID string
+}
+
+func (e *ScheduleError) Error() string {
+	return "clef: scheduled job " + e.JobID + " already exists"
+}
+
+// armJob starts the timer that runs a scheduled job.
+func armJob(ctx context.Context, jobID string, inv ActionInvocation, delay time.Duration) {
+	schedulerMu.Lock()
+	defer schedulerMu.Unlock()
+	schedulerTimers[jobID] = time.AfterFunc(delay, func() {
+		schedulerMu.Lock()
+		delete(schedulerTimers, jobID)
+		schedulerMu.Unlock()
+		runScheduled(ctx, jobID, inv)
+	})
+}
+
+// runScheduled claims a scheduled job, so a concurrent cancel loses, then
+// runs it and records the result.
+func runScheduled(ctx context.Context, jobID string, inv ActionInvocation) {
+	storage := schedulerStorage()
+	record, ok := storage.Get(schedulerRelation, jobID)
+	if !ok || record["status"] != JobScheduled {
+		return
+	}
+	running := withStatus(record, JobRunning)
+	if ok, _ := storage.CompareAndSwap(schedulerRelation, jobID, record, running, []string{"status"}); !ok {
+		return
+	}
+	c := InvokeLocal(ctx, inv)
+	done := withStatus(running, JobDone)
+	done["variant"] = c.Variant
+	done["output"] = c.Output
+	done["completedAt"] = time.Now().UTC().Format(time.RFC3339Nano)
+	storage.Put(schedulerRelation, jobID, done)
+	evictFinishedJobs(storage, time.Now())
+}
+
+// evictFinishedJobs deletes the records of jobs that completed or were
+// cancelled more than JobRetention before now.
+func evictFinishedJobs(storage Storage, now time.Time) {
+	var expired []string
+	for _, status := range []string{JobDone, JobCancelled} {
+		for _, record := range storage.Find(schedulerRelation, map[string]any{"status": status}) {
+			completedAt, _ := record["completedAt"].(string)
+			at, err := time.Parse(time.RFC3339Nano, completedAt)
+			if jobID, ok := record["jobId"].(string); ok && err == nil && now.Sub(at) > JobRetention {
+				expired = append(expired, jobID)
+			}
+		}
+	}
+	if len(expired) > 0 {
+		storage.BulkDelete(schedulerRelation, expired)
+	}
+}
+
+func withStatus(record map[string]any, status string) map[string]any {
+	out := make(map[string]any, len(record)+1)
+	for k, v := range record {
+		out[k] = v
+	}
+	out["status"] = status
+	return out
+}
+
+// CancelScheduled cancels a job that has not started and reports whether
+// it did.
+func CancelScheduled(jobID string) bool {
+	storage := schedulerStorage()
+	record, ok := storage.Get(schedulerRelation, jobID)
+	if !ok || record["status"] != JobScheduled {
+		return false
+	}
+	cancelled := withStatus(record, JobCancelled)
+	cancelled["completedAt"] = time.Now().UTC().Format(time.RFC3339Nano)
+	if ok, _ := storage.CompareAndSwap(schedulerRelation, jobID, record, cancelled, []string{"status"}); !ok {
+		return false
+	}
+	schedulerMu.Lock()
+	if t, ok := schedulerTimers[jobID]; ok {
+		t.Stop()
+		delete(schedulerTimers, jobID)
+	}
+	schedulerMu.Unlock()
+	return true
+}
+
+// ResumeScheduled re-arms the scheduled jobs found in SchedulerConcept's
+// storage that have no timer in this process, typically after a restart,
+// and evicts finished jobs older than JobRetention. Jobs whose time has
+// passed run at once; records without a concept and action are skipped.
+// As with ScheduleInvoke, jobs run with InvokeLocal in ctx, so resuming
+// from within an invocation runs them through that server's options. It
+// returns the number re-armed.
+func ResumeScheduled(ctx context.Context) int {
+	storage := schedulerStorage()
+	evictFinishedJobs(storage, time.Now())
+	n := 0
+	for _, record := range storage.Find(schedulerRelation, map[string]any{"status": JobScheduled}) {
+		jobID, _ := record["jobId"].(string)
+		schedulerMu.Lock()
+		_, armed := schedulerTimers[jobID]
+		schedulerMu.Unlock()
+		if armed {
+			continue
+		}
+		var inv ActionInvocation
+		inv.Concept, _ = record["concept"].(string)
+		inv.Action, _ = record["action"].(string)
+		if jobID == "" || inv.Concept == "" || inv.Action == "" {
+			continue
+		}
+		runAtText, _ := record["runAt"].(string)
+		runAt, _ := time.Parse(time.RFC3339Nano, runAtText)
+		inv.Input, _ = record["input"].(map[string]any)
+		inv.ID, _ = record["id"].(string)
+		inv.Flow, _ = record["flow"].(string)
+		armJob(context.WithoutCancel(ctx), jobID, inv, max(time.Until(runAt), 0))
+		n++
+	}
+	return n
+}
+
+// scheduledJob returns the record of a scheduled job.
+func scheduledJob(jobID string) (map[string]any, bool) {
+	return schedulerStorage().Get(schedulerRelation, jobID)
+}
+
+// handleCancelJob serves DELETE /jobs/{jobID} for scheduled jobs.
+func (s *server) handleCancelJob(w http.ResponseWriter, r *http.Request) {
+	jobID := r.PathValue("jobID")
+	record, ok := scheduledJob(jobID)
+	if !ok {
+		http.Error(w, "unknown job", http.StatusNotFound)
+		return
+	}
+	if !CancelScheduled(jobID) {
+		record, _ = scheduledJob(jobID)
+		s.writeJSONStatus(w, r, http.StatusConflict, map[string]any{"status": record["status"], "error": "job can no longer be cancelled"})
+		return
+	}
+	s.writeJSON(w, r, map[string]any{"jobId": jobID, "status": JobCancelled})
+}
@@ -0,0 +1,150 @@
+package clef
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingHandler counts its invocations.
+type countingHandler struct {
+	calls atomic.Int32
+}
+
+func (h *countingHandler) Handle(action string, input map[string]any, storage Storage) map[string]any {
+	h.calls.Add(1)
+	return map[string]any{"variant": "ok"}
+}
+
+func jobRecord(t *testing.T, h http.Handler, jobID string) map[string]any {
+	t.Helper()
+	rec := jobRequest(h, http.MethodGet, "/jobs/"+jobID)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /jobs/%s: %d %s", jobID, rec.Code, rec.Body)
+	}
+	var out map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&out); err != nil {
+		t.Fatalf("decode job: %v", err)
+	}
+	return out
+}
+
+func TestScheduleInvoke(t *testing.T) {
+	resetRegistry()
+	counter := &countingHandler{}
+	Register("urn:test/Counter", counter, nil)
+	h := NewHandler(WithJobEndpoint("secret"))
+
+	jobID, err := ScheduleInvoke(context.Background(), 50*time.Millisecond, ActionInvocation{Concept: "urn:test/Counter", Action: "tick"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := counter.calls.Load(); n != 0 {
+		t.Fatalf("handler ran %d times before the delay", n)
+	}
+	if got := jobRecord(t, h, jobID)["status"]; got != JobScheduled {
+		t.Errorf("status before delay = %v, want %q", got, JobScheduled)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if n := counter.calls.Load(); n != 1 {
+		t.Fatalf("handler ran %d times, want 1", n)
+	}
+	record := jobRecord(t, h, jobID)
+	if record["status"] != JobDone || record["variant"] != "ok" {
+		t.Errorf("unexpected job record %v", record)
+	}
+	if rec := jobRequest(h, http.MethodDelete, "/jobs/"+jobID); rec.Code != http.StatusConflict {
+		t.Errorf("DELETE of a finished job: %d, want 409", rec.Code)
+	}
+}
+
+func TestScheduleInvokeCancel(t *testing.T) {
+	resetRegistry()
+	counter := &countingHandler{}
+	Register("urn:test/Counter", counter, nil)
+	storage := NewInMemoryStorage()
+	if err := RegisterScheduler(storage); err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(WithJobEndpoint("secret"))
+
+	jobID, err := ScheduleInvoke(context.Background(), 50*time.Millisecond, ActionInvocation{Concept: "urn:test/Counter", Action: "tick"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := storage.Get("jobs", jobID); !ok {
+		t.Fatal("job not persisted in the scheduler storage")
+	}
+	if rec := jobRequest(h, http.MethodDelete, "/jobs/"+jobID); rec.Code != http.StatusOK {
+		t.Fatalf("DELETE: %d %s", rec.Code, rec.Body)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if n := counter.calls.Load(); n != 0 {
+		t.Errorf("cancelled job ran %d times", n)
+	}
+	if got := jobRecord(t, h, jobID)["status"]; got != JobCancelled {
+		t.Errorf("status = %v, want %q", got, JobCancelled)
+	}
+	if rec := jobRequest(h, http.MethodDelete, "/jobs/unknown"); rec.Code != http.StatusNotFound {
+		t.Errorf("DELETE of unknown job: %d, want 404", rec.Code)
+	}
+}
+
+func TestResumeScheduled(t *testing.T) {
+	resetRegistry()
+	counter := &countingHandler{}
+	Register("urn:test/Counter", counter, nil)
+	storage := NewInMemoryStorage()
+	RegisterScheduler(storage)
+	storage.Put("jobs", "j1", map[string]any{
+		"jobId": "j1", "status": JobScheduled, "runAt": time.Now().UTC().Format(time.RFC3339Nano),
+		"concept": "urn:test/Counter", "action": "tick",
+	})
+
+	if n := ResumeScheduled(context.Background()); n != 1 {
+		t.Fatalf("ResumeScheduled = %d, want 1", n)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := counter.calls.Load(); n != 1 {
+		t.Errorf("resumed job ran %d times, want 1", n)
+	}
+}
+
+func TestSchedulerToleratesMalformedRecords(t *testing.T) {
+	resetRegistry()
+	storage := NewInMemoryStorage()
+	RegisterScheduler(storage)
+	storage.Put("jobs", "j1", map[string]any{"jobId": "j1", "status": 3})
+	storage.Put("jobs", "j2", map[string]any{"jobId": "j2", "status": JobScheduled})
+
+	c := InvokeLocal(context.Background(), ActionInvocation{Concept: SchedulerConcept, Action: "cancel", Input: map[string]any{"job_id": "j1"}})
+	if c.Output["code"] != "conflict" {
+		t.Errorf("cancel of a malformed job = %+v", c.Output)
+	}
+	if n := ResumeScheduled(context.Background()); n != 0 {
+		t.Errorf("ResumeScheduled re-armed %d malformed jobs", n)
+	}
+}
+
+func TestEvictFinishedJobs(t *testing.T) {
+	storage := NewInMemoryStorage()
+	now := time.Now()
+	old := now.Add(-JobRetention - time.Minute).UTC().Format(time.RFC3339Nano)
+	recent := now.UTC().Format(time.RFC3339Nano)
+	storage.Put("jobs", "done-old", map[string]any{"jobId": "done-old", "status": JobDone, "completedAt": old})
+	storage.Put("jobs", "cancelled-old", map[string]any{"jobId": "cancelled-old", "status": JobCancelled, "completedAt": old})
+	storage.Put("jobs", "done-recent", map[string]any{"jobId": "done-recent", "status": JobDone, "completedAt": recent})
+	storage.Put("jobs", "scheduled", map[string]any{"jobId": "scheduled", "status": JobScheduled})
+
+	evictFinishedJobs(storage, now)
+	for key, kept := range map[string]bool{"done-old": false, "cancelled-old": false, "done-recent": true, "scheduled": true} {
+		if _, ok := storage.Get("jobs", key); ok != kept {
+			t.Errorf("%s kept = %v, want %v", key, ok, kept)
+		}
+	}
+}
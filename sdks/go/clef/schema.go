@@ -0,0 +1,110 @@
+package clef
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// SchemaValidationMiddleware validates each action's input against a JSON
+// Schema before the handler runs. schemas maps action names to schema
+// documents; actions without a schema pass through unchecked. Invalid
+// input gets an error completion with code "validation_failed" and an
+// "errors" list of {path, message} entries, and the handler is not
+// called. Metadata fields, whose names start with "_" (see
+// InputEnricher), are not validated, so schemas need not list them. It
+// panics if a schema does not compile, since that is a programming error
+// caught at startup.
+//
+// Example:
+//
+//	clef.SchemaValidationMiddleware(map[string]map[string]any{
+//	    "register": {
+//	        "type":     "object",
+//	        "required": []any{"username"},
+//	        "properties": map[string]any{
+//	            "username": map[string]any{"type": "string"},
+//	        },
+//	    },
+//	})
+func SchemaValidationMiddleware(schemas map[string]map[string]any) MiddlewareFunc {
+	compiled := make(map[string]*jsonschema.Schema, len(schemas))
+	for action, doc := range schemas {
+		schema, err := compileSchema(action, doc)
+		if err != nil {
+			panic(fmt.Sprintf("clef: schema for action %q: %v", action, err))
+		}
+		compiled[action] = schema
+	}
+
+	return func(next ConceptHandler) ConceptHandler {
+		return HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+			schema, ok := compiled[action]
+			if !ok {
+				return callHandler(ctx, next, action, input, storage)
+			}
+			if errs := validateInput(schema, withoutMetadata(input)); len(errs) > 0 {
+				return map[string]any{
+					"variant": "error",
+					"code":    "validation_failed",
+					"message": "input does not match schema for " + action,
+					"errors":  errs,
+				}
+			}
+			return callHandler(ctx, next, action, input, storage)
+		})
+	}
+}
+
+func compileSchema(action string, doc map[string]any) (*jsonschema.Schema, error) {
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	url := "clef://schemas/" + action + ".json"
+	c := jsonschema.NewCompiler()
+	if err := c.AddResource(url, bytes.NewReader(raw)); err != nil {
+		return nil, err
+	}
+	return c.Compile(url)
+}
+
+// validateInput returns one {path, message} entry per failed constraint.
+func validateInput(schema *jsonschema.Schema, input map[string]any) []map[string]any {
+	// Round-trip through JSON so Go-typed values (ints, structs) are
+	// validated the same way as decoded wire input.
+	raw, err := json.Marshal(input)
+	if err != nil {
+		return []map[string]any{{"path": "", "message": err.Error()}}
+	}
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return []map[string]any{{"path": "", "message": err.Error()}}
+	}
+
+	err = schema.Validate(v)
+	if err == nil {
+		return nil
+	}
+	var ve *jsonschema.ValidationError
+	if !errors.As(err, &ve) {
+		return []map[string]any{{"path": "", "message": err.Error()}}
+	}
+	var errs []map[string]any
+	collectLeafErrors(ve, &errs)
+	return errs
+}
+
+func collectLeafErrors(ve *jsonschema.ValidationError, out *[]map[string]any) {
+	if len(ve.Causes) == 0 {
+		*out = append(*out, map[string]any{"path": ve.InstanceLocation, "message": ve.Message})
+		return
+	}
+	for _, cause := range ve.Causes {
+		collectLeafErrors(cause, out)
+	}
+}
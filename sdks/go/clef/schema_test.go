@@ -0,0 +1,71 @@
+package clef
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+var usernameSchema = map[string]map[string]any{
+	"register": {
+		"type":     "object",
+		"required": []any{"username"},
+		"properties": map[string]any{
+			"username": map[string]any{"type": "string"},
+		},
+	},
+}
+
+func TestSchemaValidationMiddleware(t *testing.T) {
+	calls := 0
+	inner := HandlerFunc(func(_ context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		calls++
+		return map[string]any{"variant": "ok"}
+	})
+	h := Chain(inner, SchemaValidationMiddleware(usernameSchema))
+	s := NewInMemoryStorage()
+
+	if out := h.Handle("register", map[string]any{"username": "alice"}, s); out["variant"] != "ok" {
+		t.Fatalf("expected valid input to pass, got %v", out)
+	}
+	if calls != 1 {
+		t.Fatalf("expected handler to run once, ran %d", calls)
+	}
+
+	out := h.Handle("register", map[string]any{"email": "a@example.com"}, s)
+	if out["variant"] != "error" || out["code"] != "validation_failed" {
+		t.Fatalf("expected validation_failed, got %v", out)
+	}
+	errs, _ := out["errors"].([]map[string]any)
+	if len(errs) == 0 {
+		t.Fatalf("expected validation errors, got %v", out["errors"])
+	}
+	if calls != 1 {
+		t.Error("handler must not run for invalid input")
+	}
+
+	if out := h.Handle("register", map[string]any{"username": 42}, s); out["code"] != "validation_failed" {
+		t.Errorf("expected wrong type to fail validation, got %v", out)
+	}
+	if out := h.Handle("login", map[string]any{}, s); out["variant"] != "ok" {
+		t.Errorf("expected action without schema to pass, got %v", out)
+	}
+}
+
+func TestSchemaValidationMiddlewareStatus(t *testing.T) {
+	resetRegistry()
+	Register("urn:test/User", Chain(&echoHandler{}, SchemaValidationMiddleware(usernameSchema)), nil)
+	rec := doRequest(NewHandler(), http.MethodPost, "/invoke", `{"concept":"urn:test/User","action":"register","input":{}}`)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422, got %d", rec.Code)
+	}
+}
+
+func TestSchemaValidationMiddlewarePanicsOnBadSchema(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for invalid schema")
+		}
+	}()
+	SchemaValidationMiddleware(map[string]map[string]any{"x": {"type": 12}})
+}
@@ -0,0 +1,149 @@
+package clef
+
+import (
+	"sort"
+	"time"
+)
+
+// sortRecords stably sorts records by field using natural ordering:
+// strings lexicographically, numbers numerically and time.Time
+// chronologically. Records missing the field compare greater than any
+// value, so they sort last when ascending and first when descending.
+func sortRecords(records []map[string]any, field string, ascending bool) {
+	sort.SliceStable(records, func(i, j int) bool {
+		a, aok := records[i][field]
+		b, bok := records[j][field]
+		if !aok || a == nil || !bok || b == nil {
+			aMissing := !aok || a == nil
+			bMissing := !bok || b == nil
+			if aMissing == bMissing {
+				return false
+			}
+			return bMissing == ascending
+		}
+		c := compareValues(a, b)
+		if ascending {
+			return c < 0
+		}
+		return c > 0
+	})
+}
+
+// compareValues returns -1, 0 or 1. Values of different kinds are ordered
+// by kind: numbers, then strings, then times, then booleans.
+func compareValues(a, b any) int {
+	ka, kb := valueKind(a), valueKind(b)
+	if ka != kb {
+		return cmpInt(ka, kb)
+	}
+	switch ka {
+	case kindNumber:
+		x, _ := toFloat(a)
+		y, _ := toFloat(b)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	case kindString:
+		x, y := a.(string), b.(string)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	case kindTime:
+		return a.(time.Time).Compare(b.(time.Time))
+	case kindBool:
+		x, y := a.(bool), b.(bool)
+		if x == y {
+			return 0
+		}
+		if !x {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+const (
+	kindNumber = iota
+	kindString
+	kindTime
+	kindBool
+	kindOther
+)
+
+func valueKind(v any) int {
+	if _, ok := toFloat(v); ok {
+		return kindNumber
+	}
+	switch v.(type) {
+	case string:
+		return kindString
+	case time.Time:
+		return kindTime
+	case bool:
+		return kindBool
+	}
+	return kindOther
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// toInt64 is toFloat for integers. Integers stored through a JSON-based
+// storage such as RedisStorage read back as float64, so both are
+// accepted.
+func toInt64(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return n, true
+	case int:
+		return int64(n), true
+	}
+	f, ok := toFloat(v)
+	return int64(f), ok
+}
+
+func cmpInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,86 @@
+package clef
+
+import (
+	"testing"
+	"time"
+)
+
+func names(records []map[string]any) []string {
+	out := make([]string, len(records))
+	for i, r := range records {
+		out[i], _ = r["name"].(string)
+	}
+	return out
+}
+
+func assertOrder(t *testing.T, got []map[string]any, want ...string) {
+	t.Helper()
+	g := names(got)
+	if len(g) != len(want) {
+		t.Fatalf("expected %v, got %v", want, g)
+	}
+	for i := range want {
+		if g[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, g)
+		}
+	}
+}
+
+func TestFindSortedByInt(t *testing.T) {
+	s := NewInMemoryStorage()
+	s.Put("users", "c", map[string]any{"name": "Carol", "age": 41})
+	s.Put("users", "a", map[string]any{"name": "Alice", "age": 30})
+	s.Put("users", "b", map[string]any{"name": "Bob", "age": 25})
+
+	assertOrder(t, s.FindSorted("users", nil, "age", true), "Bob", "Alice", "Carol")
+	assertOrder(t, s.FindSorted("users", nil, "age", false), "Carol", "Alice", "Bob")
+}
+
+func TestFindSortedByString(t *testing.T) {
+	s := NewInMemoryStorage()
+	s.Put("users", "1", map[string]any{"name": "Carol"})
+	s.Put("users", "2", map[string]any{"name": "Alice"})
+	s.Put("users", "3", map[string]any{"name": "Bob"})
+
+	assertOrder(t, s.FindSorted("users", nil, "name", true), "Alice", "Bob", "Carol")
+}
+
+func TestFindSortedByTime(t *testing.T) {
+	s := NewInMemoryStorage()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.Put("events", "x", map[string]any{"name": "late", "at": base.Add(2 * time.Hour)})
+	s.Put("events", "y", map[string]any{"name": "early", "at": base})
+
+	assertOrder(t, s.FindSorted("events", nil, "at", true), "early", "late")
+}
+
+func TestFindSortedMissingFieldSortsLast(t *testing.T) {
+	s := NewInMemoryStorage()
+	s.Put("users", "a", map[string]any{"name": "NoAge"})
+	s.Put("users", "b", map[string]any{"name": "Old", "age": 80})
+	s.Put("users", "c", map[string]any{"name": "Young", "age": 20})
+
+	assertOrder(t, s.FindSorted("users", nil, "age", true), "Young", "Old", "NoAge")
+}
+
+func TestFindSortedTiesKeepInsertionOrder(t *testing.T) {
+	s := NewInMemoryStorage()
+	for _, n := range []string{"e", "d", "c", "b", "a"} {
+		s.Put("users", n, map[string]any{"name": n, "group": 1})
+	}
+	// Overwriting must not move an entry to the end.
+	s.Put("users", "d", map[string]any{"name": "d", "group": 1})
+
+	for i := 0; i < 20; i++ {
+		assertOrder(t, s.FindSorted("users", nil, "group", true), "e", "d", "c", "b", "a")
+	}
+}
+
+func TestFindSortedAppliesFilter(t *testing.T) {
+	s := NewInMemoryStorage()
+	s.Put("users", "a", map[string]any{"name": "Alice", "role": "admin", "age": 30})
+	s.Put("users", "b", map[string]any{"name": "Bob", "role": "user", "age": 25})
+	s.Put("users", "c", map[string]any{"name": "Carol", "role": "admin", "age": 20})
+
+	assertOrder(t, s.FindSorted("users", map[string]any{"role": "admin"}, "age", true), "Carol", "Alice")
+}
@@ -1,6 +1,14 @@
 package clef
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"reflect"
+	"slices"
+	"sort"
 	"sync"
 	"time"
 )
@@ -13,23 +21,158 @@ type Storage interface {
 	Put(relation, key string, value map[string]any)
 	Delete(relation, key string) bool
 	Find(relation string, args map[string]any) []map[string]any
+	// FindSorted is Find with results ordered by sortField. Ties keep
+	// insertion order.
+	FindSorted(relation string, args map[string]any, sortField string, ascending bool) []map[string]any
+	// BulkPut writes every entry, keyed by entry key, as one batch and
+	// returns the number written. Readers see either none or all of it.
+	BulkPut(relation string, entries map[string]map[string]any) int
+	// BulkDelete deletes keys as one batch and returns the number that
+	// existed.
+	BulkDelete(relation string, keys []string) int
+	// CompareAndSwap atomically replaces the entry at key with replacement
+	// if its compareFields equal those of expected. A nil expected means
+	// the key must not exist, in which case the entry is created. It
+	// returns whether the swap happened and the entry now stored (nil if
+	// there is none).
+	CompareAndSwap(relation, key string, expected, replacement map[string]any, compareFields []string) (swapped bool, current map[string]any)
+	// Range returns the entries whose keys lie between startKey and
+	// endKey, sorted by key. An empty bound is unbounded; inclusive
+	// selects whether the bounds themselves are included.
+	Range(relation, startKey, endKey string, inclusive bool) []map[string]any
+}
+
+// Enumerable is implemented by storages that can list their relations
+// and the keys within a relation. Admin tooling such as ConsistencyCheck
+// requires it to walk every stored entry.
+type Enumerable interface {
+	Relations() []string
+	Keys(relation string) []string
+}
+
+// ContextualStorage is implemented by storage decorators that need the
+// invocation context, for example to record which invocation made a
+// write. Before calling a handler the transport calls WithContext and
+// passes the returned Storage to the handler in place of the original.
+type ContextualStorage interface {
+	WithContext(ctx context.Context) Storage
+}
+
+// bindStorage returns storage bound to ctx if it is a ContextualStorage.
+func bindStorage(ctx context.Context, storage Storage) Storage {
+	if cs, ok := storage.(ContextualStorage); ok {
+		return cs.WithContext(ctx)
+	}
+	return storage
+}
+
+// storageErrors collects the errors storages report during one handler
+// call, see ReportStorageError.
+type storageErrors struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+type storageErrorsKey struct{}
+
+// ReportStorageError records err, a failure the Storage methods cannot
+// return, such as a write over quota or an entry that does not decrypt,
+// against the handler call ctx belongs to. Storage decorators call it
+// with the context they were bound to (see ContextualStorage); the
+// transport then answers the call with an error result instead of the
+// handler's output. Outside a handler call err is logged.
+func ReportStorageError(ctx context.Context, err error) {
+	if ctx != nil {
+		if errs, ok := ctx.Value(storageErrorsKey{}).(*storageErrors); ok {
+			errs.mu.Lock()
+			errs.errs = append(errs.errs, err)
+			errs.mu.Unlock()
+			return
+		}
+	}
+	log.Print(err)
+}
+
+// withStorageErrors returns ctx with a collector for reported storage
+// errors, reusing the one of an enclosing handler call.
+func withStorageErrors(ctx context.Context) (context.Context, *storageErrors) {
+	if errs, ok := ctx.Value(storageErrorsKey{}).(*storageErrors); ok {
+		return ctx, errs
+	}
+	errs := &storageErrors{}
+	return context.WithValue(ctx, storageErrorsKey{}, errs), errs
+}
+
+// count returns the number of errors reported so far.
+func (e *storageErrors) count() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.errs)
+}
+
+// since returns the first error reported after the first n, or nil.
+func (e *storageErrors) since(n int) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.errs) > n {
+		return e.errs[n]
+	}
+	return nil
+}
+
+// storageFailed reports whether a storage error was reported during the
+// handler call ctx belongs to.
+func storageFailed(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	errs, ok := ctx.Value(storageErrorsKey{}).(*storageErrors)
+	return ok && errs.count() > 0
+}
+
+// storageErrorResult is the error result of a handler call during which
+// err was reported.
+func storageErrorResult(err error) map[string]any {
+	code := "storage_error"
+	switch {
+	case errors.Is(err, ErrDecryption):
+		code = "decryption_failed"
+	case errors.Is(err, ErrStorageQuotaExceeded):
+		code = "storage_quota_exceeded"
+	}
+	return map[string]any{"variant": "error", "code": code, "message": err.Error()}
 }
 
 // InMemoryStorage is a thread-safe in-memory Storage implementation.
 type InMemoryStorage struct {
 	mu        sync.RWMutex
 	relations map[string]map[string]entry
+	// sortedKeys holds each relation's keys in order, for Range.
+	sortedKeys map[string][]string
+	nextSeq    uint64
+	watchers   map[watchKey][]*watcher
+
+	// mutationSeq numbers mutations; changes and changed serve
+	// WaitForChanges.
+	mutationSeq uint64
+	changes     map[string]*changeLog
+	changed     chan struct{}
 }
 
 type entry struct {
 	Value       map[string]any
 	LastWritten time.Time
+	// Seq records insertion order; overwrites keep the original value.
+	Seq uint64
+	// DeletedAt is set while the entry is soft-deleted.
+	DeletedAt *time.Time
 }
 
 // NewInMemoryStorage creates a new empty in-memory storage.
 func NewInMemoryStorage() *InMemoryStorage {
 	return &InMemoryStorage{
-		relations: make(map[string]map[string]entry),
+		relations:  make(map[string]map[string]entry),
+		sortedKeys: make(map[string][]string),
 	}
 }
 
@@ -44,9 +187,9 @@ func (s *InMemoryStorage) Get(relation, key string) (map[string]any, bool) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	rel := s.ensureRelation(relation)
+	rel := s.relations[relation]
 	e, ok := rel[key]
-	if !ok {
+	if !ok || e.DeletedAt != nil {
 		return nil, false
 	}
 	return e.Value, true
@@ -57,10 +200,19 @@ func (s *InMemoryStorage) Put(relation, key string, value map[string]any) {
 	defer s.mu.Unlock()
 
 	rel := s.ensureRelation(relation)
+	prev, exists := rel[key]
+	seq := prev.Seq
+	if !exists {
+		s.nextSeq++
+		seq = s.nextSeq
+		s.insertKey(relation, key)
+	}
 	rel[key] = entry{
 		Value:       value,
 		LastWritten: time.Now(),
+		Seq:         seq,
 	}
+	s.notify(relation, key, WatchEvent{Key: key, Event: "put", Value: value})
 }
 
 func (s *InMemoryStorage) Delete(relation, key string) bool {
@@ -70,34 +222,434 @@ func (s *InMemoryStorage) Delete(relation, key string) bool {
 	rel := s.ensureRelation(relation)
 	if _, ok := rel[key]; ok {
 		delete(rel, key)
+		s.removeKey(relation, key)
+		s.notify(relation, key, WatchEvent{Key: key, Event: "delete"})
 		return true
 	}
 	return false
 }
 
+// BulkPut takes the write lock once for the whole batch.
+func (s *InMemoryStorage) BulkPut(relation string, entries map[string]map[string]any) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rel := s.ensureRelation(relation)
+	now := time.Now()
+	for key, value := range entries {
+		prev, exists := rel[key]
+		seq := prev.Seq
+		if !exists {
+			s.nextSeq++
+			seq = s.nextSeq
+			s.insertKey(relation, key)
+		}
+		rel[key] = entry{Value: value, LastWritten: now, Seq: seq}
+		s.notify(relation, key, WatchEvent{Key: key, Event: "put", Value: value})
+	}
+	return len(entries)
+}
+
+// BulkDelete takes the write lock once for the whole batch.
+func (s *InMemoryStorage) BulkDelete(relation string, keys []string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rel := s.ensureRelation(relation)
+	n := 0
+	for _, key := range keys {
+		if _, ok := rel[key]; ok {
+			delete(rel, key)
+			s.removeKey(relation, key)
+			s.notify(relation, key, WatchEvent{Key: key, Event: "delete"})
+			n++
+		}
+	}
+	return n
+}
+
+// MergeStrategy selects how MergeRelation treats keys that already hold a
+// live entry.
+type MergeStrategy string
+
+const (
+	// MergeReplace overwrites existing entries.
+	MergeReplace MergeStrategy = "replace"
+	// MergeKeep leaves existing entries untouched.
+	MergeKeep MergeStrategy = "keep"
+	// MergePatch shallow-merges the new fields into existing entries.
+	MergePatch MergeStrategy = "patch"
+)
+
+// validate returns an error unless m is one of the MergeStrategy
+// constants.
+func (m MergeStrategy) validate() error {
+	switch m {
+	case MergeReplace, MergeKeep, MergePatch:
+		return nil
+	}
+	return fmt.Errorf("clef: unknown merge strategy %q", m)
+}
+
+// merge returns what strategy stores at a key holding prev when value is
+// merged in, and false if the key is left untouched.
+func (m MergeStrategy) merge(prev, value map[string]any) (map[string]any, bool) {
+	switch m {
+	case MergeKeep:
+		return nil, false
+	case MergePatch:
+		patched := maps.Clone(prev)
+		maps.Copy(patched, value)
+		return patched, true
+	}
+	return value, true
+}
+
+// Merger is implemented by storages that merge a batch of entries
+// atomically, see MergeRelation.
+type Merger interface {
+	MergeRelation(relation string, entries map[string]map[string]any, strategy MergeStrategy) (int, error)
+}
+
+// MergeRelation upserts entries, keyed by entry key, into relation of
+// storage and returns the number written or patched. New keys are
+// inserted under every strategy; strategy decides what happens to
+// existing ones, and an unknown strategy is an error. A storage that
+// implements Merger merges the batch atomically; any other, such as a
+// decorator, gets a Get and a Put per entry.
+//
+// Example:
+//
+//	n, err := clef.MergeRelation(storage, "products", imported, clef.MergePatch)
+func MergeRelation(storage Storage, relation string, entries map[string]map[string]any, strategy MergeStrategy) (int, error) {
+	if m, ok := storage.(Merger); ok {
+		return m.MergeRelation(relation, entries, strategy)
+	}
+	if err := strategy.validate(); err != nil {
+		return 0, err
+	}
+	n := 0
+	for key, value := range entries {
+		if prev, ok := storage.Get(relation, key); ok {
+			if value, ok = strategy.merge(prev, value); !ok {
+				continue
+			}
+		}
+		storage.Put(relation, key, value)
+		n++
+	}
+	return n, nil
+}
+
+// MergeRelation implements Merger, merging entries under one write lock.
+func (s *InMemoryStorage) MergeRelation(relation string, entries map[string]map[string]any, strategy MergeStrategy) (int, error) {
+	if err := strategy.validate(); err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rel := s.ensureRelation(relation)
+	now := time.Now()
+	n := 0
+	for key, value := range entries {
+		prev, exists := rel[key]
+		if exists && prev.DeletedAt == nil {
+			var ok bool
+			if value, ok = strategy.merge(prev.Value, value); !ok {
+				continue
+			}
+		}
+		seq := prev.Seq
+		if !exists {
+			s.nextSeq++
+			seq = s.nextSeq
+			s.insertKey(relation, key)
+		}
+		rel[key] = entry{Value: value, LastWritten: now, Seq: seq}
+		s.notify(relation, key, WatchEvent{Key: key, Event: "put", Value: value})
+		n++
+	}
+	return n, nil
+}
+
+// CompareAndSwap holds the write lock across the comparison and the write.
+func (s *InMemoryStorage) CompareAndSwap(relation, key string, expected, replacement map[string]any, compareFields []string) (bool, map[string]any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rel := s.ensureRelation(relation)
+	prev, exists := rel[key]
+	live := exists && prev.DeletedAt == nil
+	var current map[string]any
+	if live {
+		current = prev.Value
+	}
+	if !casMatches(current, live, expected, compareFields) {
+		return false, current
+	}
+
+	seq := prev.Seq
+	if !exists {
+		s.nextSeq++
+		seq = s.nextSeq
+		s.insertKey(relation, key)
+	}
+	rel[key] = entry{Value: replacement, LastWritten: time.Now(), Seq: seq}
+	s.notify(relation, key, WatchEvent{Key: key, Event: "put", Value: replacement})
+	return true, replacement
+}
+
+// insertKey adds a new key to the relation's sorted keys by binary
+// insertion. The caller holds the write lock.
+func (s *InMemoryStorage) insertKey(relation, key string) {
+	keys := s.sortedKeys[relation]
+	i, found := slices.BinarySearch(keys, key)
+	if !found {
+		s.sortedKeys[relation] = slices.Insert(keys, i, key)
+	}
+}
+
+// removeKey drops a deleted key from the relation's sorted keys. The
+// caller holds the write lock.
+func (s *InMemoryStorage) removeKey(relation, key string) {
+	keys := s.sortedKeys[relation]
+	if i, found := slices.BinarySearch(keys, key); found {
+		s.sortedKeys[relation] = slices.Delete(keys, i, i+1)
+	}
+}
+
+// Range binary-searches the relation's sorted keys for startKey and
+// walks forward to endKey.
+func (s *InMemoryStorage) Range(relation, startKey, endKey string, inclusive bool) []map[string]any {
+	_, values := s.rangeWithKeys(relation, startKey, endKey, inclusive)
+	return values
+}
+
+// rangeWithKeys implements keyedRanger.
+func (s *InMemoryStorage) rangeWithKeys(relation, startKey, endKey string, inclusive bool) ([]string, []map[string]any) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := s.sortedKeys[relation]
+	i := 0
+	if startKey != "" {
+		i, _ = slices.BinarySearch(keys, startKey)
+	}
+	rel := s.relations[relation]
+	var inRange []string
+	results := []map[string]any{}
+	for ; i < len(keys); i++ {
+		key := keys[i]
+		if !keyInRange(key, startKey, endKey, inclusive) {
+			if endKey != "" && key >= endKey {
+				break
+			}
+			continue
+		}
+		if e := rel[key]; e.DeletedAt == nil {
+			inRange = append(inRange, key)
+			results = append(results, e.Value)
+		}
+	}
+	return inRange, results
+}
+
+// keyInRange reports whether key lies between startKey and endKey, where
+// an empty bound is unbounded.
+func keyInRange(key, startKey, endKey string, inclusive bool) bool {
+	if startKey != "" && (key < startKey || !inclusive && key == startKey) {
+		return false
+	}
+	if endKey != "" && (key > endKey || !inclusive && key == endKey) {
+		return false
+	}
+	return true
+}
+
+// casMatches reports whether the stored entry satisfies a CompareAndSwap
+// expectation: absent when expected is nil, otherwise present with equal
+// compareFields.
+func casMatches(current map[string]any, found bool, expected map[string]any, compareFields []string) bool {
+	if expected == nil || !found {
+		return expected == nil && !found
+	}
+	for _, f := range compareFields {
+		if !sameValue(current[f], expected[f]) {
+			return false
+		}
+	}
+	return true
+}
+
 func (s *InMemoryStorage) Find(relation string, args map[string]any) []map[string]any {
+	return s.find(relation, args, false)
+}
+
+// FindIncludeDeleted is Find including soft-deleted entries.
+func (s *InMemoryStorage) FindIncludeDeleted(relation string, args map[string]any) []map[string]any {
+	return s.find(relation, args, true)
+}
+
+func (s *InMemoryStorage) find(relation string, args map[string]any, includeDeleted bool) []map[string]any {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	rel := s.ensureRelation(relation)
+	rel := s.relations[relation]
 	var results []map[string]any
 
 	for _, e := range rel {
-		if matchesArgs(e.Value, args) {
+		if (includeDeleted || e.DeletedAt == nil) && matchesArgs(e.Value, args) {
 			results = append(results, e.Value)
 		}
 	}
 	return results
 }
 
-func matchesArgs(value, args map[string]any) bool {
-	if args == nil {
-		return true
+func (s *InMemoryStorage) FindSorted(relation string, args map[string]any, sortField string, ascending bool) []map[string]any {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rel := s.relations[relation]
+	var matched []entry
+	for _, e := range rel {
+		if e.DeletedAt == nil && matchesArgs(e.Value, args) {
+			matched = append(matched, e)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].Seq < matched[j].Seq })
+
+	results := make([]map[string]any, len(matched))
+	for i, e := range matched {
+		results[i] = e.Value
 	}
+	sortRecords(results, sortField, ascending)
+	return results
+}
+
+// SoftDelete hides the entry at relation/key from reads without removing
+// it, so it can be brought back with Restore. It reports whether a live
+// entry was found. Put on a soft-deleted key replaces and revives it;
+// Delete removes it for good.
+func (s *InMemoryStorage) SoftDelete(relation, key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rel := s.relations[relation]
+	e, ok := rel[key]
+	if !ok || e.DeletedAt != nil {
+		return false
+	}
+	now := time.Now()
+	e.DeletedAt = &now
+	rel[key] = e
+	s.notify(relation, key, WatchEvent{Key: key, Event: "delete"})
+	return true
+}
+
+// Restore undoes SoftDelete, making the entry visible again with its
+// previous value. It reports whether a soft-deleted entry was found.
+func (s *InMemoryStorage) Restore(relation, key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rel := s.relations[relation]
+	e, ok := rel[key]
+	if !ok || e.DeletedAt == nil {
+		return false
+	}
+	e.DeletedAt = nil
+	rel[key] = e
+	s.notify(relation, key, WatchEvent{Key: key, Event: "put", Value: e.Value})
+	return true
+}
+
+// Relations returns the names of all relations that have been touched.
+func (s *InMemoryStorage) Relations() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.relations))
+	for name := range s.relations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Keys returns the keys stored in a relation in lexicographic order,
+// omitting soft-deleted entries.
+func (s *InMemoryStorage) Keys(relation string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rel := s.relations[relation]
+	keys := make([]string, 0, len(rel))
+	for k, e := range rel {
+		if e.DeletedAt == nil {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// matchesArgs reports whether value matches every filter in args. A
+// filter holding a list ([]any) matches a list field that contains at
+// least one of its elements, or a scalar field equal to any of them (SQL
+// IN); any other filter must equal the field.
+func matchesArgs(value, args map[string]any) bool {
 	for k, v := range args {
-		if value[k] != v {
+		if !matchesArg(value[k], v) {
 			return false
 		}
 	}
 	return true
 }
+
+func matchesArg(field, filter any) bool {
+	want, ok := filter.([]any)
+	if !ok {
+		return sameValue(field, filter)
+	}
+	have, isList := listValue(field)
+	if !isList {
+		have = []any{field}
+	}
+	for _, h := range have {
+		for _, w := range want {
+			if sameValue(h, w) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// listValue returns field as a []any if it holds a list. Handlers often
+// store []string, while JSON-decoded records hold []any.
+func listValue(field any) ([]any, bool) {
+	switch l := field.(type) {
+	case []any:
+		return l, true
+	case []string:
+		out := make([]any, len(l))
+		for i, s := range l {
+			out[i] = s
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+// sameValue is == that does not panic on uncomparable values such as
+// maps and slices.
+func sameValue(a, b any) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if !reflect.TypeOf(a).Comparable() || !reflect.TypeOf(b).Comparable() {
+		return reflect.DeepEqual(a, b)
+	}
+	return a == b
+}
@@ -0,0 +1,122 @@
+package clef
+
+import (
+	"context"
+	"sort"
+)
+
+// RoutingFn reports whether relation belongs in the primary storage of a
+// CompositeStorage (true) or its fallback (false).
+type RoutingFn func(relation string) bool
+
+// CompositeStorage splits relations between two storages, typically a
+// fast in-memory primary for hot relations and a persistent fallback for
+// everything else. Each relation lives in exactly one of them.
+type CompositeStorage struct {
+	primary  Storage
+	fallback Storage
+	route    RoutingFn
+}
+
+// NewCompositeStorage routes each relation to primary when route returns
+// true and to fallback otherwise.
+func NewCompositeStorage(primary, fallback Storage, route func(relation string) bool) *CompositeStorage {
+	return &CompositeStorage{primary: primary, fallback: fallback, route: route}
+}
+
+// HotColdCompositeStorage keeps the hot relations in primary and all
+// others in fallback.
+//
+// Example:
+//
+//	redis, _ := clef.NewRedisStorage("localhost:6379", "", 0)
+//	storage := clef.HotColdCompositeStorage([]string{"sessions"}, clef.NewInMemoryStorage(), redis)
+func HotColdCompositeStorage(hot []string, primary, fallback Storage) *CompositeStorage {
+	set := make(map[string]bool, len(hot))
+	for _, r := range hot {
+		set[r] = true
+	}
+	return NewCompositeStorage(primary, fallback, func(relation string) bool { return set[relation] })
+}
+
+func (s *CompositeStorage) storageFor(relation string) Storage {
+	if s.route(relation) {
+		return s.primary
+	}
+	return s.fallback
+}
+
+func (s *CompositeStorage) Get(relation, key string) (map[string]any, bool) {
+	return s.storageFor(relation).Get(relation, key)
+}
+
+func (s *CompositeStorage) Put(relation, key string, value map[string]any) {
+	s.storageFor(relation).Put(relation, key, value)
+}
+
+func (s *CompositeStorage) Delete(relation, key string) bool {
+	return s.storageFor(relation).Delete(relation, key)
+}
+
+func (s *CompositeStorage) Find(relation string, args map[string]any) []map[string]any {
+	return s.storageFor(relation).Find(relation, args)
+}
+
+func (s *CompositeStorage) FindSorted(relation string, args map[string]any, sortField string, ascending bool) []map[string]any {
+	return s.storageFor(relation).FindSorted(relation, args, sortField, ascending)
+}
+
+func (s *CompositeStorage) Range(relation, startKey, endKey string, inclusive bool) []map[string]any {
+	return s.storageFor(relation).Range(relation, startKey, endKey, inclusive)
+}
+
+func (s *CompositeStorage) BulkPut(relation string, entries map[string]map[string]any) int {
+	return s.storageFor(relation).BulkPut(relation, entries)
+}
+
+func (s *CompositeStorage) BulkDelete(relation string, keys []string) int {
+	return s.storageFor(relation).BulkDelete(relation, keys)
+}
+
+func (s *CompositeStorage) CompareAndSwap(relation, key string, expected, replacement map[string]any, compareFields []string) (bool, map[string]any) {
+	return s.storageFor(relation).CompareAndSwap(relation, key, expected, replacement, compareFields)
+}
+
+// WithContext implements ContextualStorage by binding both storages.
+func (s *CompositeStorage) WithContext(ctx context.Context) Storage {
+	return &CompositeStorage{
+		primary:  bindStorage(ctx, s.primary),
+		fallback: bindStorage(ctx, s.fallback),
+		route:    s.route,
+	}
+}
+
+// Relations lists the relations routed to each storage that it can
+// enumerate.
+func (s *CompositeStorage) Relations() []string {
+	var names []string
+	for _, part := range []struct {
+		storage Storage
+		primary bool
+	}{{s.primary, true}, {s.fallback, false}} {
+		enum, ok := part.storage.(Enumerable)
+		if !ok {
+			continue
+		}
+		for _, r := range enum.Relations() {
+			if s.route(r) == part.primary {
+				names = append(names, r)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Keys lists the keys of relation if its storage is Enumerable.
+func (s *CompositeStorage) Keys(relation string) []string {
+	if enum, ok := s.storageFor(relation).(Enumerable); ok {
+		return enum.Keys(relation)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package clef
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHotColdCompositeStorageRoutesWrites(t *testing.T) {
+	primary := NewInMemoryStorage()
+	fallback := NewInMemoryStorage()
+	s := HotColdCompositeStorage([]string{"sessions"}, primary, fallback)
+
+	s.Put("sessions", "s1", map[string]any{"user": "alice"})
+	s.Put("articles", "a1", map[string]any{"title": "Hello"})
+
+	if _, ok := primary.Get("sessions", "s1"); !ok {
+		t.Error("expected hot relation in primary")
+	}
+	if _, ok := fallback.Get("sessions", "s1"); ok {
+		t.Error("hot relation must not reach fallback")
+	}
+	if _, ok := fallback.Get("articles", "a1"); !ok {
+		t.Error("expected cold relation in fallback")
+	}
+	if _, ok := primary.Get("articles", "a1"); ok {
+		t.Error("cold relation must not reach primary")
+	}
+
+	if v, ok := s.Get("articles", "a1"); !ok || v["title"] != "Hello" {
+		t.Errorf("expected to read cold entry back, got %v", v)
+	}
+	if got := s.Find("sessions", map[string]any{"user": "alice"}); len(got) != 1 {
+		t.Errorf("expected to find hot entry, got %v", got)
+	}
+	if !s.Delete("articles", "a1") {
+		t.Error("expected delete of cold entry")
+	}
+	if _, ok := fallback.Get("articles", "a1"); ok {
+		t.Error("expected cold entry to be deleted from fallback")
+	}
+}
+
+func TestCompositeStorageRelations(t *testing.T) {
+	primary := NewInMemoryStorage()
+	fallback := NewInMemoryStorage()
+	s := NewCompositeStorage(primary, fallback, func(r string) bool { return r == "hot" })
+
+	s.Put("hot", "k1", map[string]any{})
+	s.Put("cold", "k2", map[string]any{})
+	// An entry written directly to the wrong storage is not reachable.
+	primary.Put("cold", "stray", map[string]any{})
+
+	if got := s.Relations(); !reflect.DeepEqual(got, []string{"cold", "hot"}) {
+		t.Errorf("expected [cold hot], got %v", got)
+	}
+	if got := s.Keys("cold"); !reflect.DeepEqual(got, []string{"k2"}) {
+		t.Errorf("expected [k2], got %v", got)
+	}
+}
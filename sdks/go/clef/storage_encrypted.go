@@ -0,0 +1,270 @@
+package clef
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrDecryption is reported by EncryptedStorage for entries that fail to
+// decrypt, e.g. because they were written with another key.
+var ErrDecryption = errors.New("clef: cannot decrypt stored entry")
+
+// ciphertextField holds an EncryptedStorage entry in the inner storage,
+// and keyField its key, which Find and Range need to decrypt it.
+const (
+	ciphertextField = "_ciphertext"
+	keyField        = "_key"
+)
+
+// maxEncryptedCASAttempts bounds the retries of CompareAndSwap when the
+// ciphertext changes between its read and its swap.
+const maxEncryptedCASAttempts = 10
+
+type encryptedStorage struct {
+	inner Storage
+	key   [32]byte
+	state *encryptionState
+	// ctx is the invocation context the storage is bound to, for
+	// ReportStorageError.
+	ctx context.Context
+}
+
+// encryptionState is shared by the storages WithContext derives.
+type encryptionState struct {
+	mu    sync.Mutex
+	aeads map[string]cipher.AEAD
+}
+
+// EncryptedStorage encrypts every entry before it reaches inner. Values
+// are JSON-encoded and sealed with AES-256-GCM under a key derived from
+// key with HKDF-SHA256, using the relation name as info, and with the
+// relation and key as additional authenticated data, so an entry copied
+// to another relation or key does not decrypt. inner stores each entry
+// as {"_key": key, "_ciphertext": base64(nonce || ciphertext)}. As with
+// RedisStorage, values read back as decoded JSON, so numbers are float64.
+//
+// An entry that fails to decrypt reads as missing, and the failure,
+// wrapping ErrDecryption, is reported with ReportStorageError: the
+// handler call gets a "decryption_failed" error result, and writes
+// through the storage later in the same call are dropped, so the entry
+// is not overwritten by a handler that took it for missing.
+//
+// Find, FindSorted and Range decrypt the whole relation and filter in
+// memory, since inner cannot see the fields.
+func EncryptedStorage(inner Storage, key [32]byte) Storage {
+	return &encryptedStorage{inner: inner, key: key, state: &encryptionState{aeads: make(map[string]cipher.AEAD)}}
+}
+
+// aead returns the cipher for relation, deriving its key on first use.
+func (s *encryptedStorage) aead(relation string) (cipher.AEAD, error) {
+	s.state.mu.Lock()
+	defer s.state.mu.Unlock()
+	if a, ok := s.state.aeads[relation]; ok {
+		return a, nil
+	}
+	key, err := hkdf.Key(sha256.New, s.key[:], nil, relation, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	a, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	s.state.aeads[relation] = a
+	return a, nil
+}
+
+// additionalData binds a ciphertext to its relation and key.
+func additionalData(relation, key string) []byte {
+	return []byte(relation + "\x00" + key)
+}
+
+// seal encrypts value for relation and key. A failure is reported and
+// nil returned, and the write must then be dropped, as must every write
+// after a reported failure.
+func (s *encryptedStorage) seal(relation, key string, value map[string]any) map[string]any {
+	if storageFailed(s.ctx) {
+		return nil
+	}
+	a, err := s.aead(relation)
+	if err != nil {
+		ReportStorageError(s.ctx, fmt.Errorf("clef: encrypt %s entry: %w", relation, err))
+		return nil
+	}
+	plaintext, err := json.Marshal(value)
+	if err != nil {
+		ReportStorageError(s.ctx, fmt.Errorf("clef: encrypt %s entry: %w", relation, err))
+		return nil
+	}
+	nonce := make([]byte, a.NonceSize())
+	rand.Read(nonce)
+	sealed := a.Seal(nonce, nonce, plaintext, additionalData(relation, key))
+	return map[string]any{keyField: key, ciphertextField: base64.StdEncoding.EncodeToString(sealed)}
+}
+
+// open decrypts stored, the entry at key of relation, reporting a
+// failure.
+func (s *encryptedStorage) open(relation, key string, stored map[string]any) (map[string]any, bool) {
+	if stored == nil {
+		return nil, false
+	}
+	value, err := s.decrypt(relation, key, stored)
+	if err != nil {
+		ReportStorageError(s.ctx, err)
+		return nil, false
+	}
+	return value, true
+}
+
+func (s *encryptedStorage) decrypt(relation, key string, stored map[string]any) (map[string]any, error) {
+	a, err := s.aead(relation)
+	if err != nil {
+		return nil, fmt.Errorf("%w in %s: %v", ErrDecryption, relation, err)
+	}
+	encoded, _ := stored[ciphertextField].(string)
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < a.NonceSize() {
+		return nil, fmt.Errorf("%w in %s: malformed ciphertext", ErrDecryption, relation)
+	}
+	plaintext, err := a.Open(nil, sealed[:a.NonceSize()], sealed[a.NonceSize():], additionalData(relation, key))
+	if err != nil {
+		return nil, fmt.Errorf("%w at %s/%s: %v", ErrDecryption, relation, key, err)
+	}
+	var value map[string]any
+	if err := json.Unmarshal(plaintext, &value); err != nil {
+		return nil, fmt.Errorf("%w at %s/%s: %v", ErrDecryption, relation, key, err)
+	}
+	return value, nil
+}
+
+// openAll decrypts records, as returned by Find or Range, using their
+// stored keys, skipping and reporting those that fail.
+func (s *encryptedStorage) openAll(relation string, records []map[string]any) []map[string]any {
+	out := make([]map[string]any, 0, len(records))
+	for _, r := range records {
+		key, _ := r[keyField].(string)
+		if value, ok := s.open(relation, key, r); ok {
+			out = append(out, value)
+		}
+	}
+	return out
+}
+
+func (s *encryptedStorage) Get(relation, key string) (map[string]any, bool) {
+	stored, ok := s.inner.Get(relation, key)
+	if !ok {
+		return nil, false
+	}
+	return s.open(relation, key, stored)
+}
+
+func (s *encryptedStorage) Put(relation, key string, value map[string]any) {
+	if sealed := s.seal(relation, key, value); sealed != nil {
+		s.inner.Put(relation, key, sealed)
+	}
+}
+
+func (s *encryptedStorage) Delete(relation, key string) bool {
+	return s.inner.Delete(relation, key)
+}
+
+func (s *encryptedStorage) Find(relation string, args map[string]any) []map[string]any {
+	var results []map[string]any
+	for _, value := range s.openAll(relation, s.inner.Find(relation, nil)) {
+		if matchesArgs(value, args) {
+			results = append(results, value)
+		}
+	}
+	return results
+}
+
+// FindSorted sorts by sortField only; inner's insertion order is lost
+// for ties on storages whose Find is unordered.
+func (s *encryptedStorage) FindSorted(relation string, args map[string]any, sortField string, ascending bool) []map[string]any {
+	results := s.Find(relation, args)
+	sortRecords(results, sortField, ascending)
+	return results
+}
+
+func (s *encryptedStorage) Range(relation, startKey, endKey string, inclusive bool) []map[string]any {
+	return s.openAll(relation, s.inner.Range(relation, startKey, endKey, inclusive))
+}
+
+func (s *encryptedStorage) BulkPut(relation string, entries map[string]map[string]any) int {
+	sealed := make(map[string]map[string]any, len(entries))
+	for key, value := range entries {
+		if sealed[key] = s.seal(relation, key, value); sealed[key] == nil {
+			return 0
+		}
+	}
+	return s.inner.BulkPut(relation, sealed)
+}
+
+func (s *encryptedStorage) BulkDelete(relation string, keys []string) int {
+	return s.inner.BulkDelete(relation, keys)
+}
+
+// CompareAndSwap compares decrypted fields, then swaps on the unchanged
+// ciphertext, retrying if another writer got in between.
+func (s *encryptedStorage) CompareAndSwap(relation, key string, expected, replacement map[string]any, compareFields []string) (bool, map[string]any) {
+	for range maxEncryptedCASAttempts {
+		stored, found := s.inner.Get(relation, key)
+		var current map[string]any
+		if found {
+			if current, found = s.open(relation, key, stored); !found {
+				return false, nil
+			}
+		}
+		if !casMatches(current, found, expected, compareFields) {
+			return false, current
+		}
+		var storedExpected map[string]any
+		var storedFields []string
+		if found {
+			storedExpected, storedFields = stored, []string{ciphertextField}
+		}
+		sealed := s.seal(relation, key, replacement)
+		if sealed == nil {
+			return false, current
+		}
+		if ok, _ := s.inner.CompareAndSwap(relation, key, storedExpected, sealed, storedFields); ok {
+			return true, replacement
+		}
+	}
+	current, _ := s.Get(relation, key)
+	return false, current
+}
+
+// Relations implements Enumerable when the inner storage does.
+func (s *encryptedStorage) Relations() []string {
+	if enum, ok := s.inner.(Enumerable); ok {
+		return enum.Relations()
+	}
+	return nil
+}
+
+// Keys implements Enumerable when the inner storage does.
+func (s *encryptedStorage) Keys(relation string) []string {
+	if enum, ok := s.inner.(Enumerable); ok {
+		return enum.Keys(relation)
+	}
+	return nil
+}
+
+// WithContext implements ContextualStorage by binding the inner storage.
+func (s *encryptedStorage) WithContext(ctx context.Context) Storage {
+	return &encryptedStorage{inner: bindStorage(ctx, s.inner), key: s.key, state: s.state, ctx: ctx}
+}
@@ -0,0 +1,76 @@
+package clef
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestEncryptedStorage(t *testing.T) {
+	inner := NewInMemoryStorage()
+	var key [32]byte
+	copy(key[:], "0123456789abcdef0123456789abcdef")
+	s := EncryptedStorage(inner, key)
+
+	s.Put("patients", "p1", map[string]any{"name": "Ada", "ssn": "078-05-1120"})
+
+	raw, ok := inner.Get("patients", "p1")
+	if !ok {
+		t.Fatal("entry not written to inner storage")
+	}
+	if len(raw) != 2 || raw["ssn"] != nil || strings.Contains(raw["_ciphertext"].(string), "Ada") {
+		t.Fatalf("inner storage holds plaintext: %v", raw)
+	}
+
+	got, ok := s.Get("patients", "p1")
+	if !ok || got["name"] != "Ada" || got["ssn"] != "078-05-1120" {
+		t.Fatalf("decrypted entry = %v, %v", got, ok)
+	}
+	if found := s.Find("patients", map[string]any{"name": "Ada"}); len(found) != 1 {
+		t.Errorf("Find = %v, want the decrypted entry", found)
+	}
+	if ok, _ := s.CompareAndSwap("patients", "p1", map[string]any{"name": "Ada"}, map[string]any{"name": "Ada L."}, []string{"name"}); !ok {
+		t.Error("CompareAndSwap on decrypted fields failed")
+	}
+
+	// The relation and key are bound into the ciphertext: a copied entry
+	// does not decrypt.
+	raw, _ = inner.Get("patients", "p1")
+	inner.Put("billing", "p1", raw)
+	if _, ok := s.Get("billing", "p1"); ok {
+		t.Error("entry decrypted in another relation")
+	}
+	inner.Put("patients", "p2", raw)
+	if _, ok := s.Get("patients", "p2"); ok {
+		t.Error("entry decrypted under another key")
+	}
+
+	var wrong [32]byte
+	other := EncryptedStorage(inner, wrong)
+	if _, ok := other.Get("patients", "p1"); ok {
+		t.Fatal("entry decrypted with the wrong key")
+	}
+}
+
+func TestEncryptedStorageReportsDecryptionFailure(t *testing.T) {
+	inner := NewInMemoryStorage()
+	var key, wrong [32]byte
+	copy(key[:], "0123456789abcdef0123456789abcdef")
+	EncryptedStorage(inner, key).Put("patients", "p1", map[string]any{"name": "Ada"})
+
+	// A handler that takes an undecryptable entry for missing and
+	// creates it.
+	create := HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		if _, ok := storage.Get("patients", "p1"); !ok {
+			storage.Put("patients", "p1", map[string]any{"name": "new"})
+		}
+		return map[string]any{"variant": "ok"}
+	})
+	out := callHandler(context.Background(), create, "create", nil, EncryptedStorage(inner, wrong))
+	if out["variant"] != "error" || out["code"] != "decryption_failed" {
+		t.Fatalf("output = %v, want a decryption_failed error", out)
+	}
+	if got, ok := EncryptedStorage(inner, key).Get("patients", "p1"); !ok || got["name"] != "Ada" {
+		t.Errorf("entry = %v, %v; want it untouched", got, ok)
+	}
+}
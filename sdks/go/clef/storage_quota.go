@@ -0,0 +1,323 @@
+package clef
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrStorageQuotaExceeded is reported by QuotaStorage when a write would
+// take a storage over its byte limit.
+var ErrStorageQuotaExceeded = errors.New("clef: storage quota exceeded")
+
+// QuotaStorage is a Storage decorator that caps the approximate size of
+// its data: the length of each key plus its JSON-encoded value. A write
+// that would exceed the cap is rejected with an error wrapping
+// ErrStorageQuotaExceeded and leaves the storage unchanged. TryPut and
+// TryBulkPut return the error; Put, BulkPut and CompareAndSwap report it
+// with ReportStorageError, so the transport answers the handler call
+// with a "storage_quota_exceeded" error, and drop the writes that follow
+// in the same call. Reads and deletes are never limited.
+type QuotaStorage struct {
+	Storage
+	state *quotaState
+	// ctx is the context the storage is bound to, for ReportStorageError.
+	ctx context.Context
+}
+
+type quotaState struct {
+	mu       sync.Mutex
+	maxBytes int64
+	used     int64
+	sizes    map[string]int64
+}
+
+// NewQuotaStorage wraps inner with a limit of maxBytes. Existing data is
+// counted if inner implements Enumerable.
+func NewQuotaStorage(inner Storage, maxBytes int64) *QuotaStorage {
+	state := &quotaState{maxBytes: maxBytes, sizes: make(map[string]int64)}
+	if enum, ok := inner.(Enumerable); ok {
+		for _, relation := range enum.Relations() {
+			for _, key := range enum.Keys(relation) {
+				if value, ok := inner.Get(relation, key); ok {
+					size := entrySizeBytes(key, value)
+					state.sizes[quotaKey(relation, key)] = size
+					state.used += size
+				}
+			}
+		}
+	}
+	return &QuotaStorage{Storage: inner, state: state}
+}
+
+// WithStorageQuota limits each concept's storage to maxBytes by wrapping
+// it in a QuotaStorage. Usage is tracked per storage for the life of the
+// server, so concepts registered with the same storage share its quota.
+func WithStorageQuota(maxBytes int64) ServeOption {
+	return func(c *ServerConfig) {
+		c.storageQuota = maxBytes
+	}
+}
+
+// StorageUsage returns the approximate bytes held by storage: the
+// tracked usage of a QuotaStorage, or for an Enumerable storage the sum
+// over all its entries. Other storages report 0.
+func StorageUsage(storage Storage) int64 {
+	if q, ok := storage.(*QuotaStorage); ok {
+		q.state.mu.Lock()
+		defer q.state.mu.Unlock()
+		return q.state.used
+	}
+	enum, ok := storage.(Enumerable)
+	if !ok {
+		return 0
+	}
+	var used int64
+	for _, relation := range enum.Relations() {
+		for _, key := range enum.Keys(relation) {
+			if value, ok := storage.Get(relation, key); ok {
+				used += entrySizeBytes(key, value)
+			}
+		}
+	}
+	return used
+}
+
+func entrySizeBytes(key string, value map[string]any) int64 {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return int64(len(key))
+	}
+	return int64(len(key) + len(data))
+}
+
+func quotaKey(relation, key string) string {
+	return relation + "\x00" + key
+}
+
+// reserve returns an error if growing usage by delta would exceed the
+// quota. The caller holds mu.
+func (st *quotaState) reserve(delta int64) error {
+	if delta > 0 && st.used+delta > st.maxBytes {
+		return fmt.Errorf("%w: %d of %d bytes used, write needs %d more", ErrStorageQuotaExceeded, st.used, st.maxBytes, delta)
+	}
+	return nil
+}
+
+// rejected reports err unless nil, and whether the write must be dropped:
+// because of err, or because an earlier storage error was reported in
+// the same handler call.
+func (s *QuotaStorage) rejected(err error) bool {
+	if err != nil {
+		ReportStorageError(s.ctx, err)
+		return true
+	}
+	return storageFailed(s.ctx)
+}
+
+func (s *QuotaStorage) Put(relation, key string, value map[string]any) {
+	if storageFailed(s.ctx) {
+		return
+	}
+	s.rejected(s.TryPut(relation, key, value))
+}
+
+// TryPut is Put returning an error wrapping ErrStorageQuotaExceeded,
+// instead of reporting it, when the write does not fit.
+func (s *QuotaStorage) TryPut(relation, key string, value map[string]any) error {
+	st := s.state
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	k := quotaKey(relation, key)
+	size := entrySizeBytes(key, value)
+	if err := st.reserve(size - st.sizes[k]); err != nil {
+		return err
+	}
+	s.Storage.Put(relation, key, value)
+	st.used += size - st.sizes[k]
+	st.sizes[k] = size
+	return nil
+}
+
+func (s *QuotaStorage) BulkPut(relation string, entries map[string]map[string]any) int {
+	if storageFailed(s.ctx) {
+		return 0
+	}
+	n, err := s.TryBulkPut(relation, entries)
+	s.rejected(err)
+	return n
+}
+
+// TryBulkPut is BulkPut returning an error wrapping
+// ErrStorageQuotaExceeded, instead of reporting it, when the entries do
+// not fit. None of them are written then.
+func (s *QuotaStorage) TryBulkPut(relation string, entries map[string]map[string]any) (int, error) {
+	st := s.state
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	sizes := make(map[string]int64, len(entries))
+	var delta int64
+	for key, value := range entries {
+		sizes[key] = entrySizeBytes(key, value)
+		delta += sizes[key] - st.sizes[quotaKey(relation, key)]
+	}
+	if err := st.reserve(delta); err != nil {
+		return 0, err
+	}
+	n := s.Storage.BulkPut(relation, entries)
+	for key, size := range sizes {
+		k := quotaKey(relation, key)
+		st.used += size - st.sizes[k]
+		st.sizes[k] = size
+	}
+	return n, nil
+}
+
+// commitBatch implements batchCommitter: StorageTx.Commit writes through
+// it only if all its writes together fit the quota.
+func (s *QuotaStorage) commitBatch(writes map[string]map[string]map[string]any) {
+	if storageFailed(s.ctx) {
+		return
+	}
+	st := s.state
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	sizes := make(map[string]int64)
+	var delta int64
+	for relation, entries := range writes {
+		for key, value := range entries {
+			k := quotaKey(relation, key)
+			if value != nil {
+				sizes[k] = entrySizeBytes(key, value)
+			}
+			delta += sizes[k] - st.sizes[k]
+		}
+	}
+	if s.rejected(st.reserve(delta)) {
+		return
+	}
+	applyWrites(s.Storage, writes)
+	for relation, entries := range writes {
+		for key := range entries {
+			k := quotaKey(relation, key)
+			if size, ok := sizes[k]; ok {
+				st.used += size - st.sizes[k]
+				st.sizes[k] = size
+			} else {
+				st.release(relation, key)
+			}
+		}
+	}
+}
+
+// CompareAndSwap reserves room for replacement before comparing, so a
+// swap that would exceed the quota is rejected, and not made, even if it
+// would not have matched.
+func (s *QuotaStorage) CompareAndSwap(relation, key string, expected, replacement map[string]any, compareFields []string) (bool, map[string]any) {
+	st := s.state
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	k := quotaKey(relation, key)
+	size := entrySizeBytes(key, replacement)
+	if s.rejected(st.reserve(size - st.sizes[k])) {
+		current, _ := s.Storage.Get(relation, key)
+		return false, current
+	}
+	swapped, current := s.Storage.CompareAndSwap(relation, key, expected, replacement, compareFields)
+	if swapped {
+		st.used += size - st.sizes[k]
+		st.sizes[k] = size
+	}
+	return swapped, current
+}
+
+func (s *QuotaStorage) Delete(relation, key string) bool {
+	st := s.state
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	ok := s.Storage.Delete(relation, key)
+	st.release(relation, key)
+	return ok
+}
+
+func (s *QuotaStorage) BulkDelete(relation string, keys []string) int {
+	st := s.state
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	n := s.Storage.BulkDelete(relation, keys)
+	for _, key := range keys {
+		st.release(relation, key)
+	}
+	return n
+}
+
+// release forgets the size of a deleted entry. The caller holds mu.
+func (st *quotaState) release(relation, key string) {
+	k := quotaKey(relation, key)
+	st.used -= st.sizes[k]
+	delete(st.sizes, k)
+}
+
+// Relations implements Enumerable when the inner storage does.
+func (s *QuotaStorage) Relations() []string {
+	if enum, ok := s.Storage.(Enumerable); ok {
+		return enum.Relations()
+	}
+	return nil
+}
+
+// Keys implements Enumerable when the inner storage does.
+func (s *QuotaStorage) Keys(relation string) []string {
+	if enum, ok := s.Storage.(Enumerable); ok {
+		return enum.Keys(relation)
+	}
+	return nil
+}
+
+// WithContext implements ContextualStorage. The bound storage shares
+// usage with s and reports rejected writes against ctx.
+func (s *QuotaStorage) WithContext(ctx context.Context) Storage {
+	return &QuotaStorage{Storage: bindStorage(ctx, s.Storage), state: s.state, ctx: ctx}
+}
+
+// quotaEntry remembers which registered storage a server's QuotaStorage
+// was built for, so re-registering a concept starts a fresh count.
+type quotaEntry struct {
+	base    Storage
+	storage *QuotaStorage
+}
+
+// quotaStorage returns the QuotaStorage for the concept at uri, creating
+// it over inner on first use. Concepts registered with the same base
+// storage share one usage count, so writes through one are counted
+// against the other.
+func (s *server) quotaStorage(uri string, base, inner Storage) *QuotaStorage {
+	s.quotasMu.Lock()
+	defer s.quotasMu.Unlock()
+	if q, ok := s.quotas[uri]; ok && q.base == base {
+		return q.storage
+	}
+	if s.quotas == nil {
+		s.quotas = make(map[string]quotaEntry)
+	}
+	var q *QuotaStorage
+	for _, other := range s.quotas {
+		if other.base == base {
+			q = &QuotaStorage{Storage: inner, state: other.storage.state}
+			break
+		}
+	}
+	if q == nil {
+		q = NewQuotaStorage(inner, s.config.storageQuota)
+	}
+	s.quotas[uri] = quotaEntry{base: base, storage: q}
+	return q
+}
@@ -0,0 +1,128 @@
+package clef
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestQuotaStorageRejectsPutsOverLimit(t *testing.T) {
+	q := NewQuotaStorage(NewInMemoryStorage(), 100)
+	value := map[string]any{"v": "0123456789"} // 18 bytes as JSON
+
+	put := func(key string) error {
+		return q.TryPut("blobs", key, value)
+	}
+
+	stored := 0
+	for i := 0; i < 10; i++ {
+		if err := put(fmt.Sprintf("k%d", i)); err != nil {
+			if !errors.Is(err, ErrStorageQuotaExceeded) {
+				t.Fatalf("unexpected error %v", err)
+			}
+			break
+		}
+		stored++
+	}
+	if stored != 5 {
+		t.Fatalf("expected 5 entries of 20 bytes to fit in 100, stored %d", stored)
+	}
+	if used := StorageUsage(q); used != 100 {
+		t.Errorf("expected usage 100, got %d", used)
+	}
+	if err := put("k9"); err == nil {
+		t.Error("expected further puts to fail")
+	}
+	if _, ok := q.Get("blobs", "k9"); ok {
+		t.Error("rejected put must not be stored")
+	}
+
+	if _, ok := q.Get("blobs", "k0"); !ok {
+		t.Error("expected gets to still succeed")
+	}
+	if !q.Delete("blobs", "k0") {
+		t.Error("expected delete to succeed")
+	}
+	if err := put("k9"); err != nil {
+		t.Errorf("expected put to fit after delete, got %v", err)
+	}
+	if got := StorageUsage(q); got != StorageUsage(q.Storage) {
+		t.Errorf("tracked usage %d differs from actual %d", got, StorageUsage(q.Storage))
+	}
+}
+
+func TestQuotaStorageCountsExistingDataAndBulkPut(t *testing.T) {
+	inner := NewInMemoryStorage()
+	inner.Put("r", "a", map[string]any{"x": 1})
+	q := NewQuotaStorage(inner, 20)
+	if used := StorageUsage(q); used != 8 {
+		t.Fatalf("expected existing 8 bytes counted, got %d", used)
+	}
+	if _, err := q.TryBulkPut("r", map[string]map[string]any{"b": {"x": 1}, "c": {"x": 1}}); !errors.Is(err, ErrStorageQuotaExceeded) {
+		t.Errorf("bulk put over quota: err = %v", err)
+	}
+	if len(inner.Keys("r")) != 1 {
+		t.Error("rejected bulk put must not write any entry")
+	}
+}
+
+func TestQuotaStorageDropsWritesAfterRejection(t *testing.T) {
+	inner := NewInMemoryStorage()
+	q := NewQuotaStorage(inner, 20)
+	out := callHandler(context.Background(), HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		storage.Put("r", "big", map[string]any{"data": strings.Repeat("x", 30)})
+		storage.Put("r", "small", map[string]any{"x": 1})
+		return map[string]any{"variant": "ok"}
+	}), "put", nil, q)
+	if out["code"] != "storage_quota_exceeded" {
+		t.Fatalf("output = %v, want storage_quota_exceeded", out)
+	}
+	if keys := inner.Keys("r"); len(keys) != 0 {
+		t.Errorf("stored %v after the rejected write", keys)
+	}
+}
+
+func TestWithStorageQuotaSharedStorage(t *testing.T) {
+	resetRegistry()
+	shared := NewInMemoryStorage()
+	put := HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		key, _ := input["key"].(string)
+		storage.Put("blobs", key, map[string]any{"data": strings.Repeat("x", 30)})
+		return map[string]any{"variant": "ok"}
+	})
+	Register("urn:test/A", put, shared)
+	Register("urn:test/B", put, shared)
+	s := newServer([]ServeOption{WithStorageQuota(100)})
+
+	codes := make([]any, 0, 3)
+	for i, concept := range []string{"urn:test/A", "urn:test/B", "urn:test/A"} {
+		c := s.dispatch(context.Background(), ActionInvocation{Concept: concept, Action: "put", Input: map[string]any{"key": fmt.Sprint(i)}})
+		codes = append(codes, c.Output["code"])
+	}
+	if codes[2] != "storage_quota_exceeded" {
+		t.Errorf("codes = %v; the third write should exceed the shared quota", codes)
+	}
+}
+
+func TestWithStorageQuotaReturnsErrorCompletion(t *testing.T) {
+	resetRegistry()
+	Register("urn:test/Blob", HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		key, _ := input["key"].(string)
+		storage.Put("blobs", key, map[string]any{"data": strings.Repeat("x", 30)})
+		return map[string]any{"variant": "ok"}
+	}), nil)
+	h := NewHandler(WithStorageQuota(100))
+
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusInsufficientStorage} {
+		rec := doRequest(h, http.MethodPost, "/invoke", fmt.Sprintf(`{"concept":"urn:test/Blob","action":"put","input":{"key":"k%d"}}`, i))
+		if rec.Code != want {
+			t.Fatalf("put %d: expected %d, got %d: %s", i, want, rec.Code, rec.Body)
+		}
+		if want != http.StatusOK && !strings.Contains(rec.Body.String(), `"code":"storage_quota_exceeded"`) {
+			t.Errorf("expected storage_quota_exceeded, got %s", rec.Body)
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package clef
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStorage is a Storage backed by Redis, so replicas of a concept
+// handler can share state. Each relation is one Redis hash named
+// "<namespace>:<relation>" whose fields are the entry keys and whose
+// values are the entries encoded as JSON. Because values round-trip
+// through JSON, numbers read back as float64.
+//
+// Storage methods cannot return errors; Redis failures surface as a
+// missing entry from Get, a false from Delete, or an empty Find. Writes
+// that fail are dropped, and the most recent error is reported by Err.
+type RedisStorage struct {
+	client    *redis.Client
+	namespace string
+	lastErr   *redisError
+}
+
+// redisError records the most recent failure, shared by storages created
+// with ForConcept.
+type redisError struct {
+	mu  sync.Mutex
+	err error
+}
+
+// NewRedisStorage connects to the Redis server at addr and verifies the
+// connection. Entries are namespaced under "clef"; use ForConcept to give
+// each concept its own namespace when several share a database.
+func NewRedisStorage(addr, password string, db int) (*RedisStorage, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       db,
+	})
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("connect to redis at %s: %w", addr, err)
+	}
+	return &RedisStorage{client: client, namespace: "clef", lastErr: &redisError{}}, nil
+}
+
+// ForConcept returns a storage sharing s's connection whose entries are
+// namespaced under the concept URI.
+func (s *RedisStorage) ForConcept(uri string) *RedisStorage {
+	return &RedisStorage{client: s.client, namespace: uri, lastErr: s.lastErr}
+}
+
+// Err returns the most recent Redis error, or nil.
+func (s *RedisStorage) Err() error {
+	s.lastErr.mu.Lock()
+	defer s.lastErr.mu.Unlock()
+	return s.lastErr.err
+}
+
+// Close closes the underlying Redis connection.
+func (s *RedisStorage) Close() error {
+	return s.client.Close()
+}
+
+func (s *RedisStorage) hash(relation string) string {
+	return s.namespace + ":" + relation
+}
+
+func (s *RedisStorage) fail(err error) {
+	if err != nil && err != redis.Nil {
+		s.lastErr.mu.Lock()
+		s.lastErr.err = err
+		s.lastErr.mu.Unlock()
+	}
+}
+
+func (s *RedisStorage) Get(relation, key string) (map[string]any, bool) {
+	raw, err := s.client.HGet(context.Background(), s.hash(relation), key).Result()
+	if err != nil {
+		s.fail(err)
+		return nil, false
+	}
+	var value map[string]any
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		s.fail(err)
+		return nil, false
+	}
+	return value, true
+}
+
+func (s *RedisStorage) Put(relation, key string, value map[string]any) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		s.fail(err)
+		return
+	}
+	s.fail(s.client.HSet(context.Background(), s.hash(relation), key, raw).Err())
+}
+
+func (s *RedisStorage) Delete(relation, key string) bool {
+	n, err := s.client.HDel(context.Background(), s.hash(relation), key).Result()
+	if err != nil {
+		s.fail(err)
+		return false
+	}
+	return n > 0
+}
+
+// BulkPut writes all entries with a single HSET, which Redis applies
+// atomically.
+func (s *RedisStorage) BulkPut(relation string, entries map[string]map[string]any) int {
+	if len(entries) == 0 {
+		return 0
+	}
+	fields := make([]any, 0, 2*len(entries))
+	for key, value := range entries {
+		raw, err := json.Marshal(value)
+		if err != nil {
+			s.fail(err)
+			return 0
+		}
+		fields = append(fields, key, raw)
+	}
+	if err := s.client.HSet(context.Background(), s.hash(relation), fields...).Err(); err != nil {
+		s.fail(err)
+		return 0
+	}
+	return len(entries)
+}
+
+// BulkDelete removes all keys with a single HDEL.
+func (s *RedisStorage) BulkDelete(relation string, keys []string) int {
+	if len(keys) == 0 {
+		return 0
+	}
+	n, err := s.client.HDel(context.Background(), s.hash(relation), keys...).Result()
+	if err != nil {
+		s.fail(err)
+		return 0
+	}
+	return int(n)
+}
+
+// CompareAndSwap uses WATCH on the relation's hash and retries when
+// another client writes to it between the read and the write. expected
+// is compared after a JSON round trip, like Find arguments.
+func (s *RedisStorage) CompareAndSwap(relation, key string, expected, replacement map[string]any, compareFields []string) (bool, map[string]any) {
+	ctx := context.Background()
+	hash := s.hash(relation)
+	expected = s.roundTrip(expected)
+	raw, err := json.Marshal(replacement)
+	if err != nil {
+		s.fail(err)
+		return false, nil
+	}
+
+	for {
+		var swapped bool
+		var current map[string]any
+		err := s.client.Watch(ctx, func(tx *redis.Tx) error {
+			stored, err := tx.HGet(ctx, hash, key).Result()
+			found := err == nil
+			if err != nil && err != redis.Nil {
+				return err
+			}
+			current = nil
+			if found {
+				if err := json.Unmarshal([]byte(stored), &current); err != nil {
+					return err
+				}
+			}
+			if !casMatches(current, found, expected, compareFields) {
+				return nil
+			}
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				return pipe.HSet(ctx, hash, key, raw).Err()
+			})
+			if err == nil {
+				swapped, current = true, s.roundTrip(replacement)
+			}
+			return err
+		}, hash)
+		if err == redis.TxFailedErr {
+			continue
+		}
+		if err != nil {
+			s.fail(err)
+			return false, nil
+		}
+		return swapped, current
+	}
+}
+
+// roundTrip returns value as it would read back from Redis.
+func (s *RedisStorage) roundTrip(value map[string]any) map[string]any {
+	if value == nil {
+		return nil
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		s.fail(err)
+		return value
+	}
+	var out map[string]any
+	json.Unmarshal(raw, &out)
+	return out
+}
+
+// Find scans the relation's hash with HSCAN and filters entries on the
+// client. Arguments are compared after a JSON round trip, so numeric
+// arguments should be float64.
+func (s *RedisStorage) Find(relation string, args map[string]any) []map[string]any {
+	var results []map[string]any
+	iter := s.client.HScan(context.Background(), s.hash(relation), 0, "", 0).Iterator()
+	for iter.Next(context.Background()) {
+		// HSCAN yields field and value as alternating elements.
+		if !iter.Next(context.Background()) {
+			break
+		}
+		var value map[string]any
+		if err := json.Unmarshal([]byte(iter.Val()), &value); err != nil {
+			s.fail(err)
+			continue
+		}
+		if matchesArgs(value, args) {
+			results = append(results, value)
+		}
+	}
+	s.fail(iter.Err())
+	return results
+}
+
+// FindSorted is Find ordered by sortField. Redis hashes are unordered,
+// so ties are broken by nothing more stable than scan order.
+func (s *RedisStorage) FindSorted(relation string, args map[string]any, sortField string, ascending bool) []map[string]any {
+	results := s.Find(relation, args)
+	sortRecords(results, sortField, ascending)
+	return results
+}
+
+// Range scans the relation's hash and sorts the matching keys; Redis
+// hashes have no key order to exploit.
+func (s *RedisStorage) Range(relation, startKey, endKey string, inclusive bool) []map[string]any {
+	values := make(map[string]map[string]any)
+	iter := s.client.HScan(context.Background(), s.hash(relation), 0, "", 0).Iterator()
+	for iter.Next(context.Background()) {
+		key := iter.Val()
+		if !iter.Next(context.Background()) {
+			break
+		}
+		if !keyInRange(key, startKey, endKey, inclusive) {
+			continue
+		}
+		var value map[string]any
+		if err := json.Unmarshal([]byte(iter.Val()), &value); err != nil {
+			s.fail(err)
+			continue
+		}
+		values[key] = value
+	}
+	s.fail(iter.Err())
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	results := make([]map[string]any, len(keys))
+	for i, key := range keys {
+		results[i] = values[key]
+	}
+	return results
+}
+
+// Relations returns the relations in s's namespace that hold entries.
+func (s *RedisStorage) Relations() []string {
+	prefix := s.namespace + ":"
+	var names []string
+	iter := s.client.Scan(context.Background(), 0, escapeGlob(prefix)+"*", 0).Iterator()
+	for iter.Next(context.Background()) {
+		names = append(names, strings.TrimPrefix(iter.Val(), prefix))
+	}
+	s.fail(iter.Err())
+	sort.Strings(names)
+	return names
+}
+
+// Keys returns the keys stored in a relation in lexicographic order.
+func (s *RedisStorage) Keys(relation string) []string {
+	keys, err := s.client.HKeys(context.Background(), s.hash(relation)).Result()
+	if err != nil {
+		s.fail(err)
+		return nil
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// escapeGlob escapes Redis glob metacharacters in a literal pattern.
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,143 @@
+package clef
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newTestRedisStorage(t *testing.T) (*RedisStorage, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	s, err := NewRedisStorage(mr.Addr(), "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s, mr
+}
+
+func TestRedisStoragePutAndGet(t *testing.T) {
+	s, mr := newTestRedisStorage(t)
+	s.Put("users", "alice", map[string]any{"name": "Alice", "age": 30})
+
+	val, ok := s.Get("users", "alice")
+	if !ok {
+		t.Fatal("expected to find alice")
+	}
+	want := map[string]any{"name": "Alice", "age": float64(30)}
+	if !reflect.DeepEqual(val, want) {
+		t.Errorf("expected %v, got %v", want, val)
+	}
+	if raw := mr.HGet("clef:users", "alice"); raw != `{"age":30,"name":"Alice"}` {
+		t.Errorf("unexpected stored JSON %q", raw)
+	}
+	if s.Err() != nil {
+		t.Errorf("unexpected error: %v", s.Err())
+	}
+}
+
+func TestRedisStorageGetMissing(t *testing.T) {
+	s, _ := newTestRedisStorage(t)
+	if _, ok := s.Get("users", "nobody"); ok {
+		t.Error("expected missing entry")
+	}
+	if s.Err() != nil {
+		t.Errorf("missing entry should not record an error: %v", s.Err())
+	}
+}
+
+func TestRedisStorageDelete(t *testing.T) {
+	s, _ := newTestRedisStorage(t)
+	s.Put("users", "alice", map[string]any{"name": "Alice"})
+
+	if !s.Delete("users", "alice") {
+		t.Error("expected delete to report an existing entry")
+	}
+	if _, ok := s.Get("users", "alice"); ok {
+		t.Error("expected alice to be deleted")
+	}
+	if s.Delete("users", "alice") {
+		t.Error("expected second delete to report a missing entry")
+	}
+}
+
+func TestRedisStorageFind(t *testing.T) {
+	s, _ := newTestRedisStorage(t)
+	s.Put("users", "alice", map[string]any{"name": "Alice", "role": "admin"})
+	s.Put("users", "bob", map[string]any{"name": "Bob", "role": "user"})
+	s.Put("users", "carol", map[string]any{"name": "Carol", "role": "admin"})
+	s.Put("posts", "p1", map[string]any{"role": "admin"})
+
+	if all := s.Find("users", nil); len(all) != 3 {
+		t.Errorf("expected 3 users, got %d", len(all))
+	}
+	admins := s.FindSorted("users", map[string]any{"role": "admin"}, "name", true)
+	if len(admins) != 2 || admins[0]["name"] != "Alice" || admins[1]["name"] != "Carol" {
+		t.Errorf("unexpected admins: %v", admins)
+	}
+	if none := s.Find("empty", nil); len(none) != 0 {
+		t.Errorf("expected no results, got %v", none)
+	}
+}
+
+func TestRedisStorageBulk(t *testing.T) {
+	s, _ := newTestRedisStorage(t)
+	n := s.BulkPut("users", map[string]map[string]any{
+		"alice": {"name": "Alice"},
+		"bob":   {"name": "Bob"},
+	})
+	if n != 2 || len(s.Find("users", nil)) != 2 {
+		t.Fatalf("expected 2 users, wrote %d", n)
+	}
+	if n := s.BulkDelete("users", []string{"alice", "nobody"}); n != 1 {
+		t.Errorf("expected 1 delete, got %d", n)
+	}
+	if _, ok := s.Get("users", "bob"); !ok {
+		t.Error("expected bob to remain")
+	}
+}
+
+func TestRedisStorageForConceptIsolates(t *testing.T) {
+	s, _ := newTestRedisStorage(t)
+	a := s.ForConcept("urn:app/A")
+	b := s.ForConcept("urn:app/B")
+	a.Put("items", "x", map[string]any{"v": "a"})
+
+	if _, ok := b.Get("items", "x"); ok {
+		t.Error("expected concepts to be isolated")
+	}
+	if got := a.Relations(); !reflect.DeepEqual(got, []string{"items"}) {
+		t.Errorf("expected [items], got %v", got)
+	}
+	if got := a.Keys("items"); !reflect.DeepEqual(got, []string{"x"}) {
+		t.Errorf("expected [x], got %v", got)
+	}
+}
+
+func TestNewRedisStorageUnreachable(t *testing.T) {
+	mr := miniredis.RunT(t)
+	addr := mr.Addr()
+	mr.Close()
+	if _, err := NewRedisStorage(addr, "", 0); err == nil {
+		t.Error("expected connection error")
+	}
+}
+
+func TestRedisStorageRange(t *testing.T) {
+	s, _ := newTestRedisStorage(t)
+	testRange(t, s)
+}
+
+func TestRedisStorageCompareAndSwap(t *testing.T) {
+	s, _ := newTestRedisStorage(t)
+	testCompareAndSwapCounter(t, s, 20)
+
+	if ok, cur := s.CompareAndSwap("counters", "c", map[string]any{"n": 3}, map[string]any{"n": 4}, []string{"n"}); ok || cur["n"] != float64(20) {
+		t.Fatalf("stale swap = %v, %v", ok, cur)
+	}
+	if ok, _ := s.CompareAndSwap("counters", "c", map[string]any{"n": 20}, map[string]any{"n": 21}, []string{"n"}); !ok {
+		t.Fatal("expected swap with int expected value")
+	}
+}
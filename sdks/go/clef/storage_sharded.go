@@ -0,0 +1,237 @@
+package clef
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+)
+
+// keyedRanger is implemented by storages whose Range can also return the
+// key of each entry, so ShardedStorage can merge the shards' ranges.
+type keyedRanger interface {
+	rangeWithKeys(relation, startKey, endKey string, inclusive bool) ([]string, []map[string]any)
+}
+
+// ShardedStorage spreads each relation's entries over several storages
+// by a hash of the relation and key, so concurrent writers contend on
+// different shards. Operations on one key go to its shard; Find,
+// FindSorted and Range query every shard and merge the results.
+//
+// BulkPut and BulkDelete are split by shard, so they are atomic within
+// a shard only. FindSorted orders ties by shard, not insertion order.
+type ShardedStorage struct {
+	shards []Storage
+	hashFn func(relation, key string) int
+}
+
+// NewShardedStorage routes each key to shards[hashFn(relation, key) %
+// len(shards)]. It panics if shards is empty.
+func NewShardedStorage(shards []Storage, hashFn func(relation, key string) int) *ShardedStorage {
+	if len(shards) == 0 {
+		panic("clef: sharded storage needs at least one shard")
+	}
+	return &ShardedStorage{shards: shards, hashFn: hashFn}
+}
+
+// DefaultShardedStorage returns n InMemoryStorage shards selected by the
+// FNV-1a hash of the relation and key. It panics if n is less than 1.
+//
+// Example:
+//
+//	clef.MustRegister("urn:app/Event", &EventHandler{}, clef.DefaultShardedStorage(8))
+func DefaultShardedStorage(n int) *ShardedStorage {
+	if n < 1 {
+		panic(fmt.Sprintf("clef: sharded storage needs at least one shard, got %d", n))
+	}
+	shards := make([]Storage, n)
+	for i := range shards {
+		shards[i] = NewInMemoryStorage()
+	}
+	return NewShardedStorage(shards, fnvShardHash)
+}
+
+// fnvShardHash is the FNV-1a hash of relation and key.
+func fnvShardHash(relation, key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(relation))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	return int(h.Sum32())
+}
+
+func (s *ShardedStorage) shardIndex(relation, key string) int {
+	i := s.hashFn(relation, key) % len(s.shards)
+	if i < 0 {
+		i += len(s.shards)
+	}
+	return i
+}
+
+func (s *ShardedStorage) shardFor(relation, key string) Storage {
+	return s.shards[s.shardIndex(relation, key)]
+}
+
+// each runs fn on every shard concurrently and returns the results in
+// shard order.
+func (s *ShardedStorage) each(fn func(shard Storage) []map[string]any) []map[string]any {
+	parts := make([][]map[string]any, len(s.shards))
+	var wg sync.WaitGroup
+	for i, shard := range s.shards {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			parts[i] = fn(shard)
+		}()
+	}
+	wg.Wait()
+	var results []map[string]any
+	for _, part := range parts {
+		results = append(results, part...)
+	}
+	return results
+}
+
+func (s *ShardedStorage) Get(relation, key string) (map[string]any, bool) {
+	return s.shardFor(relation, key).Get(relation, key)
+}
+
+func (s *ShardedStorage) Put(relation, key string, value map[string]any) {
+	s.shardFor(relation, key).Put(relation, key, value)
+}
+
+func (s *ShardedStorage) Delete(relation, key string) bool {
+	return s.shardFor(relation, key).Delete(relation, key)
+}
+
+func (s *ShardedStorage) Find(relation string, args map[string]any) []map[string]any {
+	return s.each(func(shard Storage) []map[string]any {
+		return shard.Find(relation, args)
+	})
+}
+
+func (s *ShardedStorage) FindSorted(relation string, args map[string]any, sortField string, ascending bool) []map[string]any {
+	results := s.each(func(shard Storage) []map[string]any {
+		return shard.FindSorted(relation, args, sortField, ascending)
+	})
+	sortRecords(results, sortField, ascending)
+	return results
+}
+
+// Range merges the shards' ranges by key. The ranges of shards that
+// cannot report their entries' keys, which only InMemoryStorage can, come
+// last, each in key order.
+func (s *ShardedStorage) Range(relation, startKey, endKey string, inclusive bool) []map[string]any {
+	keys := make([][]string, len(s.shards))
+	values := make([][]map[string]any, len(s.shards))
+	var wg sync.WaitGroup
+	for i, shard := range s.shards {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if kr, ok := shard.(keyedRanger); ok {
+				keys[i], values[i] = kr.rangeWithKeys(relation, startKey, endKey, inclusive)
+			} else {
+				values[i] = shard.Range(relation, startKey, endKey, inclusive)
+			}
+		}()
+	}
+	wg.Wait()
+
+	results := []map[string]any{}
+	next := make([]int, len(s.shards))
+	for {
+		first := -1
+		for i, k := range keys {
+			if next[i] < len(k) && (first < 0 || k[next[i]] < keys[first][next[first]]) {
+				first = i
+			}
+		}
+		if first < 0 {
+			break
+		}
+		results = append(results, values[first][next[first]])
+		next[first]++
+	}
+	for i, part := range values {
+		if keys[i] == nil {
+			results = append(results, part...)
+		}
+	}
+	return results
+}
+
+func (s *ShardedStorage) BulkPut(relation string, entries map[string]map[string]any) int {
+	byShard := make(map[int]map[string]map[string]any)
+	for key, value := range entries {
+		i := s.shardIndex(relation, key)
+		if byShard[i] == nil {
+			byShard[i] = make(map[string]map[string]any)
+		}
+		byShard[i][key] = value
+	}
+	n := 0
+	for i, part := range byShard {
+		n += s.shards[i].BulkPut(relation, part)
+	}
+	return n
+}
+
+func (s *ShardedStorage) BulkDelete(relation string, keys []string) int {
+	byShard := make(map[int][]string)
+	for _, key := range keys {
+		i := s.shardIndex(relation, key)
+		byShard[i] = append(byShard[i], key)
+	}
+	n := 0
+	for i, part := range byShard {
+		n += s.shards[i].BulkDelete(relation, part)
+	}
+	return n
+}
+
+func (s *ShardedStorage) CompareAndSwap(relation, key string, expected, replacement map[string]any, compareFields []string) (bool, map[string]any) {
+	return s.shardFor(relation, key).CompareAndSwap(relation, key, expected, replacement, compareFields)
+}
+
+// Relations lists the relations of every shard that is Enumerable.
+func (s *ShardedStorage) Relations() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, shard := range s.shards {
+		enum, ok := shard.(Enumerable)
+		if !ok {
+			continue
+		}
+		for _, r := range enum.Relations() {
+			if !seen[r] {
+				seen[r] = true
+				names = append(names, r)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Keys lists the keys of relation in every shard that is Enumerable.
+func (s *ShardedStorage) Keys(relation string) []string {
+	var keys []string
+	for _, shard := range s.shards {
+		if enum, ok := shard.(Enumerable); ok {
+			keys = append(keys, enum.Keys(relation)...)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// WithContext implements ContextualStorage by binding every shard.
+func (s *ShardedStorage) WithContext(ctx context.Context) Storage {
+	shards := make([]Storage, len(s.shards))
+	for i, shard := range s.shards {
+		shards[i] = bindStorage(ctx, shard)
+	}
+	return &ShardedStorage{shards: shards, hashFn: s.hashFn}
+}
@@ -0,0 +1,110 @@
+package clef
+
+import (
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func TestShardedStorageDistribution(t *testing.T) {
+	s := DefaultShardedStorage(4)
+	for i := range 1000 {
+		s.Put("events", fmt.Sprintf("evt-%d", i), map[string]any{"n": i})
+	}
+	for i, shard := range s.shards {
+		n := len(shard.Find("events", nil))
+		if n < 200 || n > 300 {
+			t.Errorf("shard %d holds %d entries, want 250 ± 20%%", i, n)
+		}
+	}
+	if n := len(s.Find("events", nil)); n != 1000 {
+		t.Errorf("Find returned %d entries, want 1000", n)
+	}
+	if got, ok := s.Get("events", "evt-42"); !ok || got["n"] != 42 {
+		t.Errorf("Get = %v, %v", got, ok)
+	}
+}
+
+func TestShardedStorageOperations(t *testing.T) {
+	s := DefaultShardedStorage(3)
+	n := s.BulkPut("users", map[string]map[string]any{
+		"u1": {"name": "ada", "age": 36},
+		"u2": {"name": "alan", "age": 41},
+		"u3": {"name": "grace", "age": 85},
+		"u4": {"name": "edsger", "age": 72},
+	})
+	if n != 4 {
+		t.Errorf("BulkPut = %d, want 4", n)
+	}
+	sorted := s.FindSorted("users", nil, "age", true)
+	var ages []any
+	for _, r := range sorted {
+		ages = append(ages, r["age"])
+	}
+	if fmt.Sprint(ages) != "[36 41 72 85]" {
+		t.Errorf("FindSorted ages = %v", ages)
+	}
+	if keys := s.Keys("users"); fmt.Sprint(keys) != "[u1 u2 u3 u4]" {
+		t.Errorf("Keys = %v", keys)
+	}
+	if n := s.BulkDelete("users", []string{"u1", "u2", "missing"}); n != 2 {
+		t.Errorf("BulkDelete = %d, want 2", n)
+	}
+	if !s.Delete("users", "u3") || s.Delete("users", "u3") {
+		t.Error("Delete did not report existence")
+	}
+
+	testRange(t, DefaultShardedStorage(3))
+	testCompareAndSwapCounter(t, DefaultShardedStorage(3), 20)
+}
+
+func TestShardedStorageNegativeHash(t *testing.T) {
+	s := NewShardedStorage([]Storage{NewInMemoryStorage(), NewInMemoryStorage()}, func(relation, key string) int { return -3 })
+	s.Put("r", "k", map[string]any{"v": 1})
+	if _, ok := s.Get("r", "k"); !ok {
+		t.Error("entry lost with a negative hash")
+	}
+}
+
+func TestShardedStorageNeedsShards(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("DefaultShardedStorage(0) did not panic")
+		}
+	}()
+	DefaultShardedStorage(0)
+}
+
+func TestShardedStorageRangeWithOpaqueShard(t *testing.T) {
+	// The second shard hides InMemoryStorage's keyed range, so its
+	// entries follow the merged ones.
+	opaque := struct{ Storage }{NewInMemoryStorage()}
+	s := NewShardedStorage([]Storage{NewInMemoryStorage(), opaque, NewInMemoryStorage()}, func(relation, key string) int {
+		return int(key[0]-'a') % 3
+	})
+	for _, k := range []string{"e", "d", "c", "b", "a", "f"} {
+		s.Put("queue", k, map[string]any{"k": k})
+	}
+	var got string
+	for _, r := range s.Range("queue", "", "", true) {
+		got += r["k"].(string)
+	}
+	if got != "acdfbe" {
+		t.Errorf("Range = %q, want acdf then be", got)
+	}
+}
+
+func benchmarkShardedPut(b *testing.B, shards int) {
+	s := DefaultShardedStorage(shards)
+	var next atomic.Int64
+	value := map[string]any{"v": 1}
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			s.Put("events", strconv.FormatInt(next.Add(1), 10), value)
+		}
+	})
+}
+
+func BenchmarkShardedStoragePut1Shard(b *testing.B)  { benchmarkShardedPut(b, 1) }
+func BenchmarkShardedStoragePut4Shards(b *testing.B) { benchmarkShardedPut(b, 4) }
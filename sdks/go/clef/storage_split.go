@@ -0,0 +1,168 @@
+package clef
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// readWriteSplitStorage sends writes to one storage and reads to another.
+type readWriteSplitStorage struct {
+	writer Storage
+	reader Storage
+}
+
+// ReadWriteSplitStorage sends Put, Delete, BulkPut, BulkDelete and
+// CompareAndSwap to writer, and Get, Find, FindSorted and listing to
+// reader, typically a read replica of writer. Reads may lag behind
+// writes, so a handler that must read its own writes should use writer
+// directly. CompareAndSwap compares against writer, since a stale
+// replica would make it unsafe.
+func ReadWriteSplitStorage(writer, reader Storage) Storage {
+	return &readWriteSplitStorage{writer: writer, reader: reader}
+}
+
+func (s *readWriteSplitStorage) Get(relation, key string) (map[string]any, bool) {
+	return s.reader.Get(relation, key)
+}
+
+func (s *readWriteSplitStorage) Put(relation, key string, value map[string]any) {
+	s.writer.Put(relation, key, value)
+}
+
+func (s *readWriteSplitStorage) Delete(relation, key string) bool {
+	return s.writer.Delete(relation, key)
+}
+
+func (s *readWriteSplitStorage) Find(relation string, args map[string]any) []map[string]any {
+	return s.reader.Find(relation, args)
+}
+
+func (s *readWriteSplitStorage) FindSorted(relation string, args map[string]any, sortField string, ascending bool) []map[string]any {
+	return s.reader.FindSorted(relation, args, sortField, ascending)
+}
+
+func (s *readWriteSplitStorage) Range(relation, startKey, endKey string, inclusive bool) []map[string]any {
+	return s.reader.Range(relation, startKey, endKey, inclusive)
+}
+
+func (s *readWriteSplitStorage) BulkPut(relation string, entries map[string]map[string]any) int {
+	return s.writer.BulkPut(relation, entries)
+}
+
+func (s *readWriteSplitStorage) BulkDelete(relation string, keys []string) int {
+	return s.writer.BulkDelete(relation, keys)
+}
+
+func (s *readWriteSplitStorage) CompareAndSwap(relation, key string, expected, replacement map[string]any, compareFields []string) (bool, map[string]any) {
+	return s.writer.CompareAndSwap(relation, key, expected, replacement, compareFields)
+}
+
+// Relations implements Enumerable when reader does.
+func (s *readWriteSplitStorage) Relations() []string {
+	if enum, ok := s.reader.(Enumerable); ok {
+		return enum.Relations()
+	}
+	return nil
+}
+
+func (s *readWriteSplitStorage) Keys(relation string) []string {
+	if enum, ok := s.reader.(Enumerable); ok {
+		return enum.Keys(relation)
+	}
+	return nil
+}
+
+// WithContext implements ContextualStorage by binding both storages.
+func (s *readWriteSplitStorage) WithContext(ctx context.Context) Storage {
+	return &readWriteSplitStorage{writer: bindStorage(ctx, s.writer), reader: bindStorage(ctx, s.reader)}
+}
+
+// ReplicatingStorage simulates asynchronous replication for tests of
+// code that reads from replicas. Writes through writer go to primary at
+// once and reach replica, a separate InMemoryStorage, replicaLag later,
+// in the order they were made. A nil primary means a new
+// InMemoryStorage.
+//
+// Example:
+//
+//	storage := clef.ReadWriteSplitStorage(clef.ReplicatingStorage(nil, 50*time.Millisecond))
+func ReplicatingStorage(primary Storage, replicaLag time.Duration) (writer Storage, replica Storage) {
+	if primary == nil {
+		primary = NewInMemoryStorage()
+	}
+	r := NewInMemoryStorage()
+	return &replicatingStorage{Storage: primary, replica: r, lag: replicaLag, log: &replicationLog{}}, r
+}
+
+// replicatingStorage forwards to the primary and queues each write for
+// the replica.
+type replicatingStorage struct {
+	Storage
+	replica Storage
+	lag     time.Duration
+	log     *replicationLog
+}
+
+// replicationLog holds writes not yet applied to the replica.
+type replicationLog struct {
+	mu      sync.Mutex
+	pending []replicatedWrite
+}
+
+type replicatedWrite struct {
+	due   time.Time
+	apply func()
+}
+
+// enqueue schedules apply to run against the replica after the lag.
+func (s *replicatingStorage) enqueue(apply func()) {
+	s.log.mu.Lock()
+	s.log.pending = append(s.log.pending, replicatedWrite{due: time.Now().Add(s.lag), apply: apply})
+	s.log.mu.Unlock()
+	time.AfterFunc(s.lag, s.log.drain)
+}
+
+// drain applies every due write, oldest first.
+func (l *replicationLog) drain() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	n := 0
+	for n < len(l.pending) && !l.pending[n].due.After(now) {
+		l.pending[n].apply()
+		n++
+	}
+	l.pending = l.pending[n:]
+}
+
+func (s *replicatingStorage) Put(relation, key string, value map[string]any) {
+	s.Storage.Put(relation, key, value)
+	s.enqueue(func() { s.replica.Put(relation, key, value) })
+}
+
+func (s *replicatingStorage) Delete(relation, key string) bool {
+	ok := s.Storage.Delete(relation, key)
+	s.enqueue(func() { s.replica.Delete(relation, key) })
+	return ok
+}
+
+func (s *replicatingStorage) BulkPut(relation string, entries map[string]map[string]any) int {
+	n := s.Storage.BulkPut(relation, entries)
+	s.enqueue(func() { s.replica.BulkPut(relation, entries) })
+	return n
+}
+
+func (s *replicatingStorage) BulkDelete(relation string, keys []string) int {
+	n := s.Storage.BulkDelete(relation, keys)
+	s.enqueue(func() { s.replica.BulkDelete(relation, keys) })
+	return n
+}
+
+func (s *replicatingStorage) CompareAndSwap(relation, key string, expected, replacement map[string]any, compareFields []string) (bool, map[string]any) {
+	swapped, current := s.Storage.CompareAndSwap(relation, key, expected, replacement, compareFields)
+	if swapped {
+		s.enqueue(func() { s.replica.Put(relation, key, replacement) })
+	}
+	return swapped, current
+}
@@ -0,0 +1,53 @@
+package clef
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReadWriteSplitWithReplicaLag(t *testing.T) {
+	lag := 50 * time.Millisecond
+	storage := ReadWriteSplitStorage(ReplicatingStorage(nil, lag))
+
+	for i := range 10 {
+		storage.Put("items", string(rune('a'+i)), map[string]any{"n": i})
+	}
+	if got := len(storage.Find("items", nil)); got != 0 {
+		t.Fatalf("replica has %d entries immediately after writes, want 0", got)
+	}
+	time.Sleep(lag + 50*time.Millisecond)
+	if got := len(storage.Find("items", nil)); got != 10 {
+		t.Fatalf("replica has %d entries after lag, want 10", got)
+	}
+}
+
+func TestReadWriteSplitRoutes(t *testing.T) {
+	writer, reader := NewInMemoryStorage(), NewInMemoryStorage()
+	storage := ReadWriteSplitStorage(writer, reader)
+	storage.Put("items", "w", map[string]any{"v": 1})
+	reader.Put("items", "r", map[string]any{"v": 2})
+
+	if _, ok := writer.Get("items", "w"); !ok {
+		t.Error("Put did not reach writer")
+	}
+	if _, ok := storage.Get("items", "w"); ok {
+		t.Error("Get read from writer")
+	}
+	if _, ok := storage.Get("items", "r"); !ok {
+		t.Error("Get did not read from reader")
+	}
+	if ok, _ := storage.CompareAndSwap("items", "w", map[string]any{"v": 1}, map[string]any{"v": 3}, []string{"v"}); !ok {
+		t.Error("CompareAndSwap should compare against writer")
+	}
+}
+
+func TestReplicatingStorageKeepsOrder(t *testing.T) {
+	writer, replica := ReplicatingStorage(nil, 10*time.Millisecond)
+	writer.Put("items", "k", map[string]any{"v": 1})
+	writer.Delete("items", "k")
+	writer.Put("items", "k", map[string]any{"v": 2})
+	time.Sleep(40 * time.Millisecond)
+	if v, ok := replica.Get("items", "k"); !ok || v["v"] != 2 {
+		t.Fatalf("replica = %v, %v; want v=2", v, ok)
+	}
+}
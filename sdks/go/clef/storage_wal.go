@@ -0,0 +1,278 @@
+package clef
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sync"
+)
+
+// ErrWALCorrupt is returned by ReplayWAL for a record that fails its
+// checksum, cannot be decoded or claims to be larger than
+// MaxWALRecordSize.
+var ErrWALCorrupt = errors.New("clef: corrupt WAL record")
+
+// MaxWALRecordSize bounds the payload of one WAL record. Larger writes
+// are not logged, and ReplayWAL reads a larger length as corruption
+// rather than allocating it.
+const MaxWALRecordSize = 64 << 20
+
+// walHeaderSize is the length and CRC-32 prefix of each WAL record.
+const walHeaderSize = 8
+
+// walRecord is one logged write. Bulk writes are one record, so replay
+// applies them whole or not at all.
+type walRecord struct {
+	Op       string                    `json:"op"`
+	Relation string                    `json:"relation"`
+	Key      string                    `json:"key,omitempty"`
+	Value    map[string]any            `json:"value,omitempty"`
+	Entries  map[string]map[string]any `json:"entries,omitempty"`
+	Keys     []string                  `json:"keys,omitempty"`
+	// Expected and CompareFields are the arguments of a "cas" record.
+	Expected      map[string]any `json:"expected,omitempty"`
+	CompareFields []string       `json:"compare_fields,omitempty"`
+}
+
+// WALStorage is a Storage decorator that appends each write to a
+// write-ahead log before applying it, so the state of a storage lost in a
+// crash, such as an InMemoryStorage, can be rebuilt with ReplayWAL. Each
+// record is a 4-byte big-endian length, a 4-byte CRC-32 of the payload
+// and the operation as JSON. Every write, CompareAndSwap included, is
+// logged before it is applied, and replaying a CompareAndSwap repeats
+// the comparison. Reads pass straight through. If wal has a Sync method,
+// as *os.File does, it is called after each record so the record is on
+// disk before the write is applied.
+//
+// A write whose record cannot be logged is not applied: it is reported
+// with ReportStorageError, so the transport answers the handler call
+// with a storage error, and the writes that follow in the same call are
+// dropped too, as with QuotaStorage. Outside a handler call the error is
+// logged. The log therefore never lags the storage it protects.
+//
+// Example:
+//
+//	f, err := os.OpenFile("article.wal", os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	storage := clef.NewInMemoryStorage()
+//	if _, err := clef.ReplayWAL(f, storage); err != nil {
+//	    log.Fatal(err)
+//	}
+//	clef.MustRegister("urn:app/Article", &ArticleHandler{}, clef.NewWALStorage(storage, f))
+type WALStorage struct {
+	Storage
+	wal io.ReadWriter
+	// mu keeps the log in the order writes are applied.
+	mu *sync.Mutex
+	// ctx is the context the storage is bound to, for ReportStorageError.
+	ctx context.Context
+}
+
+// NewWALStorage logs the writes to inner to wal. It only appends to wal;
+// replay its existing records with ReplayWAL first.
+func NewWALStorage(inner Storage, wal io.ReadWriter) *WALStorage {
+	return &WALStorage{Storage: inner, wal: wal, mu: &sync.Mutex{}}
+}
+
+// WithContext implements ContextualStorage. The bound storage shares
+// the log with s and reports failed log writes against ctx.
+func (s *WALStorage) WithContext(ctx context.Context) Storage {
+	return &WALStorage{Storage: bindStorage(ctx, s.Storage), wal: s.wal, mu: s.mu, ctx: ctx}
+}
+
+func (s *WALStorage) Put(relation, key string, value map[string]any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rejected(s.append(walRecord{Op: "put", Relation: relation, Key: key, Value: value})) {
+		return
+	}
+	s.Storage.Put(relation, key, value)
+}
+
+func (s *WALStorage) Delete(relation, key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rejected(s.append(walRecord{Op: "delete", Relation: relation, Key: key})) {
+		return false
+	}
+	return s.Storage.Delete(relation, key)
+}
+
+func (s *WALStorage) BulkPut(relation string, entries map[string]map[string]any) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rejected(s.append(walRecord{Op: "bulk_put", Relation: relation, Entries: entries})) {
+		return 0
+	}
+	return s.Storage.BulkPut(relation, entries)
+}
+
+func (s *WALStorage) BulkDelete(relation string, keys []string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rejected(s.append(walRecord{Op: "bulk_delete", Relation: relation, Keys: keys})) {
+		return 0
+	}
+	return s.Storage.BulkDelete(relation, keys)
+}
+
+func (s *WALStorage) CompareAndSwap(relation, key string, expected, replacement map[string]any, compareFields []string) (bool, map[string]any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rejected(s.append(walRecord{Op: "cas", Relation: relation, Key: key, Value: replacement, Expected: expected, CompareFields: compareFields})) {
+		current, _ := s.Storage.Get(relation, key)
+		return false, current
+	}
+	return s.Storage.CompareAndSwap(relation, key, expected, replacement, compareFields)
+}
+
+// MergeRelation implements Merger. The merged entries are logged as one
+// "bulk_put" record, so replay applies the batch whole or not at all.
+func (s *WALStorage) MergeRelation(relation string, entries map[string]map[string]any, strategy MergeStrategy) (int, error) {
+	if err := strategy.validate(); err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	merged := make(map[string]map[string]any, len(entries))
+	for key, value := range entries {
+		if prev, ok := s.Storage.Get(relation, key); ok {
+			if value, ok = strategy.merge(prev, value); !ok {
+				continue
+			}
+		}
+		merged[key] = value
+	}
+	if len(merged) == 0 {
+		return 0, nil
+	}
+	if s.rejected(s.append(walRecord{Op: "bulk_put", Relation: relation, Entries: merged})) {
+		return 0, nil
+	}
+	return s.Storage.BulkPut(relation, merged), nil
+}
+
+// Relations implements Enumerable when the inner storage does.
+func (s *WALStorage) Relations() []string {
+	if enum, ok := s.Storage.(Enumerable); ok {
+		return enum.Relations()
+	}
+	return nil
+}
+
+// Keys implements Enumerable when the inner storage does.
+func (s *WALStorage) Keys(relation string) []string {
+	if enum, ok := s.Storage.(Enumerable); ok {
+		return enum.Keys(relation)
+	}
+	return nil
+}
+
+// rejected reports err unless nil, and whether the write must be dropped:
+// because of err, or because an earlier storage error was reported in
+// the same handler call.
+func (s *WALStorage) rejected(err error) bool {
+	if err != nil {
+		ReportStorageError(s.ctx, err)
+		return true
+	}
+	return storageFailed(s.ctx)
+}
+
+// append writes one record and syncs it. The caller holds mu.
+func (s *WALStorage) append(rec walRecord) error {
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("clef: WAL %s %s/%s: %w", rec.Op, rec.Relation, rec.Key, err)
+	}
+	if len(payload) > MaxWALRecordSize {
+		return fmt.Errorf("clef: WAL %s %s/%s: record of %d bytes exceeds MaxWALRecordSize", rec.Op, rec.Relation, rec.Key, len(payload))
+	}
+	buf := make([]byte, walHeaderSize, walHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(payload))
+	if _, err := s.wal.Write(append(buf, payload...)); err != nil {
+		return fmt.Errorf("clef: WAL %s %s/%s: %w", rec.Op, rec.Relation, rec.Key, err)
+	}
+	if f, ok := s.wal.(interface{ Sync() error }); ok {
+		if err := f.Sync(); err != nil {
+			return fmt.Errorf("clef: WAL %s %s/%s: sync: %w", rec.Op, rec.Relation, rec.Key, err)
+		}
+	}
+	return nil
+}
+
+// ReplayWAL applies the records read from r to storage, in order, and
+// returns how many it applied. A record cut short at the end of r, as a
+// crash mid-write leaves it, ends the log like EOF: if r has a Truncate
+// method, as *os.File does, the partial record is cut off so records
+// appended later follow the last complete one. ReplayWAL stops at the
+// first record that fails its checksum or cannot be decoded with an
+// error wrapping ErrWALCorrupt; the records before it have been applied.
+// As with RedisStorage, values come back as decoded JSON, so numbers are
+// float64.
+func ReplayWAL(r io.Reader, storage Storage) (replayed int, err error) {
+	header := make([]byte, walHeaderSize)
+	var offset int64
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				return replayed, nil
+			}
+			return replayed, truncateWAL(r, offset, err)
+		}
+		size := binary.BigEndian.Uint32(header[0:4])
+		if size > MaxWALRecordSize {
+			return replayed, fmt.Errorf("%w %d: record of %d bytes exceeds MaxWALRecordSize", ErrWALCorrupt, replayed+1, size)
+		}
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return replayed, truncateWAL(r, offset, err)
+		}
+		if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+			return replayed, fmt.Errorf("%w %d: checksum mismatch", ErrWALCorrupt, replayed+1)
+		}
+		var rec walRecord
+		if err := json.Unmarshal(payload, &rec); err != nil {
+			return replayed, fmt.Errorf("%w %d: %v", ErrWALCorrupt, replayed+1, err)
+		}
+		switch rec.Op {
+		case "put":
+			storage.Put(rec.Relation, rec.Key, rec.Value)
+		case "delete":
+			storage.Delete(rec.Relation, rec.Key)
+		case "bulk_put":
+			storage.BulkPut(rec.Relation, rec.Entries)
+		case "bulk_delete":
+			storage.BulkDelete(rec.Relation, rec.Keys)
+		case "cas":
+			storage.CompareAndSwap(rec.Relation, rec.Key, rec.Expected, rec.Value, rec.CompareFields)
+		default:
+			return replayed, fmt.Errorf("%w %d: unknown operation %q", ErrWALCorrupt, replayed+1, rec.Op)
+		}
+		replayed++
+		offset += walHeaderSize + int64(size)
+	}
+}
+
+// truncateWAL handles the read error err of a record starting at offset.
+// A record cut short by the end of the log is a torn write: it is cut
+// off the log if possible and the replay ends cleanly. Other read errors
+// are returned.
+func truncateWAL(r io.Reader, offset int64, err error) error {
+	if err != io.ErrUnexpectedEOF && err != io.EOF {
+		return fmt.Errorf("clef: reading WAL: %w", err)
+	}
+	if f, ok := r.(interface{ Truncate(size int64) error }); ok {
+		if err := f.Truncate(offset); err != nil {
+			return fmt.Errorf("clef: truncating torn WAL record: %w", err)
+		}
+	}
+	return nil
+}
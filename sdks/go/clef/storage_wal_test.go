@@ -0,0 +1,161 @@
+package clef
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWALReplay(t *testing.T) {
+	var wal bytes.Buffer
+	s := NewWALStorage(NewInMemoryStorage(), &wal)
+	for i := range 5 {
+		key := fmt.Sprintf("a%d", i)
+		s.Put("articles", key, map[string]any{"slug": key, "views": i})
+	}
+	s.BulkPut("users", map[string]map[string]any{"ada": {"name": "Ada"}, "alan": {"name": "Alan"}})
+	s.Delete("users", "alan")
+	s.CompareAndSwap("articles", "a0", map[string]any{"views": 0}, map[string]any{"slug": "a0", "views": 10}, []string{"views"})
+	s.CompareAndSwap("articles", "a1", map[string]any{"views": 99}, map[string]any{"slug": "a1", "views": 99}, []string{"views"})
+
+	// A crash loses the in-memory storage; replay rebuilds it.
+	fresh := NewInMemoryStorage()
+	n, err := ReplayWAL(bytes.NewReader(wal.Bytes()), fresh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 9 {
+		t.Errorf("replayed %d records, want 9", n)
+	}
+	for i := range 5 {
+		key := fmt.Sprintf("a%d", i)
+		got, ok := fresh.Get("articles", key)
+		want := float64(i)
+		if i == 0 {
+			want = 10
+		}
+		if !ok || got["views"] != want {
+			t.Errorf("articles/%s = %v, %v; want views %v", key, got, ok, want)
+		}
+	}
+	if got, _ := fresh.Get("articles", "a1"); got["views"] != float64(1) {
+		t.Errorf("failed CompareAndSwap applied on replay: %v", got)
+	}
+	if _, ok := fresh.Get("users", "ada"); !ok {
+		t.Error("bulk put not replayed")
+	}
+	if _, ok := fresh.Get("users", "alan"); ok {
+		t.Error("delete not replayed")
+	}
+}
+
+func TestWALReplayDetectsCorruption(t *testing.T) {
+	var wal bytes.Buffer
+	s := NewWALStorage(NewInMemoryStorage(), &wal)
+	s.Put("articles", "a1", map[string]any{"title": "first"})
+	s.Put("articles", "a2", map[string]any{"title": "second"})
+
+	corrupt := bytes.Clone(wal.Bytes())
+	corrupt[len(corrupt)-3] ^= 0xff
+	fresh := NewInMemoryStorage()
+	n, err := ReplayWAL(bytes.NewReader(corrupt), fresh)
+	if !errors.Is(err, ErrWALCorrupt) || n != 1 {
+		t.Errorf("ReplayWAL = %d, %v; want 1 record and ErrWALCorrupt", n, err)
+	}
+	if _, ok := fresh.Get("articles", "a1"); !ok {
+		t.Error("record before the corruption not applied")
+	}
+
+	huge := bytes.Clone(wal.Bytes())
+	binary.BigEndian.PutUint32(huge[0:4], math.MaxUint32)
+	if n, err := ReplayWAL(bytes.NewReader(huge), NewInMemoryStorage()); !errors.Is(err, ErrWALCorrupt) || n != 0 {
+		t.Errorf("oversized length: ReplayWAL = %d, %v; want 0 records and ErrWALCorrupt", n, err)
+	}
+}
+
+func TestWALReplayTruncatesTornTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "article.wal")
+	open := func() *os.File {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { f.Close() })
+		return f
+	}
+	f := open()
+	s := NewWALStorage(NewInMemoryStorage(), f)
+	s.Put("articles", "a1", map[string]any{"title": "first"})
+	s.Put("articles", "a2", map[string]any{"title": "second"})
+	info, _ := f.Stat()
+	// A crash in the middle of writing the second record.
+	if err := f.Truncate(info.Size() - 5); err != nil {
+		t.Fatal(err)
+	}
+
+	f = open()
+	storage := NewInMemoryStorage()
+	if n, err := ReplayWAL(f, storage); err != nil || n != 1 {
+		t.Fatalf("torn WAL: ReplayWAL = %d, %v; want 1 record and no error", n, err)
+	}
+	NewWALStorage(storage, f).Put("articles", "a3", map[string]any{"title": "third"})
+
+	f = open()
+	if n, err := ReplayWAL(f, NewInMemoryStorage()); err != nil || n != 2 {
+		t.Errorf("after restart: ReplayWAL = %d, %v; want 2 records and no error", n, err)
+	}
+}
+
+// failingWriter is a WAL whose writes fail, as on a full disk.
+type failingWriter struct{ bytes.Buffer }
+
+func (w *failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("no space left on device")
+}
+
+func TestWALStorageDropsWritesItCannotLog(t *testing.T) {
+	resetRegistry()
+	inner := NewInMemoryStorage()
+	Register("urn:test/Article", HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		storage.Put("articles", "a1", map[string]any{"slug": "a1"})
+		return map[string]any{"variant": "ok"}
+	}), NewWALStorage(inner, &failingWriter{}))
+
+	c := InvokeLocal(context.Background(), ActionInvocation{Concept: "urn:test/Article", Action: "create"})
+	if c.Variant != "error" || c.Output["code"] != "storage_error" {
+		t.Errorf("completion = %s %v, want a storage error", c.Variant, c.Output)
+	}
+	if _, ok := inner.Get("articles", "a1"); ok {
+		t.Error("write applied although it was not logged")
+	}
+}
+
+func TestWALStorageMergeRelation(t *testing.T) {
+	var wal bytes.Buffer
+	s := NewWALStorage(NewInMemoryStorage(), &wal)
+	s.Put("products", "p1", map[string]any{"name": "Pen", "price": 1})
+	n, err := MergeRelation(s, "products", map[string]map[string]any{
+		"p1": {"price": 2},
+		"p2": {"name": "Ink"},
+	}, MergePatch)
+	if err != nil || n != 2 {
+		t.Fatalf("MergeRelation = %d, %v; want 2", n, err)
+	}
+	if keys := s.Keys("products"); len(keys) != 2 {
+		t.Errorf("Keys = %v, want both products", keys)
+	}
+
+	fresh := NewInMemoryStorage()
+	if _, err := ReplayWAL(bytes.NewReader(wal.Bytes()), fresh); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := fresh.Get("products", "p1"); got["name"] != "Pen" || got["price"] != float64(2) {
+		t.Errorf("replayed p1 = %v, want the patched entry", got)
+	}
+}
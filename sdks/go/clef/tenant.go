@@ -0,0 +1,126 @@
+package clef
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// TenantHeader is the HTTP header the default tenant extractor reads when
+// the input has no "_tenant" field.
+const TenantHeader = "X-Tenant-ID"
+
+// TenantClaim is the claim holding an authenticated caller's tenant.
+const TenantClaim = "tenant"
+
+// tenantRegistry holds tenant-scoped concepts, keyed by tenant ID and
+// then concept URI. It is consulted before the global registry.
+var tenantRegistry = make(map[string]map[string]registryEntry)
+
+// RegisterTenant registers handler and storage for conceptURI within one
+// tenant. Invocations whose tenant (see WithTenantExtractor) is tenantID
+// are routed here instead of to the globally registered concept, so each
+// tenant can have its own storage, or its own handler. A concept
+// registered for any tenant is served only to the tenants that
+// registered it: other tenants, and callers without a tenant, get
+// "not_found" even if it is also registered globally. Concepts no tenant
+// registered are served from the global registry. As with Register, a
+// nil storage means a new InMemoryStorage and a ConceptValidator handler
+// is validated first. The server's storage decorators, such as
+// WithStorageQuota, apply to tenant storage too.
+//
+// Example:
+//
+//	clef.RegisterTenant("acme", "urn:app/Article", &ArticleHandler{}, acmeStorage)
+//	clef.RegisterTenant("globex", "urn:app/Article", &ArticleHandler{}, globexStorage)
+func RegisterTenant(tenantID, conceptURI string, handler ConceptHandler, storage Storage) error {
+	if tenantID == "" {
+		return fmt.Errorf("clef: empty tenant ID for %s", conceptURI)
+	}
+	if err := ValidateURIFormat(conceptURI); err != nil {
+		return err
+	}
+	if storage == nil {
+		storage = NewInMemoryStorage()
+	}
+	entry := isolate(conceptURI, registryEntry{handler: handler, storage: storage})
+	if err := validate(conceptURI, handler, entry.storage); err != nil {
+		return err
+	}
+	if tenantRegistry[tenantID] == nil {
+		tenantRegistry[tenantID] = make(map[string]registryEntry)
+	}
+	tenantRegistry[tenantID][conceptURI] = entry
+	return nil
+}
+
+// TenantExtractor returns the tenant an invocation belongs to, or "" for
+// none. r is nil for invocations that did not arrive over HTTP.
+type TenantExtractor func(inv ActionInvocation, r *http.Request) string
+
+// WithTenantExtractor replaces how the transport determines the tenant of
+// an unauthenticated invocation. The default uses the input's "_tenant"
+// string field, then the X-Tenant-ID header. Authenticated callers,
+// those with claims (see ContextWithClaims), always get the tenant of
+// their TenantClaim, or none, so they cannot pick another tenant's.
+func WithTenantExtractor(fn func(inv ActionInvocation, r *http.Request) string) ServeOption {
+	return func(c *ServerConfig) {
+		c.tenantExtractor = fn
+	}
+}
+
+// DefaultTenantExtractor reads the input's "_tenant" field, then the
+// X-Tenant-ID header.
+func DefaultTenantExtractor(inv ActionInvocation, r *http.Request) string {
+	if tenant, ok := inv.Input["_tenant"].(string); ok && tenant != "" {
+		return tenant
+	}
+	if r != nil {
+		return r.Header.Get(TenantHeader)
+	}
+	return ""
+}
+
+type tenantKey struct{}
+
+// TenantFromContext returns the tenant of the current invocation, if any.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantKey{}).(string)
+	return tenant, ok && tenant != ""
+}
+
+// withTenant attaches the tenant of inv to ctx, unless one is already
+// attached: the TenantClaim of the caller's claims, or for
+// unauthenticated callers what the tenant extractor returns. r may be
+// nil.
+func (s *server) withTenant(ctx context.Context, inv ActionInvocation, r *http.Request) context.Context {
+	if _, ok := ctx.Value(tenantKey{}).(string); ok {
+		return ctx
+	}
+	if claims := ClaimsFromContext(ctx); claims != nil {
+		tenant, _ := claims[TenantClaim].(string)
+		return context.WithValue(ctx, tenantKey{}, tenant)
+	}
+	extract := s.config.tenantExtractor
+	if extract == nil {
+		extract = DefaultTenantExtractor
+	}
+	return context.WithValue(ctx, tenantKey{}, extract(inv, r))
+}
+
+// lookupTenant returns the entry the tenant in ctx registered for inv's
+// concept, with the server's storage decorators applied. scoped reports
+// whether any tenant registered the concept, in which case the global
+// registry must not serve it.
+func (s *server) lookupTenant(ctx context.Context, inv ActionInvocation) (entry registryEntry, ok, scoped bool) {
+	tenant, _ := TenantFromContext(ctx)
+	if entry, ok := tenantRegistry[tenant][inv.Concept]; ok {
+		return s.wrapStorage(tenant+"\x00"+inv.Concept, entry), true, true
+	}
+	for _, entries := range tenantRegistry {
+		if _, ok := entries[inv.Concept]; ok {
+			return registryEntry{}, false, true
+		}
+	}
+	return registryEntry{}, false, false
+}
@@ -0,0 +1,131 @@
+package clef
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// storeHandler saves its input under input["key"].
+type storeHandler struct{}
+
+func (storeHandler) Handle(action string, input map[string]any, storage Storage) map[string]any {
+	key, _ := input["key"].(string)
+	storage.Put("items", key, map[string]any{"key": key})
+	return map[string]any{"variant": "ok"}
+}
+
+func TestTenantStorageIsolated(t *testing.T) {
+	resetRegistry()
+	acme, globex := NewInMemoryStorage(), NewInMemoryStorage()
+	if err := RegisterTenant("acme", "urn:test/Store", storeHandler{}, acme); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterTenant("globex", "urn:test/Store", storeHandler{}, globex); err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler()
+
+	doRequest(h, "POST", "/invoke", `{"concept":"urn:test/Store","action":"put","input":{"_tenant":"acme","key":"a1"}}`)
+	req := httptest.NewRequest("POST", "/invoke", strings.NewReader(`{"concept":"urn:test/Store","action":"put","input":{"key":"g1"}}`))
+	req.Header.Set(TenantHeader, "globex")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if _, ok := acme.Get("items", "a1"); !ok {
+		t.Error("acme write missing from acme storage")
+	}
+	if _, ok := acme.Get("items", "g1"); ok {
+		t.Error("globex write leaked into acme storage")
+	}
+	if _, ok := globex.Get("items", "g1"); !ok {
+		t.Error("globex write missing from globex storage")
+	}
+	if _, ok := globex.Get("items", "a1"); ok {
+		t.Error("acme write leaked into globex storage")
+	}
+
+	// Without a tenant, or for an unknown one, the global registry applies.
+	if c := invokeRecorder(t, `{"concept":"urn:test/Store","action":"put","input":{"key":"x"}}`); c.Output["code"] != "not_found" {
+		t.Errorf("no tenant: %+v", c.Output)
+	}
+}
+
+func TestWithTenantExtractor(t *testing.T) {
+	resetRegistry()
+	storage := NewInMemoryStorage()
+	RegisterTenant("t1", "urn:test/Store", storeHandler{}, storage)
+	var seen string
+	Register("urn:test/Who", HandlerFunc(func(ctx context.Context, action string, input map[string]any, s Storage) map[string]any {
+		seen, _ = TenantFromContext(ctx)
+		return map[string]any{"variant": "ok"}
+	}), nil)
+	h := NewHandler(WithTenantExtractor(func(inv ActionInvocation, r *http.Request) string {
+		return strings.TrimPrefix(r.Host, "tenant-")
+	}))
+
+	req := httptest.NewRequest("POST", "http://tenant-t1/invoke", strings.NewReader(`{"concept":"urn:test/Store","action":"put","input":{"key":"k"}}`))
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if _, ok := storage.Get("items", "k"); !ok {
+		t.Error("custom extractor did not route to tenant t1")
+	}
+
+	req = httptest.NewRequest("POST", "http://tenant-t1/invoke", strings.NewReader(`{"concept":"urn:test/Who","action":"who"}`))
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if seen != "t1" {
+		t.Errorf("TenantFromContext = %q, want t1", seen)
+	}
+}
+
+func TestTenantConceptFailsClosed(t *testing.T) {
+	resetRegistry()
+	shared, acme := NewInMemoryStorage(), NewInMemoryStorage()
+	Register("urn:test/Store", storeHandler{}, shared)
+	RegisterTenant("acme", "urn:test/Store", storeHandler{}, acme)
+	s := newServer(nil)
+
+	as := func(tenant string) context.Context {
+		return context.WithValue(context.Background(), tenantKey{}, tenant)
+	}
+	for _, tenant := range []string{"globex", ""} {
+		c := s.dispatch(as(tenant), ActionInvocation{Concept: "urn:test/Store", Action: "put", Input: map[string]any{"key": "k"}})
+		if c.Output["code"] != "not_found" {
+			t.Errorf("tenant %q: %+v, want not_found", tenant, c.Output)
+		}
+	}
+	if len(shared.Find("items", nil)) != 0 {
+		t.Error("another tenant reached the global storage")
+	}
+}
+
+func TestTenantFromClaims(t *testing.T) {
+	resetRegistry()
+	acme, globex := NewInMemoryStorage(), NewInMemoryStorage()
+	RegisterTenant("acme", "urn:test/Store", storeHandler{}, acme)
+	RegisterTenant("globex", "urn:test/Store", storeHandler{}, globex)
+	inner := NewHandler()
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inner.ServeHTTP(w, r.WithContext(ContextWithClaims(r.Context(), map[string]any{"sub": "ada", TenantClaim: "acme"})))
+	})
+
+	req := httptest.NewRequest("POST", "/invoke", strings.NewReader(`{"concept":"urn:test/Store","action":"put","input":{"_tenant":"globex","key":"k"}}`))
+	req.Header.Set(TenantHeader, "globex")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if _, ok := acme.Get("items", "k"); !ok {
+		t.Error("write did not go to the tenant of the caller's claims")
+	}
+	if _, ok := globex.Get("items", "k"); ok {
+		t.Error("authenticated caller chose another tenant")
+	}
+}
+
+func TestTenantStorageQuota(t *testing.T) {
+	resetRegistry()
+	RegisterTenant("acme", "urn:test/Store", storeHandler{}, nil)
+	s := newServer([]ServeOption{WithStorageQuota(8)})
+	c := s.dispatch(context.WithValue(context.Background(), tenantKey{}, "acme"), ActionInvocation{Concept: "urn:test/Store", Action: "put", Input: map[string]any{"key": "a-long-key"}})
+	if c.Output["code"] != "storage_quota_exceeded" {
+		t.Errorf("tenant write over quota = %+v", c.Output)
+	}
+}
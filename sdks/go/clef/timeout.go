@@ -0,0 +1,98 @@
+package clef
+
+import (
+	"context"
+	"sync"
+)
+
+// TimeoutMode selects the transport's behavior when a handler exceeds
+// ConceptOptions.Timeout.
+type TimeoutMode int
+
+const (
+	// AbortOnTimeout discards the handler's work and returns an error
+	// completion with code "timeout".
+	AbortOnTimeout TimeoutMode = iota
+	// ReturnPartialOnTimeout returns whatever the handler has written to
+	// its PartialResultWriter, marking the completion as partial.
+	ReturnPartialOnTimeout
+)
+
+// PartialResultWriter collects output fields as a handler produces them so
+// the transport can return them if the handler times out. It is safe for
+// concurrent use.
+type PartialResultWriter struct {
+	mu     sync.Mutex
+	fields map[string]any
+}
+
+// Set records an output field.
+func (p *PartialResultWriter) Set(key string, value any) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fields == nil {
+		p.fields = make(map[string]any)
+	}
+	p.fields[key] = value
+}
+
+// Snapshot returns a copy of the fields written so far.
+func (p *PartialResultWriter) Snapshot() map[string]any {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make(map[string]any, len(p.fields))
+	for k, v := range p.fields {
+		out[k] = v
+	}
+	return out
+}
+
+type partialWriterKey struct{}
+
+// PartialResultWriterFromContext returns the writer installed by the
+// transport for ReturnPartialOnTimeout concepts. It never returns nil;
+// outside such an invocation the returned writer is simply discarded.
+func PartialResultWriterFromContext(ctx context.Context) *PartialResultWriter {
+	if p, ok := ctx.Value(partialWriterKey{}).(*PartialResultWriter); ok {
+		return p
+	}
+	return &PartialResultWriter{}
+}
+
+// runWithTimeout calls the handler, enforcing the entry's timeout. The
+// returned flag reports whether the result is a partial snapshot.
+func runWithTimeout(ctx context.Context, entry registryEntry, action string, input map[string]any) (map[string]any, bool) {
+	opts := entry.options
+	if opts.Timeout <= 0 {
+		return callHandler(ctx, entry.handler, action, input, entry.storage), false
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	partial := &PartialResultWriter{}
+	if opts.TimeoutMode == ReturnPartialOnTimeout {
+		ctx = context.WithValue(ctx, partialWriterKey{}, partial)
+	}
+
+	done := make(chan map[string]any, 1)
+	go func() {
+		done <- callHandlerRecovered(ctx, entry.handler, action, input, entry.storage)
+	}()
+
+	select {
+	case result := <-done:
+		return result, false
+	case <-ctx.Done():
+		if opts.TimeoutMode == ReturnPartialOnTimeout {
+			result := partial.Snapshot()
+			result["variant"] = "ok"
+			return result, true
+		}
+		return map[string]any{
+			"variant": "error",
+			"code":    "timeout",
+			"message": "handler exceeded timeout of " + opts.Timeout.String(),
+		}, false
+	}
+}
@@ -0,0 +1,103 @@
+package clef
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// streamHandler writes one partial field, then blocks until cancelled.
+type streamHandler struct{}
+
+func (h *streamHandler) Handle(action string, input map[string]any, storage Storage) map[string]any {
+	return h.HandleContext(context.Background(), action, input, storage)
+}
+
+func (h *streamHandler) HandleContext(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+	PartialResultWriterFromContext(ctx).Set("rows", 3)
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+	}
+	return map[string]any{"variant": "ok", "rows": 10}
+}
+
+func invokeRecorder(t *testing.T, body string) ActionCompletion {
+	t.Helper()
+	rec := doRequest(NewHandler(), http.MethodPost, "/invoke", body)
+	var c ActionCompletion
+	if err := json.NewDecoder(rec.Body).Decode(&c); err != nil {
+		t.Fatalf("decode completion: %v", err)
+	}
+	return c
+}
+
+func TestTimeoutReturnsPartial(t *testing.T) {
+	resetRegistry()
+	RegisterWithOptions("urn:test/Stream", &streamHandler{}, nil, ConceptOptions{
+		Timeout:     20 * time.Millisecond,
+		TimeoutMode: ReturnPartialOnTimeout,
+	})
+
+	c := invokeRecorder(t, `{"concept":"urn:test/Stream","action":"scan"}`)
+	if !c.Partial {
+		t.Fatal("expected partial completion")
+	}
+	if c.Output["rows"] != float64(3) {
+		t.Errorf("expected partial rows=3, got %v", c.Output["rows"])
+	}
+}
+
+func TestTimeoutAborts(t *testing.T) {
+	resetRegistry()
+	RegisterWithOptions("urn:test/Stream", &streamHandler{}, nil, ConceptOptions{
+		Timeout: 20 * time.Millisecond,
+	})
+
+	c := invokeRecorder(t, `{"concept":"urn:test/Stream","action":"scan"}`)
+	if c.Partial {
+		t.Error("abort mode must not return partial results")
+	}
+	if c.Variant != "error" || c.Output["code"] != "timeout" {
+		t.Errorf("expected timeout error, got %v %v", c.Variant, c.Output)
+	}
+}
+
+func TestNoTimeoutRunsToCompletion(t *testing.T) {
+	resetRegistry()
+	Register("urn:test/Echo", &echoHandler{}, nil)
+
+	c := invokeRecorder(t, `{"concept":"urn:test/Echo","action":"echo","input":{"message":"hi"}}`)
+	if c.Partial || c.Variant != "ok" || c.Output["message"] != "hi" {
+		t.Errorf("unexpected completion %+v", c)
+	}
+}
+
+func TestHandlerGoroutinePanicBecomesError(t *testing.T) {
+	panicky := HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		panic("boom")
+	})
+	cases := map[string]func(){
+		"timeout": func() {
+			RegisterWithOptions("urn:test/Panicky", panicky, nil, ConceptOptions{Timeout: time.Second})
+		},
+		"adaptive": func() {
+			Register("urn:test/Panicky", Chain(panicky, AdaptiveTimeoutMiddleware(AdaptiveTimeoutOptions{MaxTimeout: time.Second})), nil)
+		},
+		"cpu_timeout": func() {
+			Register("urn:test/Panicky", Chain(panicky, WithCPUTimeout(time.Second)), nil)
+		},
+	}
+	for name, register := range cases {
+		t.Run(name, func(t *testing.T) {
+			resetRegistry()
+			register()
+			c := invokeRecorder(t, `{"concept":"urn:test/Panicky","action":"run"}`)
+			if c.Variant != "error" || c.Output["code"] != "panic" {
+				t.Fatalf("completion = %v %v", c.Variant, c.Output)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package clef
+
+import "context"
+
+// OutputTransformer reshapes a handler's output for the caller described
+// by claims (see ClaimsFromContext).
+type OutputTransformer func(output map[string]any, claims map[string]any) map[string]any
+
+// TransformMiddleware passes each output through the transformer for its
+// action, or the "*" entry if the action has none. Outputs with the
+// "error" variant pass through unchanged.
+//
+// Example:
+//
+//	clef.MustRegister("urn:app/User", clef.Chain(&UserHandler{}, clef.TransformMiddleware(map[string]clef.OutputTransformer{
+//	    "get": clef.PickFields("id", "name", "avatar"),
+//	    "*":   clef.RenameFields(map[string]string{"user_name": "userName"}),
+//	})), nil)
+func TransformMiddleware(transforms map[string]OutputTransformer) MiddlewareFunc {
+	return func(next ConceptHandler) ConceptHandler {
+		return HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+			output := callHandler(ctx, next, action, input, storage)
+			transform, ok := transforms[action]
+			if !ok {
+				transform, ok = transforms["*"]
+			}
+			if !ok || output["variant"] == "error" {
+				return output
+			}
+			return transform(output, ClaimsFromContext(ctx))
+		})
+	}
+}
+
+// PickFields keeps only fields, plus "variant", dropping the rest.
+func PickFields(fields ...string) OutputTransformer {
+	return func(output map[string]any, claims map[string]any) map[string]any {
+		picked := make(map[string]any, len(fields)+1)
+		if v, ok := output["variant"]; ok {
+			picked["variant"] = v
+		}
+		for _, f := range fields {
+			if v, ok := output[f]; ok {
+				picked[f] = v
+			}
+		}
+		return picked
+	}
+}
+
+// RenameFields renames the fields in mapping (old name to new name),
+// leaving the others as they are.
+func RenameFields(mapping map[string]string) OutputTransformer {
+	return func(output map[string]any, claims map[string]any) map[string]any {
+		renamed := make(map[string]any, len(output))
+		for k, v := range output {
+			if to, ok := mapping[k]; ok {
+				k = to
+			}
+			renamed[k] = v
+		}
+		return renamed
+	}
+}
+
+// NormalizeOutputMiddleware gives every output the same envelope. With
+// wrap, all fields but "variant" move into a "data" map:
+//
+//	{"variant": "ok", "user": {...}} → {"variant": "ok", "data": {"user": {...}}}
+//
+// Without wrap, the fields of a "data" map move up one level, the
+// reverse; fields already at the top level win over those in "data".
+// Outputs already in the chosen shape, and outputs with the "error"
+// variant, pass through unchanged. CacheableOutputKey stays at the top
+// level.
+func NormalizeOutputMiddleware(wrap bool) MiddlewareFunc {
+	normalize := outputNormalizer(wrap)
+	return func(next ConceptHandler) ConceptHandler {
+		return HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+			return normalize(callHandler(ctx, next, action, input, storage))
+		})
+	}
+}
+
+// WithOutputNormalization normalizes the output of every completion the
+// server dispatches, as NormalizeOutputMiddleware(wrap) does. It applies
+// to completions rather than wrapping handlers, so streaming
+// (ChunkedAction) and the other optional handler interfaces keep working,
+// and output invariants see the handler's own output.
+func WithOutputNormalization(wrap bool) ServeOption {
+	return func(c *ServerConfig) {
+		c.outputNormalizer = outputNormalizer(wrap)
+	}
+}
+
+// outputNormalizer returns the function NormalizeOutputMiddleware(wrap)
+// applies to each output.
+func outputNormalizer(wrap bool) func(map[string]any) map[string]any {
+	normalize := flattenOutput
+	if wrap {
+		normalize = wrapOutput
+	}
+	return func(output map[string]any) map[string]any {
+		if output == nil || output["variant"] == "error" {
+			return output
+		}
+		return normalize(output)
+	}
+}
+
+// envelopeField reports whether field stays outside the "data" map.
+func envelopeField(field string) bool {
+	return field == "variant" || field == CacheableOutputKey
+}
+
+func wrapOutput(output map[string]any) map[string]any {
+	data := make(map[string]any, len(output))
+	wrapped := map[string]any{"data": data}
+	for k, v := range output {
+		if envelopeField(k) {
+			wrapped[k] = v
+		} else {
+			data[k] = v
+		}
+	}
+	if len(data) == 1 {
+		if inner, ok := data["data"].(map[string]any); ok {
+			wrapped["data"] = inner
+		}
+	}
+	return wrapped
+}
+
+func flattenOutput(output map[string]any) map[string]any {
+	data, ok := output["data"].(map[string]any)
+	if !ok {
+		return output
+	}
+	flat := make(map[string]any, len(output)+len(data))
+	for k, v := range data {
+		flat[k] = v
+	}
+	for k, v := range output {
+		if k != "data" {
+			flat[k] = v
+		}
+	}
+	return flat
+}
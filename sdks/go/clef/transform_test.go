@@ -0,0 +1,102 @@
+package clef
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTransformMiddleware(t *testing.T) {
+	user := HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		if action == "fail" {
+			return map[string]any{"variant": "error", "message": "boom"}
+		}
+		return map[string]any{"variant": "ok", "id": "u1", "name": "Ada", "email": "ada@example.com", "password_hash": "x"}
+	})
+	h := Chain(user, TransformMiddleware(map[string]OutputTransformer{
+		"get": PickFields("id", "name"),
+		"*":   RenameFields(map[string]string{"name": "displayName"}),
+		"whoami": func(output, claims map[string]any) map[string]any {
+			return map[string]any{"variant": "ok", "role": claims["role"]}
+		},
+	}))
+	ctx := ContextWithClaims(context.Background(), map[string]any{"role": "editor"})
+	s := NewInMemoryStorage()
+
+	got := callHandler(ctx, h, "get", nil, s)
+	if want := map[string]any{"variant": "ok", "id": "u1", "name": "Ada"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("get = %v, want %v", got, want)
+	}
+	got = callHandler(ctx, h, "list", nil, s)
+	if got["displayName"] != "Ada" || got["name"] != nil || got["email"] == nil {
+		t.Errorf("list = %v, want name renamed by the * transform", got)
+	}
+	if got = callHandler(ctx, h, "whoami", nil, s); got["role"] != "editor" {
+		t.Errorf("whoami = %v, want the caller's claims", got)
+	}
+	if got = callHandler(ctx, h, "fail", nil, s); got["message"] != "boom" {
+		t.Errorf("error output was transformed: %v", got)
+	}
+}
+
+func TestNormalizeOutputMiddleware(t *testing.T) {
+	h := HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		switch action {
+		case "wrapped":
+			return map[string]any{"variant": "ok", "data": map[string]any{"user": map[string]any{"name": "Alice"}}}
+		case "fail":
+			return map[string]any{"variant": "error", "message": "boom"}
+		}
+		return map[string]any{"variant": "ok", "user": map[string]any{"name": "Alice"}}
+	})
+	ctx, s := context.Background(), NewInMemoryStorage()
+	nested := map[string]any{"variant": "ok", "data": map[string]any{"user": map[string]any{"name": "Alice"}}}
+	flat := map[string]any{"variant": "ok", "user": map[string]any{"name": "Alice"}}
+
+	wrap := Chain(h, NormalizeOutputMiddleware(true))
+	for _, action := range []string{"get", "wrapped"} {
+		if got := callHandler(ctx, wrap, action, nil, s); !reflect.DeepEqual(got, nested) {
+			t.Errorf("wrap %s = %v, want %v", action, got, nested)
+		}
+	}
+	flatten := Chain(h, NormalizeOutputMiddleware(false))
+	for _, action := range []string{"get", "wrapped"} {
+		if got := callHandler(ctx, flatten, action, nil, s); !reflect.DeepEqual(got, flat) {
+			t.Errorf("flatten %s = %v, want %v", action, got, flat)
+		}
+	}
+	if got := callHandler(ctx, wrap, "fail", nil, s); got["message"] != "boom" {
+		t.Errorf("error output was normalized: %v", got)
+	}
+}
+
+func TestWithOutputNormalization(t *testing.T) {
+	resetRegistry()
+	Register("urn:test/User", HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		return map[string]any{"variant": "ok", "user": map[string]any{"name": "Alice"}}
+	}), nil)
+	rec := doRequest(NewHandler(WithOutputNormalization(true)), http.MethodPost, "/invoke", `{"concept":"urn:test/User","action":"get"}`)
+	var c ActionCompletion
+	if err := json.Unmarshal(rec.Body.Bytes(), &c); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := c.Output["data"].(map[string]any)
+	if c.Variant != "ok" || data["user"] == nil || c.Output["user"] != nil {
+		t.Errorf("output = %v, want user nested under data", c.Output)
+	}
+}
+
+func TestWithOutputNormalizationKeepsStreaming(t *testing.T) {
+	resetRegistry()
+	Register("urn:test/Export", &pagesHandler{}, nil)
+	rec := doRequest(NewHandler(WithOutputNormalization(true)), http.MethodPost, "/invoke", `{"concept":"urn:test/Export","action":"export","input":{"pages":2}}`)
+	if ct := rec.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Fatalf("Content-Type = %q, want a stream: %s", ct, rec.Body.String())
+	}
+	if n := strings.Count(rec.Body.String(), "\n"); n != 3 {
+		t.Errorf("got %d lines, want 2 pages and done: %s", n, rec.Body.String())
+	}
+}
@@ -1,13 +1,20 @@
 package clef
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"maps"
 	"net/http"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
+	"google.golang.org/grpc"
 )
 
 // ActionInvocation matches the Clef wire format for an incoming action.
@@ -19,6 +26,20 @@ type ActionInvocation struct {
 	Flow    string         `json:"flow"`
 }
 
+type invocationKey struct{}
+
+// ContextWithInvocation returns a copy of ctx carrying inv. The transport
+// attaches the current invocation before calling a handler.
+func ContextWithInvocation(ctx context.Context, inv ActionInvocation) context.Context {
+	return context.WithValue(ctx, invocationKey{}, inv)
+}
+
+// InvocationFromContext returns the invocation attached to ctx, if any.
+func InvocationFromContext(ctx context.Context) (ActionInvocation, bool) {
+	inv, ok := ctx.Value(invocationKey{}).(ActionInvocation)
+	return inv, ok
+}
+
 // ActionCompletion matches the Clef wire format for an action result.
 type ActionCompletion struct {
 	ID        string         `json:"id"`
@@ -29,99 +50,460 @@ type ActionCompletion struct {
 	Output    map[string]any `json:"output"`
 	Flow      string         `json:"flow"`
 	Timestamp string         `json:"timestamp"`
+	// Partial is set when the handler timed out and Output holds only the
+	// fields it had produced so far.
+	Partial bool `json:"partial,omitempty"`
+	// AliasedFrom names the deprecated action the caller invoked when it
+	// was forwarded to Action by RegisterAlias.
+	AliasedFrom string `json:"aliasedFrom,omitempty"`
+	// CacheableOutput is set when the handler marked its output with
+	// CacheableOutputKey, so identical concurrent invocations may share it.
+	CacheableOutput bool `json:"cacheableOutput,omitempty"`
+}
+
+// completionStatus maps well-known output codes to HTTP statuses.
+var completionStatus = map[string]int{
+	"not_found":         http.StatusNotFound,
+	"unauthorized":      http.StatusUnauthorized,
+	"forbidden":         http.StatusForbidden,
+	"validation_failed": http.StatusUnprocessableEntity,
+	"conflict":          http.StatusConflict,
+	"rate_limited":      http.StatusTooManyRequests,
+	"circuit_open":      http.StatusServiceUnavailable,
+	"overloaded":        http.StatusServiceUnavailable,
+	"disabled":          http.StatusServiceUnavailable,
+
+	"storage_quota_exceeded": http.StatusInsufficientStorage,
+}
+
+// StatusCode returns the HTTP status the transport uses for c. Error
+// completions map their output code to a status, defaulting to 500;
+// quota_exceeded is 429; every other variant is a domain outcome and 200.
+func (c *ActionCompletion) StatusCode() int {
+	switch c.Variant {
+	case "error":
+		code, _ := c.Output["code"].(string)
+		if status, ok := completionStatus[code]; ok {
+			return status
+		}
+		return http.StatusInternalServerError
+	case "quota_exceeded":
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusOK
+	}
 }
 
 // ConceptQuery matches the Clef wire format for a state query.
 type ConceptQuery struct {
-	Concept  string         `json:"concept"`
-	Relation string         `json:"relation"`
-	Args     map[string]any `json:"args"`
+	Concept  string `json:"concept"`
+	Relation string `json:"relation"`
+	// Args filters records by field. A list value matches records whose
+	// field is a list sharing an element with it, or a scalar equal to one
+	// of its elements.
+	Args map[string]any `json:"args"`
 }
 
-func handleInvoke(w http.ResponseWriter, r *http.Request) {
+func (s *server) handleInvoke(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	var inv ActionInvocation
-	if err := json.NewDecoder(r.Body).Decode(&inv); err != nil {
+	dequeue := s.load.enqueue()
+	err := decodeBody(r, &inv)
+	dequeue()
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	s.serveInvocation(w, r, inv)
+}
+
+// writeCompletion writes the response for an invocation: raw bytes for a
+// BinaryOutput, 202 with a poll URL for a pending job, otherwise the
+// completion itself.
+func (s *server) writeCompletion(w http.ResponseWriter, r *http.Request, c *ActionCompletion) {
+	if b, ok := binaryOutput(c); ok {
+		writeBinary(w, c, b)
+		return
+	}
+	if c.Variant == "pending" {
+		if async, ok := startJob(c); ok {
+			s.writeJSONStatus(w, r, http.StatusAccepted, async)
+			return
+		}
+	}
+	s.writeNegotiated(w, r, c.StatusCode(), c)
+}
+
+// dispatch routes inv to its concept, applies the ACL, and runs the
+// handler. It is shared by the HTTP and gRPC transports.
+func (s *server) dispatch(ctx context.Context, inv ActionInvocation) ActionCompletion {
 	if inv.ID == "" {
 		inv.ID = uuid.New().String()
 	}
 	if inv.Flow == "" {
 		inv.Flow = uuid.New().String()
 	}
+	defer s.inflight.track(inv)()
+	if s.config.container != nil {
+		ctx = context.WithValue(ctx, containerKey{}, s.config.container)
+	}
+	if s.config.clock != nil {
+		ctx = context.WithValue(ctx, clockKey{}, s.config.clock)
+	}
+	ctx = context.WithValue(ctx, serverKey{}, s)
 
-	entry, ok := registry[inv.Concept]
+	ctx = s.withTenant(ctx, inv, nil)
+	entry, ok, scoped := s.lookupTenant(ctx, inv)
+	if !ok && !scoped {
+		entry, ok = s.lookup(inv)
+	}
 	if !ok {
-		writeJSON(w, ActionCompletion{
-			ID:        inv.ID,
-			Concept:   inv.Concept,
-			Action:    inv.Action,
-			Input:     inv.Input,
-			Variant:   "error",
-			Output:    map[string]any{"variant": "error", "message": fmt.Sprintf("unknown concept: %s", inv.Concept)},
-			Flow:      inv.Flow,
-			Timestamp: time.Now().UTC().Format(time.RFC3339),
-		})
-		return
+		return errorCompletion(inv, map[string]any{"variant": "error", "code": "not_found", "message": fmt.Sprintf("unknown concept: %s", inv.Concept)})
 	}
+	ctx, inv, aliasedFrom := entry.resolveAlias(ctx, inv)
+	ctx = s.withFlowStorage(ctx, inv, entry)
 
-	result := entry.handler.Handle(inv.Action, inv.Input, entry.storage)
-	variant, _ := result["variant"].(string)
-	if variant == "" {
-		variant = "ok"
+	var c ActionCompletion
+	if s.config.acl != nil && !s.config.acl(ctx, inv.Concept, inv.Action, ClaimsFromContext(ctx)) {
+		c = errorCompletion(inv, map[string]any{"variant": "error", "code": "forbidden", "message": "forbidden"})
+	} else {
+		start := time.Now()
+		c = invoke(ctx, entry, inv)
+		recordInvocation(inv.Concept, inv.Action, time.Since(start), c.Variant == "error")
+	}
+	c.AliasedFrom = aliasedFrom
+	if s.config.outputNormalizer != nil {
+		c.Output = s.config.outputNormalizer(c.Output)
+	}
+	if s.history != nil {
+		tenant, _ := TenantFromContext(ctx)
+		s.history.Record(tenant, c)
+	}
+	return c
+}
+
+// dispatchHTTP is dispatch for HTTP requests: it enriches the input,
+// extracts the tenant from r and updates the load tracker, if any.
+func (s *server) dispatchHTTP(r *http.Request, inv ActionInvocation) ActionCompletion {
+	s.enrich(r, &inv)
+	ctx := s.withTenant(r.Context(), inv, r)
+	if s.load == nil {
+		return s.dispatch(ctx, inv)
 	}
+	s.load.inFlight.Add(1)
+	defer s.load.inFlight.Add(-1)
+	start := time.Now()
+	c := s.dispatch(ctx, inv)
+	s.load.record(time.Since(start), c.Variant == "error")
+	return c
+}
 
-	writeJSON(w, ActionCompletion{
+// errorCompletion builds a completion for an invocation the transport
+// rejected before reaching the handler.
+func errorCompletion(inv ActionInvocation, output map[string]any) ActionCompletion {
+	return ActionCompletion{
 		ID:        inv.ID,
 		Concept:   inv.Concept,
 		Action:    inv.Action,
 		Input:     inv.Input,
-		Variant:   variant,
-		Output:    result,
+		Variant:   "error",
+		Output:    output,
 		Flow:      inv.Flow,
 		Timestamp: time.Now().UTC().Format(time.RFC3339),
-	})
+	}
 }
 
-func handleQuery(w http.ResponseWriter, r *http.Request) {
+// invoke runs one invocation against a registry entry and builds the
+// resulting completion.
+func invoke(ctx context.Context, entry registryEntry, inv ActionInvocation) ActionCompletion {
+	ctx = ContextWithInvocation(ctx, inv)
+	// The response stream belongs to this invocation only; nested calls
+	// made by its handler must not write to it.
+	cw, _ := ctx.Value(chunkWriterKey{}).(*chunkWriter)
+	if cw != nil {
+		ctx = context.WithValue(ctx, chunkWriterKey{}, (*chunkWriter)(nil))
+	}
+	// Storage errors of nested invocations fail those, not this one.
+	errs := &storageErrors{}
+	ctx = context.WithValue(ctx, storageErrorsKey{}, errs)
+	if q := entry.options.quota; q != nil {
+		if retryAfter, ok := q.allow(ctx, inv.Action, entry.storage); !ok {
+			c := errorCompletion(inv, map[string]any{
+				"variant":    "quota_exceeded",
+				"retryAfter": retryAfter.Round(time.Millisecond).String(),
+			})
+			c.Variant = "quota_exceeded"
+			return c
+		}
+	}
+	if err := entry.options.checkPreconditions(inv.Action, entry.storage); err != nil {
+		c := errorCompletion(inv, map[string]any{"variant": "precondition_failed", "message": err.Error()})
+		c.Variant = "precondition_failed"
+		return c
+	}
+
+	if h, ok := entry.handler.(ChunkedAction); ok && cw != nil {
+		return invokeChunked(ctx, entry, h, inv, cw)
+	}
+
+	ctx, commits := withPendingCommits(ctx)
+	result, partial := runWithTimeout(ctx, entry, inv.Action, inv.Input)
+	err := entry.options.checkInvariants(inv.Action, result)
+	reported := errs.count()
+	commits.finish(err == nil)
+	if err != nil {
+		result = map[string]any{"variant": "invariant_violated", "message": err.Error()}
+	} else if err := errs.since(reported); err != nil {
+		// A deferred transaction failed to commit.
+		result = storageErrorResult(err)
+	}
+	variant, _ := result["variant"].(string)
+	if variant == "" {
+		variant = "ok"
+	}
+	cacheable := result[CacheableOutputKey] == true
+	if _, ok := result[CacheableOutputKey]; ok {
+		result = maps.Clone(result)
+		delete(result, CacheableOutputKey)
+	}
+
+	return ActionCompletion{
+		ID:              inv.ID,
+		Concept:         inv.Concept,
+		Action:          inv.Action,
+		Input:           inv.Input,
+		Variant:         variant,
+		Output:          result,
+		Flow:            inv.Flow,
+		Timestamp:       time.Now().UTC().Format(time.RFC3339),
+		Partial:         partial,
+		CacheableOutput: cacheable,
+	}
+}
+
+func (s *server) handleQuery(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	var q ConceptQuery
-	if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+	if err := decodeBody(r, &q); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	s.writeNegotiated(w, r, http.StatusOK, s.query(q))
+}
+
+// query runs q against its concept's storage, or answers it from the
+// registration for the pseudo-relations SchemaRelation, MetricsRelation
+// and, when the handler holds a DynamicConfig, ConfigRelation. Unknown
+// concepts have no records.
+func (s *server) query(q ConceptQuery) []map[string]any {
 	entry, ok := registry[q.Concept]
 	if !ok {
-		writeJSON(w, []map[string]any{})
-		return
+		return []map[string]any{}
 	}
-
-	results := entry.storage.Find(q.Relation, q.Args)
+	if results, ok := pseudoRelation(q, entry); ok {
+		return results
+	}
+	results := s.wrapStorage(q.Concept, entry).storage.Find(q.Relation, q.Args)
 	if results == nil {
 		results = []map[string]any{}
 	}
-	writeJSON(w, results)
+	return results
 }
 
-func handleHealth(w http.ResponseWriter, r *http.Request) {
-	writeJSON(w, map[string]any{"healthy": true, "latencyMs": 0})
+func (s *server) writeJSON(w http.ResponseWriter, r *http.Request, data any) {
+	s.writeJSONStatus(w, r, http.StatusOK, data)
 }
 
-func writeJSON(w http.ResponseWriter, data any) {
+func (s *server) writeJSONStatus(w http.ResponseWriter, r *http.Request, status int, data any) {
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(data)
+	if !s.pretty(r) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(data)
+		return
+	}
+	body, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(status)
+	w.Write(append(body, '\n'))
+}
+
+// pretty reports whether JSON responses to r should be indented, either
+// because the server enables it or the request asks with ?pretty=1.
+func (s *server) pretty(r *http.Request) bool {
+	return s.config.EnablePrettyPrint || r.URL.Query().Get("pretty") == "1"
+}
+
+// ServerConfig holds transport settings. It is populated by the
+// ServeOptions passed to Serve or NewHandler.
+type ServerConfig struct {
+	// EnablePrettyPrint indents every JSON response. It defaults to true
+	// when the COPF_PRETTY_PRINT environment variable is "1".
+	EnablePrettyPrint bool
+
+	healthChecks []namedHealthCheck
+	acl          ACLPolicy
+	grpcClaims   GRPCClaimsExtractor
+	grpcOptions  []grpc.ServerOption
+	cors         *CORSOptions
+	flowRouter   FlowRouter
+	liveConfig   *LiveConfig
+	container    *Container
+	storageQuota int64
+
+	namespaceIsolation  bool
+	pluginDirs          []string
+	benchToken          string
+	configToken         string
+	liveConfigToken     string
+	jobsToken           string
+	consistencyToken    string
+	enrichers           []InputEnricher
+	pprofToken          string
+	loadMetrics         bool
+	requestInspector    bool
+	historySize         int
+	historyToken        string
+	outputNormalizer    func(map[string]any) map[string]any
+	flowStorageTTL      time.Duration
+	tenantExtractor     TenantExtractor
+	pollTimeout         time.Duration
+	degradation         []degradationConfig
+	degradationInterval time.Duration
+	clock               Clock
+}
+
+// ServeOption configures the HTTP transport.
+type ServeOption func(*ServerConfig)
+
+// WithPrettyPrint indents all JSON responses, for readable output during
+// development.
+func WithPrettyPrint() ServeOption {
+	return func(c *ServerConfig) {
+		c.EnablePrettyPrint = true
+	}
+}
+
+// serverKey holds the dispatching *server in an invocation's context, so
+// InvokeLocal runs nested calls under the same configuration.
+type serverKey struct{}
+
+// server serves the registered concepts under a fixed configuration.
+type server struct {
+	config ServerConfig
+
+	// quotas holds each concept's QuotaStorage under WithStorageQuota,
+	// so usage is tracked across invocations.
+	quotasMu sync.Mutex
+	quotas   map[string]quotaEntry
+
+	// load is non-nil under WithLoadMetrics.
+	load *loadTracker
+	// inflight is non-nil under WithRequestInspector.
+	inflight *inflightTracker
+	// history is non-nil under WithInvocationHistory.
+	history *InvocationHistory
+	// degradation is non-nil under WithDegradationPolicy.
+	degradation *degradationMonitor
+	// flowStore backs FlowStorage under WithFlowStorage.
+	flowStore   *InMemoryStorage
+	flowSweptAt atomic.Int64
+}
+
+// wrapStorage applies the server's storage decorators to the storage of
+// entry: the storage quota, tracked under key. Namespace isolation is
+// applied to the registered storage itself, see WithNamespaceIsolation.
+func (s *server) wrapStorage(key string, entry registryEntry) registryEntry {
+	if s.config.storageQuota > 0 {
+		entry.storage = s.quotaStorage(key, entry.storage, entry.storage)
+	}
+	return entry
+}
+
+func newServer(opts []ServeOption) *server {
+	s := &server{}
+	s.config.EnablePrettyPrint = os.Getenv("COPF_PRETTY_PRINT") == "1"
+	for _, opt := range opts {
+		opt(&s.config)
+	}
+	if s.config.namespaceIsolation {
+		enableNamespaceIsolation()
+	}
+	for _, dir := range s.config.pluginDirs {
+		loadPluginDir(dir)
+	}
+	if s.config.loadMetrics {
+		s.load = &loadTracker{}
+	}
+	if s.config.requestInspector {
+		s.inflight = &inflightTracker{}
+	}
+	if s.config.historySize > 0 && s.config.historyToken != "" {
+		s.history = NewInvocationHistory(s.config.historySize)
+	}
+	if len(s.config.degradation) > 0 {
+		s.degradation = newDegradationMonitor(s.config.degradation)
+		interval := s.config.degradationInterval
+		if interval <= 0 {
+			interval = DefaultDegradationInterval
+		}
+		// The monitor's goroutine stops once the server is unreachable.
+		runtime.AddCleanup(s, func(stop func()) { stop() }, s.degradation.start(interval))
+	}
+	if s.config.flowStorageTTL > 0 {
+		s.flowStore = NewInMemoryStorage()
+		s.flowSweptAt.Store(s.clock().Now().UnixNano())
+	}
+	if s.config.container != nil {
+		injectContainer(s.config.container)
+	}
+	return s
+}
+
+// NewHandler builds the HTTP handler for all registered concepts without
+// starting a listener, for embedding in an existing server or testing.
+func NewHandler(opts ...ServeOption) http.Handler {
+	s := newServer(opts)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/invoke", s.handleInvoke)
+	mux.HandleFunc("/query", s.handleQuery)
+	mux.HandleFunc("/health", s.handleHealth)
+	mux.HandleFunc("/readiness", s.handleReadiness)
+	mux.HandleFunc("/admin/check", s.handleAdminCheck)
+	mux.HandleFunc("/error-catalog", s.handleErrorCatalog)
+	mux.HandleFunc("/admin/config", s.handleAdminConfig)
+	mux.HandleFunc("/openapi.json", s.handleOpenAPI)
+	mux.HandleFunc("/invoke/{concept}/{action}", s.handleInvokeAction)
+	mux.HandleFunc("/jobs/{jobID}", s.handleJob)
+	mux.HandleFunc("/bench/{concept}/{action}", s.handleBench)
+	mux.HandleFunc("/config/{concept}", s.handleConfig)
+	mux.HandleFunc("/debug/pprof/", s.handlePprof)
+	mux.HandleFunc("/debug/concepts/profile", s.handleConceptProfile)
+	mux.HandleFunc("/concepts/graph", s.handleGraph)
+	mux.HandleFunc("/concepts/{concept}/history", s.handleHistory)
+	mux.HandleFunc("/concepts/{concept}/replay/{id}", s.handleReplay)
+	mux.HandleFunc("/load", s.handleLoad)
+	mux.HandleFunc("/inspect/inflight", s.handleInflight)
+	mux.HandleFunc("/poll", s.handleLongPoll)
+
+	var h http.Handler = mux
+	if s.config.cors != nil {
+		h = newCORSHandler(*s.config.cors, h)
+	}
+	return h
 }
 
 // Serve starts the HTTP transport server on the given address.
@@ -129,14 +511,29 @@ func writeJSON(w http.ResponseWriter, data any) {
 //
 // Routes:
 //
-//	POST /invoke → ActionInvocation handling
-//	POST /query  → State queries
+//	POST /invoke → ActionInvocation handling (JSON or protobuf)
+//	POST /query  → State queries (JSON or protobuf)
 //	GET  /health → Health check
-func Serve(addr string) {
-	mux := http.NewServeMux()
-	mux.HandleFunc("/invoke", handleInvoke)
-	mux.HandleFunc("/query", handleQuery)
-	mux.HandleFunc("/health", handleHealth)
+//	GET  /readiness → 503 until every Warmer has warmed up
+//	POST /admin/check → Storage consistency check (with WithConsistencyCheck)
+//	GET  /error-catalog → Registered error codes
+//	GET/POST /admin/config → Live config (with WithLiveConfig)
+//	GET  /openapi.json → OpenAPI spec of Introspectable concepts
+//	POST /invoke/{concept}/{action} → Invoke one action; body is its input
+//	GET  /jobs/{jobID} → Result of an async ("pending") action, or status of a scheduled one (with WithJobEndpoint)
+//	DELETE /jobs/{jobID} → Cancel a job from ScheduleInvoke (with WithJobEndpoint)
+//	POST /bench/{concept}/{action} → Handler timings (with WithBenchEndpoint)
+//	POST /config/{concept} → Update a DynamicConfig record (with WithConfigEndpoint)
+//	GET  /debug/pprof/ → net/http/pprof profiles (with WithPprof)
+//	GET  /debug/concepts/profile → CPU profile of one action (with WithPprof)
+//	GET  /concepts/graph → Concept dependencies declared with DependsOn
+//	GET  /concepts/{concept}/history → Recent completions (with WithInvocationHistory)
+//	POST /concepts/{concept}/replay/{id} → Rerun a recorded invocation (with WithInvocationHistory)
+//	GET  /load → LoadMetrics (with WithLoadMetrics)
+//	GET  /inspect/inflight → Invocations being dispatched (with WithRequestInspector)
+//	POST /poll → Long-poll a relation for changes after last_seq
+func Serve(addr string, opts ...ServeOption) {
+	handler := NewHandler(opts...)
 
 	fmt.Printf("Clef Go SDK v0.1.0\n")
 	fmt.Printf("Serving %d concept(s) on %s\n", len(registry), addr)
@@ -144,5 +541,5 @@ func Serve(addr string) {
 		fmt.Printf("  - %s\n", uri)
 	}
 
-	log.Fatal(http.ListenAndServe(addr, mux))
+	log.Fatal(http.ListenAndServe(addr, handler))
 }
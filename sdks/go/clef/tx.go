@@ -0,0 +1,345 @@
+package clef
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// StorageTx buffers writes to a storage until Commit, so a handler that
+// fails halfway leaves no partial state behind. Reads see the
+// transaction's own writes. Commit applies the buffered writes with one
+// BulkDelete and one BulkPut per relation; it is atomic per relation on
+// storages whose bulk operations are, such as InMemoryStorage and
+// RedisStorage, but not across relations.
+//
+// Find, FindSorted and Range merge buffered writes only when the inner
+// storage is Enumerable; otherwise they return the inner results plus
+// matching buffered puts, and may include entries the transaction
+// deleted or overwrote.
+type StorageTx struct {
+	inner Storage
+
+	mu sync.Mutex
+	// writes holds buffered writes by relation and key; a nil value is a
+	// delete.
+	writes map[string]map[string]map[string]any
+	// order records keys in first-write order, for stable Find results.
+	order map[string][]string
+}
+
+// BeginTx starts a transaction over inner.
+func BeginTx(inner Storage) *StorageTx {
+	return &StorageTx{inner: inner, writes: make(map[string]map[string]map[string]any), order: make(map[string][]string)}
+}
+
+// buffer records a write. The caller holds mu.
+func (tx *StorageTx) buffer(relation, key string, value map[string]any) {
+	rel := tx.writes[relation]
+	if rel == nil {
+		rel = make(map[string]map[string]any)
+		tx.writes[relation] = rel
+	}
+	if _, seen := rel[key]; !seen {
+		tx.order[relation] = append(tx.order[relation], key)
+	}
+	rel[key] = value
+}
+
+// get reads through the buffer. The caller holds mu.
+func (tx *StorageTx) get(relation, key string) (map[string]any, bool) {
+	if value, ok := tx.writes[relation][key]; ok {
+		return value, value != nil
+	}
+	return tx.inner.Get(relation, key)
+}
+
+func (tx *StorageTx) Get(relation, key string) (map[string]any, bool) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	return tx.get(relation, key)
+}
+
+func (tx *StorageTx) Put(relation, key string, value map[string]any) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.buffer(relation, key, value)
+}
+
+func (tx *StorageTx) Delete(relation, key string) bool {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	_, existed := tx.get(relation, key)
+	if existed {
+		tx.buffer(relation, key, nil)
+	}
+	return existed
+}
+
+func (tx *StorageTx) BulkPut(relation string, entries map[string]map[string]any) int {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		tx.buffer(relation, key, entries[key])
+	}
+	return len(entries)
+}
+
+func (tx *StorageTx) BulkDelete(relation string, keys []string) int {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	n := 0
+	for _, key := range keys {
+		if _, existed := tx.get(relation, key); existed {
+			tx.buffer(relation, key, nil)
+			n++
+		}
+	}
+	return n
+}
+
+// CompareAndSwap compares against the transaction's view. The swap is
+// atomic within the transaction only; Commit does not recheck it.
+func (tx *StorageTx) CompareAndSwap(relation, key string, expected, replacement map[string]any, compareFields []string) (bool, map[string]any) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	current, found := tx.get(relation, key)
+	if !casMatches(current, found, expected, compareFields) {
+		return false, current
+	}
+	tx.buffer(relation, key, replacement)
+	return true, replacement
+}
+
+func (tx *StorageTx) Find(relation string, args map[string]any) []map[string]any {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	results := []map[string]any{}
+	if enum, ok := tx.inner.(Enumerable); ok {
+		for _, key := range enum.Keys(relation) {
+			if _, buffered := tx.writes[relation][key]; buffered {
+				continue
+			}
+			if value, ok := tx.inner.Get(relation, key); ok && matchesArgs(value, args) {
+				results = append(results, value)
+			}
+		}
+	} else {
+		results = append(results, tx.inner.Find(relation, args)...)
+	}
+	for _, key := range tx.order[relation] {
+		if value := tx.writes[relation][key]; value != nil && matchesArgs(value, args) {
+			results = append(results, value)
+		}
+	}
+	return results
+}
+
+func (tx *StorageTx) FindSorted(relation string, args map[string]any, sortField string, ascending bool) []map[string]any {
+	results := tx.Find(relation, args)
+	sortRecords(results, sortField, ascending)
+	return results
+}
+
+func (tx *StorageTx) Range(relation, startKey, endKey string, inclusive bool) []map[string]any {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	var buffered []string
+	for _, key := range tx.order[relation] {
+		if keyInRange(key, startKey, endKey, inclusive) {
+			buffered = append(buffered, key)
+		}
+	}
+	sort.Strings(buffered)
+
+	enum, ok := tx.inner.(Enumerable)
+	if !ok {
+		results := tx.inner.Range(relation, startKey, endKey, inclusive)
+		for _, key := range buffered {
+			if value := tx.writes[relation][key]; value != nil {
+				results = append(results, value)
+			}
+		}
+		return results
+	}
+	keys := buffered
+	for _, key := range enum.Keys(relation) {
+		if _, isBuffered := tx.writes[relation][key]; !isBuffered && keyInRange(key, startKey, endKey, inclusive) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	results := []map[string]any{}
+	for _, key := range keys {
+		if value, ok := tx.get(relation, key); ok {
+			results = append(results, value)
+		}
+	}
+	return results
+}
+
+// batchCommitter is implemented by storages that apply a transaction's
+// writes, by relation and key with nil for a delete, all or nothing.
+type batchCommitter interface {
+	commitBatch(writes map[string]map[string]map[string]any)
+}
+
+// Commit applies the buffered writes to the inner storage and empties
+// the buffer. A QuotaStorage applies all of them or, if they do not fit,
+// none.
+func (tx *StorageTx) Commit() {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if b, ok := tx.inner.(batchCommitter); ok {
+		b.commitBatch(tx.writes)
+	} else {
+		applyWrites(tx.inner, tx.writes)
+	}
+	tx.reset()
+}
+
+// applyWrites applies writes, by relation and key with nil for a delete,
+// to storage with one BulkDelete and one BulkPut per relation.
+func applyWrites(storage Storage, writes map[string]map[string]map[string]any) {
+	relations := make([]string, 0, len(writes))
+	for relation := range writes {
+		relations = append(relations, relation)
+	}
+	sort.Strings(relations)
+	for _, relation := range relations {
+		var deletes []string
+		puts := make(map[string]map[string]any)
+		for key, value := range writes[relation] {
+			if value == nil {
+				deletes = append(deletes, key)
+			} else {
+				puts[key] = value
+			}
+		}
+		if len(deletes) > 0 {
+			storage.BulkDelete(relation, deletes)
+		}
+		if len(puts) > 0 {
+			storage.BulkPut(relation, puts)
+		}
+	}
+}
+
+// Rollback discards the buffered writes.
+func (tx *StorageTx) Rollback() {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.reset()
+}
+
+func (tx *StorageTx) reset() {
+	tx.writes = make(map[string]map[string]map[string]any)
+	tx.order = make(map[string][]string)
+}
+
+// failed reports whether a handler result counts as a failure for
+// TransactionMiddleware and RetryWithRollbackMiddleware.
+func failed(output map[string]any) bool {
+	return output["variant"] == "error"
+}
+
+// pendingCommits holds the transactions of an invocation that are
+// committed only once invoke has checked its output invariants.
+type pendingCommits struct {
+	mu   sync.Mutex
+	txs  []*StorageTx
+	done bool
+}
+
+type pendingCommitsKey struct{}
+
+// withPendingCommits makes commitAccepted defer commits in ctx to the
+// returned pendingCommits.
+func withPendingCommits(ctx context.Context) (context.Context, *pendingCommits) {
+	p := &pendingCommits{}
+	return context.WithValue(ctx, pendingCommitsKey{}, p), p
+}
+
+// commitAccepted commits tx, or, inside invoke, leaves it for invoke to
+// commit once the output has passed its invariants.
+func commitAccepted(ctx context.Context, tx *StorageTx) {
+	p, ok := ctx.Value(pendingCommitsKey{}).(*pendingCommits)
+	if !ok {
+		tx.Commit()
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.done {
+		// The invocation already completed, e.g. by timing out, without
+		// this transaction's result.
+		tx.Rollback()
+		return
+	}
+	p.txs = append(p.txs, tx)
+}
+
+// finish commits or rolls back the deferred transactions. Transactions
+// deferred later are rolled back.
+func (p *pendingCommits) finish(commit bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done = true
+	for _, tx := range p.txs {
+		if commit {
+			tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+	}
+	p.txs = nil
+}
+
+// TransactionMiddleware runs each invocation in a StorageTx, committing
+// it unless the handler returns the "error" variant or panics. Under the
+// transport the commit waits until the output has passed the concept's
+// OutputInvariants, so an "invariant_violated" call writes nothing, and a
+// storage error reported during the commit, such as a write over
+// WithStorageQuota, fails the call.
+func TransactionMiddleware() MiddlewareFunc {
+	return RetryWithRollbackMiddleware(0)
+}
+
+// RetryWithRollbackMiddleware runs each invocation in a StorageTx. When
+// the handler returns the "error" variant it rolls the transaction back
+// and retries on a fresh one, up to maxRetries more times, then returns
+// the last error. A successful attempt is committed, so the storage only
+// ever sees the writes of that attempt; as with TransactionMiddleware,
+// the transport commits it only if the output passes the concept's
+// OutputInvariants. A panic is not retried and commits nothing.
+//
+// Example:
+//
+//	clef.MustRegister("urn:app/Transfer", clef.Chain(&TransferHandler{}, clef.RetryWithRollbackMiddleware(3)), nil)
+func RetryWithRollbackMiddleware(maxRetries int) MiddlewareFunc {
+	return func(next ConceptHandler) ConceptHandler {
+		return HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+			var output map[string]any
+			for attempt := 0; attempt <= maxRetries; attempt++ {
+				tx := BeginTx(storage)
+				output = callHandler(ctx, next, action, input, tx)
+				if !failed(output) {
+					commitAccepted(ctx, tx)
+					return output
+				}
+				tx.Rollback()
+				if ctx.Err() != nil {
+					break
+				}
+			}
+			return output
+		})
+	}
+}
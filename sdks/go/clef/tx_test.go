@@ -0,0 +1,157 @@
+package clef
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestStorageTxCommitAndRollback(t *testing.T) {
+	inner := NewInMemoryStorage()
+	inner.Put("items", "old", map[string]any{"n": 0})
+
+	tx := BeginTx(inner)
+	tx.Put("items", "a", map[string]any{"n": 1})
+	tx.Delete("items", "old")
+	if _, ok := tx.Get("items", "a"); !ok {
+		t.Fatal("tx should see its own put")
+	}
+	if _, ok := tx.Get("items", "old"); ok {
+		t.Fatal("tx should see its own delete")
+	}
+	if got := len(tx.Find("items", nil)); got != 1 {
+		t.Fatalf("tx Find = %d records, want 1", got)
+	}
+	if got := tx.Range("items", "", "", true); len(got) != 1 || got[0]["n"] != 1 {
+		t.Fatalf("tx Range = %v, want only the buffered put", got)
+	}
+	if _, ok := inner.Get("items", "a"); ok {
+		t.Fatal("write visible before commit")
+	}
+
+	tx.Rollback()
+	if _, ok := tx.Get("items", "old"); !ok {
+		t.Fatal("rollback should discard the delete")
+	}
+
+	tx.Put("items", "b", map[string]any{"n": 2})
+	tx.Delete("items", "old")
+	tx.Commit()
+	if _, ok := inner.Get("items", "b"); !ok {
+		t.Fatal("commit did not apply put")
+	}
+	if _, ok := inner.Get("items", "old"); ok {
+		t.Fatal("commit did not apply delete")
+	}
+}
+
+// flakyTransfer writes a debit, then fails before the credit until its
+// third attempt.
+type flakyTransfer struct {
+	attempts int
+	inner    Storage
+	leaked   bool
+}
+
+func (h *flakyTransfer) Handle(action string, input map[string]any, storage Storage) map[string]any {
+	h.attempts++
+	storage.Put("ledger", "debit", map[string]any{"amount": 10, "attempt": h.attempts})
+	if h.attempts < 3 {
+		if _, ok := h.inner.Get("ledger", "debit"); ok {
+			h.leaked = true
+		}
+		return map[string]any{"variant": "error", "message": "credit failed"}
+	}
+	storage.Put("ledger", "credit", map[string]any{"amount": 10, "attempt": h.attempts})
+	return map[string]any{"variant": "ok"}
+}
+
+func TestRetryWithRollbackMiddleware(t *testing.T) {
+	inner := NewInMemoryStorage()
+	h := &flakyTransfer{inner: inner}
+	out := callHandler(context.Background(), Chain(h, RetryWithRollbackMiddleware(2)), "transfer", nil, inner)
+	if out["variant"] != "ok" || h.attempts != 3 {
+		t.Fatalf("output = %v after %d attempts", out, h.attempts)
+	}
+	if h.leaked {
+		t.Error("failed attempt's writes were visible in storage")
+	}
+	records := inner.Find("ledger", nil)
+	if len(records) != 2 {
+		t.Fatalf("ledger = %v", records)
+	}
+	for _, r := range records {
+		if r["attempt"] != 3 {
+			t.Errorf("record from attempt %v survived", r["attempt"])
+		}
+	}
+}
+
+func TestRetryWithRollbackGivesUp(t *testing.T) {
+	inner := NewInMemoryStorage()
+	h := &flakyTransfer{inner: inner}
+	out := callHandler(context.Background(), Chain(h, RetryWithRollbackMiddleware(1)), "transfer", nil, inner)
+	if out["variant"] != "error" || h.attempts != 2 {
+		t.Fatalf("output = %v after %d attempts", out, h.attempts)
+	}
+	if got := len(inner.Find("ledger", nil)); got != 0 {
+		t.Fatalf("storage has %d records after final failure", got)
+	}
+}
+
+func TestTransactionMiddlewareRollsBackInvariantViolation(t *testing.T) {
+	resetRegistry()
+	storage := NewInMemoryStorage()
+	storage.Put("accounts", "a1", map[string]any{"balance": 100})
+	withdraw := HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		acct, _ := storage.Get("accounts", "a1")
+		balance := acct["balance"].(int) - int(input["amount"].(float64))
+		storage.Put("accounts", "a1", map[string]any{"balance": balance})
+		return map[string]any{"variant": "ok", "balance": balance}
+	})
+	var opts ConceptOptions
+	opts.OutputInvariant("withdraw", func(out map[string]any) error {
+		if bal, _ := out["balance"].(int); bal < 0 {
+			return errors.New("balance must never be negative")
+		}
+		return nil
+	})
+	RegisterWithOptions("urn:test/Account", Chain(withdraw, TransactionMiddleware()), storage, opts)
+
+	c := invokeRecorder(t, `{"concept":"urn:test/Account","action":"withdraw","input":{"amount":150}}`)
+	if c.Variant != "invariant_violated" {
+		t.Fatalf("variant = %s", c.Variant)
+	}
+	if acct, _ := storage.Get("accounts", "a1"); acct["balance"] != 100 {
+		t.Fatalf("violating call persisted its writes: %v", acct)
+	}
+
+	if c := invokeRecorder(t, `{"concept":"urn:test/Account","action":"withdraw","input":{"amount":30}}`); c.Variant != "ok" {
+		t.Fatalf("variant = %s", c.Variant)
+	}
+	if acct, _ := storage.Get("accounts", "a1"); acct["balance"] != 70 {
+		t.Fatalf("valid call was not committed: %v", acct)
+	}
+}
+
+func TestTransactionMiddlewareReportsCommitOverQuota(t *testing.T) {
+	resetRegistry()
+	storage := NewInMemoryStorage()
+	write := HandlerFunc(func(ctx context.Context, action string, input map[string]any, storage Storage) map[string]any {
+		storage.Put("small", "s", map[string]any{"n": 1})
+		storage.Put("large", "l", map[string]any{"blob": strings.Repeat("x", 1024)})
+		return map[string]any{"variant": "ok"}
+	})
+	Register("urn:test/Blob", Chain(write, TransactionMiddleware()), storage)
+	h := NewHandler(WithStorageQuota(100))
+
+	rec := doRequest(h, "POST", "/invoke", `{"concept":"urn:test/Blob","action":"write"}`)
+	if rec.Code == http.StatusOK || !strings.Contains(rec.Body.String(), "storage_quota_exceeded") {
+		t.Fatalf("commit over quota: %d %s", rec.Code, rec.Body)
+	}
+	if _, ok := storage.Get("small", "s"); ok {
+		t.Error("commit partly applied: the relation that fits was written")
+	}
+}
@@ -0,0 +1,50 @@
+package clef
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Encode converts v, typically a struct describing an action's input or
+// output, into the map form handlers exchange, via a JSON round trip.
+// Numbers therefore come back as float64.
+func Encode(v any) (map[string]any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("clef: encode %T: not an object: %w", v, err)
+	}
+	return m, nil
+}
+
+// Decode converts a handler input or output map into T via a JSON round
+// trip. Keys without a matching field are ignored; fields without a key
+// keep their zero value.
+func Decode[T any](m map[string]any) (T, error) {
+	var v T
+	data, err := json.Marshal(m)
+	if err != nil {
+		return v, err
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return v, fmt.Errorf("clef: decode %T: %w", v, err)
+	}
+	return v, nil
+}
+
+// MustDecode is Decode that panics on error, for inputs already checked
+// by schema validation.
+//
+// Example:
+//
+//	in := clef.MustDecode[CreateArticleInput](input)
+func MustDecode[T any](m map[string]any) T {
+	v, err := Decode[T](m)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
@@ -0,0 +1,61 @@
+package clef
+
+import "testing"
+
+type typedInput struct {
+	Title   string   `json:"title"`
+	Body    string   `json:"body,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+	Count   *int     `json:"count,omitempty"`
+	Private bool     `json:"private"`
+}
+
+func TestEncodeDecodeOptionalFields(t *testing.T) {
+	n := 3
+	full := typedInput{Title: "t", Body: "b", Tags: []string{"x"}, Count: &n, Private: true}
+	m, err := Encode(full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Decode[typedInput](m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "t" || got.Body != "b" || len(got.Tags) != 1 || got.Count == nil || *got.Count != 3 || !got.Private {
+		t.Fatalf("round trip = %+v", got)
+	}
+
+	m, err = Encode(typedInput{Title: "t"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m["body"]; ok {
+		t.Fatalf("omitted field encoded: %v", m)
+	}
+	got, err = Decode[typedInput](m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "t" || got.Body != "" || got.Tags != nil || got.Count != nil {
+		t.Fatalf("round trip = %+v", got)
+	}
+}
+
+func TestDecodeIgnoresUnknownKeys(t *testing.T) {
+	got, err := Decode[typedInput](map[string]any{"title": "t", "extra": 1, "nested": map[string]any{"a": true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "t" {
+		t.Fatalf("title = %q", got.Title)
+	}
+}
+
+func TestMustDecodePanicsOnMismatch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic")
+		}
+	}()
+	MustDecode[typedInput](map[string]any{"title": 5})
+}
@@ -0,0 +1,40 @@
+package clef
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// conceptURIPattern matches urn:<namespace>/<ConceptName>, with an
+// optional #<suffix> for the variants FlowRouter selects between.
+var conceptURIPattern = regexp.MustCompile(`^urn:([A-Za-z0-9][A-Za-z0-9.-]*)/([A-Za-z][A-Za-z0-9_-]*)(#[A-Za-z0-9_.-]+)?$`)
+
+// ValidateURIFormat reports whether uri has the form
+// urn:<namespace>/<ConceptName>, as Register requires. The namespace is
+// letters, digits, dots and hyphens; the concept name starts with a
+// letter and may contain digits, underscores and hyphens. A #<suffix>
+// may follow, as used with WithFlowRouter.
+func ValidateURIFormat(uri string) error {
+	if !conceptURIPattern.MatchString(uri) {
+		return fmt.Errorf("clef: invalid concept URI %q: want urn:<namespace>/<ConceptName>", uri)
+	}
+	return nil
+}
+
+// NormalizeURI lowercases the namespace of uri and PascalCases its
+// concept name, so that differently spelled URIs for the same concept
+// agree. The suffix, if any, is kept as is.
+//
+//	clef.NormalizeURI("urn:MyApp/rate_limiter") // "urn:myapp/RateLimiter"
+func NormalizeURI(uri string) (string, error) {
+	m := conceptURIPattern.FindStringSubmatch(uri)
+	if m == nil {
+		return "", ValidateURIFormat(uri)
+	}
+	var name strings.Builder
+	for _, part := range strings.FieldsFunc(m[2], func(r rune) bool { return r == '_' || r == '-' }) {
+		name.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return "urn:" + strings.ToLower(m[1]) + "/" + name.String() + m[3], nil
+}
@@ -0,0 +1,160 @@
+package clef
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Warmer is an optional interface for handlers with an expensive first
+// call, such as ones that start external processes or load models.
+// Register runs WarmUp in the background; GET /readiness reports 503
+// until every WarmUp has finished.
+type Warmer interface {
+	WarmUp(ctx context.Context, storage Storage) error
+}
+
+// warmup tracks one handler's WarmUp call.
+type warmup struct {
+	done chan struct{}
+	err  error
+	// run calls WarmUp and closes done; once makes it run a single time.
+	run  func(ctx context.Context)
+	once sync.Once
+}
+
+func (wu *warmup) start(ctx context.Context) {
+	wu.once.Do(func() { go wu.run(ctx) })
+}
+
+var (
+	warmupsMu sync.Mutex
+	warmups   = make(map[string]*warmup)
+)
+
+// startWarmUp runs handler's WarmUp in a goroutine if it is a Warmer.
+// Concepts in the DependsOn graph are left for WarmAll, which warms them
+// in dependency order.
+func startWarmUp(uri string, handler ConceptHandler, storage Storage) {
+	warmupsMu.Lock()
+	defer warmupsMu.Unlock()
+	w, ok := handler.(Warmer)
+	if !ok {
+		delete(warmups, uri)
+		return
+	}
+	wu := &warmup{done: make(chan struct{})}
+	wu.run = func(ctx context.Context) {
+		defer close(wu.done)
+		if err := w.WarmUp(ctx, storage); err != nil {
+			wu.err = fmt.Errorf("clef: warming up %s: %w", uri, err)
+		}
+	}
+	warmups[uri] = wu
+	if !inDependencyGraph(uri) {
+		wu.start(context.Background())
+	}
+}
+
+// WarmAll blocks until every registered Warmer has finished its WarmUp,
+// or ctx is done. It returns the WarmUp errors joined, or ctx's error.
+// Use it instead of polling /readiness when startup can simply wait.
+//
+// WarmAll also starts the WarmUp of the concepts in the DependsOn graph,
+// each once all its dependencies have warmed up, so independent branches
+// warm in parallel. A concept whose dependency failed to warm up is not
+// warmed and reports the failure. A cycle in the graph is an error, and
+// nothing in the graph is warmed.
+func WarmAll(ctx context.Context) error {
+	order, err := dependencyOrder()
+	if err != nil {
+		return err
+	}
+	warmupsMu.Lock()
+	pending := make([]*warmup, 0, len(warmups))
+	for _, wu := range warmups {
+		pending = append(pending, wu)
+	}
+	graphed := make(map[string]*warmup, len(order))
+	for _, uri := range order {
+		if wu, ok := warmups[uri]; ok {
+			graphed[uri] = wu
+		}
+	}
+	warmupsMu.Unlock()
+
+	for uri, wu := range graphed {
+		deps := dependenciesOf(uri)
+		go func() {
+			for _, dep := range deps {
+				d, ok := graphed[dep]
+				if !ok {
+					continue
+				}
+				select {
+				case <-d.done:
+				case <-ctx.Done():
+					return
+				}
+				if d.err != nil {
+					wu.once.Do(func() {
+						wu.err = fmt.Errorf("clef: warming up %s: dependency %s failed", uri, dep)
+						close(wu.done)
+					})
+					return
+				}
+			}
+			wu.start(ctx)
+		}()
+	}
+
+	var errs []error
+	for _, wu := range pending {
+		select {
+		case <-wu.done:
+			errs = append(errs, wu.err)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// readiness reports the concepts still warming up and those whose WarmUp
+// failed.
+func readiness() (pending []string, failed map[string]string) {
+	warmupsMu.Lock()
+	defer warmupsMu.Unlock()
+	failed = make(map[string]string)
+	for uri, wu := range warmups {
+		select {
+		case <-wu.done:
+			if wu.err != nil {
+				failed[uri] = wu.err.Error()
+			}
+		default:
+			pending = append(pending, uri)
+		}
+	}
+	sort.Strings(pending)
+	return pending, failed
+}
+
+// handleReadiness serves GET /readiness: 200 once every WarmUp has
+// succeeded, 503 while any is running or after one failed.
+func (s *server) handleReadiness(w http.ResponseWriter, r *http.Request) {
+	pending, failed := readiness()
+	ready := len(pending) == 0 && len(failed) == 0
+	status := http.StatusOK
+	if !ready {
+		status = http.StatusServiceUnavailable
+	}
+	s.writeJSONStatus(w, r, status, map[string]any{
+		"ready":   ready,
+		"pending": pending,
+		"failed":  failed,
+	})
+}
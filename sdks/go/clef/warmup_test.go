@@ -0,0 +1,70 @@
+package clef
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type warmingHandler struct {
+	echoHandler
+	delay time.Duration
+	err   error
+}
+
+func (h *warmingHandler) WarmUp(ctx context.Context, storage Storage) error {
+	time.Sleep(h.delay)
+	storage.Put("cache", "warm", map[string]any{"ok": true})
+	return h.err
+}
+
+func TestReadinessWaitsForWarmUp(t *testing.T) {
+	resetRegistry()
+	storage := NewInMemoryStorage()
+	Register("urn:test/Warm", &warmingHandler{delay: 50 * time.Millisecond}, storage)
+	h := NewHandler()
+
+	if rec := doRequest(h, "GET", "/readiness", ""); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("immediately: status = %d, want 503", rec.Code)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if rec := doRequest(h, "GET", "/readiness", ""); rec.Code != http.StatusOK {
+		t.Fatalf("after warm-up: status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if _, ok := storage.Get("cache", "warm"); !ok {
+		t.Fatal("WarmUp did not receive the registered storage")
+	}
+}
+
+func TestWarmAll(t *testing.T) {
+	resetRegistry()
+	Register("urn:test/Warm", &warmingHandler{delay: 20 * time.Millisecond}, nil)
+	Register("urn:test/Echo", &echoHandler{}, nil)
+	if err := WarmAll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if rec := doRequest(NewHandler(), "GET", "/readiness", ""); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+
+	Register("urn:test/Slow", &warmingHandler{delay: time.Second}, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := WarmAll(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WarmAll = %v, want deadline exceeded", err)
+	}
+}
+
+func TestReadinessReportsWarmUpFailure(t *testing.T) {
+	resetRegistry()
+	boom := errors.New("boom")
+	Register("urn:test/Broken", &warmingHandler{err: boom}, nil)
+	if err := WarmAll(context.Background()); !errors.Is(err, boom) {
+		t.Fatalf("WarmAll = %v, want boom", err)
+	}
+	if rec := doRequest(NewHandler(), "GET", "/readiness", ""); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", rec.Code)
+	}
+}
@@ -0,0 +1,79 @@
+package clef
+
+import "sync"
+
+// watchBuffer is the channel capacity of each watcher. Events are dropped
+// for a watcher whose buffer is full rather than blocking writers.
+const watchBuffer = 16
+
+// WatchEvent describes a mutation of a watched key.
+type WatchEvent struct {
+	Key string `json:"key"`
+	// Event is "put" or "delete".
+	Event string         `json:"event"`
+	Value map[string]any `json:"value,omitempty"`
+	// Seq numbers the mutation within its storage; later mutations have
+	// higher numbers. See WaitForChanges.
+	Seq uint64 `json:"seq"`
+}
+
+type watchKey struct {
+	relation string
+	key      string
+}
+
+type watcher struct {
+	ch chan WatchEvent
+}
+
+// Watch subscribes to changes of a single key. Every Put and Delete of the
+// key sends an event to the returned channel without blocking; events are
+// dropped if the channel is full. Call the returned function to stop
+// watching; it closes the channel and is safe to call more than once.
+//
+// Watch is specific to InMemoryStorage and not part of the Storage
+// interface.
+func (s *InMemoryStorage) Watch(relation, key string) (<-chan WatchEvent, func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w := &watcher{ch: make(chan WatchEvent, watchBuffer)}
+	wk := watchKey{relation, key}
+	if s.watchers == nil {
+		s.watchers = make(map[watchKey][]*watcher)
+	}
+	s.watchers[wk] = append(s.watchers[wk], w)
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			list := s.watchers[wk]
+			for i, other := range list {
+				if other == w {
+					s.watchers[wk] = append(list[:i:i], list[i+1:]...)
+					break
+				}
+			}
+			if len(s.watchers[wk]) == 0 {
+				delete(s.watchers, wk)
+			}
+			close(w.ch)
+		})
+	}
+	return w.ch, cancel
+}
+
+// notify numbers ev, records it for WaitForChanges and delivers it to
+// the key's watchers. Callers must hold s.mu for writing, which also
+// keeps events in mutation order.
+func (s *InMemoryStorage) notify(relation, key string, ev WatchEvent) {
+	s.record(relation, &ev)
+	for _, w := range s.watchers[watchKey{relation, key}] {
+		select {
+		case w.ch <- ev:
+		default:
+		}
+	}
+}
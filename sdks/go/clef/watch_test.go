@@ -0,0 +1,68 @@
+package clef
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWatchReceivesEventsInOrder(t *testing.T) {
+	s := NewInMemoryStorage()
+	events, cancel := s.Watch("accounts", "acct-1")
+	defer cancel()
+
+	s.Put("accounts", "acct-1", map[string]any{"balance": 10})
+	s.Put("accounts", "acct-2", map[string]any{"balance": 99}) // other key: not delivered
+	s.Put("accounts", "acct-1", map[string]any{"balance": 20})
+	s.Delete("accounts", "acct-1")
+
+	want := []struct {
+		event   string
+		balance any
+	}{{"put", 10}, {"put", 20}, {"delete", nil}}
+
+	for i, w := range want {
+		select {
+		case ev := <-events:
+			if ev.Event != w.event || ev.Key != "acct-1" {
+				t.Fatalf("event %d: expected %s acct-1, got %+v", i, w.event, ev)
+			}
+			if w.balance != nil && ev.Value["balance"] != w.balance {
+				t.Errorf("event %d: expected balance %v, got %v", i, w.balance, ev.Value["balance"])
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for event %d", i)
+		}
+	}
+
+	select {
+	case ev := <-events:
+		t.Errorf("unexpected extra event %+v", ev)
+	default:
+	}
+}
+
+func TestWatchCancelClosesChannel(t *testing.T) {
+	s := NewInMemoryStorage()
+	events, cancel := s.Watch("accounts", "acct-1")
+	cancel()
+	cancel()
+
+	if _, ok := <-events; ok {
+		t.Error("expected channel to be closed")
+	}
+	// Writes after cancel must not panic on the closed channel.
+	s.Put("accounts", "acct-1", map[string]any{"balance": 1})
+}
+
+func TestWatchDropsWhenFull(t *testing.T) {
+	s := NewInMemoryStorage()
+	events, cancel := s.Watch("counters", "c")
+	defer cancel()
+
+	for i := 0; i < watchBuffer*2; i++ {
+		s.Put("counters", "c", map[string]any{"n": i})
+	}
+	if len(events) != watchBuffer {
+		t.Errorf("expected %d buffered events, got %d", watchBuffer, len(events))
+	}
+}
@@ -0,0 +1,44 @@
+// Command clefgen generates typed Encode and Decode helpers for structs
+// annotated with //copf:input or //copf:output. For each Go file given it
+// writes the helpers to a sibling file ending in _copf.go.
+//
+// Usage, typically from a go:generate directive:
+//
+//	//go:generate go run github.com/clef/go-sdk/cmd/clefgen $GOFILE
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/clef/go-sdk/clef/codegen"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: clefgen file.go...")
+		os.Exit(2)
+	}
+	for _, path := range os.Args[1:] {
+		if err := generate(path); err != nil {
+			fmt.Fprintln(os.Stderr, "clefgen:", err)
+			os.Exit(1)
+		}
+	}
+}
+
+func generate(path string) error {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	out, err := codegen.Generate(filepath.Base(path), src)
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		return fmt.Errorf("%s: no //copf:input or //copf:output structs", path)
+	}
+	return os.WriteFile(codegen.OutputName(path), out, 0o644)
+}
@@ -0,0 +1,35 @@
+// Command copf-plugin is a template for a concept plugin. Copy it, replace
+// GreeterHandler with your own handlers and build it with
+//
+//	go build -buildmode=plugin -o greeter.so .
+//
+// The host loads it with clef.LoadPlugin or clef.WithPluginDir. Host and
+// plugin must be built with the same Go toolchain and the same version of
+// github.com/clef/go-sdk.
+package main
+
+import "github.com/clef/go-sdk/clef"
+
+// ConceptHandlers is looked up by clef.LoadPlugin. It returns the
+// plugin's handlers keyed by concept URI.
+func ConceptHandlers() map[string]clef.ConceptHandler {
+	return map[string]clef.ConceptHandler{
+		"urn:plugin/Greeter": &GreeterHandler{},
+	}
+}
+
+// GreeterHandler is an example concept with a single "greet" action.
+type GreeterHandler struct{}
+
+func (h *GreeterHandler) Handle(action string, input map[string]any, storage clef.Storage) map[string]any {
+	switch action {
+	case "greet":
+		name, _ := input["name"].(string)
+		return map[string]any{"variant": "ok", "message": "Hello, " + name}
+	default:
+		return map[string]any{"variant": "error", "message": "unknown action: " + action}
+	}
+}
+
+// main is unused; plugins are loaded, not run.
+func main() {}
@@ -0,0 +1,31 @@
+// Package counter is a minimal concept handler used to demonstrate
+// benchmarking with copftest.
+package counter
+
+import "github.com/clef/go-sdk/clef"
+
+// Handler counts calls to "increment" per key.
+type Handler struct{}
+
+func (h *Handler) Handle(action string, input map[string]any, storage clef.Storage) map[string]any {
+	switch action {
+	case "increment":
+		key, _ := input["key"].(string)
+		count := 0
+		if rec, ok := storage.Get("counts", key); ok {
+			count, _ = rec["count"].(int)
+		}
+		count++
+		storage.Put("counts", key, map[string]any{"count": count})
+		return map[string]any{"variant": "ok", "count": count}
+	case "get":
+		key, _ := input["key"].(string)
+		rec, ok := storage.Get("counts", key)
+		if !ok {
+			return map[string]any{"variant": "ok", "count": 0}
+		}
+		return map[string]any{"variant": "ok", "count": rec["count"]}
+	default:
+		return map[string]any{"variant": "error", "message": "unknown action: " + action}
+	}
+}
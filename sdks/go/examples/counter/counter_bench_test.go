@@ -0,0 +1,43 @@
+package counter
+
+import (
+	"os"
+	"testing"
+
+	"github.com/clef/go-sdk/clef/copftest"
+)
+
+// Run with:
+//
+//	go test -bench . -benchmem ./examples/counter
+
+func BenchmarkIncrement(b *testing.B) {
+	copftest.BenchmarkHandler(b, &Handler{}, "increment", map[string]any{"key": "page-views"})
+}
+
+func BenchmarkIncrementParallel(b *testing.B) {
+	copftest.BenchmarkHandlerParallel(b, &Handler{}, "increment", map[string]any{"key": "page-views"})
+}
+
+func BenchmarkGet(b *testing.B) {
+	copftest.BenchmarkHandler(b, &Handler{}, "get", map[string]any{"key": "page-views"})
+}
+
+// Set COUNTER_PROFILE_DIR to save pprof profiles for inspection with
+// `go tool pprof`.
+func TestProfileIncrement(t *testing.T) {
+	dir := os.Getenv("COUNTER_PROFILE_DIR")
+	if dir == "" {
+		t.Skip("COUNTER_PROFILE_DIR not set")
+	}
+	cpu, mem, err := copftest.ProfileHandler(&Handler{}, "increment", map[string]any{"key": "page-views"}, 100000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir+"/cpu.pprof", cpu, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir+"/mem.pprof", mem, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
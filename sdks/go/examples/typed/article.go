@@ -0,0 +1,55 @@
+// Package typed shows handlers using structs for input and output, with
+// helpers generated by clefgen.
+package typed
+
+//go:generate go run github.com/clef/go-sdk/cmd/clefgen article.go
+
+import "github.com/clef/go-sdk/clef"
+
+// CreateArticleInput is the input of the "create" action.
+//
+//copf:input
+type CreateArticleInput struct {
+	Title       string   `json:"title"`
+	Description string   `json:"description,omitempty"`
+	TagList     []string `json:"tagList,omitempty"`
+	Draft       *bool    `json:"draft,omitempty"`
+}
+
+// ArticleOutput is the output of the "create" action.
+//
+//copf:output
+type ArticleOutput struct {
+	Variant string `json:"variant"`
+	Slug    string `json:"slug"`
+	Title   string `json:"title"`
+}
+
+// Handler creates articles from typed input.
+type Handler struct{}
+
+func (h *Handler) Handle(action string, input map[string]any, storage clef.Storage) map[string]any {
+	if action != "create" {
+		return map[string]any{"variant": "error", "message": "unknown action: " + action}
+	}
+	in, err := DecodeCreateArticleInput(input)
+	if err != nil {
+		return map[string]any{"variant": "error", "code": "validation_failed", "message": err.Error()}
+	}
+	out := ArticleOutput{Variant: "ok", Slug: slugify(in.Title), Title: in.Title}
+	storage.Put("articles", out.Slug, EncodeArticleOutput(out))
+	return EncodeArticleOutput(out)
+}
+
+func slugify(title string) string {
+	b := []byte(title)
+	for i, c := range b {
+		switch {
+		case c >= 'A' && c <= 'Z':
+			b[i] = c + 'a' - 'A'
+		case c == ' ':
+			b[i] = '-'
+		}
+	}
+	return string(b)
+}
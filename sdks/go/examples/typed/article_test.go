@@ -0,0 +1,31 @@
+package typed
+
+import (
+	"testing"
+
+	"github.com/clef/go-sdk/clef"
+)
+
+func TestCreateRoundTrip(t *testing.T) {
+	draft := true
+	in := CreateArticleInput{Title: "Hello World", TagList: []string{"go"}, Draft: &draft}
+	input := EncodeCreateArticleInput(in)
+	input["unknown"] = "ignored"
+
+	storage := clef.NewInMemoryStorage()
+	out, err := DecodeArticleOutput((&Handler{}).Handle("create", input, storage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Variant != "ok" || out.Slug != "hello-world" || out.Title != "Hello World" {
+		t.Fatalf("output = %+v", out)
+	}
+
+	back, err := DecodeCreateArticleInput(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back.Description != "" || len(back.TagList) != 1 || back.Draft == nil || !*back.Draft {
+		t.Fatalf("input round trip = %+v", back)
+	}
+}